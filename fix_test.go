@@ -231,6 +231,55 @@ func TestRewriteAuthorPreservesCommitCount(t *testing.T) {
 	}
 }
 
+func TestRewriteAuthorLimitedToRefs(t *testing.T) {
+	tmpDir := setupTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	mainBranch := strings.TrimSpace(runGit(t, tmpDir, "rev-parse", "--abbrev-ref", "HEAD"))
+	runGit(t, tmpDir, "checkout", "-q", "-b", "feature")
+
+	err := cmd.RewriteAuthorWithOptions(tmpDir, "johndoe@gmail.com", "John Doe", "john@example.com",
+		cmd.RewriteOptions{Refs: []string{"feature"}})
+	if err != nil {
+		t.Fatalf("RewriteAuthorWithOptions failed: %v", err)
+	}
+
+	if count := countCommitsByEmail(t, tmpDir, "johndoe@gmail.com"); count != 0 {
+		t.Errorf("Expected 0 commits from johndoe@gmail.com on feature, got %d", count)
+	}
+
+	// The protected branch must keep its original history
+	out := runGit(t, tmpDir, "log", "--format=%ae", mainBranch)
+	if !strings.Contains(out, "johndoe@gmail.com") {
+		t.Errorf("Expected %s to be untouched, got emails:\n%s", mainBranch, out)
+	}
+}
+
+func TestRewriteAuthorHostileName(t *testing.T) {
+	tmpDir := setupTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	names := []string{
+		`Seán O'Brien`,
+		`John "JD" Doe`,
+		`$(touch pwned) ` + "`touch pwned`" + ` $HOME`,
+		`back\slash; exit 1`,
+	}
+	for _, name := range names {
+		if err := rewriteAuthor(tmpDir, "john@example.com", name, "john@example.com"); err != nil {
+			t.Fatalf("RewriteAuthor(%q) failed: %v", name, err)
+		}
+		out := strings.TrimSpace(runGit(t, tmpDir, "log", "-1", "--author=john@example.com", "--format=%an"))
+		if out != name {
+			t.Errorf("Expected author name %q, got %q", name, out)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "pwned")); err == nil {
+		t.Errorf("Name was executed by the shell")
+	}
+}
+
 // rewriteAuthor wraps cmd.RewriteAuthor for testing
 func rewriteAuthor(repoPath, oldEmail, newName, newEmail string) error {
 	return cmd.RewriteAuthor(repoPath, oldEmail, newName, newEmail)
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/platform"
+)
+
+// fetchPlatformAccount returns the account token authenticates as on p. An
+// account fetched within config.AccountRefresh is reused, and an older one
+// stands in when the platform is offline or rate limited.
+func fetchPlatformAccount(p identity.Platform, token string) (*platform.Account, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := string(p) + ":" + hex.EncodeToString(sum[:8])
+	cache, err := config.LoadAccountCache()
+	if err != nil {
+		cache = &config.AccountCache{Accounts: make(map[string]config.CachedAccount)}
+	}
+	cached, ok := cache.Accounts[key]
+	if ok && time.Since(cached.Fetched) < config.AccountRefresh {
+		return cachedAccount(cached), nil
+	}
+
+	acct, err := fetchAccount(p, token)
+	if err != nil {
+		var limited *platform.RateLimitError
+		if ok && (errors.Is(err, platform.ErrOffline) || errors.As(err, &limited)) {
+			return cachedAccount(cached), nil
+		}
+		return nil, err
+	}
+	cached = config.CachedAccount{Platform: acct.Platform, Login: acct.Login, Fetched: time.Now()}
+	for _, e := range acct.Emails {
+		cached.Emails = append(cached.Emails, config.CachedEmail{Address: e.Address, Verified: e.Verified})
+	}
+	cache.Accounts[key] = cached
+	if !ReadOnly { // a cache, not a change worth reporting
+		cache.Save()
+	}
+	return acct, nil
+}
+
+func cachedAccount(cached config.CachedAccount) *platform.Account {
+	acct := &platform.Account{Platform: cached.Platform, Login: cached.Login}
+	for _, e := range cached.Emails {
+		acct.Emails = append(acct.Emails, platform.Email{Address: e.Address, Verified: e.Verified})
+	}
+	return acct
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/platform"
+)
+
+func TestPlatformAccountsDegradeOffline(t *testing.T) {
+	newSwitchRepo(t)
+	calls := 0
+	fetchAccount = func(identity.Platform, string) (*platform.Account, error) {
+		calls++
+		if calls > 1 {
+			return nil, fmt.Errorf("api.github.com: %w", platform.ErrOffline)
+		}
+		return &platform.Account{Platform: identity.PlatformGitHub, Login: "me"}, nil
+	}
+	t.Cleanup(func() { fetchAccount = platform.FetchAccount })
+
+	for range 2 {
+		if acct, err := fetchPlatformAccount(identity.PlatformGitHub, "token"); err != nil || acct.Login != "me" {
+			t.Fatalf("fetchPlatformAccount = %v, %v", acct, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected the account fetched once and then cached, got %d fetches", calls)
+	}
+	cache, _ := config.LoadAccountCache()
+	for key, acct := range cache.Accounts {
+		acct.Fetched = time.Now().Add(-2 * config.AccountRefresh)
+		cache.Accounts[key] = acct
+	}
+	cache.Save()
+	if acct, err := fetchPlatformAccount(identity.PlatformGitHub, "token"); err != nil || acct.Login != "me" {
+		t.Fatalf("expected the stale account offline, got %v, %v", acct, err)
+	}
+
+	cfg, _ := config.Load()
+	cfg.Identities[1].SSHHost = "github-personal"
+	cfg.Save()
+	sshAccount = func(host string) (string, error) { return "", fmt.Errorf("%s: %w", host, platform.ErrOffline) }
+	t.Cleanup(func() { sshAccount = platform.SSHAccount })
+	var out bytes.Buffer
+	if err := Test(&out, []string{"me@example.com"}); err != nil || !strings.Contains(out.String(), "skipped: offline") {
+		t.Fatalf("Test = %v, want the ssh check skipped: %q", err, out.String())
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"regexp"
@@ -13,7 +14,7 @@ import (
 
 var sshRemoteRe = regexp.MustCompile(`^git@([^:]+):(.+)$`)
 
-func switchSSHRemotes(cwd, alias string) error {
+func switchSSHRemotes(w io.Writer, cwd, alias string) error {
 	cmd := exec.Command("git", "remote", "-v")
 	cmd.Dir = cwd
 	out, err := cmd.Output()
@@ -59,44 +60,47 @@ func switchSSHRemotes(cwd, alias string) error {
 		}
 
 		newURL := "git@" + newHost + ":" + path
+		if readOnlySkip("set remote %s to %s", remoteName, newURL) {
+			continue
+		}
 		setCmd := exec.Command("git", "remote", "set-url", remoteName, newURL)
 		setCmd.Dir = cwd
 		if err := setCmd.Run(); err != nil {
 			return fmt.Errorf("failed to update remote %s: %w", remoteName, err)
 		}
-		fmt.Printf("  Remote %s → %s\n", remoteName, newURL)
+		fmt.Fprintf(w, "  Remote %s → %s\n", remoteName, newURL)
 	}
 	return nil
 }
 
 // Use resolves an alias and switches identity + SSH remote
-func Use() {
-	if len(os.Args) < 3 {
-		fmt.Fprintf(os.Stderr, "Usage: gitme use <alias>\n")
-		os.Exit(1)
+func Use(w io.Writer, args []string) error {
+	allowOutside := hasFlag(args, allowOutsideFlag)
+	args = positionalArgs(args)
+	if len(args) < 1 {
+		return usageErr("gitme use <alias> [--allow-outside]")
 	}
 
-	name := os.Args[2]
+	name := args[0]
 
 	aliases, err := config.LoadAliases()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading aliases: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading aliases: %w", err)
 	}
 
 	email := aliases.ResolveAlias(name)
 	if email == name {
-		fmt.Fprintf(os.Stderr, "Alias not found: %s\n", name)
-		fmt.Fprintf(os.Stderr, "Run 'gitme alias list' to see available aliases\n")
-		os.Exit(1)
+		return fmt.Errorf("alias not found: %s (run 'gitme alias list' to see available aliases)", name)
 	}
 
-	cwd, _ := os.Getwd()
+	root, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
 
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
 	}
 
 	var found *identity.Identity
@@ -108,126 +112,120 @@ func Use() {
 	}
 
 	if found == nil {
-		fmt.Fprintf(os.Stderr, "Identity not found for email: %s\n", email)
-		os.Exit(1)
+		return fmt.Errorf("identity not found for email: %s", email)
+	}
+	if err := trustRepo(w, cfg, root, allowOutside); err != nil {
+		return err
 	}
 
-	if err := ApplyIdentity(cwd, *found); err != nil {
-		fmt.Fprintf(os.Stderr, "Error applying identity: %v\n", err)
-		os.Exit(1)
+	if err := switchIdentity(cfg, root, *found); err != nil {
+		return err
 	}
 
-	if err := switchSSHRemotes(cwd, name); err != nil {
+	if err := switchSSHRemotes(w, root, name); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: could not switch SSH remotes: %v\n", err)
 	}
 
-	cfg.SetIdentityForFolder(cwd, *found)
-	cfg.Save()
-
-	fmt.Println(SuccessStyle.Render("Switched to:"), found.Name, "<"+found.Email+">")
+	fmt.Fprintln(w, SuccessStyle.Render("Switched to:"), found.Name, "<"+found.Email+">")
+	return nil
 }
 
 // Alias handles the alias subcommand
-func Alias() {
-	if len(os.Args) < 3 {
-		aliasUsage()
-		os.Exit(1)
+func Alias(w io.Writer, args []string) error {
+	if len(args) < 1 {
+		aliasUsage(w)
+		return &ExitError{Code: 1}
 	}
 
-	switch os.Args[2] {
+	switch args[0] {
 	case "add", "set":
-		aliasAdd()
+		return aliasAdd(w, args[1:])
 	case "list", "ls":
-		aliasList()
+		return aliasList(w)
 	case "remove", "rm":
-		aliasRemove()
+		return aliasRemove(w, args[1:])
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown alias command: %s\n", os.Args[2])
-		aliasUsage()
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Unknown alias command: %s\n", args[0])
+		aliasUsage(w)
+		return &ExitError{Code: 1}
 	}
 }
 
-func aliasUsage() {
-	fmt.Println("Usage:")
-	fmt.Println("  gitme alias add <name> <email>  Add an alias for quick switching")
-	fmt.Println("  gitme alias list                List all aliases")
-	fmt.Println("  gitme alias rm <name>           Remove an alias")
-	fmt.Println()
-	fmt.Println("Example:")
-	fmt.Println("  gitme alias add work volodymyr@company.com")
-	fmt.Println("  gitme alias add personal me@gmail.com")
-	fmt.Println("  gitme use work    # Uses the alias to switch identity")
+func aliasUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w, "  gitme alias add <name> <email>  Add an alias for quick switching")
+	fmt.Fprintln(w, "  gitme alias list                List all aliases")
+	fmt.Fprintln(w, "  gitme alias rm <name>           Remove an alias")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Example:")
+	fmt.Fprintln(w, "  gitme alias add work volodymyr@company.com")
+	fmt.Fprintln(w, "  gitme alias add personal me@gmail.com")
+	fmt.Fprintln(w, "  gitme use work    # Uses the alias to switch identity")
 }
 
-func aliasAdd() {
-	if len(os.Args) < 5 {
-		fmt.Fprintf(os.Stderr, "Usage: gitme alias add <name> <email>\n")
-		os.Exit(1)
+func aliasAdd(w io.Writer, args []string) error {
+	if len(args) < 2 {
+		return usageErr("gitme alias add <name> <email>")
 	}
 
-	name := os.Args[3]
-	email := os.Args[4]
+	name := args[0]
+	email := args[1]
 
 	aliases, err := config.LoadAliases()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading aliases: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading aliases: %w", err)
 	}
 
 	aliases.SetAlias(name, email)
 
 	if err := aliases.Save(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving aliases: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("saving aliases: %w", err)
 	}
 
-	fmt.Println(SuccessStyle.Render("Added alias:"), name, "→", email)
+	fmt.Fprintln(w, SuccessStyle.Render("Added alias:"), name, "→", email)
+	return nil
 }
 
-func aliasList() {
+func aliasList(w io.Writer) error {
 	aliases, err := config.LoadAliases()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading aliases: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading aliases: %w", err)
 	}
 
 	if len(aliases.Aliases) == 0 {
-		fmt.Println("No aliases configured.")
-		fmt.Println("Add one with: gitme alias add <name> <email>")
-		return
+		fmt.Fprintln(w, "No aliases configured.")
+		fmt.Fprintln(w, "Add one with: gitme alias add <name> <email>")
+		return nil
 	}
 
-	fmt.Println(HeaderStyle.Render("Aliases:"))
-	fmt.Println()
+	fmt.Fprintln(w, HeaderStyle.Render("Aliases:"))
+	fmt.Fprintln(w)
 	for name, email := range aliases.Aliases {
-		fmt.Printf("  %s → %s\n", name, email)
+		fmt.Fprintf(w, "  %s → %s\n", name, email)
 	}
+	return nil
 }
 
-func aliasRemove() {
-	if len(os.Args) < 4 {
-		fmt.Fprintf(os.Stderr, "Usage: gitme alias rm <name>\n")
-		os.Exit(1)
+func aliasRemove(w io.Writer, args []string) error {
+	if len(args) < 1 {
+		return usageErr("gitme alias rm <name>")
 	}
 
-	name := os.Args[3]
+	name := args[0]
 
 	aliases, err := config.LoadAliases()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading aliases: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading aliases: %w", err)
 	}
 
 	if !aliases.RemoveAlias(name) {
-		fmt.Fprintf(os.Stderr, "Alias not found: %s\n", name)
-		os.Exit(1)
+		return fmt.Errorf("alias not found: %s", name)
 	}
 
 	if err := aliases.Save(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving aliases: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("saving aliases: %w", err)
 	}
 
-	fmt.Println(SuccessStyle.Render("Removed alias:"), name)
+	fmt.Fprintln(w, SuccessStyle.Render("Removed alias:"), name)
+	return nil
 }
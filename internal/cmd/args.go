@@ -0,0 +1,56 @@
+package cmd
+
+import "strings"
+
+// hasFlag reports whether any of the given flags is present in args
+func hasFlag(args []string, flags ...string) bool {
+	for _, arg := range args {
+		for _, f := range flags {
+			if arg == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// positionalArgs returns args with all --flags removed
+func positionalArgs(args []string) []string {
+	var result []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--") {
+			continue
+		}
+		result = append(result, arg)
+	}
+	return result
+}
+
+// withoutFlag returns args with a --flag and its value removed
+func withoutFlag(args []string, flag string) []string {
+	var result []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == flag:
+			i++
+		case strings.HasPrefix(args[i], flag+"="):
+		default:
+			result = append(result, args[i])
+		}
+	}
+	return result
+}
+
+// flagValue returns the value of a --flag given as "--flag value" or
+// "--flag=value"
+func flagValue(args []string, flag string) (string, bool) {
+	for i, arg := range args {
+		if value, ok := strings.CutPrefix(arg, flag+"="); ok {
+			return value, true
+		}
+		if arg == flag && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+)
+
+const auditUsage = "gitme audit emails [--resolve] [--reindex] [--strict]"
+
+// unknownEmail is an author address found in the indexed repos that is no
+// identity's and was not decided on
+type unknownEmail struct {
+	Name    string   `json:"name"`
+	Email   string   `json:"email"`
+	Commits int      `json:"commits"`
+	Repos   []string `json:"repos"`
+	Like    string   `json:"like,omitempty"` // email of the identity it resembles
+	Reason  string   `json:"reason,omitempty"`
+}
+
+// Audit lists the unknown emails in the history of the indexed repos. With
+// --resolve it asks what to do with each one, and remembers the answer.
+func Audit(w io.Writer, args []string) error {
+	positional := positionalArgs(args)
+	if len(positional) != 1 || positional[0] != "emails" {
+		return usageErr(auditUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	audit, err := config.LoadAudit()
+	if err != nil {
+		return fmt.Errorf("loading audit decisions: %w", err)
+	}
+	emails, skipped, err := findUnknownEmails(cfg, audit, args)
+	if err != nil {
+		return err
+	}
+
+	if hasFlag(args, "--resolve") {
+		if !interactive() {
+			return fmt.Errorf("--resolve asks about each email; run it in a terminal")
+		}
+		if err := resolveEmails(w, cfg, audit, emails); err != nil {
+			return err
+		}
+		return reportSkipped(w, skipped, hasFlag(args, "--strict"))
+	}
+
+	out := newRenderer(w)
+	var blocks []render.Block
+	if len(emails) == 0 {
+		blocks = append(blocks, render.Line("No unknown emails in the indexed repos."))
+	} else {
+		var list render.List
+		for _, e := range emails {
+			list = append(list, render.Item{Text: fmt.Sprintf("%s <%s>", e.Name, e.Email), Detail: unknownEmailDetail(e)})
+		}
+		blocks = append(blocks, render.Header("Unknown emails:"), list,
+			render.Note("Resolve them one by one with: gitme audit emails --resolve"))
+	}
+	if rewrites := auditRewrites(audit); len(rewrites) > 0 {
+		var list render.List
+		for _, d := range rewrites {
+			list = append(list, render.Item{Text: d.Email + " → " + d.Into, Detail: d.Repos})
+		}
+		blocks = append(blocks, render.Line(""), render.Header("Queued rewrites:"), list,
+			render.Note("Rewrite each repo with: gitme fix:rewrite <email> <identity>"))
+	}
+	if err := out.Render(emails, blocks...); err != nil {
+		return err
+	}
+	return reportSkipped(w, skipped, hasFlag(args, "--strict"))
+}
+
+// findUnknownEmails reads the authors of every indexed repo and returns those
+// that are no identity's, bot or team member, and neither ignored nor
+// decided on: first the ones that resemble an identity, then by commits
+func findUnknownEmails(cfg *config.Config, audit *config.AuditConfig, args []string) ([]unknownEmail, []repowalk.Skipped, error) {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading settings: %w", err)
+	}
+	known := make(map[string]bool)
+	for _, id := range cfg.Identities {
+		known[strings.ToLower(id.Email)] = true
+		for _, alt := range id.AltEmails {
+			known[strings.ToLower(alt)] = true
+		}
+	}
+	team := teamEmails(teamBook())
+
+	repos, skipped := indexedRepos(cfg, args)
+	type result struct {
+		repo string
+		out  []byte
+		err  error
+	}
+	results := repowalk.Map(repos, func(repo string) result {
+		out, err := repowalk.Git(repo, repowalk.GitEnv(), "log", "--format=%aN%x00%aE")
+		return result{repo, out, err}
+	})
+
+	byEmail := make(map[string]*unknownEmail)
+	for r := range results {
+		if s, ok := repowalk.Failed(r.repo, r.err); ok {
+			skipped = append(skipped, s)
+		}
+		for _, line := range strings.Split(string(r.out), "\n") {
+			name, email, ok := strings.Cut(strings.TrimSpace(line), "\x00")
+			lower := strings.ToLower(email)
+			if !ok || lower == "" || known[lower] || team[lower] != "" || identity.IsAutomated(name, lower) ||
+				slices.Contains(settings.Ignored, lower) || audit.Decided(lower) {
+				continue
+			}
+			e, ok := byEmail[lower]
+			if !ok {
+				e = &unknownEmail{Name: name, Email: email}
+				byEmail[lower] = e
+			}
+			e.Commits++
+			if !slices.Contains(e.Repos, r.repo) {
+				e.Repos = append(e.Repos, r.repo)
+			}
+		}
+	}
+
+	emails := []unknownEmail{}
+	for _, e := range byEmail {
+		if like, ok := identity.Resemble(e.Name, e.Email, cfg.Identities); ok {
+			e.Like, e.Reason = like.Identity.Email, like.Reason
+		}
+		slices.Sort(e.Repos)
+		emails = append(emails, *e)
+	}
+	slices.SortFunc(emails, func(a, b unknownEmail) int {
+		resembling := func(e unknownEmail) int {
+			if e.Like != "" {
+				return 0
+			}
+			return 1
+		}
+		return cmp.Or(resembling(a)-resembling(b), b.Commits-a.Commits, cmp.Compare(a.Email, b.Email))
+	})
+	return emails, skipped, nil
+}
+
+func unknownEmailDetail(e unknownEmail) []string {
+	detail := []string{fmt.Sprintf("%d commits in %d repos", e.Commits, len(e.Repos))}
+	if e.Like != "" {
+		detail = append(detail, WarnStyle.Render(fmt.Sprintf("Probably %s with another address (%s)", e.Like, e.Reason)))
+	}
+	return detail
+}
+
+// auditRewrites returns the decisions to rewrite an email
+func auditRewrites(audit *config.AuditConfig) []config.AuditDecision {
+	var rewrites []config.AuditDecision
+	for _, d := range audit.Decisions {
+		if d.Action == config.AuditRewrite {
+			rewrites = append(rewrites, d)
+		}
+	}
+	return rewrites
+}
+
+// resolveEmails asks what to do with each unknown email and records the
+// decision right away, so quitting halfway keeps the answers given
+func resolveEmails(w io.Writer, cfg *config.Config, audit *config.AuditConfig, emails []unknownEmail) error {
+	if len(emails) == 0 {
+		fmt.Fprintln(w, "No unknown emails in the indexed repos.")
+		return nil
+	}
+	for i, e := range emails {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%s %s <%s>\n", HeaderStyle.Render(fmt.Sprintf("[%d/%d]", i+1, len(emails))), e.Name, e.Email)
+		for _, line := range unknownEmailDetail(e) {
+			fmt.Fprintln(w, "  "+line)
+		}
+		fmt.Fprint(w, "[a]dd as identity, [m]ap to an identity, [i]gnore, [r]ewrite later, [s]kip, [q]uit: ")
+		decision := config.AuditDecision{Email: e.Email, At: time.Now()}
+		switch strings.ToLower(readLine()) {
+		case "a":
+			decision.Action = config.AuditAdd
+			cfg.AcceptCandidate(identity.Candidate{Identity: identity.Identity{
+				Name: e.Name, Email: e.Email, Source: identity.SourceHistory, Platform: identity.DetectPlatform(e.Email)}})
+			if err := cfg.Save(); err != nil {
+				return fmt.Errorf("saving config: %w", err)
+			}
+			fmt.Fprintln(w, SuccessStyle.Render("Added:"), e.Email)
+		case "m":
+			id := pickIdentity(w, cfg, e.Like)
+			if id == nil {
+				continue
+			}
+			decision.Action, decision.Into = config.AuditMap, id.Email
+			cfg.MergeCandidate(identity.Candidate{Identity: identity.Identity{Name: e.Name, Email: e.Email}}, id.Email)
+			if err := cfg.Save(); err != nil {
+				return fmt.Errorf("saving config: %w", err)
+			}
+			path, err := addMailmapEntry(*id, e.Email)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, SuccessStyle.Render("Mapped:"), e.Email, "→", id.Email, DimStyle.Render("("+path+")"))
+		case "i":
+			decision.Action = config.AuditIgnore
+			if err := Ignore(w, []string{e.Email}); err != nil {
+				return err
+			}
+		case "r":
+			id := pickIdentity(w, cfg, e.Like)
+			if id == nil {
+				continue
+			}
+			decision.Action, decision.Into, decision.Repos = config.AuditRewrite, id.Email, e.Repos
+			fmt.Fprintln(w, SuccessStyle.Render("Queued rewrite:"), e.Email, "→", id.Email)
+		case "q":
+			return nil
+		default:
+			continue
+		}
+		audit.Decide(decision)
+		if err := audit.Save(); err != nil {
+			return fmt.Errorf("saving audit decisions: %w", err)
+		}
+		if queue, err := config.LoadCandidates(); err == nil {
+			if _, ok := queue.Take(e.Email); ok {
+				queue.Save()
+			}
+		}
+	}
+	return nil
+}
+
+// pickIdentity asks for an identity by number, offering like's first; nil
+// when the answer names none
+func pickIdentity(w io.Writer, cfg *config.Config, like string) *identity.Identity {
+	if len(cfg.Identities) == 0 {
+		fmt.Fprintln(w, DimStyle.Render("No identities yet, skipped"))
+		return nil
+	}
+	def := max(0, slices.IndexFunc(cfg.Identities, func(id identity.Identity) bool { return strings.EqualFold(id.Email, like) }))
+	for i, id := range cfg.Identities {
+		fmt.Fprintf(w, "  %d. %s\n", i+1, id.String())
+	}
+	fmt.Fprintf(w, "Identity [%d]: ", def+1)
+	answer := readLine()
+	if answer == "" {
+		return &cfg.Identities[def]
+	}
+	n, err := strconv.Atoi(answer)
+	if err != nil || n < 1 || n > len(cfg.Identities) {
+		fmt.Fprintln(w, DimStyle.Render("No such identity, skipped"))
+		return nil
+	}
+	return &cfg.Identities[n-1]
+}
+
+// addMailmapEntry maps email to id in the mailmap git reads for every repo:
+// the file of the global mailmap.file, or one in gitme's config directory
+// that it points mailmap.file at. It returns the path of the mailmap.
+func addMailmapEntry(id identity.Identity, email string) (string, error) {
+	out, _ := exec.Command("git", "config", "--global", "--get", "mailmap.file").Output()
+	path := strings.TrimSpace(string(out))
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, rest)
+	}
+	if path == "" {
+		path = filepath.Join(config.Dir(), "mailmap")
+		if !readOnlySkip("set the global mailmap.file to %s", path) {
+			if out, err := exec.Command("git", "config", "--global", "mailmap.file", path).CombinedOutput(); err != nil {
+				return "", fmt.Errorf("setting mailmap.file: %w: %s", err, strings.TrimSpace(string(out)))
+			}
+		}
+	}
+	entry := fmt.Sprintf("%s <%s> <%s>\n", id.Name, id.Email, email)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	if strings.Contains(strings.ToLower(string(data)), strings.ToLower(entry)) {
+		return path, nil
+	}
+	if readOnlySkip("add %q to %s", strings.TrimSpace(entry), path) {
+		return path, nil
+	}
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		entry = "\n" + entry
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("creating %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(entry); err != nil {
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/platform"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+// fetchAccount asks a platform API which account a token belongs to
+var fetchAccount = platform.FetchAccount
+
+// authCheck is the outcome of authenticating as an identity one way
+type authCheck struct {
+	Via      string `json:"via"`
+	Account  string `json:"account,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Skipped  string `json:"skipped,omitempty"` // why it was not tried, e.g. offline
+}
+
+// ok reports whether authentication worked and landed on the expected account
+func (c authCheck) ok() bool {
+	return c.Error == "" && (c.Skipped != "" || c.Expected == "" || strings.EqualFold(c.Account, c.Expected))
+}
+
+// Test checks that an identity's ssh key and API token authenticate as its
+// platform account
+func Test(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return usageErr("gitme test <email|alias>")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	id := resolveIdentity(cfg, args[0])
+	if id == nil {
+		return fmt.Errorf("identity not found: %s", args[0])
+	}
+
+	checks := authChecks(id, true)
+	out := newRenderer(w)
+	if len(checks) == 0 && out.Format() != render.JSON {
+		fmt.Fprintf(w, "%s Nothing to test for %s\n", WarnStyle.Render("⚠"), id.Email)
+		fmt.Fprintln(w, DimStyle.Render("Set its platform, an ssh host (gitme remote prefer "+id.Email+" ssh <host>) or a token (gitme token set "+id.Email+")"))
+		return nil
+	}
+
+	var list render.List
+	failed := false
+	for _, c := range checks {
+		marker, text := out.Style(SuccessStyle, "✓"), c.Via+" authenticates as "+c.Account
+		switch {
+		case c.Skipped != "":
+			marker, text = out.Style(DimStyle, "-"), c.Via+": skipped: "+c.Skipped
+		case c.Error != "":
+			marker, text = out.Style(WarnStyle, "✗"), c.Via+": "+c.Error
+		case !c.ok():
+			marker, text = out.Style(WarnStyle, "✗"), fmt.Sprintf("%s authenticates as %s, expected %s", c.Via, c.Account, c.Expected)
+		}
+		failed = failed || !c.ok()
+		list = append(list, render.Item{Marker: marker, Text: text})
+	}
+	if checks == nil {
+		checks = []authCheck{}
+	}
+	if err := out.Render(checks, render.Header(id.String()), list); err != nil {
+		return err
+	}
+	if failed {
+		return &ExitError{Code: 1}
+	}
+	return nil
+}
+
+// authChecks authenticates as id over ssh, through its host alias, and over
+// its stored API token. The account expected is its username, else the one
+// its token belongs to. Unless defaultHost is set, ssh is only tried for
+// identities with their own host alias, as the platform's default host tests
+// whichever key ssh picks first.
+func authChecks(id *identity.Identity, defaultHost bool) []authCheck {
+	var checks []authCheck
+	expected := id.Username
+
+	if token := identityToken(id.Email); token != "" {
+		check := authCheck{Via: "API token", Expected: expected}
+		if acct, err := fetchAccount(id.Platform, token); errors.Is(err, platform.ErrOffline) {
+			check.Skipped = "offline"
+		} else if err != nil {
+			check.Error = err.Error()
+		} else {
+			check.Account = acct.Login
+			if expected == "" {
+				expected = acct.Login
+			}
+			if found, _ := acct.HasEmail(id.Email); !found {
+				check.Error = fmt.Sprintf("account %s does not own %s", acct.Login, id.Email)
+			}
+		}
+		checks = append(checks, check)
+	}
+
+	host := id.SSHHost
+	if host == "" && defaultHost {
+		host = platformHost(id.Platform)
+	}
+	if host != "" {
+		check := authCheck{Via: "ssh key for " + host, Expected: expected}
+		if account, err := sshAccount(host); errors.Is(err, platform.ErrOffline) {
+			check.Skipped = "offline"
+		} else if err != nil {
+			check.Error = err.Error()
+		} else {
+			check.Account = account
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// platformHost returns the host a platform serves git from, "" if unknown
+func platformHost(p identity.Platform) string {
+	switch p {
+	case identity.PlatformGitHub:
+		return "github.com"
+	case identity.PlatformGitLab:
+		return "gitlab.com"
+	case identity.PlatformBitbucket:
+		return "bitbucket.org"
+	}
+	return ""
+}
+
+// authFindings runs the authentication checks of identities with their own
+// ssh host alias or a stored token. Landing on another account is an error,
+// pushes would go out as someone else; failing to authenticate a warning.
+func authFindings(cfg *config.Config) []finding {
+	var findings []finding
+	for i := range cfg.Identities {
+		id := &cfg.Identities[i]
+		for _, c := range authChecks(id, false) {
+			switch {
+			case c.Skipped != "":
+				continue
+			case c.Error != "":
+				findings = append(findings, finding{Severity: severityWarning, Subject: id.String(),
+					Message: c.Via + ": " + c.Error})
+			case !c.ok():
+				findings = append(findings, finding{Severity: severityError, Subject: id.String(),
+					Message: fmt.Sprintf("%s authenticates as %s, expected %s", c.Via, c.Account, c.Expected)})
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/platform"
+)
+
+func TestTestCatchesKeyOfAnotherAccount(t *testing.T) {
+	newSwitchRepo(t)
+	cfg, _ := config.Load()
+	cfg.Identities[1].SSHHost = "github-personal"
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("saving config: %v", err)
+	}
+	var asked string
+	sshAccount = func(host string) (string, error) {
+		asked = host
+		return "work-login", nil
+	}
+	t.Cleanup(func() { sshAccount = platform.SSHAccount })
+
+	var out bytes.Buffer
+	var exitErr *ExitError
+	if err := Test(&out, []string{"me@example.com"}); !errors.As(err, &exitErr) {
+		t.Fatalf("Test = %v, want a failure: %q", err, out.String())
+	}
+	if asked != "github-personal" || !strings.Contains(out.String(), "authenticates as work-login, expected me") {
+		t.Fatalf("unexpected output for host %q: %q", asked, out.String())
+	}
+	if err := Doctor(io.Discard, nil); !errors.As(err, &exitErr) || exitErr.Code != int(severityError) {
+		t.Fatalf("Doctor = %v, want the error exit code", err)
+	}
+
+	sshAccount = func(string) (string, error) { return "me", nil }
+	out.Reset()
+	if err := Test(&out, []string{"me@example.com"}); err != nil {
+		t.Fatalf("Test = %v, want success: %q", err, out.String())
+	}
+}
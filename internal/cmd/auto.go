@@ -1,48 +1,137 @@
 package cmd
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/vosamoilenko/gitme/internal/config"
 	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/policy"
+	"github.com/vosamoilenko/gitme/internal/render"
 )
 
 // Auto detects and applies identity based on rules or path derivation
-func Auto() {
+func Auto(w io.Writer, args []string) error {
 	cwd, err := os.Getwd()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("getting current directory: %w", err)
 	}
 
-	gitDir := filepath.Join(cwd, ".git")
-	if _, err := os.Stat(gitDir); err != nil {
+	// Rules and derivation look at the repo root so that every subdirectory
+	// of a repo resolves to the same identity
+	cwd, err = RepoRoot(cwd)
+	if err != nil {
 		// Not a git repo, silently exit (for shell hook usage)
-		return
+		return nil
 	}
 
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
 	}
 
-	rules, err := config.LoadRules()
+	rules, err := loadRules(cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading rules: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading rules: %w", err)
 	}
 
 	settings, err := config.LoadSettings()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading settings: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading settings: %w", err)
+	}
+
+	if !hasFlag(args, "--quiet") {
+		_, _, err = autoRepo(w, cwd, cfg, rules, settings, hasFlag(args, allowOutsideFlag))
+		return err
+	}
+	// Shell hooks run this on every cd: one line when there is something to
+	// say, nothing otherwise
+	mismatch, switched, err := autoRepo(io.Discard, cwd, cfg, rules, settings, hasFlag(args, allowOutsideFlag))
+	switch {
+	case err != nil:
+		return err
+	case switched:
+		fmt.Fprintf(w, "%s gitme: switched to %s\n", SuccessStyle.Render("✓"), gitConfigValue(cwd, "user.email"))
+	case mismatch != nil:
+		fmt.Fprintf(w, "%s gitme: committing as %s, expected %s %s\n", WarnStyle.Render("⚠"),
+			cmp.Or(mismatch.Current, "nobody"), mismatch.Expected, DimStyle.Render("(gitme auto)"))
+	default:
+		if id, _, err := expectedIdentity(cfg, rules, cwd); err == nil && id == nil {
+			if reason := strictFallback(rules, cfg, cwd, settings.Strict); reason != "" {
+				fmt.Fprintln(w, unmatchedWarning(cwd, gitConfigValue(cwd, "user.email"), reason))
+			}
+		}
+	}
+	return nil
+}
+
+// Mismatch is a repo left on another identity than the one expected for it
+type Mismatch struct {
+	Repo     string `json:"repo"`
+	Current  string `json:"current"`
+	Expected string `json:"expected"`
+	Source   string `json:"source"`
+}
+
+// errAmbiguousPath is returned when several identities match a repo's path
+var errAmbiguousPath = errors.New("multiple identities match this path")
+
+// expectedIdentity returns the identity the repo at root should use and
+// why: its branch identity, else its .gitme file, else a rule, else the
+// default of its remote's host, else what its path suggests. It returns nil
+// without an error when nothing applies.
+func expectedIdentity(cfg *config.Config, rules *config.RulesConfig, root string) (*identity.Identity, string, error) {
+	// A branch identity applies on its branches only, so it comes first;
+	// then a .gitme file in the repo takes precedence over global rules
+	if ref, pattern, ok := cfg.BranchIdentity(root, currentBranch(root)); ok {
+		id := resolveIdentity(cfg, ref)
+		if id == nil {
+			return nil, "", fmt.Errorf("branch %s names an unknown identity: %s", pattern, ref)
+		}
+		return id, "branch: " + pattern, nil
+	}
+	if name, file := repoOverride(root); name != "" {
+		id := resolveIdentity(cfg, name)
+		if id == nil {
+			return nil, "", fmt.Errorf("%s names an unknown identity: %s", file, name)
+		}
+		return id, "override: " + file, nil
+	}
+
+	if rule := rules.FindRuleForPath(root); rule != nil {
+		if id := resolveIdentity(cfg, rule.Ref()); id != nil {
+			return id, "rule: " + rule.Pattern, nil
+		}
 	}
 
+	// Then the default of the remote's host, set on purpose, over a guess
+	if id, host := hostDefaultIdentity(cfg, root); id != nil {
+		return id, "default: " + host, nil
+	}
+
+	// Without a rule, derive it from the path (ghq-style)
+	id, source, ambiguous := deriveIdentityFromPath(root, cfg.Identities)
+	if ambiguous {
+		return nil, "", fmt.Errorf("%w (%s)", errAmbiguousPath, source)
+	}
+	return id, source, nil
+}
+
+// autoRepo checks the identity of the repo at root and applies the expected
+// one when auto_apply is on, in repos outside the workspace dirs only with
+// allowOutside. It returns the mismatch it could not fix and whether it
+// switched the repo.
+func autoRepo(w io.Writer, cwd string, cfg *config.Config, rules *config.RulesConfig, settings *config.Settings, allowOutside bool) (*Mismatch, bool, error) {
 	var currentEmail string
 	cmd := exec.Command("git", "config", "user.email")
 	cmd.Dir = cwd
@@ -50,182 +139,338 @@ func Auto() {
 		currentEmail = strings.TrimSpace(string(out))
 	}
 
-	var expectedIdentity *identity.Identity
-	var matchSource string
-
-	// 1. Check explicit rules first
-	if rule := rules.FindRuleForPath(cwd); rule != nil {
-		for _, id := range cfg.Identities {
-			if strings.EqualFold(id.Email, rule.Email) {
-				expectedIdentity = &id
-				matchSource = "rule: " + rule.Pattern
-				break
-			}
+	expectedIdentity, matchSource, err := expectedIdentity(cfg, rules, cwd)
+	if err != nil {
+		fmt.Fprintf(w, "%s %v\n", WarnStyle.Render("⚠"), err)
+		if errors.Is(err, errAmbiguousPath) {
+			fmt.Fprintln(w, DimStyle.Render("Add a rule to choose one: gitme rule add <pattern> <email>"))
 		}
+		return nil, false, nil
 	}
 
-	// 2. If no rule, try to derive from path (ghq-style)
 	if expectedIdentity == nil {
-		expectedIdentity, matchSource = deriveIdentityFromPath(cwd, cfg.Identities)
+		if reason := strictFallback(rules, cfg, cwd, settings.Strict); reason != "" {
+			fmt.Fprintln(w, unmatchedWarning(cwd, currentEmail, reason))
+			fmt.Fprintln(w, DimStyle.Render("Pick one with 'gitme set <email>' or add a rule: gitme rule add <pattern> <email>"))
+		}
+		return nil, false, warnPolicy(w, cwd, currentEmail)
 	}
 
-	if expectedIdentity == nil {
-		return
+	if strings.EqualFold(currentEmail, expectedIdentity.Email) {
+		return nil, false, warnPolicy(w, cwd, currentEmail)
 	}
 
-	if strings.EqualFold(currentEmail, expectedIdentity.Email) {
-		return // All good
+	// Never switch to an identity the repo's policy forbids
+	violations, _, err := policyViolations(cwd, expectedIdentity.Email)
+	if err != nil {
+		return nil, false, err
+	}
+	mismatch := &Mismatch{Repo: cwd, Current: currentEmail, Expected: expectedIdentity.Email, Source: matchSource}
+	if len(violations) > 0 {
+		fmt.Fprintf(w, "%s %s (%s) is not allowed by %s:\n", WarnStyle.Render("⚠"), expectedIdentity.Email, matchSource, policy.FileName)
+		for _, v := range violations {
+			fmt.Fprintf(w, "  %s\n", v)
+		}
+		return mismatch, false, nil
 	}
 
-	// Mismatch detected
+	// Mismatch detected; hooks firing in checkouts outside the workspace dirs
+	// only report it
+	if settings.AutoApply && !allowOutside && !insideWorkspace(cfg, cwd) {
+		fmt.Fprintf(w, "%s Not switching %s to %s: it is outside your workspace dirs\n", WarnStyle.Render("⚠"), cwd, expectedIdentity.Email)
+		fmt.Fprintln(w, DimStyle.Render("Run 'gitme auto "+allowOutsideFlag+"' to switch it anyway"))
+		return mismatch, false, nil
+	}
 	if settings.AutoApply {
 		if err := ApplyIdentity(cwd, *expectedIdentity); err != nil {
-			fmt.Fprintf(os.Stderr, "Error applying identity: %v\n", err)
-			os.Exit(1)
+			return mismatch, false, fmt.Errorf("applying identity: %w", err)
 		}
-		fmt.Printf("%s Auto-switched to: %s <%s> (%s)\n",
+		// cfg may be older than the config on disk, e.g. in a watch pass, so
+		// only the use is written back
+		cfg.MarkUsed(expectedIdentity.Email)
+		if err := config.RecordUses(map[string]time.Time{expectedIdentity.Email: time.Now()}); err != nil {
+			return mismatch, true, fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Fprintf(w, "%s Auto-switched to: %s <%s> (%s)\n",
 			SuccessStyle.Render("✓"),
 			expectedIdentity.Name, expectedIdentity.Email, matchSource)
 	} else {
-		fmt.Printf("%s Identity mismatch!\n", WarnStyle.Render("⚠"))
-		fmt.Printf("  Current:  %s\n", currentEmail)
-		fmt.Printf("  Expected: %s <%s>\n", expectedIdentity.Name, expectedIdentity.Email)
-		fmt.Printf("  Source:   %s\n", DimStyle.Render(matchSource))
-		fmt.Println()
-		fmt.Println(DimStyle.Render("Run 'gitme set " + expectedIdentity.Email + "' to switch"))
-		fmt.Println(DimStyle.Render("Or 'gitme config auto_apply on' to auto-switch"))
+		fmt.Fprintf(w, "%s Identity mismatch!\n", WarnStyle.Render("⚠"))
+		fmt.Fprintf(w, "  Current:  %s\n", currentEmail)
+		fmt.Fprintf(w, "  Expected: %s <%s>\n", expectedIdentity.Name, expectedIdentity.Email)
+		fmt.Fprintf(w, "  Source:   %s\n", DimStyle.Render(matchSource))
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, DimStyle.Render("Run 'gitme set "+expectedIdentity.Email+"' to switch"))
+		fmt.Fprintln(w, DimStyle.Render("Or 'gitme config auto_apply on' to auto-switch"))
+		return mismatch, false, nil
 	}
+	return nil, true, nil
 }
 
-func deriveIdentityFromPath(path string, identities []identity.Identity) (*identity.Identity, string) {
-	for _, id := range identities {
-		switch id.Platform {
-		case identity.PlatformGitHub:
-			if strings.Contains(path, "github.com") {
-				return &id, "derived: github.com in path"
-			}
-		case identity.PlatformGitLab:
-			if strings.Contains(path, "gitlab.com") {
-				return &id, "derived: gitlab.com in path"
+// strictFallback returns why the repo at root must not fall back to the
+// global identity, a strict rule over it or the strict setting for repos in
+// the workspace dirs; empty when the repo sets its own user.email or nothing
+// is strict there
+func strictFallback(rules *config.RulesConfig, cfg *config.Config, root string, strict bool) string {
+	if localConfigValue(root, "user.email") != "" {
+		return ""
+	}
+	if rule := rules.StrictRuleForPath(root); rule != nil {
+		return "strict rule: " + rule.Pattern
+	}
+	if strict && insideWorkspace(cfg, root) {
+		return "strict setting"
+	}
+	return ""
+}
+
+// unmatchedWarning is the warning about a strict repo no identity matches
+func unmatchedWarning(root, current, reason string) string {
+	return fmt.Sprintf("%s No identity matches %s; it would commit as %s (%s)",
+		WarnStyle.Render("✗"), root, cmp.Or(current, "nobody"), reason)
+}
+
+// warnPolicy reports policy violations of the identity already in effect
+func warnPolicy(w io.Writer, root, email string) error {
+	violations, _, err := policyViolations(root, email)
+	if err != nil {
+		return err
+	}
+	for _, v := range violations {
+		fmt.Fprintf(w, "%s %s (%s)\n", WarnStyle.Render("⚠"), v, policy.FileName)
+	}
+	return nil
+}
+
+// repoOverride returns the alias or email named by a .gitme file in the repo's
+// git dir or at its root, in that order, and the file it came from
+func repoOverride(root string) (name, file string) {
+	paths := []string{filepath.Join(root, ".gitme")}
+	cmd := exec.Command("git", "rev-parse", "--git-common-dir")
+	cmd.Dir = root
+	if out, err := cmd.Output(); err == nil {
+		gitDir := strings.TrimSpace(string(out))
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(root, gitDir)
+		}
+		paths = append([]string{filepath.Join(gitDir, ".gitme")}, paths...)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				return line, path
 			}
-		case identity.PlatformBitbucket:
-			if strings.Contains(path, "bitbucket.org") {
-				return &id, "derived: bitbucket.org in path"
+		}
+	}
+	return "", ""
+}
+
+// deriveIdentityFromPath picks the identity whose platform host appears in the
+// path. If several identities share that platform the match is ambiguous.
+func deriveIdentityFromPath(path string, identities []identity.Identity) (*identity.Identity, string, bool) {
+	hosts := []struct {
+		platform identity.Platform
+		host     string
+	}{
+		{identity.PlatformGitHub, "github.com"},
+		{identity.PlatformGitLab, "gitlab.com"},
+		{identity.PlatformBitbucket, "bitbucket.org"},
+	}
+
+	for _, h := range hosts {
+		if !strings.Contains(path, h.host) {
+			continue
+		}
+		var candidates []identity.Identity
+		for _, id := range identities {
+			if id.Platform == h.platform {
+				candidates = append(candidates, id)
 			}
 		}
+		source := "derived: " + h.host + " in path"
+		switch len(candidates) {
+		case 0:
+			continue
+		case 1:
+			return &candidates[0], source, false
+		default:
+			return nil, source, true
+		}
+	}
+	return nil, "", false
+}
+
+// loadRules reads the rules, moving ones written with an email over to the
+// ID of that identity
+func loadRules(cfg *config.Config) (*config.RulesConfig, error) {
+	rules, err := config.LoadRules()
+	if err != nil {
+		return nil, err
+	}
+	if rules.Migrate(cfg.Identities) {
+		rules.Save() // migrated again on the next load if this fails
 	}
-	return nil, ""
+	return rules, nil
+}
+
+// ruleTarget describes the identity a rule points at: its email, after the
+// alias when the rule names one
+func ruleTarget(cfg *config.Config, rule config.Rule) string {
+	target := rule.Ref()
+	if id := resolveIdentity(cfg, rule.Ref()); id != nil {
+		if rule.Ref() == id.ID || strings.EqualFold(rule.Ref(), id.Email) {
+			target = id.Email
+		} else {
+			target += " (" + id.Email + ")"
+		}
+	}
+	if rule.Strict {
+		target = strings.TrimSpace(target + " [strict]")
+	}
+	return target
 }
 
 // Rule manages auto-switch rules
-func Rule() {
-	if len(os.Args) < 3 {
-		fmt.Fprintf(os.Stderr, "Usage: gitme rule <add|list|rm> [args]\n")
-		os.Exit(1)
+func Rule(w io.Writer, args []string) error {
+	if len(args) < 1 {
+		return usageErr("gitme rule <add|list|rm> [args]")
 	}
 
-	subCmd := os.Args[2]
+	subCmd := args[0]
 
-	rules, err := config.LoadRules()
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	rules, err := loadRules(cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading rules: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading rules: %w", err)
 	}
 
 	switch subCmd {
 	case "add":
-		if len(os.Args) < 5 {
-			fmt.Fprintf(os.Stderr, "Usage: gitme rule add <pattern> <email>\n")
-			fmt.Fprintf(os.Stderr, "Example: gitme rule add github.com/myuser me@example.com\n")
-			os.Exit(1)
-		}
-		pattern := os.Args[3]
-		email := os.Args[4]
-
-		cfg, _ := config.Load()
-		found := false
-		for _, id := range cfg.Identities {
-			if strings.EqualFold(id.Email, email) {
-				found = true
-				break
+		positional := positionalArgs(args)
+		strict := hasFlag(args, "--strict")
+		if len(positional) == 2 && strict {
+			rules.SetStrict(positional[1], true)
+			if err := rules.Save(); err != nil {
+				return fmt.Errorf("saving rules: %w", err)
 			}
+			fmt.Fprintf(w, "%s %s is strict: repos there need an identity of their own\n", SuccessStyle.Render("✓"), positional[1])
+			return nil
+		}
+		if len(positional) < 3 {
+			return usageErr("gitme rule add <pattern> <email|alias> [--strict]\nExample: gitme rule add github.com/myuser me@example.com")
+		}
+		pattern := positional[1]
+		ref := positional[2]
+
+		// An alias is kept as typed; an email becomes the identity's ID so
+		// the rule survives the identity changing address
+		isAlias := false
+		if aliases, err := config.LoadAliases(); err == nil {
+			_, isAlias = aliases.Aliases[ref]
 		}
-		if !found {
-			fmt.Fprintf(os.Stderr, "Warning: %s is not a known identity\n", email)
+		id := resolveIdentity(cfg, ref)
+		switch {
+		case id == nil:
+			fmt.Fprintf(os.Stderr, "Warning: %s is not a known identity\n", ref)
+		case !isAlias:
+			ref = id.ID
 		}
 
-		rules.AddRule(pattern, email)
+		rules.AddRule(pattern, ref)
+		if strict {
+			rules.SetStrict(pattern, true)
+		}
 		if err := rules.Save(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error saving rules: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("saving rules: %w", err)
 		}
-		fmt.Printf("%s Added rule: %s → %s\n", SuccessStyle.Render("✓"), pattern, email)
+		fmt.Fprintf(w, "%s Added rule: %s → %s\n", SuccessStyle.Render("✓"), pattern, ruleTarget(cfg, config.Rule{Identity: ref, Strict: strict}))
 
 	case "list", "ls":
 		if len(rules.Rules) == 0 {
-			fmt.Println("No rules configured.")
-			fmt.Println(DimStyle.Render("Add one with: gitme rule add <pattern> <email>"))
-			return
+			fmt.Fprintln(w, "No rules configured.")
+			fmt.Fprintln(w, DimStyle.Render("Add one with: gitme rule add <pattern> <email>"))
+			return nil
 		}
-		fmt.Println(HeaderStyle.Render("Auto-switch rules:"))
-		fmt.Println()
+		table := render.Table{Sep: " → "}
 		for _, r := range rules.Rules {
-			fmt.Printf("  %s → %s\n", r.Pattern, r.Email)
+			table.Rows = append(table.Rows, []string{r.Pattern, ruleTarget(cfg, r)})
 		}
+		return newRenderer(w).Render(rules.Rules, render.Header("Auto-switch rules:"), table)
 
 	case "rm", "remove":
-		if len(os.Args) < 4 {
-			fmt.Fprintf(os.Stderr, "Usage: gitme rule rm <pattern>\n")
-			os.Exit(1)
+		if len(args) < 2 {
+			return usageErr("gitme rule rm <pattern>")
 		}
-		pattern := os.Args[3]
+		pattern := args[1]
 		if rules.RemoveRule(pattern) {
 			if err := rules.Save(); err != nil {
-				fmt.Fprintf(os.Stderr, "Error saving rules: %v\n", err)
-				os.Exit(1)
+				return fmt.Errorf("saving rules: %w", err)
 			}
-			fmt.Printf("%s Removed rule: %s\n", SuccessStyle.Render("✓"), pattern)
+			fmt.Fprintf(w, "%s Removed rule: %s\n", SuccessStyle.Render("✓"), pattern)
 		} else {
-			fmt.Fprintf(os.Stderr, "Rule not found: %s\n", pattern)
-			os.Exit(1)
+			return fmt.Errorf("rule not found: %s", pattern)
 		}
 
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown rule command: %s\n", subCmd)
-		fmt.Fprintf(os.Stderr, "Usage: gitme rule <add|list|rm> [args]\n")
-		os.Exit(1)
+		return usageErr("gitme rule <add|list|rm> [args]")
 	}
+	return nil
 }
 
 // Config manages settings
-func Config() {
-	if len(os.Args) < 3 {
+func Config(w io.Writer, args []string) error {
+	if len(args) < 1 {
 		settings, err := config.LoadSettings()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading settings: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("loading settings: %w", err)
 		}
-		fmt.Println(HeaderStyle.Render("Settings:"))
-		fmt.Println()
-		autoApplyStr := "off"
+		autoApplyStr, readOnlyStr, strictStr := "off", "off", "off"
 		if settings.AutoApply {
 			autoApplyStr = "on"
 		}
-		fmt.Printf("  auto_apply: %s\n", autoApplyStr)
-		return
+		if settings.ReadOnly {
+			readOnlyStr = "on"
+		}
+		if settings.Strict {
+			strictStr = "on"
+		}
+		backupLimitStr := "off"
+		if limit := settings.BackupLimit(); limit > 0 {
+			backupLimitStr = fmt.Sprintf("%d MB", limit>>20)
+		}
+		return newRenderer(w).Render(settings, render.Header("Settings:"), render.KV{
+			{"auto_apply", autoApplyStr},
+			{"protected_branches", cmp.Or(strings.Join(settings.ProtectedBranchPatterns(), ","), "none")},
+			{"timezone", cmp.Or(settings.Timezone, "commit")},
+			{"disabled_scanners", cmp.Or(strings.Join(settings.DisabledScanners, ","), "none")},
+			{"icons", cmp.Or(settings.Icons, identity.IconsText)},
+			{"reference_dirs", cmp.Or(strings.Join(settings.ReferenceDirs, ","), "none")},
+			{"read_only", readOnlyStr},
+			{"backup_limit", backupLimitStr},
+			{"team_directory", cmp.Or(settings.TeamDirectory, "none")},
+			{"strict", strictStr},
+			{"scan_exclude", cmp.Or(strings.Join(settings.ScanExclude, ","), "none")},
+		})
 	}
 
-	key := os.Args[2]
-	if len(os.Args) < 4 {
-		fmt.Fprintf(os.Stderr, "Usage: gitme config <key> <value>\n")
-		os.Exit(1)
+	key := args[0]
+	if len(args) < 2 {
+		return usageErr("gitme config <key> <value>")
 	}
-	value := os.Args[3]
+	value := args[1]
 
 	settings, err := config.LoadSettings()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading settings: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading settings: %w", err)
 	}
 
 	switch key {
@@ -236,16 +481,158 @@ func Config() {
 		case "off", "false", "0", "no":
 			settings.AutoApply = false
 		default:
-			fmt.Fprintf(os.Stderr, "Invalid value: %s (use on/off)\n", value)
-			os.Exit(1)
+			return fmt.Errorf("invalid value: %s (use on/off)", value)
+		}
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Set auto_apply = %s\n", SuccessStyle.Render("✓"), value)
+	case "protected_branches":
+		// An empty list protects nothing; nil brings the defaults back
+		settings.ProtectedBranches = []string{}
+		if value == "default" {
+			settings.ProtectedBranches = nil
+		}
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" && pattern != "none" && pattern != "default" {
+				settings.ProtectedBranches = append(settings.ProtectedBranches, pattern)
+			}
+		}
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Set protected_branches = %s\n", SuccessStyle.Render("✓"), cmp.Or(strings.Join(settings.ProtectedBranchPatterns(), ","), "none"))
+	case "disabled_scanners":
+		settings.DisabledScanners = []string{}
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || name == "none" {
+				continue
+			}
+			if !slices.Contains(identity.Phases, identity.Phase(name)) {
+				return fmt.Errorf("unknown scanner: %s (scanners: %s)", name, scannerNames())
+			}
+			settings.DisabledScanners = append(settings.DisabledScanners, name)
+		}
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Set disabled_scanners = %s\n", SuccessStyle.Render("✓"), cmp.Or(strings.Join(settings.DisabledScanners, ","), "none"))
+	case "timezone":
+		if _, err := statsLocation(value); err != nil {
+			return err
+		}
+		settings.Timezone = value
+		if strings.EqualFold(value, "commit") {
+			settings.Timezone = ""
+		}
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Set timezone = %s\n", SuccessStyle.Render("✓"), value)
+	case "reference_dirs":
+		settings.ReferenceDirs = []string{}
+		for _, dir := range strings.Split(value, ",") {
+			if dir = strings.TrimSpace(dir); dir != "" && dir != "none" {
+				settings.ReferenceDirs = append(settings.ReferenceDirs, dir)
+			}
+		}
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Set reference_dirs = %s\n", SuccessStyle.Render("✓"), cmp.Or(strings.Join(settings.ReferenceDirs, ","), "none"))
+		fmt.Fprintln(w, DimStyle.Render("Run 'gitme scan' to drop what was found there"))
+	case "scan_exclude":
+		settings.ScanExclude = []string{}
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" && pattern != "none" {
+				if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+					return fmt.Errorf("invalid pattern: %s", pattern)
+				}
+				settings.ScanExclude = append(settings.ScanExclude, pattern)
+			}
+		}
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Set scan_exclude = %s\n", SuccessStyle.Render("✓"), cmp.Or(strings.Join(settings.ScanExclude, ","), "none"))
+		fmt.Fprintln(w, DimStyle.Render("Run 'gitme scan --full' to walk again what is no longer excluded"))
+	case "icons":
+		value = strings.ToLower(value)
+		if !slices.Contains(identity.IconSets, value) {
+			return fmt.Errorf("unknown icon set: %s (sets: %s)", value, strings.Join(identity.IconSets, ", "))
+		}
+		settings.Icons = value
+		if value == identity.IconsText {
+			settings.Icons = ""
+		}
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Set icons = %s\n", SuccessStyle.Render("✓"), value)
+	case "read_only":
+		switch strings.ToLower(value) {
+		case "on", "true", "1", "yes":
+			settings.ReadOnly = true
+		case "off", "false", "0", "no":
+			settings.ReadOnly = false
+		default:
+			return fmt.Errorf("invalid value: %s (use on/off)", value)
 		}
 		if err := settings.Save(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error saving settings: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("saving settings: %w", err)
 		}
-		fmt.Printf("%s Set auto_apply = %s\n", SuccessStyle.Render("✓"), value)
+		fmt.Fprintf(w, "%s Set read_only = %s\n", SuccessStyle.Render("✓"), value)
+	case "strict":
+		switch strings.ToLower(value) {
+		case "on", "true", "1", "yes":
+			settings.Strict = true
+		case "off", "false", "0", "no":
+			settings.Strict = false
+		default:
+			return fmt.Errorf("invalid value: %s (use on/off)", value)
+		}
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Set strict = %s\n", SuccessStyle.Render("✓"), value)
+	case "backup_limit":
+		if strings.EqualFold(value, "off") {
+			settings.BackupLimitMB = -1
+		} else {
+			mb, err := strconv.Atoi(strings.TrimSuffix(strings.ToUpper(value), "MB"))
+			if err != nil || mb <= 0 {
+				return fmt.Errorf("invalid value: %s (use megabytes, e.g. 1024, or off)", value)
+			}
+			settings.BackupLimitMB = mb
+		}
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Set backup_limit = %s\n", SuccessStyle.Render("✓"), value)
+	case "team_directory":
+		settings.TeamDirectory = ""
+		if value != "none" {
+			source, err := teamSource(value)
+			if err != nil {
+				return err
+			}
+			settings.TeamDirectory = source
+		}
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Set team_directory = %s\n", SuccessStyle.Render("✓"), cmp.Or(settings.TeamDirectory, "none"))
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown setting: %s\n", key)
-		os.Exit(1)
+		return fmt.Errorf("unknown setting: %s", key)
+	}
+	return nil
+}
+
+func scannerNames() string {
+	var names []string
+	for _, phase := range identity.Phases {
+		names = append(names, string(phase))
 	}
+	return strings.Join(names, ", ")
 }
@@ -1,9 +1,19 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/vosamoilenko/gitme/internal/config"
 	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
 )
 
 func TestDeriveIdentityFromPathSingleCandidate(t *testing.T) {
@@ -35,3 +45,341 @@ func TestDeriveIdentityFromPathAmbiguous(t *testing.T) {
 		t.Fatalf("expected nil identity for ambiguous match, got %+v", got)
 	}
 }
+
+func TestDeriveIdentityFromPathSkipsHostsWithoutIdentities(t *testing.T) {
+	ids := []identity.Identity{
+		{Name: "GitLab B", Email: "b@example.com", Platform: identity.PlatformGitLab},
+	}
+
+	got, source, ambiguous := deriveIdentityFromPath("/src/github.com/acme/gitlab.com-mirror", ids)
+	if ambiguous || got == nil || got.Email != "b@example.com" || source != "derived: gitlab.com in path" {
+		t.Fatalf("expected the GitLab identity, got %+v (%q, ambiguous %v)", got, source, ambiguous)
+	}
+	if got, _, ambiguous := deriveIdentityFromPath("/src/bitbucket.org/acme/repo", ids); got != nil || ambiguous {
+		t.Fatalf("expected no identity without one on the platform, got %+v", got)
+	}
+}
+
+func TestRepoOverridePrefersGitDir(t *testing.T) {
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "init", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v (%s)", err, out)
+	}
+
+	if name, _ := repoOverride(repo); name != "" {
+		t.Fatalf("expected no override, got %q", name)
+	}
+
+	os.WriteFile(filepath.Join(repo, ".gitme"), []byte("# team identity\nwork\n"), 0644)
+	if name, _ := repoOverride(repo); name != "work" {
+		t.Fatalf("expected committed override, got %q", name)
+	}
+
+	os.WriteFile(filepath.Join(repo, ".git", ".gitme"), []byte("me@example.com\n"), 0644)
+	if name, file := repoOverride(repo); name != "me@example.com" || filepath.Base(filepath.Dir(file)) != ".git" {
+		t.Fatalf("expected .git override, got %q from %s", name, file)
+	}
+}
+
+func TestHookInstallAndUninstall(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	rc := filepath.Join(home, ".bashrc")
+	const original = "alias ll='ls -l'"
+	if err := os.WriteFile(rc, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := Hook(io.Discard, []string{"install", "bash"}); err != nil {
+			t.Fatalf("hook install failed: %v", err)
+		}
+	}
+	data, _ := os.ReadFile(rc)
+	if got := strings.Count(string(data), hookLoaders["bash"]); got != 1 || !strings.HasPrefix(string(data), original+"\n") {
+		t.Fatalf("expected the hook added once after the existing content:\n%s", data)
+	}
+
+	if err := Hook(io.Discard, []string{"uninstall", "bash"}); err != nil {
+		t.Fatalf("hook uninstall failed: %v", err)
+	}
+	if data, _ := os.ReadFile(rc); string(data) != original+"\n" {
+		t.Fatalf("expected only the hook removed, got:\n%s", data)
+	}
+}
+
+func TestGitHookInstallRunsAutoOnClone(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(home, ".gitconfig"))
+	config.SetDir(filepath.Join(home, "gitme"))
+	t.Cleanup(func() { config.SetDir("") })
+	home, _ = filepath.EvalSymlinks(home)
+
+	// A stand-in gitme on PATH records how the hook calls it
+	bin := filepath.Join(home, "bin")
+	os.MkdirAll(bin, 0755)
+	calls := filepath.Join(home, "calls")
+	os.WriteFile(filepath.Join(bin, "gitme"), []byte("#!/bin/sh\necho \"$PWD $*\" >> "+calls+"\n"), 0755)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if err := Hook(io.Discard, []string{"git-install"}); err != nil {
+		t.Fatalf("hook git-install failed: %v", err)
+	}
+	src, dst := filepath.Join(home, "src"), filepath.Join(home, "dst")
+	for _, args := range [][]string{
+		{"init", "-q", src},
+		{"-C", src, "-c", "user.name=A", "-c", "user.email=a@example.com", "commit", "-q", "--allow-empty", "-m", "first"},
+		{"clone", "-q", src, dst},
+		{"-C", dst, "checkout", "-q", "-b", "topic"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v (%s)", args, err, out)
+		}
+	}
+	data, _ := os.ReadFile(calls)
+	if got := strings.TrimSpace(string(data)); got != dst+" auto --quiet" {
+		t.Fatalf("expected gitme auto to run once, in the clone, got %q", got)
+	}
+
+	if err := Hook(io.Discard, []string{"git-uninstall"}); err != nil {
+		t.Fatalf("hook git-uninstall failed: %v", err)
+	}
+	if dir := gitTemplateDir(); dir != "" {
+		t.Fatalf("expected init.templateDir unset, got %s", dir)
+	}
+}
+
+func TestGuardBlocksCommitsWithTheWrongIdentity(t *testing.T) {
+	repo := newSwitchRepo(t)
+	var out bytes.Buffer
+	if err := Set(&out, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if err := Guard(&out, []string{"install"}); err != nil {
+		t.Fatalf("guard install failed: %v", err)
+	}
+	hook, err := os.ReadFile(filepath.Join(repo, ".git", "hooks", "pre-commit"))
+	if err != nil || !strings.Contains(string(hook), "gitme verify") {
+		t.Fatalf("expected a pre-commit hook running gitme verify, got %q (%v)", hook, err)
+	}
+
+	out.Reset()
+	if err := Verify(&out, nil); err != nil || out.Len() > 0 {
+		t.Fatalf("expected the mapped identity to pass quietly: %v\n%s", err, out.String())
+	}
+	mustGit(t, repo, "config", "user.email", "me@example.com")
+	var exitErr *ExitError
+	if err := Verify(&out, nil); !errors.As(err, &exitErr) || exitErr.Code != 1 || !strings.HasPrefix(out.String(), "mismatch: ") {
+		t.Fatalf("expected the wrong identity to fail as a mismatch, got %v:\n%s", err, out.String())
+	}
+
+	// With husky, the check goes in the script husky runs
+	if err := Guard(&out, []string{"uninstall"}); err != nil {
+		t.Fatalf("guard uninstall failed: %v", err)
+	}
+	mustGit(t, repo, "config", "core.hooksPath", ".husky/_")
+	script := filepath.Join(repo, ".husky", "pre-commit")
+	if err := os.MkdirAll(filepath.Dir(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("npm test\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Guard(&out, []string{"install"}); err != nil {
+		t.Fatalf("guard install with husky failed: %v", err)
+	}
+	if data, _ := os.ReadFile(script); !strings.HasPrefix(string(data), "npm test\n") || !strings.Contains(string(data), "gitme verify") {
+		t.Fatalf("expected gitme verify appended to the husky script, got %q", data)
+	}
+	if err := Guard(&out, []string{"uninstall"}); err != nil {
+		t.Fatalf("guard uninstall with husky failed: %v", err)
+	}
+	if data, _ := os.ReadFile(script); string(data) != "npm test\n" {
+		t.Fatalf("expected the husky script restored, got %q", data)
+	}
+}
+
+func TestGuardBlocksCommitsAgainstThePolicy(t *testing.T) {
+	repo := newSwitchRepo(t)
+	if err := Set(&bytes.Buffer{}, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, ".gitme.yml"), []byte("allowed_domains: [corp.com]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	var exitErr *ExitError
+	if err := Verify(&out, nil); !errors.As(err, &exitErr) || exitErr.Code != 6 || !strings.HasPrefix(out.String(), "policy: ") {
+		t.Fatalf("expected the disallowed domain to fail as policy, got %v:\n%s", err, out.String())
+	}
+	if !strings.Contains(guardCheck, "1|2|5|6)") {
+		t.Errorf("expected the guard to block on policy: %s", guardCheck)
+	}
+}
+
+func TestPolicySigningAcceptsGitBooleans(t *testing.T) {
+	repo := newSwitchRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, ".gitme.yml"), []byte("require_signing: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for value, signed := range map[string]bool{"true": true, "yes": true, "on": true, "1": true, "false": false, "0": false} {
+		mustGit(t, repo, "config", "commit.gpgsign", value)
+		violations, _, err := policyViolations(repo, "me@corp.com")
+		if err != nil {
+			t.Fatalf("policyViolations failed: %v", err)
+		}
+		if got := len(violations) == 0; got != signed {
+			t.Errorf("commit.gpgsign = %s: violations %v, want signing seen as %v", value, violations, signed)
+		}
+	}
+}
+
+func TestVerifyReasonsAndExitCodes(t *testing.T) {
+	repo := newSwitchRepo(t)
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	mustGit(t, repo, "config", "--local", "--unset", "user.email")
+	t.Chdir(t.TempDir())
+	OutputFormat = render.JSON
+	t.Cleanup(func() { OutputFormat = render.Styled })
+
+	for _, tc := range []struct {
+		path   string
+		reason string
+		code   int
+	}{
+		{repo, "unset", 2},
+		{".", "not-a-repo", 4},
+	} {
+		var out bytes.Buffer
+		var exitErr *ExitError
+		if err := Verify(&out, []string{tc.path}); !errors.As(err, &exitErr) || exitErr.Code != tc.code {
+			t.Fatalf("verify %s: expected exit status %d, got %v", tc.path, tc.code, err)
+		}
+		var report verifyReport
+		if err := json.Unmarshal(out.Bytes(), &report); err != nil || report.Reason != tc.reason || report.OK {
+			t.Fatalf("verify %s: expected reason %s, got %+v (%v)", tc.path, tc.reason, report, err)
+		}
+	}
+}
+
+func TestHostDefaultAppliesWhenNoRuleDoes(t *testing.T) {
+	repo := newSwitchRepo(t)
+	mustGit(t, repo, "remote", "add", "origin", "git@gitlab.corp.com:team/repo.git")
+	if err := Default(io.Discard, []string{"https://GitLab.corp.com/", "me@corp.com"}); err != nil {
+		t.Fatalf("default failed: %v", err)
+	}
+
+	cfg, _ := config.Load()
+	rules, _ := loadRules(cfg)
+	id, source, err := expectedIdentity(cfg, rules, repo)
+	if err != nil || id == nil || id.Email != "me@corp.com" || source != "default: gitlab.corp.com" {
+		t.Fatalf("expected the host default, got %+v from %q (%v)", id, source, err)
+	}
+
+	rules.AddRule(repo, cfg.IdentityByRef("me@example.com").ID)
+	if id, _, _ := expectedIdentity(cfg, rules, repo); id == nil || id.Email != "me@example.com" {
+		t.Fatalf("expected the rule to win over the host default, got %+v", id)
+	}
+
+	if err := Default(io.Discard, []string{"rm", "gitlab.corp.com"}); err != nil {
+		t.Fatalf("default rm failed: %v", err)
+	}
+	if cfg, _ := config.Load(); len(cfg.HostDefaults) != 0 {
+		t.Fatalf("expected the host default removed, got %v", cfg.HostDefaults)
+	}
+}
+
+func TestStrictTreesRefuseTheGlobalIdentity(t *testing.T) {
+	repo := newSwitchRepo(t)
+	mustGit(t, repo, "config", "--global", "user.email", "me@example.com")
+	verify := func() int {
+		var exitErr *ExitError
+		if err := Verify(io.Discard, nil); errors.As(err, &exitErr) {
+			return exitErr.Code
+		} else if err != nil {
+			t.Fatalf("verify failed: %v", err)
+		}
+		return 0
+	}
+	if code := verify(); code != 0 {
+		t.Fatalf("expected the global identity accepted without strict, got exit status %d", code)
+	}
+
+	if err := Rule(io.Discard, []string{"add", "work", "--strict"}); err != nil {
+		t.Fatalf("rule add failed: %v", err)
+	}
+	if code := verify(); code != 5 {
+		t.Fatalf("expected exit status 5 under a strict rule, got %d", code)
+	}
+	var out bytes.Buffer
+	if err := Auto(&out, []string{"--quiet"}); err != nil || !strings.Contains(out.String(), "No identity matches") {
+		t.Fatalf("expected auto --quiet to warn, got %q (%v)", out.String(), err)
+	}
+
+	if err := Rule(io.Discard, []string{"rm", "work"}); err != nil {
+		t.Fatalf("rule rm failed: %v", err)
+	}
+	if err := Config(io.Discard, []string{"strict", "on"}); err != nil {
+		t.Fatalf("config failed: %v", err)
+	}
+	if code := verify(); code != 5 {
+		t.Fatalf("expected exit status 5 with the strict setting, got %d", code)
+	}
+	if err := Set(io.Discard, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if code := verify(); code != 0 {
+		t.Fatalf("expected a repo with its own identity accepted, got exit status %d", code)
+	}
+}
+
+func TestConfigProtectedBranchesCanBeEmptied(t *testing.T) {
+	newSwitchRepo(t)
+	for _, tc := range []struct{ value, want string }{
+		{"", "none"},
+		{"none", "none"},
+		{"trunk, release/*", "trunk,release/*"},
+		{"default", "main,master,release/*"},
+	} {
+		if err := Config(&bytes.Buffer{}, []string{"protected_branches", tc.value}); err != nil {
+			t.Fatalf("config protected_branches %q failed: %v", tc.value, err)
+		}
+		var out bytes.Buffer
+		if err := Config(&out, nil); err != nil {
+			t.Fatalf("config failed: %v", err)
+		}
+		if !strings.Contains(out.String(), "protected_branches") || !strings.Contains(out.String(), " "+tc.want+"\n") {
+			t.Errorf("after %q want protected_branches %s, got:\n%s", tc.value, tc.want, out.String())
+		}
+	}
+}
+
+func TestRuleFollowsIdentityWhenItsEmailChanges(t *testing.T) {
+	repo := newSwitchRepo(t)
+
+	var out bytes.Buffer
+	if err := Rule(&out, []string{"add", repo, "me@corp.com"}); err != nil {
+		t.Fatalf("rule add failed: %v", err)
+	}
+	cfg, _ := config.Load()
+	cfg.Identities[0].Email = "me@newcorp.com"
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("saving config: %v", err)
+	}
+
+	out.Reset()
+	if err := Rule(&out, []string{"list"}); err != nil {
+		t.Fatalf("rule list failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "→ me@newcorp.com") {
+		t.Fatalf("expected the rule to follow the identity, got %q", out.String())
+	}
+	rules, _ := config.LoadRules()
+	if id := resolveIdentity(cfg, rules.FindRuleForPath(repo).Ref()); id == nil || id.Email != "me@newcorp.com" {
+		t.Fatalf("rule resolves to %+v", id)
+	}
+}
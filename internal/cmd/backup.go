@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+// backup is a bundle of a repo's refs taken before a rewrite
+type backup struct {
+	Path   string
+	Pruned []string // older bundles removed to stay under the size limit
+}
+
+// backupRepo bundles every ref of the repo at root into the backups
+// directory, then removes the oldest bundles past the size limit of settings.
+// It returns nil when backups are off.
+func backupRepo(root string, settings *config.Settings) (*backup, error) {
+	limit := settings.BackupLimit()
+	if limit == 0 {
+		return nil, nil
+	}
+	dir := config.BackupsDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating backups directory: %w", err)
+	}
+	path := filepath.Join(dir, filepath.Base(root)+"-"+time.Now().Format("20060102-150405")+".bundle")
+	cmd := exec.Command("git", "bundle", "create", path, "--all")
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("git bundle: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	b := &backup{Path: path}
+	pruned, err := pruneBackups(dir, path, limit)
+	if err != nil {
+		return b, fmt.Errorf("pruning backups: %w", err)
+	}
+	b.Pruned = pruned
+	return b, nil
+}
+
+// pruneBackups removes the oldest bundles in dir until they fit in limit
+// bytes, always keeping the one at keep
+func pruneBackups(dir, keep string, limit int64) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var bundles []os.FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".bundle") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			bundles = append(bundles, info)
+		}
+	}
+	slices.SortFunc(bundles, func(a, b os.FileInfo) int {
+		return cmp.Or(b.ModTime().Compare(a.ModTime()), strings.Compare(b.Name(), a.Name()))
+	})
+
+	var total int64
+	if info, err := os.Stat(keep); err == nil {
+		total = info.Size()
+	}
+	var pruned []string
+	for _, info := range bundles {
+		path := filepath.Join(dir, info.Name())
+		if path == keep {
+			continue
+		}
+		total += info.Size()
+		if total <= limit {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, path)
+	}
+	return pruned, nil
+}
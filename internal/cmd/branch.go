@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+const branchUsage = "gitme branch <add <pattern> <email|alias>|list|rm <pattern>|apply>"
+
+// branchHookMarker identifies the post-checkout hook gitme installs
+const branchHookMarker = "# Installed by gitme"
+
+const branchHook = `#!/bin/sh
+` + branchHookMarker + `: switches user.name/email to the identity of the checked out branch
+[ "$3" = "1" ] || exit 0
+command -v gitme >/dev/null 2>&1 || exit 0
+exec gitme branch apply
+`
+
+// Branch manages per-branch identities of the current repo, which a
+// post-checkout hook applies whenever the branch changes
+func Branch(w io.Writer, args []string) error {
+	if len(args) < 1 {
+		return usageErr(branchUsage)
+	}
+	root, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			return usageErr("gitme branch add <pattern> <email|alias>")
+		}
+		pattern := args[1]
+		id := resolveIdentity(cfg, args[2])
+		if id == nil {
+			return fmt.Errorf("identity not found: %s", args[2])
+		}
+		if err := installBranchHook(w, root); err != nil {
+			return err
+		}
+		// Remember the identity in use so other branches can switch back to it
+		if repoDefaultIdentity(cfg, root) == nil {
+			if current := resolveIdentity(cfg, gitConfigValue(root, "user.email")); current != nil {
+				cfg.SetIdentityForFolder(root, *current)
+			}
+		}
+		cfg.SetBranchIdentity(root, pattern, *id)
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Fprintf(w, "%s Branches %s commit as %s\n", SuccessStyle.Render("✓"), pattern, id.String())
+		return applyBranchIdentity(w, cfg, root)
+
+	case "list", "ls":
+		patterns := cfg.BranchIdentities[root]
+		out := newRenderer(w)
+		if len(patterns) == 0 && out.Format() != render.JSON {
+			fmt.Fprintln(w, "No branch identities in this repository.")
+			fmt.Fprintln(w, DimStyle.Render("Add one with: gitme branch add <pattern> <email>"))
+			return nil
+		}
+		keys := make([]string, 0, len(patterns))
+		for pattern := range patterns {
+			keys = append(keys, pattern)
+		}
+		sort.Strings(keys)
+		var table render.Table
+		table.Sep = " → "
+		for _, pattern := range keys {
+			target := patterns[pattern]
+			if id := resolveIdentity(cfg, target); id != nil {
+				target = id.Email
+			}
+			table.Rows = append(table.Rows, []string{pattern, target})
+		}
+		return out.Render(patterns, render.Header("Branch identities:"), table)
+
+	case "rm", "remove":
+		if len(args) < 2 {
+			return usageErr("gitme branch rm <pattern>")
+		}
+		if !cfg.RemoveBranchIdentity(root, args[1]) {
+			return fmt.Errorf("no branch identity for: %s", args[1])
+		}
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Fprintf(w, "%s Removed branch identity: %s\n", SuccessStyle.Render("✓"), args[1])
+		if len(cfg.BranchIdentities[root]) == 0 {
+			if err := removeBranchHook(root); err != nil {
+				return err
+			}
+		}
+		return switchBranchIdentity(w, cfg, root)
+
+	case "apply":
+		return applyBranchIdentity(w, cfg, root)
+
+	default:
+		return usageErr(branchUsage)
+	}
+}
+
+// applyBranchIdentity switches the repo at root to the identity of its current
+// branch, or back to the repo's own identity on branches without one. Repos
+// without branch identities are left alone.
+func applyBranchIdentity(w io.Writer, cfg *config.Config, root string) error {
+	if len(cfg.BranchIdentities[root]) == 0 {
+		return nil
+	}
+	return switchBranchIdentity(w, cfg, root)
+}
+
+// switchBranchIdentity applies the identity of the current branch of root or
+// the repo's own one
+func switchBranchIdentity(w io.Writer, cfg *config.Config, root string) error {
+	branch := currentBranch(root)
+	var id *identity.Identity
+	source := "repo default"
+	if email, pattern, ok := cfg.BranchIdentity(root, branch); ok {
+		if id = resolveIdentity(cfg, email); id == nil {
+			return fmt.Errorf("branch %s names an unknown identity: %s", pattern, email)
+		}
+		source = "branch: " + pattern
+	} else {
+		id = repoDefaultIdentity(cfg, root)
+	}
+	if id == nil || strings.EqualFold(gitConfigValue(root, "user.email"), id.Email) {
+		return nil
+	}
+	if err := ApplyIdentity(root, *id); err != nil {
+		return fmt.Errorf("applying identity: %w", err)
+	}
+	if err := config.RecordUses(map[string]time.Time{id.Email: time.Now()}); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	fmt.Fprintf(w, "gitme: %s commits as %s (%s)\n", branch, id.String(), source)
+	return nil
+}
+
+// repoDefaultIdentity is the identity the repo at root uses outside branch
+// identities: its folder mapping, else its .gitme file, else a rule
+func repoDefaultIdentity(cfg *config.Config, root string) *identity.Identity {
+	if id, ok := cfg.GetIdentityForFolder(root); ok {
+		return resolveIdentity(cfg, id.Email)
+	}
+	if name, _ := repoOverride(root); name != "" {
+		return resolveIdentity(cfg, name)
+	}
+	if rules, err := loadRules(cfg); err == nil {
+		if rule := rules.FindRuleForPath(root); rule != nil {
+			return resolveIdentity(cfg, rule.Ref())
+		}
+	}
+	return nil
+}
+
+// currentBranch returns the branch checked out in dir, "" when detached
+func currentBranch(dir string) string {
+	cmd := exec.Command("git", "symbolic-ref", "--short", "-q", "HEAD")
+	cmd.Dir = dir
+	out, _ := cmd.Output()
+	return strings.TrimSpace(string(out))
+}
+
+// branchHookPath returns where git looks for the post-checkout hook of the
+// repo at root, honoring core.hooksPath
+func branchHookPath(root string) (string, error) {
+	return hookPath(root, "post-checkout")
+}
+
+// hookPath returns where git looks for the hook called name of the repo at
+// root
+func hookPath(root, name string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks/"+name)
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("finding hooks directory: %w", err)
+	}
+	path := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	return path, nil
+}
+
+// installBranchHook writes gitme's post-checkout hook, replacing the one new
+// clones get from gitme hook git-install. A hook gitme did not install is
+// left alone with a note on calling gitme from it.
+func installBranchHook(w io.Writer, root string) error {
+	path, err := branchHookPath(root)
+	if err != nil {
+		return err
+	}
+	if data, err := os.ReadFile(path); err == nil && !strings.Contains(string(data), templateHookMarker) {
+		if !strings.Contains(string(data), branchHookMarker) {
+			fmt.Fprintf(w, "%s %s already exists\n", WarnStyle.Render("⚠"), path)
+			fmt.Fprintln(w, DimStyle.Render("Run 'gitme branch apply' from it to switch identities on checkout"))
+		}
+		return nil
+	}
+	if readOnlySkip("install a post-checkout hook at %s", path) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating hooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(branchHook), 0755); err != nil {
+		return fmt.Errorf("writing post-checkout hook: %w", err)
+	}
+	return nil
+}
+
+// removeBranchHook deletes the post-checkout hook if gitme installed it
+func removeBranchHook(root string) error {
+	path, err := branchHookPath(root)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), branchHookMarker) {
+		return nil
+	}
+	if readOnlySkip("remove the post-checkout hook at %s", path) {
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("removing post-checkout hook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+func TestBranchIdentitiesFollowCheckouts(t *testing.T) {
+	repo := newSwitchRepo(t)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=A", "GIT_AUTHOR_EMAIL=a@x", "GIT_COMMITTER_NAME=A", "GIT_COMMITTER_EMAIL=a@x")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v (%s)", args, err, out)
+		}
+	}
+	git("commit", "-q", "--allow-empty", "-m", "init")
+	git("branch", "-M", "main")
+	if err := Set(&bytes.Buffer{}, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Branch(&out, []string{"add", "release/*", "me@corp.com"}); err != nil {
+		t.Fatalf("branch add failed: %v", err)
+	}
+	hook := filepath.Join(repo, ".git", "hooks", "post-checkout")
+	if data, err := os.ReadFile(hook); err != nil || !strings.Contains(string(data), "gitme branch apply") {
+		t.Fatalf("expected the post-checkout hook, got %q (%v)", data, err)
+	}
+
+	// The hook finds no gitme binary in tests, so apply as it would
+	git("checkout", "-q", "-b", "release/1.0")
+	if err := Branch(&out, []string{"apply"}); err != nil {
+		t.Fatalf("branch apply failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "user.email"); got != "me@corp.com" {
+		t.Fatalf("user.email on release/1.0 = %q, want me@corp.com", got)
+	}
+	if cfg, _ := config.Load(); cfg.IdentityByRef("me@corp.com").LastUsed.IsZero() {
+		t.Fatal("expected the branch switch to count as a use")
+	}
+	git("checkout", "-q", "main")
+	if err := Branch(&out, []string{"apply"}); err != nil {
+		t.Fatalf("branch apply failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "user.email"); got != "me@example.com" {
+		t.Fatalf("user.email on main = %q, want me@example.com", got)
+	}
+
+	if err := Branch(&out, []string{"rm", "release/*"}); err != nil {
+		t.Fatalf("branch rm failed: %v", err)
+	}
+	if _, err := os.Stat(hook); !os.IsNotExist(err) {
+		t.Fatalf("expected the hook removed with the last branch identity, got %v", err)
+	}
+}
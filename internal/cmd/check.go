@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/platform"
+	"github.com/vosamoilenko/gitme/internal/policy"
+	"github.com/vosamoilenko/gitme/internal/remoteurl"
+)
+
+// Check enforces the repository's .gitme.yml policy on its configured identity
+func Check(w io.Writer, args []string) error {
+	root, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
+
+	email := gitConfigValue(root, "user.email")
+	violations, found, err := policyViolations(root, email)
+	if err != nil {
+		return err
+	}
+	if !found {
+		fmt.Fprintln(w, DimStyle.Render("No "+policy.FileName+" in this repository"))
+		return nil
+	}
+
+	if len(violations) > 0 {
+		for _, v := range violations {
+			fmt.Fprintf(w, "%s %s\n", WarnStyle.Render("✗"), v)
+		}
+		return &ExitError{Code: 1}
+	}
+	fmt.Fprintf(w, "%s %s complies with %s\n", SuccessStyle.Render("✓"), email, policy.FileName)
+	return nil
+}
+
+// policyViolations checks email and the repo's signing setup against the
+// policy at root; found is false when the repo has no policy
+func policyViolations(root, email string) (violations []string, found bool, err error) {
+	p, err := policy.Load(root)
+	if err != nil {
+		return nil, false, fmt.Errorf("loading policy: %w", err)
+	}
+	if p == nil {
+		return nil, false, nil
+	}
+	return p.Violations(policy.Setup{
+		Email:   email,
+		Signing: gitConfigBool(root, "commit.gpgsign"),
+	}), true, nil
+}
+
+// gitConfigValue returns the effective git config value of key in dir
+func gitConfigValue(dir, key string) string {
+	cmd := exec.Command("git", "config", "--get", key)
+	cmd.Dir = dir
+	out, _ := cmd.Output()
+	return strings.TrimSpace(string(out))
+}
+
+// gitConfigBool reports whether the effective git config value of key in dir
+// is true as git reads booleans, so yes, on and 1 count too
+func gitConfigBool(dir, key string) bool {
+	cmd := exec.Command("git", "config", "--type=bool", "--get", key)
+	cmd.Dir = dir
+	out, _ := cmd.Output()
+	return strings.TrimSpace(string(out)) == "true"
+}
+
+// sshAccount asks a host which account an ssh key belongs to
+var sshAccount = platform.SSHAccount
+
+// CheckRemote compares the account a push to the remote authenticates as
+// with the platform account of the configured commit identity
+func CheckRemote(w io.Writer, args []string) error {
+	root, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
+	remote := "origin"
+	if pos := positionalArgs(args); len(pos) > 0 {
+		remote = pos[0]
+	}
+
+	rawURL := gitConfigValue(root, "remote."+remote+".pushurl")
+	if rawURL == "" {
+		rawURL = gitConfigValue(root, "remote."+remote+".url")
+	}
+	if rawURL == "" {
+		return fmt.Errorf("no remote named %s", remote)
+	}
+	u, err := remoteurl.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("remote %s: %w", remote, err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	email := gitConfigValue(root, "user.email")
+	id := resolveIdentity(cfg, email)
+	if id == nil {
+		return fmt.Errorf("commit identity %s is not known to gitme", email)
+	}
+
+	expected, err := expectedAccount(id)
+	if errors.Is(err, platform.ErrOffline) {
+		fmt.Fprintf(w, "%s Skipped: offline, cannot look up the account of %s\n", WarnStyle.Render("⚠"), id.Email)
+		return nil
+	}
+	if expected == "" {
+		fmt.Fprintf(w, "%s No platform account known for %s\n", WarnStyle.Render("⚠"), id.Email)
+		fmt.Fprintln(w, DimStyle.Render("Set it with: gitme username "+id.Email+" <login>"))
+		return nil
+	}
+
+	var account, via string
+	if u.Protocol == remoteurl.SSH {
+		via = "ssh key for " + u.Host
+		if account, err = sshAccount(u.SSHDestination()); errors.Is(err, platform.ErrOffline) {
+			fmt.Fprintf(w, "%s Skipped: offline, cannot ask %s which account the ssh key is\n", WarnStyle.Render("⚠"), u.Host)
+			return nil
+		} else if err != nil {
+			return err
+		}
+	} else {
+		via = "credential.username"
+		if account = gitConfigValue(root, "credential.username"); account == "" {
+			fmt.Fprintf(w, "%s Cannot tell which account pushes to %s over https\n", WarnStyle.Render("⚠"), u.Host)
+			fmt.Fprintln(w, DimStyle.Render("Set credential.username, or switch the remote to ssh: gitme remote prefer "+id.Email+" ssh"))
+			return nil
+		}
+	}
+
+	if !strings.EqualFold(account, expected) {
+		fmt.Fprintf(w, "%s Pushing to %s as %s (%s), but committing as %s (%s)\n",
+			WarnStyle.Render("✗"), remote, account, via, id.Email, expected)
+		return &ExitError{Code: 1}
+	}
+	fmt.Fprintf(w, "%s %s pushes as %s, matching %s\n", SuccessStyle.Render("✓"), remote, account, id.Email)
+	return nil
+}
+
+// expectedAccount returns the platform login of id: its username, or the
+// account its stored API token belongs to. The error is set only offline.
+func expectedAccount(id *identity.Identity) (string, error) {
+	if id.Username != "" {
+		return id.Username, nil
+	}
+	if token := identityToken(id.Email); token != "" {
+		acct, err := fetchPlatformAccount(id.Platform, token)
+		if errors.Is(err, platform.ErrOffline) {
+			return "", err
+		}
+		if err == nil {
+			return acct.Login, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/x/term"
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+// Stdin is where interactive prompts read answers from
+var Stdin io.Reader = os.Stdin
+
+var stdinReader *bufio.Reader
+
+// writeClipboard copies text to the system clipboard
+var writeClipboard = clipboard.WriteAll
+
+// OutputFormat is the format read commands render their results in
+var OutputFormat = render.Styled
+
+func newRenderer(w io.Writer) *render.Renderer {
+	return render.New(w, OutputFormat)
+}
+
+// UsageError is returned when a command is invoked with invalid arguments
+type UsageError struct {
+	Usage string
+}
+
+func (e *UsageError) Error() string {
+	return "usage: " + e.Usage
+}
+
+func usageErr(format string, a ...interface{}) error {
+	return &UsageError{Usage: fmt.Sprintf(format, a...)}
+}
+
+// ExitError requests a non-zero exit status; the command has already
+// reported the reason to the user
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// readLine reads one trimmed line of user input
+func readLine() string {
+	if stdinReader == nil {
+		stdinReader = bufio.NewReader(Stdin)
+	}
+	line, _ := stdinReader.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// interactive reports whether prompts can be answered: stdin is a terminal
+// or a reader standing in for one
+func interactive() bool {
+	f, ok := Stdin.(*os.File)
+	return !ok || term.IsTerminal(f.Fd())
+}
+
+// ReadOnly makes gitme describe the changes it would make to git config,
+// its own config and history instead of making them
+var ReadOnly bool
+
+// ReadOnlyLog is where read-only mode describes the changes it skips
+var ReadOnlyLog io.Writer = os.Stderr
+
+// SetReadOnly turns read-only mode on for gitme's own config files too
+func SetReadOnly() {
+	ReadOnly = true
+	config.SetReadOnly(func(action string) { readOnlySkip("%s", action) })
+}
+
+// readOnlySkip reports in read-only mode what would be done and that it
+// should be skipped
+func readOnlySkip(format string, a ...interface{}) bool {
+	if !ReadOnly {
+		return false
+	}
+	fmt.Fprintf(ReadOnlyLog, "%s would %s\n", DimStyle.Render("read-only:"), fmt.Sprintf(format, a...))
+	return true
+}
+
+// confirm asks a yes/no question, defaulting to no
+func confirm(w io.Writer, question string) bool {
+	fmt.Fprintf(w, "%s [y/N] ", question)
+	return strings.ToLower(readLine()) == "y"
+}
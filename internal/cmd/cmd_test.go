@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+func TestRuleWritesToWriter(t *testing.T) {
+	config.SetDir(t.TempDir())
+	defer config.SetDir("")
+
+	var out bytes.Buffer
+	if err := Rule(&out, []string{"add", "github.com/acme", "a@example.com"}); err != nil {
+		t.Fatalf("rule add failed: %v", err)
+	}
+
+	out.Reset()
+	if err := Rule(&out, []string{"list"}); err != nil {
+		t.Fatalf("rule list failed: %v", err)
+	}
+	want := "Auto-switch rules:\n\n  github.com/acme → a@example.com\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
+
+func TestCommandErrorsAreReturned(t *testing.T) {
+	config.SetDir(t.TempDir())
+	defer config.SetDir("")
+
+	var out bytes.Buffer
+	var usage *UsageError
+	if err := Rule(&out, nil); !errors.As(err, &usage) {
+		t.Fatalf("expected usage error, got %v", err)
+	}
+
+	if err := Rule(&out, []string{"rm", "github.com/missing"}); err == nil || errors.As(err, &usage) {
+		t.Fatalf("expected plain error for missing rule, got %v", err)
+	}
+}
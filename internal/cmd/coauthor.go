@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+const coauthorUsage = "gitme coauthor <email|alias|name>... [--copy]\n" +
+	"       gitme coauthor add <name> <email> | list | rm <email|name>"
+
+// coauthorTrailer is a Co-authored-by trailer and who it credits
+type coauthorTrailer struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Trailer string `json:"trailer"`
+}
+
+// Coauthor prints Co-authored-by trailers for collaborators, members of the
+// team directory or identities, copying them to the clipboard with --copy,
+// and manages the collaborators
+func Coauthor(w io.Writer, args []string) error {
+	positional := positionalArgs(args)
+	if len(positional) == 0 {
+		return usageErr(coauthorUsage)
+	}
+	book, err := config.LoadCoauthors()
+	if err != nil {
+		return fmt.Errorf("loading co-authors: %w", err)
+	}
+	switch positional[0] {
+	case "add":
+		return coauthorAdd(w, book, positional[1:])
+	case "list", "ls":
+		return coauthorList(w, book)
+	case "remove", "rm":
+		return coauthorRemove(w, book, positional[1:])
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	team := teamBook()
+	var trailers []coauthorTrailer
+	var lines []string
+	for _, ref := range positional {
+		co, ok := book.Find(ref)
+		if !ok {
+			co, ok = team.Find(ref)
+		}
+		if !ok {
+			id := resolveIdentity(cfg, ref)
+			if id == nil {
+				return fmt.Errorf("unknown co-author: %s (add them with: gitme coauthor add <name> <email>)", ref)
+			}
+			co = config.Coauthor{Name: id.Name, Email: id.Email}
+		}
+		t := coauthorTrailer{Name: co.Name, Email: co.Email, Trailer: fmt.Sprintf("Co-authored-by: %s <%s>", co.Name, co.Email)}
+		trailers = append(trailers, t)
+		lines = append(lines, t.Trailer)
+	}
+
+	out := newRenderer(w)
+	var blocks []render.Block
+	for _, line := range lines {
+		blocks = append(blocks, render.Line(line))
+	}
+	if hasFlag(args, "--copy") {
+		if err := writeClipboard(strings.Join(lines, "\n")); err != nil {
+			return fmt.Errorf("copying to clipboard: %w", err)
+		}
+		blocks = append(blocks, render.Note("(copied to clipboard)"))
+	}
+	return out.Render(trailers, blocks...)
+}
+
+func coauthorAdd(w io.Writer, book *config.CoauthorsConfig, args []string) error {
+	if len(args) != 2 || !strings.Contains(args[1], "@") {
+		return usageErr("gitme coauthor add <name> <email>")
+	}
+	if readOnlySkip("add co-author %s <%s>", args[0], args[1]) {
+		return nil
+	}
+	book.Add(args[0], args[1])
+	if err := book.Save(); err != nil {
+		return fmt.Errorf("saving co-authors: %w", err)
+	}
+	fmt.Fprintf(w, "%s Added co-author: %s <%s>\n", SuccessStyle.Render("✓"), args[0], args[1])
+	return nil
+}
+
+func coauthorList(w io.Writer, book *config.CoauthorsConfig) error {
+	out := newRenderer(w)
+	if len(book.Coauthors) == 0 && out.Format() != render.JSON {
+		fmt.Fprintln(w, "No co-authors yet.")
+		fmt.Fprintln(w, DimStyle.Render("Add one with: gitme coauthor add <name> <email>"))
+		return nil
+	}
+	var list render.List
+	for _, co := range book.Coauthors {
+		list = append(list, render.Item{Text: fmt.Sprintf("%s <%s>", co.Name, co.Email)})
+	}
+	return out.Render(book.Coauthors, render.Header("Co-authors:"), list)
+}
+
+func coauthorRemove(w io.Writer, book *config.CoauthorsConfig, args []string) error {
+	if len(args) != 1 {
+		return usageErr("gitme coauthor rm <email|name>")
+	}
+	if readOnlySkip("remove co-author %s", args[0]) {
+		return nil
+	}
+	if !book.Remove(args[0]) {
+		return fmt.Errorf("co-author not found: %s", args[0])
+	}
+	if err := book.Save(); err != nil {
+		return fmt.Errorf("saving co-authors: %w", err)
+	}
+	fmt.Fprintf(w, "%s Removed co-author: %s\n", SuccessStyle.Render("✓"), args[0])
+	return nil
+}
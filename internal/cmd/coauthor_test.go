@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/atotto/clipboard"
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+func TestCoauthorPrintsTrailers(t *testing.T) {
+	newSwitchRepo(t)
+	var out bytes.Buffer
+	if err := Coauthor(&out, []string{"add", "Jane Doe", "jane@example.org"}); err != nil {
+		t.Fatalf("coauthor add failed: %v", err)
+	}
+	var copied string
+	writeClipboard = func(text string) error { copied = text; return nil }
+	t.Cleanup(func() { writeClipboard = clipboard.WriteAll })
+
+	out.Reset()
+	if err := Coauthor(&out, []string{"jane", "me@corp.com", "--copy"}); err != nil {
+		t.Fatalf("coauthor failed: %v", err)
+	}
+	want := "Co-authored-by: Jane Doe <jane@example.org>\nCo-authored-by: Work <me@corp.com>"
+	if copied != want || !strings.Contains(out.String(), want) {
+		t.Fatalf("expected trailers for the collaborator and the identity, copied %q:\n%s", copied, out.String())
+	}
+	if err := Coauthor(&out, []string{"nobody"}); err == nil {
+		t.Fatal("expected an unknown co-author to fail")
+	}
+
+	if err := Coauthor(&out, []string{"rm", "jane@example.org"}); err != nil {
+		t.Fatalf("coauthor rm failed: %v", err)
+	}
+	if book, _ := config.LoadCoauthors(); len(book.Coauthors) != 0 {
+		t.Fatalf("expected no co-authors left, got %v", book.Coauthors)
+	}
+}
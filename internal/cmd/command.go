@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+// Command is a gitme subcommand: how it is invoked, the flags it takes and
+// the function that runs it
+type Command struct {
+	Name        string
+	Aliases     []string
+	Usage       string // as in the usage error, starting with "gitme"
+	Summary     string
+	Subcommands []string // words completed right after the name
+	Flags       []Flag
+	IdentityArg bool // the first argument after any subcommand names an identity
+	Run         func(w io.Writer, args []string) error
+}
+
+// Flag is a flag a command accepts
+type Flag struct {
+	Name  string // e.g. "--dry-run"
+	Short string // e.g. "-a", or ""
+	Value string // placeholder of its value, or "" when it takes none
+	Help  string
+}
+
+// Lookup returns the command called name or one of its aliases, or nil
+func Lookup(name string) *Command {
+	for _, c := range Commands {
+		if c.Name == name || slices.Contains(c.Aliases, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Execute runs the command with args, or prints its help when they hold -h
+// or --help. Positional arguments are passed first and flags after them, so
+// commands see the same arguments whatever order they were given in;
+// anything after "--" is passed last, as it is.
+func (c *Command) Execute(w io.Writer, args []string) error {
+	var positional, flags, rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = args[i:]
+			break
+		}
+		if arg == "-h" || arg == "--help" {
+			c.PrintHelp(w)
+			return nil
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			positional = append(positional, arg)
+			continue
+		}
+		name, _, hasValue := strings.Cut(arg, "=")
+		f := c.flag(name)
+		switch {
+		case f == nil:
+			return fmt.Errorf("unknown flag %s for gitme %s (see gitme %s -h)", name, c.Name, c.Name)
+		case f.Value == "" && hasValue:
+			return fmt.Errorf("flag %s takes no value", name)
+		case f.Value != "" && !hasValue:
+			if i+1 == len(args) || args[i+1] == "--" {
+				return fmt.Errorf("flag %s needs a value: %s %s", name, name, f.Value)
+			}
+			flags = append(flags, arg, args[i+1])
+			i++
+		default:
+			flags = append(flags, arg)
+		}
+	}
+	return c.Run(w, slices.Concat(positional, flags, rest))
+}
+
+// flag returns the flag of the command called name, or nil
+func (c *Command) flag(name string) *Flag {
+	for i, f := range c.Flags {
+		if f.Name == name || f.Short != "" && f.Short == name {
+			return &c.Flags[i]
+		}
+	}
+	return nil
+}
+
+// PrintHelp shows what the command does, its usage and its flags
+func (c *Command) PrintHelp(w io.Writer) {
+	fmt.Fprintln(w, c.Summary)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, HeaderStyle.Render("Usage:"))
+	for _, line := range strings.Split(c.Usage, "\n") {
+		fmt.Fprintln(w, "  "+line)
+	}
+
+	names := []string{"-h, --help"}
+	helps := []string{"Show this help"}
+	for _, f := range c.Flags {
+		name := f.Name
+		if f.Short != "" {
+			name = f.Short + ", " + name
+		}
+		if f.Value != "" {
+			name += " " + f.Value
+		}
+		names = append(names, name)
+		helps = append(helps, f.Help)
+	}
+	width := len(slices.MaxFunc(names, func(a, b string) int { return len(a) - len(b) }))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, HeaderStyle.Render("Flags:"))
+	for i, name := range names {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, name, helps[i])
+	}
+	if len(c.Aliases) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Aliases: "+strings.Join(c.Aliases, ", "))
+	}
+}
+
+// Complete returns the completions of the last of args, the word being
+// typed, given the words before it: command names, then subcommands, flags
+// and identities
+func Complete(args []string) []string {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	word := args[len(args)-1]
+	var words []string
+	if len(args) == 1 {
+		for _, c := range Commands {
+			words = append(words, c.Name)
+		}
+		return withPrefix(words, word)
+	}
+
+	c := Lookup(args[0])
+	if c == nil {
+		return nil
+	}
+	before := args[1 : len(args)-1]
+	if slices.Contains(before, "--") {
+		return nil
+	}
+	if len(before) > 0 {
+		if f := c.flag(before[len(before)-1]); f != nil && f.Value != "" {
+			return nil
+		}
+	}
+	if strings.HasPrefix(word, "-") {
+		words = append(words, "--help")
+		for _, f := range c.Flags {
+			words = append(words, f.Name)
+		}
+		return withPrefix(words, word)
+	}
+
+	var positional []string
+	for i := 0; i < len(before); i++ {
+		if f := c.flag(before[i]); f != nil {
+			if f.Value != "" {
+				i++
+			}
+			continue
+		}
+		positional = append(positional, before[i])
+	}
+	if len(c.Subcommands) > 0 {
+		if len(positional) == 0 {
+			return withPrefix(c.Subcommands, word)
+		}
+		positional = positional[1:]
+	}
+	if c.IdentityArg && len(positional) == 0 {
+		return withPrefix(identityRefs(), word)
+	}
+	return nil
+}
+
+// identityRefs returns the emails of the known identities and the aliases
+func identityRefs() []string {
+	var refs []string
+	if cfg, err := config.Load(); err == nil {
+		for _, id := range cfg.Identities {
+			refs = append(refs, id.Email)
+		}
+	}
+	if aliases, err := config.LoadAliases(); err == nil {
+		for name := range aliases.Aliases {
+			refs = append(refs, name)
+		}
+	}
+	slices.Sort(refs)
+	return refs
+}
+
+// withPrefix returns the words starting with prefix
+func withPrefix(words []string, prefix string) []string {
+	var result []string
+	for _, word := range words {
+		if strings.HasPrefix(word, prefix) {
+			result = append(result, word)
+		}
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCommandParsesFlagsAndHelp(t *testing.T) {
+	var got []string
+	c := &Command{
+		Name: "x", Usage: "gitme x <a> [--as <e>]", Summary: "Do x",
+		Flags: []Flag{{Name: "--as", Value: "<e>"}, {Name: "--all", Short: "-a"}},
+		Run:   func(w io.Writer, args []string) error { got = args; return nil },
+	}
+	if err := c.Execute(io.Discard, []string{"--as", "me", "-a", "one", "--", "--p"}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if want := []string{"one", "--as", "me", "-a", "--", "--p"}; !slices.Equal(got, want) {
+		t.Fatalf("expected positional arguments first, got %q", got)
+	}
+	for _, args := range [][]string{{"--bogus"}, {"one", "--as"}, {"--all=yes"}} {
+		if err := c.Execute(io.Discard, args); err == nil {
+			t.Fatalf("expected %q to be rejected", args)
+		}
+	}
+
+	got = nil
+	var out bytes.Buffer
+	if err := c.Execute(&out, []string{"one", "-h"}); err != nil || got != nil {
+		t.Fatalf("expected -h to print help instead of running, got %v, %q", err, got)
+	}
+	if !strings.Contains(out.String(), "gitme x <a> [--as <e>]") || !strings.Contains(out.String(), "-a, --all") {
+		t.Fatalf("unexpected help:\n%s", out.String())
+	}
+}
+
+func TestCompleteOffersCommandsFlagsAndIdentities(t *testing.T) {
+	newSwitchRepo(t)
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"fix:"}, []string{"fix:scan", "fix:rewrite"}},
+		{[]string{"rule", ""}, []string{"add", "list", "rm"}},
+		{[]string{"mixed", "--m"}, []string{"--max-commits"}},
+		{[]string{"set", "me@c"}, []string{"me@corp.com"}},
+		{[]string{"token", "set", ""}, []string{"me@corp.com", "me@example.com"}},
+		{[]string{"repos", "--group", ""}, nil},
+	} {
+		if got := Complete(tc.args); !slices.Equal(got, tc.want) {
+			t.Errorf("Complete(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+	for _, c := range Commands {
+		if Lookup(c.Name) != c || c.Run == nil || c.Usage == "" || c.Summary == "" {
+			t.Errorf("command %s is not fully registered", c.Name)
+		}
+	}
+}
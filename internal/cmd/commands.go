@@ -0,0 +1,391 @@
+package cmd
+
+// Flags several commands share
+var (
+	strictFlag       = Flag{Name: "--strict", Help: "Fail if any path could not be read"}
+	reindexFlag      = Flag{Name: "--reindex", Help: "Walk the workspace again instead of using the repo index"}
+	allowOutside     = Flag{Name: allowOutsideFlag, Help: "Also change a repo outside the workspace dirs"}
+	dryRunFlag       = Flag{Name: "--dry-run", Help: "Show what would change without changing it"}
+	includeProtected = Flag{Name: "--include-protected", Help: "Also rewrite protected branches (main, master, release/*)"}
+)
+
+// Commands are the subcommands of gitme, in the order help lists them
+var Commands = []*Command{
+	// Identity management
+	{
+		Name: "list", Aliases: []string{"ls"}, Run: List,
+		Usage:   "gitme list [--remote]",
+		Summary: "List all known identities",
+		Flags:   []Flag{{Name: "--remote", Help: "Mark identities verified/unverified on GitHub/GitLab (needs tokens)"}},
+	},
+	{
+		Name: "add", Run: Add,
+		Usage:   "gitme add [<name> <email>]",
+		Summary: "Add a new identity, interactively without a name and email",
+	},
+	{
+		Name: "import", Run: Import,
+		Usage:       importUsage,
+		Summary:     "Add identities in bulk, or convert another tool's profiles into identities and rules",
+		Subcommands: []string{"identities", "from"},
+	},
+	{
+		Name: "remove", Aliases: []string{"rm"}, Run: Remove,
+		Usage:       "gitme remove <#|email>",
+		Summary:     "Remove an identity by number or email",
+		IdentityArg: true,
+	},
+	{
+		Name: "scan", Aliases: []string{"refresh"}, Run: Scan,
+		Usage:   "gitme scan [--history] [--verbose] [--full] [--strict]",
+		Summary: "Rescan the machine for git identities and show what changed",
+		Flags: []Flag{
+			{Name: "--history", Help: "Also queue candidate identities from recent commits"},
+			{Name: "--verbose", Short: "-v", Help: "Show what each scanner found and how long it took"},
+			{Name: "--full", Help: "Rebuild the repo index from a walk of every directory"},
+			strictFlag,
+		},
+	},
+	{
+		Name: "reset", Run: Reset,
+		Usage:   "gitme reset",
+		Summary: "Delete the config and rescan from scratch",
+	},
+	{
+		Name: "username", Run: Username,
+		Usage:       "gitme username <email|alias> [username]",
+		Summary:     "Show or set the platform username of an identity (used for noreply email)",
+		IdentityArg: true,
+	},
+	{
+		Name: "color", Run: Color,
+		Usage:       "gitme color <email|alias> [<0-255|#rrggbb>|auto]",
+		Summary:     "Show or set the color an identity is shown in",
+		IdentityArg: true,
+	},
+	{
+		Name: "branch", Run: Branch,
+		Usage:       branchUsage,
+		Summary:     "Commit as another identity on matching branches (post-checkout hook)",
+		Subcommands: []string{"add", "list", "rm", "apply"},
+	},
+	{
+		Name: "forget", Run: Forget,
+		Usage:   forgetUsage,
+		Summary: "Drop identities and repos found only under a path; later scans skip it",
+		Flags:   []Flag{{Name: "--undo", Help: "Let scans include the path again"}},
+	},
+	{
+		Name: "ignore", Run: Ignore,
+		Usage:       ignoreUsage,
+		Summary:     "Remove an identity and keep scans from re-adding it",
+		Subcommands: []string{"list", "rm"},
+	},
+	{
+		Name: "review", Run: Review,
+		Usage:       "gitme review [list|accept <email>|merge <email> <into>|dismiss <email>]",
+		Summary:     "Accept, merge or dismiss candidate identities and new names (TUI without arguments)",
+		Subcommands: []string{"list", "accept", "merge", "dismiss"},
+	},
+
+	// Repository commands
+	{
+		Name: "repos", Run: Repos,
+		Usage:   "gitme repos [--pinned] [--group platform|org|identity] [--reindex] [--strict]",
+		Summary: "Show all repos and which identity they use",
+		Flags: []Flag{
+			{Name: "--pinned", Help: "Show only pinned repos"},
+			{Name: "--group", Value: "<platform|org|identity>", Help: "Group repos by platform, remote org or identity"},
+			reindexFlag,
+			strictFlag,
+		},
+	},
+	{
+		Name: "pin", Run: Pin,
+		Usage:   "gitme pin [path]",
+		Summary: "Pin a repo so it is listed first",
+	},
+	{
+		Name: "unpin", Run: Unpin,
+		Usage:   "gitme unpin [path]",
+		Summary: "Unpin a repo",
+	},
+	{
+		Name: "mixed", Run: Mixed,
+		Usage:   "gitme mixed [--max-commits <n>] [--fix [--include-protected]] [--reindex] [--strict]",
+		Summary: "Show repos with multiple identities in history",
+		Flags: []Flag{
+			{Name: "--max-commits", Value: "<n>", Help: "Only read the last n commits of each repo"},
+			{Name: "--fix", Help: "Rewrite each one to the identity it should use, via fix:rewrite"},
+			includeProtected,
+			reindexFlag,
+			strictFlag,
+		},
+	},
+	{
+		Name: "current", Aliases: []string{"whoami"}, Run: Current,
+		Usage:   "gitme current [--copy]",
+		Summary: "Show the current identity for this folder",
+		Flags:   []Flag{{Name: "--copy", Help: "Also copy it to the clipboard as Name <email>"}},
+	},
+	{
+		Name: "set", Run: Set,
+		Usage:   "gitme set <email> [--author-only] [--allow-outside]",
+		Summary: "Set the identity of this repo by email (no TUI)",
+		Flags: []Flag{
+			{Name: "--author-only", Help: "Author commits as email, committer unchanged"},
+			allowOutside,
+		},
+		IdentityArg: true,
+	},
+	{
+		Name: "clone", Run: Clone,
+		Usage:   "gitme clone <url> [dir] [--as <email|alias>]",
+		Summary: "Clone using the remote preference of the identity that applies",
+		Flags:   []Flag{{Name: "--as", Value: "<email|alias>", Help: "Clone as this identity instead of the one that applies"}},
+	},
+	{
+		Name: "remote", Run: Remote,
+		Usage: "gitme remote prefer <email|alias> [ssh [host-alias]|https|none]\n" +
+			"gitme remote fix [--dry-run]",
+		Summary:     "Show or set an identity's remote protocol, or rewrite this repo's remotes to it",
+		Subcommands: []string{"prefer", "fix"},
+		Flags:       []Flag{dryRunFlag},
+		IdentityArg: true,
+	},
+	{
+		Name: "key", Run: Key,
+		Usage:       keyUsage,
+		Summary:     "Show or set the ssh key switching to an identity sets as core.sshCommand",
+		IdentityArg: true,
+	},
+	{
+		Name: "signing", Run: Signing,
+		Usage:       signingUsage,
+		Summary:     "Show or set the key an identity's commits are signed with",
+		IdentityArg: true,
+	},
+	{
+		Name: "keys", Run: Keys,
+		Usage:       keysUsage,
+		Summary:     "Upload an identity's signing key to its GitHub/GitLab account",
+		Subcommands: []string{"push"},
+		IdentityArg: true,
+	},
+	{
+		Name: "profile", Run: Profile,
+		Usage:       profileUsage,
+		Summary:     "Show or change the extra git config switching to an identity sets",
+		IdentityArg: true,
+	},
+	{
+		Name: "insteadof", Run: InsteadOf,
+		Usage:       insteadOfUsage,
+		Summary:     "Show or change the URL rewrites switching to an identity sets as url.<base>.insteadOf",
+		IdentityArg: true,
+	},
+
+	// Fix commands
+	{
+		Name: "fix:scan", Run: FixScan,
+		Usage:   "gitme fix:scan [--list [email|alias]]",
+		Summary: "Show commits by your identities in the current repo and their platforms",
+		Flags:   []Flag{{Name: "--list", Help: "List the commits themselves, of every identity or of the one given"}},
+	},
+	{
+		Name: "fix:rewrite", Run: FixRewrite,
+		Usage:   "gitme fix:rewrite <old-email> <new-email> [--branch <name>|--range <base>..<branch>] [--since <date>] [--include-protected] [--dry-run] [-- <path>...]",
+		Summary: "Rewrite commits from the old to the new email and verify the result",
+		Flags: []Flag{
+			{Name: "--branch", Value: "<name>", Help: "Only that branch"},
+			{Name: "--range", Value: "<base>..<branch>", Help: "Only the commits of branch after base"},
+			{Name: "--since", Value: "<date>", Help: "Only commits since date, e.g. to fix unpushed work"},
+			includeProtected,
+			{Name: "--dry-run", Help: "List the commits it would rewrite, by branch"},
+		},
+	},
+	{
+		Name: "check", Run: Check,
+		Usage:   "gitme check",
+		Summary: "Check this repo's identity against its .gitme.yml policy",
+	},
+	{
+		Name: "verify", Run: Verify,
+		Usage: "gitme verify [path] | --all",
+		Summary: "Exit 0 quietly when the repo commits as the identity gitme expects, else print\n" +
+			"<reason>: <message> and exit 1 (mismatch), 2 (unset), 3 (unclear) or 4 (not-a-repo)",
+		Flags: []Flag{{Name: "--all", Help: "Check every identity's email and keys against its platform account instead"}},
+	},
+	{
+		Name: "guard", Run: Guard,
+		Usage:       guardUsage,
+		Summary:     "Block commits with the wrong identity in this repo (pre-commit hook running gitme verify)",
+		Subcommands: []string{"install", "uninstall"},
+	},
+	{
+		Name: "check-remote", Run: CheckRemote,
+		Usage:   "gitme check-remote [remote]",
+		Summary: "Check the remote pushes as the same account you commit as",
+	},
+	{
+		Name: "diff-config", Run: DiffConfig,
+		Usage:   "gitme diff-config [--exit-code]",
+		Summary: "Diff the identity config gitme would write against .git/config",
+		Flags:   []Flag{{Name: "--exit-code", Help: "Exit with 1 when they differ"}},
+	},
+	{
+		Name: "sync-gitconfig", Run: SyncGitconfig,
+		Usage:   syncGitconfigUsage,
+		Summary: "Write folder mappings and rules as includeIf sections of ~/.gitconfig",
+		Flags: []Flag{
+			dryRunFlag,
+			{Name: "--remove", Help: "Take the sections gitme wrote out again"},
+		},
+	},
+
+	// Auto-switch commands
+	{
+		Name: "auto", Run: Auto,
+		Usage:   "gitme auto [--allow-outside] [--quiet]",
+		Summary: "Detect and apply the identity for the current dir",
+		Flags: []Flag{
+			allowOutside,
+			{Name: "--quiet", Help: "One line on a switch or mismatch, else nothing (for shell hooks)"},
+		},
+	},
+	{
+		Name: "hook", Run: Hook,
+		Usage:       hookUsage,
+		Summary:     "Run gitme auto on every cd, or in every repo cloned from now on",
+		Subcommands: []string{"install", "uninstall", "git-install", "git-uninstall"},
+		Flags:       []Flag{{Name: "--print", Help: "Print the hook instead of adding it to the shell's rc file"}},
+	},
+	{
+		Name: "rule", Run: Rule,
+		Usage:       "gitme rule add <pattern> [<email|alias>] [--strict]\ngitme rule list\ngitme rule rm <pattern>",
+		Summary:     "Manage the rules auto-switch picks identities by",
+		Subcommands: []string{"add", "list", "rm"},
+		Flags:       []Flag{{Name: "--strict", Help: "Never let repos there fall back to the global identity"}},
+	},
+	{
+		Name: "default", Run: Default,
+		Usage:       defaultUsage,
+		Summary:     "Show or set the identity repos use by the host of their remote",
+		Subcommands: []string{"rm"},
+	},
+	{
+		Name: "config", Run: Config,
+		Usage:   "gitme config [<key> <value>]",
+		Summary: "Show or change settings",
+		Subcommands: []string{"auto_apply", "protected_branches", "timezone", "disabled_scanners",
+			"icons", "reference_dirs", "read_only", "backup_limit", "team_directory", "strict", "scan_exclude"},
+	},
+	{
+		Name: "watch", Run: Watch,
+		Usage:       watchUsage + "\ngitme watch <install|status|stop|uninstall>",
+		Summary:     "Keep every repo on its expected identity (runs until stopped, or as a service)",
+		Subcommands: []string{"install", "status", "stop", "uninstall"},
+		Flags: []Flag{
+			{Name: "--interval", Value: "<duration>", Help: "How often to check the repos (default 1m)"},
+			{Name: "--http", Value: "<host:port>", Help: "Serve mismatches as JSON at /status"},
+			{Name: "--digest", Value: "<terminal|notify>", Help: "Summarize each week's commits and mismatches"},
+		},
+	},
+	{
+		Name: "menubar", Run: Menubar,
+		Usage:   "gitme menubar",
+		Summary: "Print an xbar/SwiftBar plugin menu with quick-switch actions",
+	},
+
+	// Worktree management
+	{
+		Name: "tree", Run: Tree,
+		Usage: "gitme tree path [<path>]\ngitme tree cb <branch>\ngitme tree co <branch>\n" +
+			"gitme tree ls\ngitme tree rm <name|path|--all>",
+		Summary:     "Manage the worktrees of this project",
+		Subcommands: []string{"path", "cb", "co", "ls", "rm"},
+		Flags:       []Flag{{Name: "--all", Help: "With rm, remove all worktrees (keeps the main repo)"}},
+	},
+
+	// Aliases
+	{
+		Name: "alias", Run: Alias,
+		Usage:       "gitme alias add <name> <email>\ngitme alias list\ngitme alias rm <name>",
+		Summary:     "Manage aliases for quick switching",
+		Subcommands: []string{"add", "list", "rm"},
+	},
+	{
+		Name: "coauthor", Run: Coauthor,
+		Usage:       coauthorUsage,
+		Summary:     "Print Co-authored-by trailers for collaborators or identities, or manage the collaborators",
+		Subcommands: []string{"add", "list", "rm"},
+		Flags:       []Flag{{Name: "--copy", Help: "Also copy the trailers to the clipboard"}},
+	},
+	{
+		Name: "audit", Run: Audit,
+		Usage:       auditUsage,
+		Summary:     "List the unknown emails in the indexed repos, or resolve them one by one",
+		Subcommands: []string{"emails"},
+		Flags: []Flag{
+			{Name: "--resolve", Help: "Add, map, ignore or queue for rewrite each one, remembering the answer"},
+			reindexFlag, strictFlag,
+		},
+	},
+	{
+		Name: "team", Run: Team,
+		Usage:   "gitme team [--refresh]",
+		Summary: "List the collaborators in the team directory",
+		Flags:   []Flag{{Name: "--refresh", Help: "Fetch a directory served over https again now"}},
+	},
+	{
+		Name: "use", Run: Use,
+		Usage:       "gitme use <alias> [--allow-outside]",
+		Summary:     "Switch identity by alias name",
+		Flags:       []Flag{allowOutside},
+		IdentityArg: true,
+	},
+
+	// Statistics
+	{
+		Name: "stats", Run: Stats,
+		Usage:   "gitme stats [--all | --compare <email|alias> <email|alias> | --path <path>] [--timezone <zone>]",
+		Summary: "Show commit stats by identity in the current repo, or across all repos",
+		Flags: []Flag{
+			{Name: "--all", Short: "-a", Help: "Across all repos"},
+			{Name: "--compare", Help: "Compare two identities side by side across all repos"},
+			{Name: "--path", Value: "<path>", Help: "Only commits touching path in the current repo"},
+			{Name: "--timezone", Value: "<zone>", Help: "Bucket commits in zone (e.g. Europe/Berlin, local)"},
+			reindexFlag,
+			strictFlag,
+		},
+	},
+
+	// Platform tokens
+	{
+		Name: "token", Run: Token,
+		Usage:       "gitme token set <email|alias>\ngitme token remove <email|alias>",
+		Summary:     "Store or delete a GitHub/GitLab API token in the OS keychain",
+		Subcommands: []string{"set", "remove"},
+		IdentityArg: true,
+	},
+
+	// Diagnostics
+	{
+		Name: "doctor", Run: Doctor,
+		Usage:   "gitme doctor [--fix]",
+		Summary: "Check signing keys, authentication, mapped folders and identities (exit 2 on errors, 1 on warnings)",
+		Flags:   []Flag{{Name: "--fix", Help: "Re-apply mapped identities"}},
+	},
+	{
+		Name: "test", Run: Test,
+		Usage:       "gitme test <email|alias>",
+		Summary:     "Check an identity's ssh key and token authenticate as its account",
+		IdentityArg: true,
+	},
+	{
+		Name: "completion", Run: Completion,
+		Usage:       completionUsage,
+		Summary:     "Print a shell completion script",
+		Subcommands: completionShells,
+	},
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"slices"
+)
+
+const completionUsage = "gitme completion <bash|zsh|fish|powershell>"
+
+// completionShells are the shells gitme completion has scripts for
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// completionScripts ask gitme __complete for the words to offer, so they
+// stay current with the installed gitme and the configured identities
+var completionScripts = map[string]string{
+	"bash": `_gitme_complete() {
+  local IFS=$'\n'
+  COMPREPLY=($(command gitme __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+COMP_WORDBREAKS=${COMP_WORDBREAKS//:}
+complete -o default -F _gitme_complete gitme
+`,
+	"zsh": `#compdef gitme
+_gitme_complete() {
+  local -a words_
+  words_=("${(@f)$(command gitme __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+  compadd -a words_
+}
+compdef _gitme_complete gitme
+`,
+	"fish": `complete -c gitme -f -a '(command gitme __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
+`,
+	"powershell": `Register-ArgumentCompleter -Native -CommandName gitme -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') { $words += '' }
+    gitme __complete @words 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`,
+}
+
+// Completion prints the completion script of a shell, e.g. for
+// eval "$(gitme completion bash)" in ~/.bashrc
+func Completion(w io.Writer, args []string) error {
+	if len(args) != 1 || !slices.Contains(completionShells, args[0]) {
+		return usageErr(completionUsage)
+	}
+	fmt.Fprint(w, completionScripts[args[0]])
+	return nil
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+// configChange is one identity key of .git/config and the value gitme would
+// give it; "" means unset
+type configChange struct {
+	Key      string `json:"key"`
+	Current  string `json:"current"`
+	Expected string `json:"expected"`
+}
+
+// configDiff is what gitme diff-config found
+type configDiff struct {
+	Identity string         `json:"identity"`
+	Source   string         `json:"source"`
+	Changes  []configChange `json:"changes"`
+}
+
+// DiffConfig shows how the identity config gitme would write for the current
+// repo differs from its .git/config, as a unified diff. With --exit-code it
+// exits 1 when they differ.
+func DiffConfig(w io.Writer, args []string) error {
+	exitCode := hasFlag(args, "--exit-code")
+	if len(positionalArgs(args)) > 0 {
+		return usageErr("gitme diff-config [--exit-code]")
+	}
+	root, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	rules, err := loadRules(cfg)
+	if err != nil {
+		return fmt.Errorf("loading rules: %w", err)
+	}
+
+	id, source, err := diffConfigIdentity(cfg, rules, root)
+	if err != nil {
+		return err
+	}
+	out := newRenderer(w)
+	if id == nil {
+		if out.Format() == render.JSON {
+			return out.Render(configDiff{Changes: []configChange{}})
+		}
+		fmt.Fprintln(w, "No mapping, rule or .gitme file applies to this repository.")
+		fmt.Fprintln(w, DimStyle.Render("gitme leaves its config alone; pick an identity with: gitme set <email>"))
+		return nil
+	}
+
+	diff := configDiff{Identity: id.String(), Source: source, Changes: identityChanges(root, *id)}
+	differs := false
+	for _, c := range diff.Changes {
+		differs = differs || c.Current != c.Expected
+	}
+	if !differs && out.Format() != render.JSON {
+		fmt.Fprintf(w, "%s .git/config already has %s (%s)\n", SuccessStyle.Render("✓"), id.String(), source)
+		return nil
+	}
+
+	blocks := []render.Block{
+		render.Line(out.Style(HeaderStyle, "--- a/.git/config")),
+		render.Line(out.Style(HeaderStyle, fmt.Sprintf("+++ b/.git/config (%s, %s)", id.String(), source))),
+	}
+	section := ""
+	for _, c := range diff.Changes {
+		if c.Current == "" && c.Expected == "" {
+			continue
+		}
+		name, key, _ := strings.Cut(c.Key, ".")
+		if name != section {
+			section = name
+			blocks = append(blocks, render.Line(out.Style(DimStyle, "@@ ["+name+"] @@")))
+		}
+		switch {
+		case c.Current == c.Expected:
+			if c.Current != "" {
+				blocks = append(blocks, render.Line("     "+key+" = "+c.Current))
+			}
+		default:
+			if c.Current != "" {
+				blocks = append(blocks, render.Line(out.Style(WarnStyle, "-    "+key+" = "+c.Current)))
+			}
+			if c.Expected != "" {
+				blocks = append(blocks, render.Line(out.Style(SuccessStyle, "+    "+key+" = "+c.Expected)))
+			}
+		}
+	}
+	if err := out.Render(diff, blocks...); err != nil {
+		return err
+	}
+	if differs && exitCode {
+		return &ExitError{Code: 1}
+	}
+	return nil
+}
+
+// diffConfigIdentity returns the identity gitme would write to the repo at
+// root: a branch identity or .gitme file first, then the folder mapping
+// gitme set records, then rules and the path
+func diffConfigIdentity(cfg *config.Config, rules *config.RulesConfig, root string) (*identity.Identity, string, error) {
+	id, source, err := expectedIdentity(cfg, rules, root)
+	if err != nil && !errors.Is(err, errAmbiguousPath) {
+		return nil, "", err
+	}
+	if strings.HasPrefix(source, "branch: ") || strings.HasPrefix(source, "override: ") {
+		return id, source, nil
+	}
+	if mapped, ok := cfg.GetIdentityForFolder(root); ok {
+		return &mapped, "folder mapping", nil
+	}
+	return id, source, err
+}
+
+// identityChanges compares the keys ApplyIdentity writes with the local
+// config of the repo at root, grouped by section. An ssh command or signing
+// config the user set is left as it is.
+func identityChanges(root string, id identity.Identity) []configChange {
+	signing := make(map[string]string)
+	for _, kv := range signingConfig(id) {
+		signing[kv[0]] = kv[1]
+	}
+	keepSigning := id.SigningKey == "" && localConfigValue(root, signingMarker) != "true"
+
+	var changes []configChange
+	for _, c := range []configChange{
+		{Key: "user.email", Expected: id.Email},
+		{Key: "user.name", Expected: id.Name},
+		{Key: "user.signingkey", Expected: signing["user.signingkey"]},
+		{Key: "credential.username", Expected: id.Username},
+		{Key: "core.sshCommand"},
+		{Key: "gpg.format", Expected: signing["gpg.format"]},
+		{Key: "commit.gpgsign", Expected: signing["commit.gpgsign"]},
+	} {
+		c.Current = localConfigValue(root, c.Key)
+		switch c.Key {
+		case "core.sshCommand":
+			if id.SSHKey != "" {
+				c.Expected = sshCommand(id.SSHKey)
+			} else if !gitmeSSHCommand(c.Current) {
+				c.Expected = c.Current
+			}
+		case "user.signingkey", "gpg.format", "commit.gpgsign":
+			if keepSigning {
+				c.Expected = c.Current
+			}
+		}
+		changes = append(changes, c)
+	}
+
+	// The profile comes last and wins; keys of the profile applied before
+	// that it lacks are removed
+	profile := profileConfig(id)
+	base := len(changes)
+	for _, key := range slices.Concat(profileKeys(id), appliedProfileKeys(root)) {
+		i := slices.IndexFunc(changes, func(c configChange) bool { return strings.EqualFold(c.Key, key) })
+		expected := strings.Join(profile[key], ", ")
+		switch {
+		case i < 0:
+			current := strings.Join(localConfigValues(root, key), ", ")
+			changes = append(changes, configChange{Key: key, Current: current, Expected: expected})
+		case i < base:
+			if _, ok := profile[key]; ok {
+				changes[i].Expected = expected
+			} else if changes[i].Expected == changes[i].Current {
+				changes[i].Expected = "" // kept as the user's, but it is the old profile's
+			}
+		}
+	}
+	return changes
+}
+
+// localConfigValue returns the value of key in the local config of the repo
+// at dir, ignoring global and system config
+func localConfigValue(dir, key string) string {
+	cmd := exec.Command("git", "config", "--local", "--get", key)
+	cmd.Dir = dir
+	out, _ := cmd.Output()
+	return strings.TrimSpace(string(out))
+}
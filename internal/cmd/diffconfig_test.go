@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDiffConfigPreviewsIdentityKeys(t *testing.T) {
+	repo := newSwitchRepo(t)
+	mustGit(t, repo, "config", "user.email", "old@corp.com")
+	mustGit(t, repo, "config", "user.name", "Work")
+	if err := Rule(&bytes.Buffer{}, []string{"add", repo, "me@example.com"}); err != nil {
+		t.Fatalf("rule add failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	err := DiffConfig(&out, []string{"--exit-code"})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("expected exit 1 for a difference, got %v", err)
+	}
+	for _, line := range []string{
+		"+++ b/.git/config (Personal <me@example.com>, rule: " + repo + ")",
+		"@@ [user] @@",
+		"-    email = old@corp.com",
+		"+    email = me@example.com",
+		"-    name = Work",
+		"+    name = Personal",
+		"@@ [credential] @@",
+		"+    username = me",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Fatalf("expected %q in:\n%s", line, out.String())
+		}
+	}
+	if got := mustGit(t, repo, "config", "--local", "user.email"); got != "old@corp.com" {
+		t.Fatalf("diff-config changed user.email to %q", got)
+	}
+
+	if err := Set(&bytes.Buffer{}, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	out.Reset()
+	if err := DiffConfig(&out, []string{"--exit-code"}); err != nil {
+		t.Fatalf("expected no difference after set, got %v:\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "already has Personal <me@example.com> (folder mapping)") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+	"github.com/vosamoilenko/gitme/internal/stats"
+)
+
+// Ways the watcher delivers the weekly digest
+const (
+	digestTerminal = "terminal"
+	digestNotify   = "notify"
+)
+
+// weeklyDigest summarizes one week of commits and identity mismatches
+type weeklyDigest struct {
+	Week       string                 `json:"week"`
+	Since      time.Time              `json:"since"`
+	Until      time.Time              `json:"until"`
+	Identities []*stats.IdentityStats `json:"identities"`
+	Mismatches []string               `json:"mismatches"`
+}
+
+// digestMode reads --digest, which is terminal or notify; "" means no digest
+func digestMode(args []string) (string, error) {
+	mode, ok := flagValue(args, "--digest")
+	if !ok {
+		return "", nil
+	}
+	if mode != digestTerminal && mode != digestNotify {
+		return "", usageErr(watchUsage)
+	}
+	return mode, nil
+}
+
+// weekStart returns midnight of the Monday starting the week of t
+func weekStart(t time.Time) time.Time {
+	days := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -days).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// recordDigest adds a watch pass to this week's digest state. Once a new
+// week has begun it returns the digest of the week collected before.
+func recordDigest(now time.Time, report *watchReport) (*weeklyDigest, error) {
+	state, err := config.LoadDigest()
+	if err != nil {
+		return nil, fmt.Errorf("loading digest state: %w", err)
+	}
+
+	start := weekStart(now)
+	var digest *weeklyDigest
+	if !state.Start.IsZero() && state.Start.Before(start) {
+		if digest, err = buildDigest(state); err != nil {
+			return nil, err
+		}
+		state = &config.DigestState{Mismatches: make(map[string]string)}
+	}
+	if state.Start.IsZero() {
+		state.Start = start
+	}
+	for _, m := range report.Mismatches {
+		state.Mismatches[m.Repo] = m.Current + " → " + m.Expected
+	}
+	if err := state.Save(); err != nil {
+		return nil, fmt.Errorf("saving digest state: %w", err)
+	}
+	return digest, nil
+}
+
+// buildDigest counts the commits of known identities in the collected week
+func buildDigest(state *config.DigestState) (*weeklyDigest, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	knownEmails := make(map[string]bool)
+	for _, id := range cfg.Identities {
+		knownEmails[strings.ToLower(id.Email)] = true
+	}
+
+	year, week := state.Start.ISOWeek()
+	digest := &weeklyDigest{
+		Week:       fmt.Sprintf("%d-W%02d", year, week),
+		Since:      state.Start,
+		Until:      state.Start.AddDate(0, 0, 7),
+		Mismatches: []string{},
+	}
+	aggregated := &stats.RepoStats{ByIdentity: make(map[string]*stats.IdentityStats)}
+	opts := stats.Options{Env: repowalk.GitEnv(), Since: digest.Since, Until: digest.Until}
+	for r := range collectStats(cfg, knownEmails, opts) {
+		if r.err == nil {
+			mergeRepoStats(aggregated, r.stats)
+		}
+	}
+	digest.Identities = aggregated.SortedIdentities()
+
+	for repo, mismatch := range state.Mismatches {
+		digest.Mismatches = append(digest.Mismatches, repo+": "+mismatch)
+	}
+	sort.Strings(digest.Mismatches)
+	return digest, nil
+}
+
+// summary is the one-line form of the digest used for notifications
+func (d *weeklyDigest) summary() string {
+	commits := 0
+	for _, idStats := range d.Identities {
+		commits += idStats.CommitCount
+	}
+	return fmt.Sprintf("%d commits as %d identities, %d repos on the wrong identity",
+		commits, len(d.Identities), len(d.Mismatches))
+}
+
+// writeDigest prints the digest with commits per identity and mismatches
+func writeDigest(w io.Writer, d *weeklyDigest) error {
+	title := fmt.Sprintf("Weekly digest %s (%s – %s):", d.Week,
+		d.Since.Format("Jan 2"), d.Until.AddDate(0, 0, -1).Format("Jan 2"))
+	blocks := []render.Block{render.Header(title)}
+
+	var commits render.List
+	for _, idStats := range d.Identities {
+		commits = append(commits, render.Item{
+			Text:   fmt.Sprintf("%s <%s>", idStats.Name, idStats.Email),
+			Detail: []string{fmt.Sprintf("%d commits", idStats.CommitCount)},
+		})
+	}
+	if len(commits) == 0 {
+		blocks = append(blocks, render.Note("No commits from your identities"))
+	} else {
+		blocks = append(blocks, commits)
+	}
+	blocks = append(blocks, render.Line(""))
+
+	if len(d.Mismatches) == 0 {
+		blocks = append(blocks, render.Note("No identity mismatches detected"))
+	} else {
+		var mismatches render.List
+		for _, m := range d.Mismatches {
+			mismatches = append(mismatches, render.Item{Marker: "⚠", Text: m})
+		}
+		blocks = append(blocks, render.Header("Mismatches detected:"), mismatches)
+	}
+	return newRenderer(w).Render(d, blocks...)
+}
+
+// deliverDigest writes the digest to the watcher's output or sends it as a
+// desktop notification
+func deliverDigest(w io.Writer, mode string, d *weeklyDigest) error {
+	if mode == digestNotify {
+		return notify("gitme weekly digest "+d.Week, d.summary())
+	}
+	return writeDigest(w, d)
+}
+
+// notify shows a desktop notification
+var notify = func(title, message string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", message, title)
+		return exec.Command("osascript", "-e", script).Run()
+	case "linux":
+		return exec.Command("notify-send", title, message).Run()
+	}
+	return fmt.Errorf("notifications are not supported on %s", runtime.GOOS)
+}
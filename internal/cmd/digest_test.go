@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWeekStart(t *testing.T) {
+	for _, day := range []string{"2024-01-08", "2024-01-10", "2024-01-14"} {
+		d, _ := time.Parse(time.DateOnly, day)
+		if got := weekStart(d.Add(15 * time.Hour)).Format(time.DateOnly); got != "2024-01-08" {
+			t.Errorf("weekStart(%s) = %s, want Monday 2024-01-08", day, got)
+		}
+	}
+}
+
+func TestRecordDigestSummarizesPreviousWeek(t *testing.T) {
+	newSwitchRepo(t)
+	repo := filepath.Join(os.Getenv("HOME"), "Developer", "app")
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v (%s)", err, out)
+	}
+	commitAs(t, repo, "me@corp.com", "2024-01-09T10:00:00+00:00")
+	commitAs(t, repo, "me@corp.com", "2024-01-16T10:00:00+00:00") // the week after
+
+	wednesday := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	report := &watchReport{Mismatches: []Mismatch{{Repo: repo, Current: "me@example.com", Expected: "me@corp.com"}}}
+	if d, err := recordDigest(wednesday, report); err != nil || d != nil {
+		t.Fatalf("first pass of the week = %v, %v; want no digest", d, err)
+	}
+	if d, err := recordDigest(wednesday.Add(time.Hour), &watchReport{}); err != nil || d != nil {
+		t.Fatalf("later pass of the week = %v, %v; want no digest", d, err)
+	}
+
+	d, err := recordDigest(wednesday.AddDate(0, 0, 5), &watchReport{})
+	if err != nil || d == nil {
+		t.Fatalf("first pass of the next week = %v, %v; want a digest", d, err)
+	}
+	if d.Week != "2024-W02" || len(d.Identities) != 1 || d.Identities[0].CommitCount != 1 {
+		t.Fatalf("digest = %+v, want one commit in 2024-W02", d)
+	}
+	if len(d.Mismatches) != 1 {
+		t.Fatalf("mismatches = %v, want the one seen during the week", d.Mismatches)
+	}
+	if d, _ := recordDigest(wednesday.AddDate(0, 0, 6), &watchReport{}); d != nil {
+		t.Fatalf("digest delivered twice: %+v", d)
+	}
+}
@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+// signingSetup is the signing configuration git will use in a directory
+type signingSetup struct {
+	Dir     string // "" for global config
+	Email   string
+	Key     string // user.signingkey
+	Format  string // gpg.format: openpgp, ssh or x509
+	Enabled bool   // commit.gpgsign
+}
+
+// severity ranks doctor findings; its value is the exit code it causes
+type severity int
+
+const (
+	severityInfo severity = iota
+	severityWarning
+	severityError
+)
+
+func (s severity) String() string {
+	switch s {
+	case severityError:
+		return "error"
+	case severityWarning:
+		return "warning"
+	}
+	return "info"
+}
+
+func (s severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// finding is one problem doctor found; fix, when set, remediates it safely
+type finding struct {
+	Severity severity `json:"severity"`
+	Subject  string   `json:"subject"`
+	Message  string   `json:"message"`
+	Fixed    bool     `json:"fixed,omitempty"`
+	fix      func() error
+}
+
+// Doctor checks the signing setup and authentication of every identity, the
+// mapped folders and the identity list. Errors exit 2 and warnings 1; --fix remediates the safe
+// findings first, so it can run unattended.
+func Doctor(w io.Writer, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	findings := signingFindings(cfg)
+	findings = append(findings, authFindings(cfg)...)
+	findings = append(findings, mappingFindings(cfg)...)
+	findings = append(findings, identityFindings(cfg)...)
+	findings = append(findings, ruleFindings(cfg)...)
+
+	if hasFlag(args, "--fix") {
+		for i := range findings {
+			f := &findings[i]
+			if f.fix == nil {
+				continue
+			}
+			if err := f.fix(); err != nil {
+				f.Message += fmt.Sprintf(" (fix failed: %s)", err)
+				continue
+			}
+			f.Fixed = true
+		}
+	}
+	return reportFindings(w, findings)
+}
+
+// reportFindings prints findings by severity and returns the exit code of
+// the worst one left unfixed
+func reportFindings(w io.Writer, findings []finding) error {
+	out := newRenderer(w)
+	worst := severityInfo
+	var blocks []render.Block
+	for _, tier := range []struct {
+		severity severity
+		title    string
+		marker   string
+	}{
+		{severityError, "Errors:", "✗"},
+		{severityWarning, "Warnings:", "⚠"},
+		{severityInfo, "Info:", "-"},
+	} {
+		var list render.List
+		for _, f := range findings {
+			if f.Severity != tier.severity {
+				continue
+			}
+			marker := tier.marker
+			if f.Fixed {
+				marker = "✓"
+			} else {
+				worst = max(worst, f.Severity)
+			}
+			detail := f.Message
+			if f.Fixed {
+				detail += " (fixed)"
+			} else if f.fix != nil {
+				detail += " (fixable with --fix)"
+			}
+			list = append(list, render.Item{Marker: marker, Text: f.Subject, Detail: []string{detail}})
+		}
+		if len(list) > 0 {
+			blocks = append(blocks, render.Header(tier.title), list, render.Line(""))
+		}
+	}
+
+	switch worst {
+	case severityError:
+		blocks = append(blocks, render.Line(out.Style(WarnStyle, "Problems found that will make commits fail or go out wrong")))
+	case severityWarning:
+		blocks = append(blocks, render.Line(out.Style(WarnStyle, "Warnings found")))
+	default:
+		blocks = append(blocks, render.Line(out.Style(SuccessStyle, "No problems found")))
+	}
+	if findings == nil {
+		findings = []finding{}
+	}
+	if err := out.Render(findings, blocks...); err != nil {
+		return err
+	}
+	if worst > severityInfo {
+		return &ExitError{Code: int(worst)}
+	}
+	return nil
+}
+
+// signingFindings checks that the signing setup of every identity works
+func signingFindings(cfg *config.Config) []finding {
+	home, _ := os.UserHomeDir()
+	setups := []signingSetup{readSigningSetup(home, true)}
+	for _, folder := range mappedFolders(cfg) {
+		if _, err := os.Stat(folder); err != nil {
+			continue
+		}
+		setup := readSigningSetup(folder, false)
+		id, _ := cfg.GetIdentityForFolder(folder)
+		setup.Email = id.Email
+		setups = append(setups, setup)
+	}
+
+	var findings []finding
+	for _, setup := range setups {
+		where := setup.Dir
+		if where == "" {
+			where = "global"
+		}
+		subject := fmt.Sprintf("%s (%s)", cmp.Or(setup.Email, "no email"), where)
+		if !setup.Enabled && setup.Key == "" {
+			findings = append(findings, finding{Severity: severityInfo, Subject: subject, Message: "commit signing is off"})
+			continue
+		}
+		if err := checkSigningKey(setup); err != nil {
+			findings = append(findings, finding{Severity: severityError, Subject: subject, Message: err.Error()})
+		}
+	}
+	return findings
+}
+
+// mappingFindings checks that mapped folders exist and their repos still use
+// the mapped identity; re-applying it is a safe fix
+func mappingFindings(cfg *config.Config) []finding {
+	var findings []finding
+	for _, folder := range mappedFolders(cfg) {
+		id, ok := cfg.GetIdentityForFolder(folder)
+		if !ok {
+			findings = append(findings, finding{Severity: severityWarning, Subject: folder,
+				Message: "mapped to unknown identity " + cfg.Folders[folder]})
+			continue
+		}
+		if _, err := os.Stat(folder); err != nil {
+			findings = append(findings, finding{Severity: severityWarning, Subject: folder,
+				Message: "mapped folder no longer exists"})
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(folder, ".git")); err != nil {
+			continue
+		}
+		if current := gitConfigValue(folder, "user.email"); !strings.EqualFold(current, id.Email) {
+			findings = append(findings, finding{Severity: severityWarning, Subject: folder,
+				Message: fmt.Sprintf("uses %s but is mapped to %s", cmp.Or(current, "no identity"), id.Email),
+				fix:     func() error { return ApplyIdentity(folder, id) }})
+		}
+	}
+	return findings
+}
+
+// identityFindings notes identities worth a look: unused ones and candidates
+// waiting for review
+func identityFindings(cfg *config.Config) []finding {
+	var findings []finding
+	for _, id := range staleIdentities(cfg.Identities, time.Now().Add(-staleAfter)) {
+		findings = append(findings, finding{Severity: severityInfo, Subject: id.String(),
+			Message: "last used " + ago(id.LastUsed)})
+	}
+	if queue, err := config.LoadCandidates(); err == nil && len(queue.Pending) > 0 {
+		findings = append(findings, finding{Severity: severityInfo, Subject: "candidate identities",
+			Message: fmt.Sprintf("%d waiting for gitme review", len(queue.Pending))})
+	}
+	return findings
+}
+
+// ruleFindings warns about rules pointing at no known identity, e.g. at an
+// alias since removed
+func ruleFindings(cfg *config.Config) []finding {
+	rules, err := loadRules(cfg)
+	if err != nil {
+		return []finding{{Severity: severityWarning, Subject: "rules", Message: err.Error()}}
+	}
+	var findings []finding
+	for _, rule := range rules.Rules {
+		if rule.Ref() != "" && resolveIdentity(cfg, rule.Ref()) == nil {
+			findings = append(findings, finding{Severity: severityWarning, Subject: "rule " + rule.Pattern,
+				Message: "points at unknown identity " + rule.Ref()})
+		}
+	}
+	return findings
+}
+
+func mappedFolders(cfg *config.Config) []string {
+	folders := make([]string, 0, len(cfg.Folders))
+	for folder := range cfg.Folders {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+	return folders
+}
+
+// readSigningSetup reads the effective signing config for dir
+func readSigningSetup(dir string, global bool) signingSetup {
+	get := func(key string, flags ...string) string {
+		args := []string{"config"}
+		if global {
+			args = append(args, "--global")
+		}
+		args = append(append(args, flags...), "--get", key)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, _ := cmd.Output()
+		return strings.TrimSpace(string(out))
+	}
+
+	setup := signingSetup{
+		Email:   get("user.email"),
+		Key:     get("user.signingkey"),
+		Format:  get("gpg.format"),
+		Enabled: get("commit.gpgsign", "--type=bool") == "true",
+	}
+	if !global {
+		setup.Dir = dir
+	}
+	if setup.Format == "" {
+		setup.Format = "openpgp"
+	}
+	return setup
+}
+
+// checkSigningKey verifies the configured key is present and usable
+func checkSigningKey(setup signingSetup) error {
+	switch setup.Format {
+	case "ssh":
+		if setup.Key == "" {
+			return fmt.Errorf("gpg.format is ssh but user.signingkey is not set")
+		}
+		if strings.HasPrefix(setup.Key, "key::") || strings.HasPrefix(setup.Key, "ssh-") {
+			return nil // literal public key, resolved through ssh-agent
+		}
+		keyPath := setup.Key
+		if strings.HasPrefix(keyPath, "~") {
+			home, _ := os.UserHomeDir()
+			keyPath = filepath.Join(home, keyPath[1:])
+		}
+		if _, err := os.Stat(keyPath); err != nil {
+			return fmt.Errorf("ssh signing key %s not found", setup.Key)
+		}
+		return nil
+
+	case "x509":
+		if _, err := exec.LookPath("gpgsm"); err != nil {
+			return fmt.Errorf("gpg.format is x509 but gpgsm is not installed")
+		}
+		key := setup.Key
+		if key == "" {
+			key = setup.Email
+		}
+		if err := exec.Command("gpgsm", "--list-secret-keys", key).Run(); err != nil {
+			return fmt.Errorf("no x509 secret key for %s", key)
+		}
+		return nil
+
+	default:
+		if _, err := exec.LookPath("gpg"); err != nil {
+			return fmt.Errorf("commit signing is enabled but gpg is not installed")
+		}
+		key := setup.Key
+		if key == "" {
+			key = setup.Email // git falls back to the committer email
+		}
+		out, err := exec.Command("gpg", "--list-secret-keys", "--with-colons", key).Output()
+		if err != nil {
+			return fmt.Errorf("no gpg secret key for %s", key)
+		}
+		if err := exec.Command("gpg-connect-agent", "/bye").Run(); err != nil {
+			return fmt.Errorf("gpg-agent is not reachable")
+		}
+		if onSmartcard(string(out)) {
+			if err := exec.Command("gpg", "--card-status").Run(); err != nil {
+				return fmt.Errorf("key %s lives on a smartcard that is not inserted", key)
+			}
+		}
+		return nil
+	}
+}
+
+// onSmartcard reports whether gpg --with-colons output describes a key stub
+// whose secret part lives on a card (serial number in field 15)
+func onSmartcard(colons string) bool {
+	for _, line := range strings.Split(colons, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) > 14 && (fields[0] == "sec" || fields[0] == "ssb") {
+			if fields[14] != "" && fields[14] != "+" && fields[14] != "#" {
+				return true
+			}
+		}
+	}
+	return false
+}
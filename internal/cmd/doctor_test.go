@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+func TestDoctorFixesMappedIdentity(t *testing.T) {
+	repo := newSwitchRepo(t)
+	cfg, _ := config.Load()
+	cfg.SetIdentityForFolder(repo, cfg.Identities[0])
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("saving config: %v", err)
+	}
+	mustGit(t, repo, "config", "--local", "user.email", "me@example.com")
+
+	var exitErr *ExitError
+	if err := Doctor(io.Discard, nil); !errors.As(err, &exitErr) || exitErr.Code != int(severityWarning) {
+		t.Fatalf("Doctor = %v, want the warning exit code", err)
+	}
+	if err := Doctor(io.Discard, []string{"--fix"}); err != nil {
+		t.Fatalf("Doctor --fix = %v, want success", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "user.email"); got != "me@corp.com" {
+		t.Fatalf("user.email = %q, want the mapped identity re-applied", got)
+	}
+}
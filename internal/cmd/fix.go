@@ -1,48 +1,64 @@
 package cmd
 
 import (
+	"cmp"
 	"fmt"
-	"os"
+	"io"
+	"maps"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/rewrite"
 )
 
-// FixScan shows commits by your identities in current repo
-func FixScan() {
-	cwd, _ := os.Getwd()
-
-	gitDir := filepath.Join(cwd, ".git")
-	if _, err := os.Stat(gitDir); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: not a git repository\n")
-		os.Exit(1)
+// FixScan shows commits by your identities in current repo. With --list it
+// prints the commits themselves, of every identity or of the one given.
+func FixScan(w io.Writer, args []string) error {
+	list := hasFlag(args, "--list")
+	positional := positionalArgs(args)
+	if len(positional) > 1 || len(positional) == 1 && !list {
+		return usageErr("gitme fix:scan [--list [email|alias]]")
+	}
+	root, err := requireGitRoot()
+	if err != nil {
+		return err
 	}
 
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
 	}
 
 	knownEmails := make(map[string]bool)
 	for _, id := range cfg.Identities {
 		knownEmails[strings.ToLower(id.Email)] = true
 	}
+	if len(positional) == 1 {
+		email := positional[0]
+		if id := resolveIdentity(cfg, email); id != nil {
+			email = id.Email
+		}
+		knownEmails = map[string]bool{strings.ToLower(email): true}
+	}
 
-	cmd := exec.Command("git", "log", "--format=%H|%an|%ae")
-	cmd.Dir = cwd
+	cmd := exec.Command("git", "log", "--format=%h%x00%an%x00%ae%x00%ad%x00%s", "--date=short")
+	cmd.Dir = root
 	output, err := cmd.Output()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error running git log: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("running git log: %w", err)
 	}
 
 	type commitInfo struct {
-		name  string
-		email string
-		count int
+		name    string
+		email   string
+		count   int
+		commits []rewriteCommit
 	}
 	identityCounts := make(map[string]*commitInfo)
 
@@ -51,8 +67,8 @@ func FixScan() {
 		if line == "" {
 			continue
 		}
-		parts := strings.SplitN(line, "|", 3)
-		if len(parts) != 3 {
+		parts := strings.SplitN(line, "\x00", 5)
+		if len(parts) != 5 {
 			continue
 		}
 		name := parts[1]
@@ -68,62 +84,118 @@ func FixScan() {
 			identityCounts[key] = &commitInfo{name: name, email: email, count: 0}
 		}
 		identityCounts[key].count++
+		if list {
+			identityCounts[key].commits = append(identityCounts[key].commits, rewriteCommit{Hash: parts[0], Date: parts[3], Subject: parts[4]})
+		}
 	}
 
+	if len(identityCounts) == 0 && len(positional) == 1 {
+		fmt.Fprintf(w, "No commits found from %s in this repo.\n", positional[0])
+		return nil
+	}
 	if len(identityCounts) == 0 {
-		fmt.Println("No commits found from your known identities in this repo.")
-		return
+		fmt.Fprintln(w, "No commits found from your known identities in this repo.")
+		return nil
 	}
 
 	var configuredEmail string
 	cmdEmail := exec.Command("git", "config", "user.email")
-	cmdEmail.Dir = cwd
+	cmdEmail.Dir = root
 	if out, err := cmdEmail.Output(); err == nil {
 		configuredEmail = strings.ToLower(strings.TrimSpace(string(out)))
 	}
 
-	fmt.Println(HeaderStyle.Render("Commits by your identities in this repo:"))
-	fmt.Println()
+	repoPlatform := indexRepo(root).Platform
+	header := "Commits by your identities in this repo:"
+	if repoPlatform != identity.PlatformUnknown {
+		header = fmt.Sprintf("Commits by your identities in this %s repo:", repoPlatform)
+	}
+	fmt.Fprintln(w, HeaderStyle.Render(header))
+	fmt.Fprintln(w)
 
-	for _, info := range identityCounts {
+	infos := slices.SortedFunc(maps.Values(identityCounts), func(a, b *commitInfo) int {
+		return cmp.Or(b.count-a.count, cmp.Compare(a.email, b.email))
+	})
+	icons := iconSet()
+	for _, info := range infos {
 		marker := ""
 		emailLower := strings.ToLower(info.email)
 		if emailLower == configuredEmail {
 			marker = " " + SuccessStyle.Render("(current)")
 		}
-		fmt.Printf("  %s <%s>%s\n", info.name, info.email, marker)
-		fmt.Printf("    %s\n", DimStyle.Render(fmt.Sprintf("%d commits", info.count)))
+		platform := identity.PlatformUnknown
+		if id := cfg.IdentityByRef(info.email); id != nil {
+			platform = id.Platform
+		}
+		fmt.Fprintf(w, "  %s%s <%s>%s\n", platformIcon(icons, platform), info.name, info.email, marker)
+		fmt.Fprintf(w, "    %s\n", DimStyle.Render(fmt.Sprintf("%d commits", info.count)))
+		if note := platformMatch(platform, repoPlatform); note != "" {
+			fmt.Fprintf(w, "    %s\n", note)
+		}
+		for _, c := range info.commits {
+			fmt.Fprintf(w, "    %s  %s  %s\n", WarnStyle.Render(c.Hash), DimStyle.Render(c.Date), c.Subject)
+		}
 	}
 
 	if len(identityCounts) > 1 {
-		fmt.Println()
-		fmt.Println(DimStyle.Render("To rewrite history, use:"))
-		fmt.Println(DimStyle.Render("  gitme fix:rewrite <old-email> <new-email>"))
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, DimStyle.Render("To rewrite history, use:"))
+		fmt.Fprintln(w, DimStyle.Render("  gitme fix:rewrite <old-email> <new-email>"))
 	}
+	return nil
 }
 
-// FixRewrite rewrites commits from old email to new email
-func FixRewrite() {
-	if len(os.Args) < 4 {
-		fmt.Fprintf(os.Stderr, "Usage: gitme fix:rewrite <old-email> <new-email>\n")
-		os.Exit(1)
+// platformMatch notes whether an identity of platform belongs in a repo
+// whose remote is on repoPlatform; "" when either is unknown
+func platformMatch(platform, repoPlatform identity.Platform) string {
+	switch {
+	case platform == identity.PlatformUnknown || repoPlatform == identity.PlatformUnknown:
+		return ""
+	case platform == repoPlatform:
+		return DimStyle.Render(fmt.Sprintf("%s identity, matches the remote", platform))
+	default:
+		return WarnStyle.Render(fmt.Sprintf("⚠ %s identity in a %s repo", platform, repoPlatform))
 	}
+}
 
-	cwd, _ := os.Getwd()
+// FixRewrite rewrites commits from old email to new email
+func FixRewrite(w io.Writer, args []string) error {
+	var paths []string
+	if i := slices.Index(args, "--"); i >= 0 {
+		args, paths = args[:i], args[i+1:]
+	}
+	includeProtected := hasFlag(args, "--include-protected")
+	dryRun := hasFlag(args, "--dry-run")
+	branch, hasBranch := flagValue(args, "--branch")
+	revRange, hasRange := flagValue(args, "--range")
+	since, hasSince := flagValue(args, "--since")
+	for _, flag := range []string{"--branch", "--range", "--since"} {
+		args = withoutFlag(args, flag)
+	}
+	args = positionalArgs(args)
+	if len(args) < 2 || hasBranch && hasRange {
+		return usageErr("gitme fix:rewrite <old-email> <new-email> [--branch <name>|--range <base>..<branch>] [--since <date>] [--include-protected] [--dry-run] [-- <path>...]")
+	}
 
-	gitDir := filepath.Join(cwd, ".git")
-	if _, err := os.Stat(gitDir); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: not a git repository\n")
-		os.Exit(1)
+	root, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
+	if paths, err = repoPathspecs(paths); err != nil {
+		return err
 	}
 
-	oldEmail := os.Args[2]
-	newEmail := os.Args[3]
+	oldEmail := args[0]
+	newEmail := args[1]
 
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
 	}
 
 	var newName string
@@ -134,86 +206,361 @@ func FixRewrite() {
 		}
 	}
 	if newName == "" {
-		fmt.Fprintf(os.Stderr, "Error: %s is not a known identity\n", newEmail)
-		fmt.Fprintf(os.Stderr, "Add it first with: gitme add \"Name\" \"%s\"\n", newEmail)
-		os.Exit(1)
+		return fmt.Errorf("%s is not a known identity (add it first with: gitme add \"Name\" \"%s\")", newEmail, newEmail)
 	}
 
-	cmd := exec.Command("git", "log", "--format=%ae")
-	cmd.Dir = cwd
+	opts := RewriteOptions{Paths: paths}
+	var protected []string
+	if hasRange {
+		base, tip, ok := strings.Cut(revRange, "..")
+		if !ok || base == "" || strings.HasPrefix(tip, ".") {
+			return usageErr("gitme fix:rewrite <old-email> <new-email> --range <base>..<branch>")
+		}
+		if !revisionExists(root, base) {
+			return fmt.Errorf("unknown revision: %s", base)
+		}
+		if tip == "" || tip == "HEAD" {
+			if tip = currentBranch(root); tip == "" {
+				return fmt.Errorf("HEAD is detached; name the branch: --range %s..<branch>", base)
+			}
+		}
+		branch, hasBranch = tip, true
+		opts.Limit = append(opts.Limit, "^"+base)
+	}
+	if hasSince {
+		opts.Limit = append(opts.Limit, "--since="+since)
+	}
+	if hasBranch {
+		branches, err := localBranches(root)
+		if err != nil {
+			return fmt.Errorf("listing branches: %w", err)
+		}
+		if !slices.Contains(branches, branch) {
+			return fmt.Errorf("no local branch named %s", branch)
+		}
+		if !includeProtected && isProtectedBranch(branch, protectedBranchPatterns(root, settings)) {
+			return fmt.Errorf("%s is protected; use --include-protected to rewrite it anyway", branch)
+		}
+		opts.Refs = []string{branch}
+	} else if !includeProtected {
+		branches, err := localBranches(root)
+		if err != nil {
+			return fmt.Errorf("listing branches: %w", err)
+		}
+		patterns := protectedBranchPatterns(root, settings)
+		var allowed []string
+		for _, branch := range branches {
+			if isProtectedBranch(branch, patterns) {
+				protected = append(protected, branch)
+			} else {
+				allowed = append(allowed, branch)
+			}
+		}
+		// Always name the allowed branches: falling back to every ref would
+		// take in the protected ones, and refs beyond the local branches
+		if len(allowed) == 0 {
+			if len(protected) == 0 {
+				return fmt.Errorf("no local branches to rewrite")
+			}
+			return fmt.Errorf("all branches are protected (%s); use --include-protected to rewrite them anyway",
+				strings.Join(protected, ", "))
+		}
+		opts.Refs = allowed
+	}
+
+	logArgs := slices.Concat([]string{"log", "--format=%ae %ce"}, opts.revArgs(), []string{"--"}, opts.Paths)
+	cmd := exec.Command("git", logArgs...)
+	cmd.Dir = root
 	output, err := cmd.Output()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error running git log: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("running git log: %w", err)
 	}
 
 	count := 0
 	for _, line := range strings.Split(string(output), "\n") {
-		if strings.EqualFold(strings.TrimSpace(line), oldEmail) {
+		author, committer, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if strings.EqualFold(author, oldEmail) || strings.EqualFold(committer, oldEmail) {
 			count++
 		}
 	}
 
 	if count == 0 {
-		fmt.Printf("No commits found from %s\n", oldEmail)
-		return
+		fmt.Fprintf(w, "No commits found from %s\n", oldEmail)
+		return nil
+	}
+
+	fmt.Fprintln(w, HeaderStyle.Render("Rewrite plan:"))
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  From: %s\n", oldEmail)
+	fmt.Fprintf(w, "  To:   %s <%s>\n", newName, newEmail)
+	fmt.Fprintf(w, "  Commits to rewrite: %d\n", count)
+	if hasBranch {
+		fmt.Fprintf(w, "  Branch: %s\n", branch)
+	}
+	if hasRange || hasSince {
+		fmt.Fprintf(w, "  Limited to: %s\n", strings.Join(opts.describeLimit(), ", "))
+	}
+	if len(paths) > 0 {
+		fmt.Fprintf(w, "  Touching: %s\n", strings.Join(paths, ", "))
+	}
+	if len(protected) > 0 {
+		fmt.Fprintf(w, "  Skipping protected: %s\n", strings.Join(protected, ", "))
+		fmt.Fprintln(w, DimStyle.Render("  (use --include-protected to rewrite them too)"))
+	}
+	fmt.Fprintln(w)
+	if dryRun {
+		branches := opts.Refs
+		if len(branches) == 0 {
+			if branches, err = localBranches(root); err != nil {
+				return fmt.Errorf("listing branches: %w", err)
+			}
+		}
+		preview, err := rewritePreview(root, oldEmail, branches, opts)
+		if err != nil {
+			return err
+		}
+		if err := printRewritePreview(w, preview); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, DimStyle.Render("Dry run: no commits were rewritten"))
+		return nil
+	}
+	fmt.Fprintln(w, WarnStyle.Render("WARNING: This rewrites git history!"))
+	fmt.Fprintln(w, DimStyle.Render("You will need to force push after this."))
+	fmt.Fprintln(w)
+	if !confirm(w, "Continue?") {
+		fmt.Fprintln(w, "Aborted.")
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	before, err := snapshotHistory(root, opts)
+	if err != nil {
+		return err
+	}
+	// Upstreams as they are now, which the rewrite leaves alone; force pushes
+	// compare against and lease on these
+	tracked, err := trackedBranches(root, opts.Refs)
+	if err != nil {
+		return fmt.Errorf("inspecting upstream branches: %w", err)
+	}
+	if !ReadOnly {
+		b, err := backupRepo(root, settings)
+		if b == nil && err != nil {
+			return fmt.Errorf("backing up the repo: %w", err)
+		}
+		if b != nil {
+			fmt.Fprintf(w, "%s Backed up all refs to %s\n", SuccessStyle.Render("✓"), b.Path)
+			fmt.Fprintln(w, DimStyle.Render(fmt.Sprintf("  Restore with: git fetch --update-head-ok --force %s 'refs/*:refs/*'", b.Path)))
+			if len(b.Pruned) > 0 {
+				fmt.Fprintln(w, DimStyle.Render(fmt.Sprintf("  Removed %d older backups over the %d MB limit", len(b.Pruned), settings.BackupLimit()>>20)))
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(w, WarnStyle.Render("⚠ "+err.Error()))
+		}
 	}
+	fmt.Fprintln(w, "Rewriting commits...")
 
-	fmt.Println(HeaderStyle.Render("Rewrite plan:"))
-	fmt.Println()
-	fmt.Printf("  From: %s\n", oldEmail)
-	fmt.Printf("  To:   %s <%s>\n", newName, newEmail)
-	fmt.Printf("  Commits to rewrite: %d\n", count)
-	fmt.Println()
-	fmt.Println(WarnStyle.Render("WARNING: This rewrites git history!"))
-	fmt.Println(DimStyle.Render("You will need to force push after this."))
-	fmt.Println()
-	fmt.Print("Continue? [y/N] ")
+	err = RewriteAuthorWithOptions(root, oldEmail, newName, newEmail, opts)
+	if err != nil {
+		return fmt.Errorf("rewriting history: %w", err)
+	}
 
-	var response string
-	fmt.Scanln(&response)
-	if strings.ToLower(response) != "y" {
-		fmt.Println("Aborted.")
-		return
+	fmt.Fprintln(w, SuccessStyle.Render("Done!"))
+	fmt.Fprintln(w)
+	verified := true
+	if !ReadOnly {
+		after, err := snapshotHistory(root, opts)
+		if err != nil {
+			return fmt.Errorf("verifying the rewrite: %w", err)
+		}
+		verified = printRewriteReport(w, before, after, oldEmail, newEmail, hasRange || hasSince || len(paths) > 0)
+		fmt.Fprintln(w)
+	}
+	if !verified {
+		fmt.Fprintln(w, WarnStyle.Render("The rewrite did not come out as planned; check the history before pushing."))
+		return &ExitError{Code: 1}
 	}
+	offerForcePush(w, root, tracked)
+	return nil
+}
+
+// rewriteCommit is a commit fix:rewrite would change
+type rewriteCommit struct {
+	Hash    string `json:"hash"`
+	Date    string `json:"date"`
+	Subject string `json:"subject"`
+}
 
-	fmt.Println()
-	fmt.Println("Rewriting commits...")
+// branchRewrite is the commits of a branch fix:rewrite would change
+type branchRewrite struct {
+	Branch  string          `json:"branch"`
+	Commits []rewriteCommit `json:"commits"`
+}
 
-	err = RewriteAuthor(cwd, oldEmail, newName, newEmail)
+// rewritePreview lists, for each of branches, the commits naming email as
+// author or committer that the Limit and Paths of opts leave in, newest first
+func rewritePreview(root, email string, branches []string, opts RewriteOptions) ([]branchRewrite, error) {
+	var preview []branchRewrite
+	for _, branch := range branches {
+		logArgs := append([]string{"log", "--format=%H%x00%ad%x00%ae%x00%ce%x00%s", "--date=short", branch}, opts.Limit...)
+		cmd := exec.Command("git", slices.Concat(logArgs, []string{"--"}, opts.Paths)...)
+		cmd.Dir = root
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("running git log on %s: %w", branch, err)
+		}
+		group := branchRewrite{Branch: branch, Commits: []rewriteCommit{}}
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			fields := strings.SplitN(line, "\x00", 5)
+			if len(fields) != 5 || !strings.EqualFold(fields[2], email) && !strings.EqualFold(fields[3], email) {
+				continue
+			}
+			group.Commits = append(group.Commits, rewriteCommit{Hash: fields[0], Date: fields[1], Subject: fields[4]})
+		}
+		if len(group.Commits) > 0 {
+			preview = append(preview, group)
+		}
+	}
+	return preview, nil
+}
+
+// printRewritePreview shows the commits a rewrite would change, by branch
+func printRewritePreview(w io.Writer, preview []branchRewrite) error {
+	out := newRenderer(w)
+	blocks := []render.Block{render.Header("Commits that would be rewritten:")}
+	for i, group := range preview {
+		if i > 0 {
+			blocks = append(blocks, render.Line(""))
+		}
+		var table render.Table
+		for _, c := range group.Commits {
+			table.Rows = append(table.Rows, []string{out.Style(WarnStyle, c.Hash[:min(7, len(c.Hash))]), out.Style(DimStyle, c.Date), c.Subject})
+		}
+		blocks = append(blocks, render.Line(fmt.Sprintf("%s (%d)", group.Branch, len(group.Commits))), table)
+	}
+	if preview == nil {
+		preview = []branchRewrite{}
+	}
+	return out.Render(preview, blocks...)
+}
+
+// RewriteOptions limits which refs and commits a rewrite touches
+type RewriteOptions struct {
+	Refs  []string // branches to rewrite; empty means rewrite.AllRefs
+	Limit []string // rev-list arguments excluding commits, e.g. ^origin/main or --since=<date>
+	Paths []string // pathspecs relative to the repo root; only commits touching them are rewritten
+}
+
+func (o RewriteOptions) revArgs() []string {
+	refs := o.Refs
+	if len(refs) == 0 {
+		refs = rewrite.AllRefs
+	}
+	return slices.Concat(refs, o.Limit)
+}
+
+// describeLimit spells out the commits Limit leaves in
+func (o RewriteOptions) describeLimit() []string {
+	var parts []string
+	for _, arg := range o.Limit {
+		if base, ok := strings.CutPrefix(arg, "^"); ok {
+			parts = append(parts, "commits after "+base)
+		} else if date, ok := strings.CutPrefix(arg, "--since="); ok {
+			parts = append(parts, "commits since "+date)
+		}
+	}
+	return parts
+}
+
+// repoPathspecs makes pathspecs given relative to the working directory
+// relative to the repo root, where fix:rewrite runs git; ones with magic
+// such as :(glob) are left as they are
+func repoPathspecs(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	out, err := exec.Command("git", "rev-parse", "--show-prefix").Output()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error rewriting history: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("running git rev-parse: %w", err)
 	}
+	prefix := strings.TrimSpace(string(out))
+	var specs []string
+	for _, spec := range paths {
+		if !strings.HasPrefix(spec, ":") {
+			spec = filepath.ToSlash(filepath.Join(prefix, spec))
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
 
-	fmt.Println(SuccessStyle.Render("Done!"))
-	fmt.Println()
-	fmt.Println("Next steps:")
-	fmt.Println(DimStyle.Render("  git push --force-with-lease"))
+// revisionExists reports whether rev names a commit in the repo at root
+func revisionExists(root, rev string) bool {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	cmd.Dir = root
+	return cmd.Run() == nil
 }
 
-// RewriteAuthor rewrites commits from oldEmail to newName/newEmail using git filter-branch
+// RewriteAuthor rewrites commits from oldEmail to newName/newEmail across all
+// branches and tags
 func RewriteAuthor(repoPath, oldEmail, newName, newEmail string) error {
-	script := `
-if [ "$GIT_COMMITTER_EMAIL" = "` + oldEmail + `" ]; then
-    export GIT_COMMITTER_NAME="` + newName + `"
-    export GIT_COMMITTER_EMAIL="` + newEmail + `"
-fi
-if [ "$GIT_AUTHOR_EMAIL" = "` + oldEmail + `" ]; then
-    export GIT_AUTHOR_NAME="` + newName + `"
-    export GIT_AUTHOR_EMAIL="` + newEmail + `"
-fi
-`
-	cmd := exec.Command("git", "filter-branch", "-f", "--env-filter", script, "--", "--all")
+	return RewriteAuthorWithOptions(repoPath, oldEmail, newName, newEmail, RewriteOptions{})
+}
+
+// RewriteAuthorWithOptions rewrites commits from oldEmail to newName/newEmail
+func RewriteAuthorWithOptions(repoPath, oldEmail, newName, newEmail string, opts RewriteOptions) error {
+	if readOnlySkip("rewrite %s's commits in %s to %s <%s>", oldEmail, repoPath, newName, newEmail) {
+		return nil
+	}
+	_, err := rewrite.Email(repoPath, oldEmail, rewrite.Person{Name: newName, Email: newEmail}, rewrite.Options{Refs: opts.Refs, Revs: opts.Limit, Paths: opts.Paths})
+	return err
+}
+
+// localBranches returns the short names of all local branches
+func localBranches(repoPath string) ([]string, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname:short)", "refs/heads")
 	cmd.Dir = repoPath
-	cmd.Env = append(os.Environ(), "FILTER_BRANCH_SQUELCH_WARNING=1")
-	output, err := cmd.CombinedOutput()
+	out, err := cmd.Output()
 	if err != nil {
-		if strings.Contains(string(output), "nothing to rewrite") ||
-			strings.Contains(string(output), "Found nothing to rewrite") {
-			return nil
+		return nil, err
+	}
+	var branches []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			branches = append(branches, line)
 		}
-		return fmt.Errorf("%v: %s", err, output)
 	}
-	return nil
+	return branches, nil
+}
+
+// protectedBranchPatterns returns the repo's gitme.protectedBranch values,
+// falling back to the global setting
+func protectedBranchPatterns(repoPath string, settings *config.Settings) []string {
+	cmd := exec.Command("git", "config", "--get-all", "gitme.protectedBranch")
+	cmd.Dir = repoPath
+	if out, err := cmd.Output(); err == nil {
+		var patterns []string
+		for _, line := range strings.Split(string(out), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				patterns = append(patterns, line)
+			}
+		}
+		if len(patterns) > 0 {
+			return patterns
+		}
+	}
+	return settings.ProtectedBranchPatterns()
+}
+
+// isProtectedBranch reports whether branch matches any of the glob patterns
+func isProtectedBranch(branch string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
 }
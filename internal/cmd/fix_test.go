@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+// commitBy makes an empty commit in repo authored and committed by email
+func commitBy(t *testing.T, repo, email, subject string) {
+	t.Helper()
+	cmd := exec.Command("git", "-C", repo, "commit", "-q", "--allow-empty", "-m", subject)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=A", "GIT_AUTHOR_EMAIL="+email, "GIT_COMMITTER_NAME=A", "GIT_COMMITTER_EMAIL="+email)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("commit failed: %v (%s)", err, out)
+	}
+}
+
+func TestFixRewriteDryRunListsCommitsByBranch(t *testing.T) {
+	repo := newSwitchRepo(t)
+	commitBy(t, repo, "me@corp.com", "on main")
+	exec.Command("git", "-C", repo, "branch", "-M", "main").Run()
+	exec.Command("git", "-C", repo, "checkout", "-q", "-b", "feature").Run()
+	commitBy(t, repo, "me@example.com", "mine")
+	commitBy(t, repo, "me@corp.com", "wrong identity")
+	before := mustGit(t, repo, "rev-parse", "feature")
+
+	var out bytes.Buffer
+	if err := FixRewrite(&out, []string{"--dry-run", "me@corp.com", "me@example.com"}); err != nil {
+		t.Fatalf("fix:rewrite --dry-run failed: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{"feature (2)", "wrong identity", "on main", "Skipping protected: main", "Dry run"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "mine") {
+		t.Fatalf("expected only commits by me@corp.com:\n%s", got)
+	}
+	if after := mustGit(t, repo, "rev-parse", "feature"); after != before {
+		t.Fatal("expected a dry run to leave history alone")
+	}
+}
+
+func TestFixScanListsCommits(t *testing.T) {
+	repo := newSwitchRepo(t)
+	commitBy(t, repo, "me@corp.com", "corp work")
+	commitBy(t, repo, "me@example.com", "side project")
+	hash := mustGit(t, repo, "rev-parse", "--short", "HEAD~1")
+
+	var out bytes.Buffer
+	if err := FixScan(&out, []string{"--list", "me@corp.com"}); err != nil {
+		t.Fatalf("fix:scan --list failed: %v", err)
+	}
+	got := out.String()
+	if !strings.Contains(got, hash) || !strings.Contains(got, "corp work") {
+		t.Fatalf("expected the commit of me@corp.com listed:\n%s", got)
+	}
+	if strings.Contains(got, "side project") {
+		t.Fatalf("expected only commits of me@corp.com:\n%s", got)
+	}
+
+	out.Reset()
+	if err := FixScan(&out, nil); err != nil {
+		t.Fatalf("fix:scan failed: %v", err)
+	}
+	if strings.Contains(out.String(), "corp work") {
+		t.Fatalf("expected counts only without --list:\n%s", out.String())
+	}
+}
+
+func TestFixRewriteLimitedToARange(t *testing.T) {
+	repo := newSwitchRepo(t)
+	commitBy(t, repo, "me@corp.com", "pushed")
+	exec.Command("git", "-C", repo, "branch", "-M", "topic").Run()
+	pushed := mustGit(t, repo, "rev-parse", "HEAD")
+	commitBy(t, repo, "me@corp.com", "not pushed")
+
+	Stdin, stdinReader = strings.NewReader("y\n"), nil
+	t.Cleanup(func() { Stdin, stdinReader = os.Stdin, nil })
+	var out bytes.Buffer
+	if err := FixRewrite(&out, []string{"me@corp.com", "me@example.com", "--range", pushed + ".."}); err != nil {
+		t.Fatalf("fix:rewrite --range failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Commits to rewrite: 1") {
+		t.Fatalf("expected one commit in the plan:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "✓ unchanged") || !strings.Contains(out.String(), "1 commits from me@corp.com left outside the scope") {
+		t.Fatalf("expected the verification to pass with the pushed commit left alone:\n%s", out.String())
+	}
+	if got := mustGit(t, repo, "log", "--format=%ae", "-1"); got != "me@example.com" {
+		t.Fatalf("expected the unpushed commit rewritten, got %s", got)
+	}
+	if got := mustGit(t, repo, "rev-parse", "HEAD~1"); got != pushed {
+		t.Fatalf("expected the pushed commit to keep its hash %s, got %s", pushed, got)
+	}
+
+	if err := FixRewrite(&bytes.Buffer{}, []string{"me@corp.com", "me@example.com", "--branch", "nope"}); err == nil {
+		t.Fatal("expected an unknown branch to be refused")
+	}
+}
+
+func TestFixRewriteBacksUpTheRepoFirst(t *testing.T) {
+	repo := newSwitchRepo(t)
+	commitBy(t, repo, "me@corp.com", "old")
+	before := mustGit(t, repo, "rev-parse", "HEAD")
+	old := filepath.Join(config.BackupsDir(), "repo-20000101-000000.bundle")
+	if err := os.MkdirAll(config.BackupsDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(old, make([]byte, 1<<20), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(old, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+	if err := (&config.Settings{BackupLimitMB: 1}).Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	Stdin, stdinReader = strings.NewReader("y\n"), nil
+	t.Cleanup(func() { Stdin, stdinReader = os.Stdin, nil })
+	var out bytes.Buffer
+	if err := FixRewrite(&out, []string{"me@corp.com", "me@example.com", "--include-protected"}); err != nil {
+		t.Fatalf("fix:rewrite failed: %v", err)
+	}
+	bundles, _ := filepath.Glob(filepath.Join(config.BackupsDir(), "repo-*.bundle"))
+	if len(bundles) != 1 || bundles[0] == old {
+		t.Fatalf("expected only the new backup to be kept, got %v\n%s", bundles, out.String())
+	}
+	if heads := mustGit(t, repo, "bundle", "list-heads", bundles[0]); !strings.Contains(heads, before) {
+		t.Fatalf("expected the backup to hold the commit before the rewrite %s, got:\n%s", before, heads)
+	}
+	if !strings.Contains(out.String(), "Removed 1 older backups") {
+		t.Fatalf("expected the old backup to be reported as removed:\n%s", out.String())
+	}
+}
+
+func TestFixScanFlagsIdentitiesOfAnotherPlatform(t *testing.T) {
+	repo := newSwitchRepo(t)
+	cfg, _ := config.Load()
+	cfg.IdentityByRef("me@corp.com").Platform = identity.PlatformGitLab
+	cfg.IdentityByRef("me@example.com").Platform = identity.PlatformGitHub
+	cfg.Save()
+	mustGit(t, repo, "config", "remote.origin.url", "git@github.com:me/site.git")
+	for _, email := range []string{"me@corp.com", "me@example.com", "me@example.com"} {
+		commitBy(t, repo, email, "x")
+	}
+
+	var out bytes.Buffer
+	if err := FixScan(&out, nil); err != nil {
+		t.Fatalf("fix:scan failed: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{"in this github repo", "github identity, matches the remote", "gitlab identity in a github repo"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "me@example.com") > strings.Index(got, "me@corp.com") {
+		t.Fatalf("expected identities with more commits first:\n%s", got)
+	}
+}
+
+func TestFixRewriteLeavesRemoteTrackingRefsAlone(t *testing.T) {
+	repo := newSwitchRepo(t)
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	mustGit(t, repo, "init", "-q", "--bare", remote)
+	commitBy(t, repo, "me@corp.com", "pushed")
+	mustGit(t, repo, "branch", "-M", "feature")
+	mustGit(t, repo, "remote", "add", "origin", remote)
+	mustGit(t, repo, "push", "-q", "-u", "origin", "feature")
+	pushed := mustGit(t, repo, "rev-parse", "origin/feature")
+
+	// Rewrite, then decline the force push
+	Stdin, stdinReader = strings.NewReader("y\nn\n"), nil
+	t.Cleanup(func() { Stdin, stdinReader = os.Stdin, nil })
+	var out bytes.Buffer
+	if err := FixRewrite(&out, []string{"me@corp.com", "me@example.com"}); err != nil {
+		t.Fatalf("fix:rewrite failed: %v", err)
+	}
+	if got := mustGit(t, repo, "rev-parse", "origin/feature"); got != pushed {
+		t.Fatalf("origin/feature = %s, want it left at the pushed %s", got, pushed)
+	}
+	if strings.Contains(out.String(), "remotes/origin") || !strings.Contains(out.String(), "feature → origin/feature") {
+		t.Fatalf("want only feature rewritten and offered for a force push:\n%s", out.String())
+	}
+
+	// The force push leases on the upstream recorded before the rewrite
+	Stdin, stdinReader = strings.NewReader("y\ny\n"), nil
+	commitBy(t, repo, "me@corp.com", "more")
+	out.Reset()
+	if err := FixRewrite(&out, []string{"me@corp.com", "me@example.com"}); err != nil {
+		t.Fatalf("fix:rewrite failed: %v", err)
+	}
+	local := mustGit(t, repo, "rev-parse", "feature")
+	if got := mustGit(t, remote, "rev-parse", "feature"); got != local {
+		t.Fatalf("remote feature = %s, want the rewritten %s:\n%s", got, local, out.String())
+	}
+}
+
+func TestFixRewriteNamesTheAllowedBranches(t *testing.T) {
+	repo := newSwitchRepo(t)
+	commitBy(t, repo, "me@corp.com", "released")
+	mustGit(t, repo, "branch", "-M", "feature")
+	mustGit(t, repo, "tag", "v1")
+	mustGit(t, repo, "update-ref", "refs/remotes/origin/main", "HEAD")
+	released := mustGit(t, repo, "rev-parse", "HEAD")
+
+	Stdin, stdinReader = strings.NewReader("y\n"), nil
+	t.Cleanup(func() { Stdin, stdinReader = os.Stdin, nil })
+	var out bytes.Buffer
+	if err := FixRewrite(&out, []string{"me@corp.com", "me@example.com"}); err != nil {
+		t.Fatalf("fix:rewrite failed: %v", err)
+	}
+	if got := mustGit(t, repo, "log", "--format=%ae", "-1", "feature"); got != "me@example.com" {
+		t.Fatalf("feature author = %s, want it rewritten:\n%s", got, out.String())
+	}
+	for _, ref := range []string{"origin/main", "v1"} {
+		if got := mustGit(t, repo, "rev-parse", ref); got != released {
+			t.Errorf("%s = %s, want it left at %s without --include-protected", ref, got, released)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/rewrite"
+)
+
+// historySnapshot is what fix:rewrite compares before and after rewriting:
+// the commits of the branches it rewrites by author email, and every ref
+type historySnapshot struct {
+	Emails map[string]int    `json:"emails"` // lowercased author email → commits
+	Total  int               `json:"total"`  // commits
+	Refs   map[string]string `json:"refs"`   // ref name → object
+}
+
+// snapshotHistory takes a historySnapshot of the refs opts rewrites in the
+// repo at root. Limits and paths are left out: they select which commits
+// change, not which ones must survive.
+func snapshotHistory(root string, opts RewriteOptions) (historySnapshot, error) {
+	snap := historySnapshot{Emails: make(map[string]int), Refs: make(map[string]string)}
+	refs := opts.Refs
+	if len(refs) == 0 {
+		refs = rewrite.AllRefs
+	}
+	cmd := exec.Command("git", slices.Concat([]string{"log", "--format=%ae"}, refs, []string{"--"})...)
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return snap, fmt.Errorf("running git log: %w", err)
+	}
+	for _, email := range strings.Fields(string(out)) {
+		snap.Emails[strings.ToLower(email)]++
+		snap.Total++
+	}
+
+	cmd = exec.Command("git", "for-each-ref", "--format=%(refname) %(objectname)")
+	cmd.Dir = root
+	if out, err = cmd.Output(); err != nil {
+		return snap, fmt.Errorf("running git for-each-ref: %w", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if ref, object, ok := strings.Cut(line, " "); ok {
+			snap.Refs[ref] = object
+		}
+	}
+	return snap, nil
+}
+
+// printRewriteReport shows what a rewrite from oldEmail to newEmail changed:
+// the commits of each identity whose count moved, whether the commit count
+// held and which refs now point elsewhere. It reports whether the rewrite
+// looks right; commits left from oldEmail only count against it when the
+// rewrite was not scoped to some of them.
+func printRewriteReport(w io.Writer, before, after historySnapshot, oldEmail, newEmail string, scoped bool) bool {
+	oldEmail, newEmail = strings.ToLower(oldEmail), strings.ToLower(newEmail)
+	emails := []string{oldEmail, newEmail}
+	seen := maps.Clone(before.Emails)
+	maps.Copy(seen, after.Emails)
+	for _, email := range slices.Sorted(maps.Keys(seen)) {
+		if before.Emails[email] != after.Emails[email] && !slices.Contains(emails, email) {
+			emails = append(emails, email)
+		}
+	}
+
+	out := newRenderer(w)
+	ok := true
+	table := render.Table{Rows: [][]string{{"", out.Style(DimStyle, "before"), out.Style(DimStyle, "after")}}}
+	for _, email := range emails {
+		table.Rows = append(table.Rows, []string{email, strconv.Itoa(before.Emails[email]), strconv.Itoa(after.Emails[email])})
+	}
+	total := out.Style(SuccessStyle, "✓ unchanged")
+	if before.Total != after.Total {
+		total, ok = out.Style(WarnStyle, fmt.Sprintf("⚠ %+d", after.Total-before.Total)), false
+	}
+	table.Rows = append(table.Rows, []string{"total", strconv.Itoa(before.Total), strconv.Itoa(after.Total), total})
+
+	var rewritten []string
+	for ref, object := range after.Refs {
+		if before.Refs[ref] != object {
+			rewritten = append(rewritten, strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/"), "refs/"))
+		}
+	}
+	var lost []string
+	for ref := range before.Refs {
+		if _, kept := after.Refs[ref]; !kept {
+			lost = append(lost, ref)
+		}
+	}
+	slices.Sort(rewritten)
+	slices.Sort(lost)
+
+	blocks := []render.Block{render.Header("Verification:"), table, render.Line("")}
+	blocks = append(blocks, render.Line(fmt.Sprintf("  Refs rewritten: %d %s", len(rewritten), out.Style(DimStyle, strings.Join(rewritten, ", ")))))
+	if len(lost) > 0 {
+		ok = false
+		blocks = append(blocks, render.Line(out.Style(WarnStyle, "  ⚠ Refs gone: "+strings.Join(lost, ", "))))
+	}
+	switch left := after.Emails[oldEmail]; {
+	case left == 0:
+		blocks = append(blocks, render.Line(out.Style(SuccessStyle, "  ✓ No commits left from "+oldEmail)))
+	case scoped:
+		blocks = append(blocks, render.Line(out.Style(DimStyle, fmt.Sprintf("  %d commits from %s left outside the scope", left, oldEmail))))
+	default:
+		ok = false
+		blocks = append(blocks, render.Line(out.Style(WarnStyle, fmt.Sprintf("  ⚠ %d commits still from %s", left, oldEmail))))
+	}
+	out.Render(map[string]historySnapshot{"before": before, "after": after}, blocks...)
+	return ok
+}
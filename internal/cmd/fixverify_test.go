@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRewriteReportFlagsLostCommits(t *testing.T) {
+	before := historySnapshot{Emails: map[string]int{"old@x.com": 2, "new@x.com": 1}, Total: 3, Refs: map[string]string{"refs/heads/main": "a", "refs/heads/topic": "b"}}
+	after := historySnapshot{Emails: map[string]int{"old@x.com": 1, "new@x.com": 1}, Total: 2, Refs: map[string]string{"refs/heads/main": "c"}}
+	var out bytes.Buffer
+	if printRewriteReport(&out, before, after, "old@x.com", "new@x.com", false) {
+		t.Fatalf("expected the report to fail:\n%s", out.String())
+	}
+	for _, want := range []string{"⚠ -1", "Refs rewritten: 1", "Refs gone: refs/heads/topic", "1 commits still from old@x.com"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in the report:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+const forgetUsage = "gitme forget <path|pattern>  (or: gitme forget --undo <path|pattern>)"
+
+// Forget drops identities found only under a path, e.g. a vendored or cloned
+// third-party repo, along with the repos and candidates indexed there. The
+// path is remembered so later scans leave it alone.
+func Forget(w io.Writer, args []string) error {
+	undo := hasFlag(args, "--undo")
+	positional := positionalArgs(args)
+	if len(positional) != 1 {
+		return usageErr(forgetUsage)
+	}
+	pattern := forgetPattern(positional[0])
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	if undo {
+		i := slices.Index(settings.Forgotten, pattern)
+		if i < 0 {
+			return fmt.Errorf("not forgotten: %s", pattern)
+		}
+		settings.Forgotten = slices.Delete(settings.Forgotten, i, i+1)
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Scans include %s again\n", SuccessStyle.Render("✓"), pattern)
+		fmt.Fprintln(w, DimStyle.Render("Run 'gitme scan --full' to pick up its identities and repos"))
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	match := forgottenMatcher([]string{pattern})
+	var removed []identity.Identity
+	cfg.Identities, removed = forgetIdentities(cfg.Identities, match)
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+
+	repos := 0
+	if idx, err := config.LoadRepoIndex(); err == nil {
+		kept := idx.Repos[:0]
+		for _, repo := range idx.Repos {
+			if !match(repo.Path) {
+				kept = append(kept, repo)
+			}
+		}
+		repos = len(idx.Repos) - len(kept)
+		idx.Repos = kept
+		idx.Save()
+	}
+
+	queue, err := config.LoadCandidates()
+	if err != nil {
+		return fmt.Errorf("loading candidates: %w", err)
+	}
+	queue.Pending = forgetCandidates(queue.Pending, match)
+	if err := queue.Save(); err != nil {
+		return fmt.Errorf("saving candidates: %w", err)
+	}
+
+	if !slices.Contains(settings.Forgotten, pattern) {
+		settings.Forgotten = append(settings.Forgotten, pattern)
+	}
+	if err := settings.Save(); err != nil {
+		return fmt.Errorf("saving settings: %w", err)
+	}
+
+	var list render.List
+	for _, id := range removed {
+		list = append(list, render.Item{Text: id.String()})
+	}
+	blocks := []render.Block{render.Line(fmt.Sprintf("%s Forgot %s: %d identities, %d indexed repos",
+		SuccessStyle.Render("✓"), pattern, len(removed), repos))}
+	if len(list) > 0 {
+		blocks = append(blocks, list)
+	}
+	blocks = append(blocks, render.Note("Later scans skip it; undo with: gitme forget --undo "+pattern))
+	return newRenderer(w).Render(struct {
+		Pattern    string              `json:"pattern"`
+		Identities []identity.Identity `json:"identities"`
+		Repos      int                 `json:"repos"`
+	}{pattern, removed, repos}, blocks...)
+}
+
+// forgetPattern makes a path argument absolute so it compares with the
+// absolute source paths of identities; ~ is expanded in globs too
+func forgetPattern(arg string) string {
+	if strings.HasPrefix(arg, "~/") {
+		home, _ := os.UserHomeDir()
+		arg = filepath.Join(home, arg[2:])
+	}
+	if abs, err := filepath.Abs(arg); err == nil {
+		arg = abs
+	}
+	return arg
+}
+
+// forgottenMatcher reports whether a path lies under one of patterns: a
+// directory, or a glob matching the path or one of its parents
+func forgottenMatcher(patterns []string) func(path string) bool {
+	return func(path string) bool {
+		if !filepath.IsAbs(path) {
+			return false // "manual", "gpg" and other non-file sources
+		}
+		for _, pattern := range patterns {
+			if path == pattern || strings.HasPrefix(path, pattern+string(filepath.Separator)) {
+				return true
+			}
+			if !strings.ContainsAny(pattern, "*?[") {
+				continue
+			}
+			for dir := path; dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+				if ok, _ := filepath.Match(pattern, dir); ok {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// forgetIdentities drops the sources match reports and the identities left
+// without any; identities found elsewhere too are kept
+func forgetIdentities(identities []identity.Identity, match func(string) bool) (kept, removed []identity.Identity) {
+	kept = make([]identity.Identity, 0, len(identities))
+	for _, id := range identities {
+		sources := id.Sources
+		if len(sources) == 0 && id.Source != "" {
+			sources = []string{id.Source}
+		}
+		remaining := slices.DeleteFunc(slices.Clone(sources), match)
+		switch {
+		case len(sources) > 0 && len(remaining) == 0:
+			removed = append(removed, id)
+			continue
+		case len(remaining) < len(sources):
+			if match(id.Source) {
+				id.Source = remaining[0]
+			}
+			if len(id.Sources) > 0 {
+				id.Sources = remaining
+			}
+		}
+		kept = append(kept, id)
+	}
+	return kept, removed
+}
+
+// forgetCandidates drops the repos match reports from the candidates and the
+// candidates only seen there
+func forgetCandidates(candidates []identity.Candidate, match func(string) bool) []identity.Candidate {
+	kept := make([]identity.Candidate, 0, len(candidates))
+	for _, cand := range candidates {
+		repos := slices.DeleteFunc(slices.Clone(cand.Repos), match)
+		if len(cand.Repos) > 0 && len(repos) == 0 {
+			continue
+		}
+		cand.Repos = repos
+		kept = append(kept, cand)
+	}
+	return kept
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+func TestForgetDropsIdentitiesFoundOnlyUnderPath(t *testing.T) {
+	newSwitchRepo(t)
+	home, _ := os.UserHomeDir()
+	vendor := filepath.Join(home, "src", "app", "vendor", "lib", ".git", "config")
+	cfg, _ := config.Load()
+	cfg.Identities = append(cfg.Identities,
+		identity.Identity{Name: "Lib", Email: "dev@lib.org", Source: vendor, Sources: []string{vendor}},
+		identity.Identity{Name: "Me", Email: "me@home.org", Source: vendor, Sources: []string{vendor, filepath.Join(home, ".gitconfig")}},
+	)
+	cfg.Save()
+
+	var out bytes.Buffer
+	if err := Forget(&out, []string{"~/src/*/vendor"}); err != nil {
+		t.Fatalf("forget failed: %v", err)
+	}
+	cfg, _ = config.Load()
+	var emails []string
+	for _, id := range cfg.Identities {
+		emails = append(emails, id.Email)
+		if id.Email == "me@home.org" && (len(id.Sources) != 1 || id.Source != filepath.Join(home, ".gitconfig")) {
+			t.Fatalf("expected only the vendored source dropped, got %+v", id)
+		}
+	}
+	if strings.Join(emails, ",") != "me@corp.com,me@example.com,me@home.org" {
+		t.Fatalf("unexpected identities after forget: %v\n%s", emails, out.String())
+	}
+
+	settings, _ := config.LoadSettings()
+	if match := forgottenMatcher(settings.Forgotten); !match(vendor) || match(filepath.Join(home, "src", "app", ".git", "config")) {
+		t.Fatalf("expected later scans to skip only the vendored tree, got %v", settings.Forgotten)
+	}
+	if err := Forget(&out, []string{"--undo", "~/src/*/vendor"}); err != nil {
+		t.Fatalf("forget --undo failed: %v", err)
+	}
+	if settings, _ = config.LoadSettings(); len(settings.Forgotten) != 0 {
+		t.Fatalf("expected undo to clear the path, got %v", settings.Forgotten)
+	}
+}
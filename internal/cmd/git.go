@@ -1,11 +1,35 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
-// RepoRoot returns the git repository root for a working directory.
+// ResolveGitEnv makes relative GIT_DIR and GIT_WORK_TREE absolute. git
+// resolves them against the directory it runs in, and gitme runs git from
+// the repository root rather than the caller's directory.
+func ResolveGitEnv() error {
+	for _, key := range []string{"GIT_DIR", "GIT_WORK_TREE"} {
+		value := os.Getenv(key)
+		if value == "" || filepath.IsAbs(value) {
+			continue
+		}
+		abs, err := filepath.Abs(value)
+		if err != nil {
+			return fmt.Errorf("resolving %s: %w", key, err)
+		}
+		if err := os.Setenv(key, abs); err != nil {
+			return fmt.Errorf("setting %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// RepoRoot returns the git repository root for a working directory. Like git,
+// it honors GIT_DIR and GIT_WORK_TREE.
 func RepoRoot(cwd string) (string, error) {
 	cmd := exec.Command("git", "-C", cwd, "rev-parse", "--show-toplevel")
 	out, err := cmd.Output()
@@ -14,3 +38,30 @@ func RepoRoot(cwd string) (string, error) {
 	}
 	return strings.TrimSpace(string(out)), nil
 }
+
+// requireGitRoot returns the root of the repository containing the working
+// directory; it works from subdirectories and linked worktrees
+func requireGitRoot() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("getting current directory: %w", err)
+	}
+	root, err := RepoRoot(cwd)
+	if err != nil {
+		return "", fmt.Errorf("not inside a git repository")
+	}
+	return root, nil
+}
+
+// workingRepo returns the working directory and the root of the repository
+// containing it; outside a repository both are the working directory
+func workingRepo() (cwd, root string, err error) {
+	cwd, err = os.Getwd()
+	if err != nil {
+		return "", "", fmt.Errorf("getting current directory: %w", err)
+	}
+	if root, err = RepoRoot(cwd); err != nil {
+		return cwd, cwd, nil
+	}
+	return cwd, root, nil
+}
@@ -42,3 +42,37 @@ func TestRepoRootFromSubdirectory(t *testing.T) {
 		t.Fatalf("expected root %q, got %q", canonicalTmp, canonicalRoot)
 	}
 }
+
+func TestRequireGitRootFromLinkedWorktree(t *testing.T) {
+	tmpDir := t.TempDir()
+	repo := filepath.Join(tmpDir, "repo")
+	for _, args := range [][]string{
+		{"init", repo},
+		{"-C", repo, "-c", "user.name=T", "-c", "user.email=t@example.com", "commit", "--allow-empty", "-m", "init"},
+		{"-C", repo, "worktree", "add", filepath.Join(tmpDir, "wt"), "-b", "wt"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v (%s)", args, err, out)
+		}
+	}
+
+	subdir := filepath.Join(tmpDir, "wt", "sub")
+	if err := os.MkdirAll(subdir, 0755); err != nil {
+		t.Fatalf("failed to create subdir: %v", err)
+	}
+	t.Chdir(subdir)
+
+	root, err := requireGitRoot()
+	if err != nil {
+		t.Fatalf("requireGitRoot returned error: %v", err)
+	}
+	want, _ := filepath.EvalSymlinks(filepath.Join(tmpDir, "wt"))
+	if got, _ := filepath.EvalSymlinks(root); got != want {
+		t.Fatalf("expected root %q, got %q", want, got)
+	}
+
+	t.Chdir(tmpDir)
+	if _, err := requireGitRoot(); err == nil {
+		t.Fatalf("expected error outside a repository")
+	}
+}
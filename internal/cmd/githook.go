@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+// templateHookMarker identifies the post-checkout hook gitme hook
+// git-install puts in the git template directory. It contains
+// branchHookMarker, so gitme branch treats copies of it as its own.
+const templateHookMarker = branchHookMarker + " hook git-install"
+
+// templateHook runs gitme auto when git checks out a repo it just cloned
+// (or a new worktree), which post-checkout sees as a checkout from the null
+// commit; git has no post-clone hook
+const templateHook = `#!/bin/sh
+` + templateHookMarker + `: applies the identity expected for a repo once it is cloned
+case "$1" in *[!0]*) exit 0 ;; esac
+command -v gitme >/dev/null 2>&1 || exit 0
+exec gitme auto --quiet
+`
+
+// gitTemplateDir returns the init.templateDir of the global git config, or
+// "" when there is none
+func gitTemplateDir() string {
+	out, _ := exec.Command("git", "config", "--global", "--get", "init.templateDir").Output()
+	dir := strings.TrimSpace(string(out))
+	if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, rest)
+	}
+	return dir
+}
+
+// gitHookInstall puts templateHook in the git template directory, setting
+// one up under the config directory when there is none, so repos cloned from
+// now on get their identity before the first commit
+func gitHookInstall(w io.Writer) error {
+	dir := gitTemplateDir()
+	ownDir := dir == ""
+	if ownDir {
+		dir = filepath.Join(config.Dir(), "git-template")
+	}
+	path := filepath.Join(dir, "hooks", "post-checkout")
+	if data, err := os.ReadFile(path); err == nil && !strings.Contains(string(data), templateHookMarker) {
+		fmt.Fprintf(w, "%s %s already exists\n", WarnStyle.Render("⚠"), path)
+		fmt.Fprintln(w, DimStyle.Render("Run 'gitme auto' from it to apply identities to new clones"))
+		return nil
+	}
+	if readOnlySkip("install a post-checkout hook in the git template directory %s", dir) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating template hooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(templateHook), 0755); err != nil {
+		return fmt.Errorf("writing post-checkout hook: %w", err)
+	}
+	if ownDir {
+		if out, err := exec.Command("git", "config", "--global", "init.templateDir", dir).CombinedOutput(); err != nil {
+			return fmt.Errorf("setting init.templateDir: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	fmt.Fprintf(w, "%s New clones run gitme auto: post-checkout hook in %s\n", SuccessStyle.Render("✓"), dir)
+	if settings, err := config.LoadSettings(); err == nil && !settings.AutoApply {
+		fmt.Fprintln(w, DimStyle.Render("auto_apply is off, so they only warn about a wrong identity; turn it on with: gitme config auto_apply on"))
+	}
+	fmt.Fprintln(w, DimStyle.Render("Repos cloned before are left as they are; undo with: gitme hook git-uninstall"))
+	return nil
+}
+
+// gitHookUninstall removes the hook gitHookInstall added, and the template
+// directory when gitme set it up
+func gitHookUninstall(w io.Writer) error {
+	dir := gitTemplateDir()
+	if dir == "" {
+		fmt.Fprintln(w, "No git template directory set.")
+		return nil
+	}
+	path := filepath.Join(dir, "hooks", "post-checkout")
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), templateHookMarker) {
+		fmt.Fprintf(w, "No gitme hook in %s\n", dir)
+		return nil
+	}
+	if readOnlySkip("remove the post-checkout hook from the git template directory %s", dir) {
+		return nil
+	}
+	if dir == filepath.Join(config.Dir(), "git-template") {
+		if out, err := exec.Command("git", "config", "--global", "--unset", "init.templateDir").CombinedOutput(); err != nil {
+			return fmt.Errorf("unsetting init.templateDir: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("removing %s: %w", dir, err)
+		}
+	} else if err := os.Remove(path); err != nil {
+		return fmt.Errorf("removing post-checkout hook: %w", err)
+	}
+	fmt.Fprintf(w, "%s Removed the post-checkout hook from %s\n", SuccessStyle.Render("✓"), dir)
+	fmt.Fprintln(w, DimStyle.Render("Repos cloned with it keep their copy in .git/hooks"))
+	return nil
+}
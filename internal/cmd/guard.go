@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/policy"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+const guardUsage = "gitme guard <install|uninstall>"
+
+// guardHookMarker identifies the pre-commit hook and the lines in husky
+// scripts gitme guard install adds
+const guardHookMarker = branchHookMarker + " guard"
+
+const guardHook = `#!/bin/sh
+` + guardHookMarker + `: blocks commits made with another identity than gitme expects
+command -v gitme >/dev/null 2>&1 || exit 0
+` + guardCheck
+
+// guardLines are added to the pre-commit script of husky, which runs them
+// from hooks it generates itself
+const guardLines = guardHookMarker + "\n" +
+	"if command -v gitme >/dev/null 2>&1; then\n  " + guardCheck + "fi\n"
+
+// guardCheck fails the commit when gitme verify finds the wrong identity or
+// none, but not when it cannot tell which one is expected. It also holds up
+// under sh -e, which husky runs scripts with.
+const guardCheck = `gitme verify || case $? in 1|2|5|6) echo "Switch with 'gitme auto' or 'gitme set', or commit anyway with git commit --no-verify" >&2; exit 1 ;; esac
+`
+
+// Exit statuses of gitme verify by reason
+var verifyExitCodes = map[string]int{
+	"mismatch":   1, // user.email is another identity's
+	"unset":      2, // no user.email at all
+	"unclear":    3, // the expected identity cannot be told, e.g. an ambiguous path
+	"not-a-repo": 4,
+	"unmatched":  5, // strict, yet no identity matches and the global one would be used
+	"policy":     6, // the identity breaks the repo's .gitme.yml
+}
+
+// verifyReport is what gitme verify found. Reason is a key of
+// verifyExitCodes, empty when the repo commits as expected.
+type verifyReport struct {
+	Repo     string `json:"repo"`
+	OK       bool   `json:"ok"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Email    string `json:"email,omitempty"`    // user.email in effect
+	Expected string `json:"expected,omitempty"` // email of the identity expected
+	Source   string `json:"source,omitempty"`
+}
+
+// Verify checks that the repo at path, or the current one, commits as the
+// identity its branch identity, folder mapping or rules expect. It prints
+// nothing and exits 0 when it does, or when nothing is expected; otherwise it
+// prints "<reason>: <message>" and exits with the status of the reason, for
+// hooks, CI checks and prompts. With --all it checks the identities against
+// their platform accounts instead.
+func Verify(w io.Writer, args []string) error {
+	positional := positionalArgs(args)
+	if len(positional) > 1 || len(positional) > 0 && hasFlag(args, "--all") {
+		return usageErr("gitme verify [path] | --all")
+	}
+	if hasFlag(args, "--all") {
+		return verifyAll(w)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("getting current directory: %w", err)
+	}
+	if len(positional) == 1 {
+		if cwd, err = filepath.Abs(positional[0]); err != nil {
+			return fmt.Errorf("resolving %s: %w", positional[0], err)
+		}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	rules, err := loadRules(cfg)
+	if err != nil {
+		return fmt.Errorf("loading rules: %w", err)
+	}
+
+	report := verifyRepo(cfg, rules, cwd)
+	out := newRenderer(w)
+	var blocks []render.Block
+	if !report.OK {
+		blocks = append(blocks, render.Line(report.Reason+": "+report.Message))
+	}
+	if err := out.Render(report, blocks...); err != nil {
+		return err
+	}
+	if !report.OK {
+		return &ExitError{Code: verifyExitCodes[report.Reason]}
+	}
+	return nil
+}
+
+// verifyRepo checks the identity of the repo cwd is in, and that it complies
+// with the repo's policy
+func verifyRepo(cfg *config.Config, rules *config.RulesConfig, cwd string) verifyReport {
+	root, err := RepoRoot(cwd)
+	if err != nil {
+		return verifyReport{Repo: cwd, Reason: "not-a-repo", Message: cwd + " is not inside a git repository"}
+	}
+	report := verifyReport{Repo: root, Email: gitConfigValue(cwd, "user.email")}
+	expected, source, err := verifyIdentity(cfg, rules, root, cwd)
+	switch {
+	case err != nil:
+		report.Reason, report.Message = "unclear", err.Error()
+	case expected == nil:
+		report.OK = true
+		settings, err := config.LoadSettings()
+		if err != nil {
+			settings = &config.Settings{}
+		}
+		if reason := strictFallback(rules, cfg, root, settings.Strict); reason != "" {
+			report.OK, report.Reason, report.Source = false, "unmatched", reason
+			report.Message = fmt.Sprintf("no identity matches, would commit as %s (%s)", cmp.Or(report.Email, "nobody"), reason)
+		}
+	default:
+		report.Expected, report.Source = expected.Email, source
+		switch {
+		case strings.EqualFold(report.Email, expected.Email):
+			report.OK = true
+		case report.Email == "":
+			report.Reason = "unset"
+			report.Message = fmt.Sprintf("no user.email set, expected %s (%s)", expected.String(), source)
+		default:
+			report.Reason = "mismatch"
+			report.Message = fmt.Sprintf("committing as %s, expected %s (%s)", report.Email, expected.String(), source)
+		}
+	}
+	if !report.OK {
+		return report
+	}
+	violations, _, err := policyViolations(root, report.Email)
+	switch {
+	case err != nil:
+		report.OK, report.Reason, report.Message = false, "unclear", err.Error()
+	case len(violations) > 0:
+		report.OK, report.Reason, report.Source = false, "policy", policy.FileName
+		report.Message = strings.Join(violations, "; ")
+	}
+	return report
+}
+
+// verifyIdentity returns the identity the repo at root should commit as and
+// why: a branch identity of the checked out branch first, then the folder
+// mapping, then what expectedIdentity finds. It returns nil when nothing
+// applies.
+func verifyIdentity(cfg *config.Config, rules *config.RulesConfig, root, cwd string) (*identity.Identity, string, error) {
+	if _, _, ok := cfg.BranchIdentity(root, currentBranch(root)); !ok {
+		if id, ok := mappedIdentity(cfg, root, cwd); ok {
+			return &id, "folder mapping", nil
+		}
+	}
+	return expectedIdentity(cfg, rules, root)
+}
+
+// Guard installs or removes a pre-commit hook that runs gitme verify. With
+// husky the check goes in .husky/pre-commit, and next to a hook of the
+// pre-commit framework in pre-commit.legacy, which that hook runs first.
+func Guard(w io.Writer, args []string) error {
+	positional := positionalArgs(args)
+	if len(positional) != 1 || positional[0] != "install" && positional[0] != "uninstall" {
+		return usageErr(guardUsage)
+	}
+	root, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
+	path, err := hookPath(root, "pre-commit")
+	if err != nil {
+		return err
+	}
+	if positional[0] == "uninstall" {
+		return guardUninstall(w, path)
+	}
+
+	if script, ok := huskyScript(path); ok {
+		data, err := os.ReadFile(script)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("reading %s: %w", script, err)
+		}
+		if strings.Contains(string(data), guardHookMarker) {
+			fmt.Fprintf(w, "The gitme guard is already in %s\n", script)
+			return nil
+		}
+		if readOnlySkip("add gitme verify to %s", script) {
+			return nil
+		}
+		content := string(data)
+		if content == "" {
+			content = "#!/bin/sh\n"
+		} else if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if err := os.WriteFile(script, []byte(content+guardLines), 0755); err != nil {
+			return fmt.Errorf("writing %s: %w", script, err)
+		}
+		printGuardInstalled(w, script)
+		return nil
+	}
+
+	if data, err := os.ReadFile(path); err == nil && !strings.Contains(string(data), guardHookMarker) {
+		if !strings.Contains(string(data), "pre-commit.com") {
+			fmt.Fprintf(w, "%s %s already exists\n", WarnStyle.Render("⚠"), path)
+			fmt.Fprintln(w, DimStyle.Render("Run 'gitme verify' from it to block commits with the wrong identity"))
+			return nil
+		}
+		path += ".legacy"
+		if data, err := os.ReadFile(path); err == nil && !strings.Contains(string(data), guardHookMarker) {
+			fmt.Fprintf(w, "%s %s already exists\n", WarnStyle.Render("⚠"), path)
+			fmt.Fprintln(w, DimStyle.Render("Run 'gitme verify' from it to block commits with the wrong identity"))
+			return nil
+		}
+	}
+	if readOnlySkip("install a pre-commit hook at %s", path) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating hooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(guardHook), 0755); err != nil {
+		return fmt.Errorf("writing pre-commit hook: %w", err)
+	}
+	printGuardInstalled(w, path)
+	return nil
+}
+
+func printGuardInstalled(w io.Writer, path string) {
+	fmt.Fprintf(w, "%s Commits are checked with gitme verify: %s\n", SuccessStyle.Render("✓"), path)
+	fmt.Fprintln(w, DimStyle.Render("Skip it once with git commit --no-verify; undo with: gitme guard uninstall"))
+}
+
+// guardUninstall removes what gitme guard install added for the pre-commit
+// hook at path
+func guardUninstall(w io.Writer, path string) error {
+	if script, ok := huskyScript(path); ok {
+		data, _ := os.ReadFile(script)
+		rest, found := withoutGuardLines(string(data))
+		if !found {
+			fmt.Fprintf(w, "No gitme guard in %s\n", script)
+			return nil
+		}
+		if readOnlySkip("remove gitme verify from %s", script) {
+			return nil
+		}
+		if err := os.WriteFile(script, []byte(rest), 0755); err != nil {
+			return fmt.Errorf("writing %s: %w", script, err)
+		}
+		fmt.Fprintf(w, "%s Removed the gitme guard from %s\n", SuccessStyle.Render("✓"), script)
+		return nil
+	}
+
+	for _, p := range []string{path, path + ".legacy"} {
+		data, err := os.ReadFile(p)
+		if err != nil || !strings.Contains(string(data), guardHookMarker) {
+			continue
+		}
+		if readOnlySkip("remove the pre-commit hook at %s", p) {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("removing pre-commit hook: %w", err)
+		}
+		fmt.Fprintf(w, "%s Removed the gitme guard: %s\n", SuccessStyle.Render("✓"), p)
+		return nil
+	}
+	fmt.Fprintln(w, "No gitme guard in this repo.")
+	return nil
+}
+
+// huskyScript returns the pre-commit script husky runs when it manages the
+// hooks directory of the hook at path: .husky/pre-commit, whether
+// core.hooksPath points at .husky (husky 4 to 8) or at .husky/_ (husky 9)
+func huskyScript(path string) (string, bool) {
+	dir := filepath.Dir(path)
+	if filepath.Base(dir) == "_" {
+		dir = filepath.Dir(dir)
+	}
+	if filepath.Base(dir) != ".husky" {
+		return "", false
+	}
+	return filepath.Join(dir, "pre-commit"), true
+}
+
+// withoutGuardLines removes the lines gitme guard install added to a husky
+// script, from the marker to the end of the if block, and reports whether
+// there were any
+func withoutGuardLines(content string) (string, bool) {
+	lines := strings.SplitAfter(content, "\n")
+	begin := slices.IndexFunc(lines, func(l string) bool { return strings.TrimSpace(l) == guardHookMarker })
+	if begin < 0 {
+		return content, false
+	}
+	end := slices.IndexFunc(lines[begin:], func(l string) bool {
+		l = strings.TrimSpace(l)
+		return l == "fi" || strings.HasSuffix(l, "; fi")
+	})
+	if end < 0 {
+		return content, false
+	}
+	return strings.Join(slices.Delete(lines, begin, begin+end+1), ""), true
+}
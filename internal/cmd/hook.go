@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+const hookUsage = "gitme hook <install|uninstall> [bash|zsh|fish|powershell] [--print] | gitme hook <git-install|git-uninstall>"
+
+// Lines around the block gitme hook install appends to a shell rc file
+const (
+	hookBegin = "# >>> gitme hook >>>"
+	hookEnd   = "# <<< gitme hook <<<"
+)
+
+// hookScripts run gitme auto --quiet whenever the working directory changes
+var hookScripts = map[string]string{
+	"bash": `_gitme_hook() {
+  if [ "$PWD" != "${_GITME_PWD:-}" ]; then
+    _GITME_PWD=$PWD
+    command gitme auto --quiet
+  fi
+}
+case ";${PROMPT_COMMAND:-};" in
+  *";_gitme_hook;"*) ;;
+  *) PROMPT_COMMAND="_gitme_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+esac
+`,
+	"zsh": `_gitme_hook() { command gitme auto --quiet; }
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd _gitme_hook
+_gitme_hook
+`,
+	"fish": `function _gitme_hook --on-variable PWD
+    command gitme auto --quiet
+end
+_gitme_hook
+`,
+	"powershell": `$global:_GitmePwd = $null
+$global:_GitmePrompt = $function:prompt
+function global:prompt {
+    if ($PWD.Path -ne $global:_GitmePwd) {
+        $global:_GitmePwd = $PWD.Path
+        gitme auto --quiet
+    }
+    & $global:_GitmePrompt
+}
+`,
+}
+
+// hookLoaders are what rc files run to load the hook, so it stays current
+// with the installed gitme
+var hookLoaders = map[string]string{
+	"bash":       `eval "$(gitme hook install bash --print)"`,
+	"zsh":        `eval "$(gitme hook install zsh --print)"`,
+	"fish":       `gitme hook install fish --print | source`,
+	"powershell": `gitme hook install powershell --print | Out-String | Invoke-Expression`,
+}
+
+// Hook installs or removes the shell hook that runs gitme auto on every cd.
+// With --print the hook is printed instead, for dotfiles managed by hand.
+func Hook(w io.Writer, args []string) error {
+	positional := positionalArgs(args)
+	if len(positional) == 1 && positional[0] == "git-install" {
+		return gitHookInstall(w)
+	}
+	if len(positional) == 1 && positional[0] == "git-uninstall" {
+		return gitHookUninstall(w)
+	}
+	if len(positional) < 1 || len(positional) > 2 || positional[0] != "install" && positional[0] != "uninstall" {
+		return usageErr(hookUsage)
+	}
+	shell := defaultShell()
+	if len(positional) == 2 {
+		shell = positional[1]
+	}
+	script, ok := hookScripts[shell]
+	if !ok {
+		return fmt.Errorf("unsupported shell: %s (use bash, zsh, fish or powershell)", shell)
+	}
+	if hasFlag(args, "--print") {
+		if positional[0] == "uninstall" {
+			return usageErr(hookUsage)
+		}
+		fmt.Fprint(w, script)
+		return nil
+	}
+	rc, err := shellRCFile(shell)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(rc)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %w", rc, err)
+	}
+	rest, installed := withoutHookBlock(string(data))
+
+	if positional[0] == "uninstall" {
+		if !installed {
+			fmt.Fprintf(w, "No gitme hook in %s\n", rc)
+			return nil
+		}
+		if readOnlySkip("remove the gitme hook from %s", rc) {
+			return nil
+		}
+		if err := os.WriteFile(rc, []byte(rest), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", rc, err)
+		}
+		fmt.Fprintf(w, "%s Removed the gitme hook from %s\n", SuccessStyle.Render("✓"), rc)
+		fmt.Fprintln(w, DimStyle.Render("It stays active in open shells until they restart"))
+		return nil
+	}
+
+	if installed {
+		fmt.Fprintf(w, "The gitme hook is already in %s\n", rc)
+		return nil
+	}
+	if readOnlySkip("add the gitme hook to %s", rc) {
+		return nil
+	}
+	if rest != "" && !strings.HasSuffix(rest, "\n") {
+		rest += "\n"
+	}
+	rest += hookBegin + "\n" + hookLoaders[shell] + "\n" + hookEnd + "\n"
+	if err := os.MkdirAll(filepath.Dir(rc), 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(rc), err)
+	}
+	if err := os.WriteFile(rc, []byte(rest), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", rc, err)
+	}
+	fmt.Fprintf(w, "%s Added the gitme hook to %s\n", SuccessStyle.Render("✓"), rc)
+	fmt.Fprintln(w, DimStyle.Render("Open a new shell to start switching identities on cd; undo with: gitme hook uninstall "+shell))
+	return nil
+}
+
+// defaultShell guesses the shell to hook from $SHELL
+func defaultShell() string {
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	shell := filepath.Base(os.Getenv("SHELL"))
+	if shell == "pwsh" {
+		return "powershell"
+	}
+	if _, ok := hookScripts[shell]; ok {
+		return shell
+	}
+	return "bash"
+}
+
+// shellRCFile returns the startup file of shell the hook goes in
+func shellRCFile(shell string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding home directory: %w", err)
+	}
+	switch shell {
+	case "zsh":
+		return filepath.Join(cmp.Or(os.Getenv("ZDOTDIR"), home), ".zshrc"), nil
+	case "fish":
+		return filepath.Join(cmp.Or(os.Getenv("XDG_CONFIG_HOME"), filepath.Join(home, ".config")), "fish", "config.fish"), nil
+	case "powershell":
+		if runtime.GOOS == "windows" {
+			return filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1"), nil
+		}
+		return filepath.Join(cmp.Or(os.Getenv("XDG_CONFIG_HOME"), filepath.Join(home, ".config")), "powershell", "Microsoft.PowerShell_profile.ps1"), nil
+	}
+	return filepath.Join(home, ".bashrc"), nil
+}
+
+// withoutHookBlock removes the block gitme hook install added from the rc
+// file content and reports whether there was one
+func withoutHookBlock(content string) (string, bool) {
+	lines := strings.SplitAfter(content, "\n")
+	begin := slices.IndexFunc(lines, func(l string) bool { return strings.TrimSpace(l) == hookBegin })
+	if begin < 0 {
+		return content, false
+	}
+	end := slices.IndexFunc(lines[begin:], func(l string) bool { return strings.TrimSpace(l) == hookEnd })
+	if end < 0 {
+		return content, false
+	}
+	return strings.Join(slices.Delete(lines, begin, begin+end+1), ""), true
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/remoteurl"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+const defaultUsage = "gitme default [<host> <email|alias> | rm <host>]"
+
+// platformHosts are the hosts the platform names stand for in gitme default
+var platformHosts = map[string]string{
+	"github":    "github.com",
+	"gitlab":    "gitlab.com",
+	"bitbucket": "bitbucket.org",
+}
+
+// hostDefault is the default identity of a remote host
+type hostDefault struct {
+	Host  string `json:"host"`
+	Email string `json:"email"`
+}
+
+// Default shows or changes the identity repos use by the host of their
+// remote when no branch identity, .gitme file or rule applies
+func Default(w io.Writer, args []string) error {
+	positional := positionalArgs(args)
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	switch {
+	case len(positional) == 0:
+		defaults := []hostDefault{}
+		var table render.Table
+		table.Sep = " → "
+		for _, host := range slices.Sorted(maps.Keys(cfg.HostDefaults)) {
+			email := cfg.HostDefaults[host]
+			if id, ok := cfg.HostDefault(host); ok {
+				email = id.Email
+			}
+			defaults = append(defaults, hostDefault{Host: host, Email: email})
+			table.Rows = append(table.Rows, []string{host, email})
+		}
+		out := newRenderer(w)
+		if len(defaults) == 0 && out.Format() != render.JSON {
+			fmt.Fprintln(w, "No host defaults.")
+			fmt.Fprintln(w, DimStyle.Render("Add one with: gitme default github.com <email>"))
+			return nil
+		}
+		return out.Render(defaults, render.Header("Host defaults:"), table)
+	case len(positional) == 2 && (positional[0] == "rm" || positional[0] == "remove"):
+		host := normalizeHost(positional[1])
+		if readOnlySkip("remove the default identity of %s", host) {
+			return nil
+		}
+		if !cfg.RemoveHostDefault(host) {
+			return fmt.Errorf("no default identity for: %s", host)
+		}
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Fprintf(w, "%s Removed default of %s\n", SuccessStyle.Render("✓"), host)
+		return nil
+	case len(positional) == 2:
+		host := normalizeHost(positional[0])
+		if host == "" || strings.ContainsAny(host, "/ ") {
+			return fmt.Errorf("not a host: %s (e.g. github.com)", positional[0])
+		}
+		id := resolveIdentity(cfg, positional[1])
+		if id == nil {
+			return fmt.Errorf("identity not found: %s", positional[1])
+		}
+		if readOnlySkip("make %s the default identity of %s", id.Email, host) {
+			return nil
+		}
+		cfg.SetHostDefault(host, *id)
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Fprintf(w, "%s Repos on %s default to %s\n", SuccessStyle.Render("✓"), host, id.String())
+		fmt.Fprintln(w, DimStyle.Render("Rules, branch identities and .gitme files still take precedence"))
+		return nil
+	default:
+		return usageErr(defaultUsage)
+	}
+}
+
+// normalizeHost lowercases a host given on the command line, dropping a
+// scheme and trailing slash, and maps platform names to their hosts
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if _, rest, ok := strings.Cut(host, "://"); ok {
+		host = rest
+	}
+	host = strings.TrimSuffix(host, "/")
+	if h, ok := platformHosts[host]; ok {
+		return h
+	}
+	return host
+}
+
+// hostDefaultIdentity returns the default identity of the host of the origin
+// remote of the repo at root, else of its first remote, and that host. An
+// ssh host alias is looked up as written and as the host it stands for.
+func hostDefaultIdentity(cfg *config.Config, root string) (*identity.Identity, string) {
+	if len(cfg.HostDefaults) == 0 {
+		return nil, ""
+	}
+	raw := gitConfigValue(root, "remote.origin.url")
+	if raw == "" {
+		cmd := exec.Command("git", "config", "--get-regexp", `^remote\..*\.url$`)
+		cmd.Dir = root
+		out, _ := cmd.Output()
+		line, _, _ := strings.Cut(string(out), "\n")
+		_, raw, _ = strings.Cut(line, " ")
+	}
+	u, err := remoteurl.Parse(raw)
+	if err != nil {
+		return nil, ""
+	}
+	if id, ok := cfg.HostDefault(u.Host); ok {
+		return &id, strings.ToLower(u.Host)
+	}
+	if u.Protocol == remoteurl.SSH {
+		if host := remoteurl.ResolveHost(u.Host); host != u.Host {
+			if id, ok := cfg.HostDefault(host); ok {
+				return &id, strings.ToLower(host)
+			}
+		}
+	}
+	return nil, ""
+}
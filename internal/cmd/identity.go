@@ -1,84 +1,259 @@
 package cmd
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/vosamoilenko/gitme/internal/config"
 	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/platform"
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
 )
 
 // List shows all known identities
-func List() {
+func List(w io.Writer, args []string) error {
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
 	}
 
 	// Scan for new identities
-	scanned, _ := identity.Scan()
-	cfg.UpdateIdentities(scanned)
-	cfg.Save()
+	if result, err := scanIdentities(nil); err == nil {
+		if err := settleRenames(w, cfg, cfg.UpdateIdentities(result.Identities), false); err != nil {
+			return err
+		}
+	}
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
 
-	if len(cfg.Identities) == 0 {
-		fmt.Println("No identities found.")
-		fmt.Println("Add one with: gitme add \"Your Name\" \"your@email.com\"")
-		return
+	out := newRenderer(w)
+	if len(cfg.Identities) == 0 && out.Format() != render.JSON {
+		fmt.Fprintln(w, "No identities found.")
+		fmt.Fprintln(w, "Add one with: gitme add \"Your Name\" \"your@email.com\"")
+		return nil
 	}
 
-	fmt.Println(HeaderStyle.Render("Identities:"))
-	fmt.Println()
-	for i, id := range cfg.Identities {
-		platformIcon := getPlatformIcon(id.Platform)
-		fmt.Printf("  %d. %s%s <%s>\n", i+1, platformIcon, id.Name, id.Email)
-		if len(id.Sources) > 0 {
-			for _, src := range id.Sources {
-				fmt.Printf("     %s\n", DimStyle.Render(src))
+	var statuses map[string]string
+	if hasFlag(args, "--remote") {
+		statuses = remoteStatuses(cfg)
+		// Usernames may have been filled in from the accounts
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+	}
+
+	data := identityListing{Identities: []identityStatus{}, Folders: make(map[string]string)}
+	for _, id := range cfg.Identities {
+		data.Identities = append(data.Identities, identityStatus{Identity: id, Remote: statuses[strings.ToLower(id.Email)]})
+	}
+	blocks := []render.Block{render.Header("Identities:"), identityList(out, cfg.Identities, statuses)}
+
+	if len(cfg.Folders) > 0 {
+		folders := make([]string, 0, len(cfg.Folders))
+		for folder := range cfg.Folders {
+			folders = append(folders, folder)
+		}
+		sort.Strings(folders)
+		var mappings render.List
+		for _, folder := range folders {
+			email := cfg.Folders[folder]
+			if id, ok := cfg.GetIdentityForFolder(folder); ok {
+				email = id.Email
 			}
-		} else if id.Source != "" {
-			fmt.Printf("     %s\n", DimStyle.Render(id.Source))
+			data.Folders[folder] = email
+			mappings = append(mappings, render.Item{Text: folder, Detail: []string{email}})
+		}
+		blocks = append(blocks, render.Line(""), render.Header("Folder mappings:"), mappings)
+	}
+
+	if stale := staleIdentities(cfg.Identities, time.Now().Add(-staleAfter)); len(stale) > 0 {
+		blocks = append(blocks, render.Line(""), render.Header("Unused for over a year:"))
+		var list render.List
+		for _, id := range stale {
+			data.Stale = append(data.Stale, id.Email)
+			list = append(list, render.Item{Text: id.String(), Detail: []string{"last used " + id.LastUsed.Format("2006-01-02")}})
+		}
+		blocks = append(blocks, list, render.Note("Remove with: gitme remove <email>"))
+	}
+	return out.Render(data, blocks...)
+}
+
+// staleAfter is how long an identity may go unused before list suggests
+// removing it
+const staleAfter = 365 * 24 * time.Hour
+
+// staleIdentities returns the identities last used before cutoff. Identities
+// never seen in use are left out: their use may predate tracking.
+func staleIdentities(ids []identity.Identity, cutoff time.Time) []identity.Identity {
+	var stale []identity.Identity
+	for _, id := range ids {
+		if !id.LastUsed.IsZero() && id.LastUsed.Before(cutoff) {
+			stale = append(stale, id)
+		}
+	}
+	return stale
+}
+
+// ago describes how long ago t was, e.g. "3 days ago"
+func ago(t time.Time) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit + " ago"
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	d := time.Since(t)
+	switch {
+	case d < 24*time.Hour:
+		return "today"
+	case d < 30*24*time.Hour:
+		return plural(int(d.Hours()/24), "day")
+	case d < 365*24*time.Hour:
+		return plural(int(d.Hours()/24/30), "month")
+	}
+	return plural(int(d.Hours()/24/365), "year")
+}
+
+// identityListing is the data behind gitme list
+type identityListing struct {
+	Identities []identityStatus  `json:"identities"`
+	Folders    map[string]string `json:"folders"`
+	Stale      []string          `json:"stale,omitempty"` // emails unused for over a year
+}
+
+// identityStatus is an identity with its verification status on its platform
+type identityStatus struct {
+	identity.Identity
+	Remote string `json:"remote,omitempty"`
+}
+
+// identityList renders numbered identities with their sources; statuses may be nil
+func identityList(out *render.Renderer, identities []identity.Identity, statuses map[string]string) render.List {
+	icons := iconSet()
+	list := make(render.List, 0, len(identities))
+	for i, id := range identities {
+		text := platformIcon(icons, id.Platform) + out.Color(id.DisplayColor(), id.String())
+		if id.Username != "" {
+			text += " " + out.Style(DimStyle, "@"+id.Username)
+		}
+		if statuses != nil {
+			text += " " + renderRemoteStatus(out, statuses[strings.ToLower(id.Email)])
+		}
+		if !id.LastUsed.IsZero() {
+			text += " " + out.Style(DimStyle, "used "+ago(id.LastUsed))
+		}
+		item := render.Item{Marker: fmt.Sprintf("%d.", i+1), Text: text, Detail: id.Sources}
+		if len(id.Sources) == 0 && id.Source != "" {
+			item.Detail = []string{id.Source}
+		}
+		list = append(list, item)
+	}
+	return list
+}
+
+// remoteStatuses fetches the accounts behind every stored token and returns a
+// status per lowercased identity email: verified, unverified (on an account
+// but not verified there), missing (on none of the accounts of its platform)
+// or unknown. Offline, identities without a cached account stay unknown.
+func remoteStatuses(cfg *config.Config) map[string]string {
+	var accounts []*platform.Account
+	skipped := 0
+	for i := range cfg.Identities {
+		id := &cfg.Identities[i]
+		token := storedToken(id.Email)
+		if token == "" {
+			continue
+		}
+		acct, err := fetchPlatformAccount(id.Platform, token)
+		if errors.Is(err, platform.ErrOffline) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", id.Email, err)
+			continue
+		}
+		if id.Username == "" {
+			id.Username = acct.Login
 		}
+		accounts = append(accounts, acct)
 	}
 
-	if len(cfg.FolderIdentities) > 0 {
-		fmt.Println()
-		fmt.Println(HeaderStyle.Render("Folder mappings:"))
-		fmt.Println()
-		for folder, id := range cfg.FolderIdentities {
-			fmt.Printf("  %s\n", folder)
-			fmt.Printf("     %s\n", DimStyle.Render(id.Email))
+	statuses := make(map[string]string)
+	for _, id := range cfg.Identities {
+		status := "unknown"
+		for _, acct := range accounts {
+			if acct.Platform != id.Platform {
+				continue
+			}
+			found, verified := acct.HasEmail(id.Email)
+			if found && verified {
+				status = "verified"
+				break
+			}
+			if found {
+				status = "unverified"
+			} else if status == "unknown" {
+				status = "missing"
+			}
 		}
+		statuses[strings.ToLower(id.Email)] = status
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: remote status of %d identities skipped: offline\n", skipped)
+	}
+	return statuses
+}
+
+func renderRemoteStatus(out *render.Renderer, status string) string {
+	switch status {
+	case "verified":
+		return out.Style(SuccessStyle, "[verified]")
+	case "unverified":
+		return out.Style(WarnStyle, "[unverified]")
+	case "missing":
+		return out.Style(WarnStyle, "[not on account]")
+	default:
+		return out.Style(DimStyle, "[unknown]")
 	}
 }
 
 // Add adds a new identity
-func Add() {
+func Add(w io.Writer, args []string) error {
 	var name, email string
 
-	if len(os.Args) >= 4 {
-		name = os.Args[2]
-		email = os.Args[3]
+	if len(args) >= 2 {
+		name = args[0]
+		email = args[1]
 	} else {
-		fmt.Print("Name: ")
-		fmt.Scanln(&name)
-		fmt.Print("Email: ")
-		fmt.Scanln(&email)
+		fmt.Fprint(w, "Name: ")
+		name = readLine()
+		fmt.Fprint(w, "Email: ")
+		email = readLine()
 	}
 
 	name = strings.TrimSpace(name)
 	email = strings.TrimSpace(email)
 
 	if name == "" || email == "" {
-		fmt.Fprintf(os.Stderr, "Both name and email are required\n")
-		os.Exit(1)
+		return fmt.Errorf("both name and email are required")
+	}
+	if !identity.ValidEmail(email) {
+		return fmt.Errorf("not a valid email: %s", email)
 	}
 
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
 	}
 
 	newId := identity.Identity{
@@ -89,43 +264,39 @@ func Add() {
 
 	for _, id := range cfg.Identities {
 		if id.Email == email {
-			fmt.Printf("Identity with email %s already exists\n", email)
-			os.Exit(1)
+			return fmt.Errorf("identity with email %s already exists", email)
 		}
 	}
 
 	cfg.Identities = append(cfg.Identities, newId)
 	if err := cfg.Save(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("saving config: %w", err)
 	}
 
-	fmt.Println(SuccessStyle.Render("Added:"), name, "<"+email+">")
+	fmt.Fprintln(w, SuccessStyle.Render("Added:"), name, "<"+email+">")
+	return nil
 }
 
 // Remove removes an identity
-func Remove() {
-	if len(os.Args) < 3 {
-		fmt.Fprintf(os.Stderr, "Usage: gitme remove <number|email>\n")
-		fmt.Fprintf(os.Stderr, "  gitme rm 3        Remove identity #3\n")
-		fmt.Fprintf(os.Stderr, "  gitme rm gmail    Remove by partial email match\n")
-		os.Exit(1)
+func Remove(w io.Writer, args []string) error {
+	if len(args) < 1 {
+		return usageErr("gitme remove <number|email>\n" +
+			"  gitme rm 3        Remove identity #3\n" +
+			"  gitme rm gmail    Remove by partial email match")
 	}
 
-	arg := os.Args[2]
+	arg := args[0]
 
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
 	}
 
 	var removeIndex int = -1
 	if idx, err := fmt.Sscanf(arg, "%d", &removeIndex); err == nil && idx == 1 {
 		removeIndex--
 		if removeIndex < 0 || removeIndex >= len(cfg.Identities) {
-			fmt.Fprintf(os.Stderr, "Invalid index: %s (valid: 1-%d)\n", arg, len(cfg.Identities))
-			os.Exit(1)
+			return fmt.Errorf("invalid index: %s (valid: 1-%d)", arg, len(cfg.Identities))
 		}
 	}
 
@@ -138,9 +309,7 @@ func Remove() {
 		}
 
 		if len(matches) == 0 {
-			fmt.Fprintf(os.Stderr, "No identity found matching: %s\n", arg)
-			fmt.Fprintf(os.Stderr, "Run 'gitme list' to see all identities\n")
-			os.Exit(1)
+			return fmt.Errorf("no identity found matching: %s (run 'gitme list' to see all identities)", arg)
 		}
 
 		if len(matches) > 1 {
@@ -150,50 +319,73 @@ func Remove() {
 				fmt.Fprintf(os.Stderr, "  %d. %s <%s>\n", idx+1, id.Name, id.Email)
 			}
 			fmt.Fprintf(os.Stderr, "\nUse the number to remove a specific one: gitme rm %d\n", matches[0]+1)
-			os.Exit(1)
+			return &ExitError{Code: 1}
 		}
 
 		removeIndex = matches[0]
 	}
 
 	removed := cfg.Identities[removeIndex]
-	cfg.Identities = append(cfg.Identities[:removeIndex], cfg.Identities[removeIndex+1:]...)
+	removeIdentities(cfg, map[string]bool{removed.Email: true})
 
-	fmt.Println(SuccessStyle.Render("Removed:"), removed.Name, "<"+removed.Email+">")
+	fmt.Fprintln(w, SuccessStyle.Render("Removed:"), removed.Name, "<"+removed.Email+">")
 	if removed.Source != "" {
-		fmt.Println(DimStyle.Render("  was at: " + removed.Source))
+		fmt.Fprintln(w, DimStyle.Render("  was at: "+removed.Source))
+	}
+	if removed.Source != "manual" {
+		fmt.Fprintln(w, DimStyle.Render("Scans will find it again; keep it out with: gitme ignore "+removed.Email))
 	}
 
 	if err := cfg.Save(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("saving config: %w", err)
 	}
+	return nil
 }
 
 // Scan rescans for git identities
-func Scan() {
-	fmt.Println("Scanning for git identities...")
+func Scan(w io.Writer, args []string) error {
+	if cp, _ := config.LoadScanCheckpoint(); cp != nil {
+		fmt.Fprintf(w, "Resuming interrupted scan (%d identities found so far)...\n", len(cp.Identities))
+	} else {
+		fmt.Fprintln(w, "Scanning for git identities...")
+	}
 
-	scanned, err := identity.Scan()
+	result, err := scanIdentities(nil)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error scanning: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("scanning: %w", err)
 	}
+	scanned := result.Identities
 
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
 	}
 
-	// Keep manual identities
+	// Keep manual identities and those accepted from commit history
 	manualIdentities := []identity.Identity{}
 	for _, id := range cfg.Identities {
-		if id.Source == "manual" {
+		if id.Source == "manual" || id.Source == identity.SourceHistory {
 			manualIdentities = append(manualIdentities, id)
 		}
 	}
 
+	stored := cfg.Identities
+	previous := make(map[string]identity.Identity)
+	for _, id := range cfg.Identities {
+		previous[strings.ToLower(id.Email)] = id
+	}
+	// Names drift; a stored one stays until the new one is confirmed
+	var renames []config.Rename
+	for i := range scanned {
+		if prev, ok := previous[strings.ToLower(scanned[i].Email)]; ok {
+			if r, ok := config.NameDrift(prev, scanned[i]); ok {
+				renames = append(renames, r)
+				scanned[i].Name = prev.Name
+			}
+			scanned[i].MergeUserFields(prev)
+		}
+	}
+
 	cfg.Identities = scanned
 	for _, id := range manualIdentities {
 		found := false
@@ -207,77 +399,296 @@ func Scan() {
 			cfg.Identities = append(cfg.Identities, id)
 		}
 	}
+	if err := settleRenames(w, cfg, renames, true); err != nil {
+		return err
+	}
 
 	if err := cfg.Save(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("saving config: %w", err)
 	}
+	repoIndex(cfg, hasFlag(args, "--full"))
 
-	fmt.Println(SuccessStyle.Render(fmt.Sprintf("Found %d identities", len(cfg.Identities))))
-	fmt.Println()
-	printIdentities(cfg.Identities)
+	if len(stored) == 0 {
+		err = printFoundIdentities(w, cfg.Identities)
+	} else {
+		err = printScanDiff(w, diffScan(stored, cfg.Identities, renames))
+	}
+	if err != nil {
+		return err
+	}
+	if hasFlag(args, "--verbose", "-v") {
+		if err := printScanTimings(w, result.Timings); err != nil {
+			return err
+		}
+	}
+	printThirdParty(w, result.ThirdParty, hasFlag(args, "--verbose", "-v"))
+	if hasFlag(args, "--history") {
+		if err := queueHistoryCandidates(w, cfg); err != nil {
+			return err
+		}
+	}
+	return reportSkipped(w, result.Skipped, hasFlag(args, "--strict"))
+}
+
+// printThirdParty notes the repos left out as third-party, listing them when
+// verbose
+func printThirdParty(w io.Writer, repos []string, verbose bool) {
+	out := newRenderer(w)
+	if len(repos) == 0 || out.Format() == render.JSON {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, out.Style(DimStyle, fmt.Sprintf("Left out %d third-party repos (owners never seen with your identities, or under reference_dirs)", len(repos))))
+	if verbose {
+		for _, repo := range repos {
+			fmt.Fprintf(w, "  %s\n", repo)
+		}
+	}
+}
+
+// printScanTimings shows how long each scanner took and what it found
+func printScanTimings(w io.Writer, timings []identity.Timing) error {
+	out := newRenderer(w)
+	if out.Format() == render.JSON {
+		return nil // the identities are the JSON output
+	}
+	var table render.Table
+	for _, t := range timings {
+		table.Rows = append(table.Rows, []string{string(t.Phase),
+			fmt.Sprintf("%d new", t.Found), out.Style(DimStyle, t.Duration.Round(time.Millisecond).String())})
+	}
+	return out.Render(nil, render.Header("Scanners:"), table)
+}
+
+// Commit history sampling: commits read per repo, and how often an author
+// must appear to be proposed
+const (
+	historySample     = 200
+	historyMinCommits = 3
+)
+
+// queueHistoryCandidates queues authors from recent commits that look like
+// the user but are not configured anywhere for gitme review
+func queueHistoryCandidates(w io.Writer, cfg *config.Config) error {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Sampling commit history...")
+	repos, _ := indexedRepos(cfg, nil)
+	candidates, skipped := identity.HistoryCandidates(repos, cfg.Identities, historySample, historyMinCommits, repowalk.GitEnv())
+	if settings, err := config.LoadSettings(); err == nil {
+		candidates = slices.DeleteFunc(candidates, func(c identity.Candidate) bool {
+			return slices.Contains(settings.Ignored, strings.ToLower(c.Email))
+		})
+	}
+	if len(candidates) == 0 {
+		fmt.Fprintln(w, DimStyle.Render("No candidate identities found in commit history"))
+		return reportSkipped(w, skipped, false)
+	}
+
+	queue, err := config.LoadCandidates()
+	if err != nil {
+		return fmt.Errorf("loading candidates: %w", err)
+	}
+	added := queue.Queue(candidates)
+	if err := queue.Save(); err != nil {
+		return fmt.Errorf("saving candidates: %w", err)
+	}
+	if added > 0 {
+		fmt.Fprintln(w, SuccessStyle.Render(fmt.Sprintf("Queued %d candidate identities from history", added)))
+	}
+	if len(queue.Pending) > 0 {
+		fmt.Fprintln(w, DimStyle.Render(fmt.Sprintf("%d pending; review them with: gitme review", len(queue.Pending))))
+	}
+	return reportSkipped(w, skipped, false)
 }
 
 // Reset deletes config and rescans
-func Reset() {
-	fmt.Println("Deleting config and rescanning...")
+func Reset(w io.Writer, args []string) error {
+	fmt.Fprintln(w, "Deleting config and rescanning...")
 
 	if err := config.Delete(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error deleting config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("deleting config: %w", err)
+	}
+	if err := config.ClearScanCheckpoint(); err != nil {
+		return fmt.Errorf("deleting scan checkpoint: %w", err)
 	}
 
-	scanned, err := identity.Scan()
+	result, err := scanIdentities(nil)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error scanning: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("scanning: %w", err)
 	}
 
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
 	}
 
-	cfg.Identities = scanned
+	cfg.Identities = result.Identities
 	if err := cfg.Save(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("saving config: %w", err)
+	}
+
+	return printFoundIdentities(w, cfg.Identities)
+}
+
+// Username shows or sets the platform username of an identity
+func Username(w io.Writer, args []string) error {
+	if len(args) < 1 {
+		return usageErr("gitme username <email|alias> [username]")
 	}
 
-	fmt.Println(SuccessStyle.Render(fmt.Sprintf("Found %d identities", len(cfg.Identities))))
-	fmt.Println()
-	for i, id := range cfg.Identities {
-		platformIcon := getPlatformIcon(id.Platform)
-		fmt.Printf("  %d. %s%s <%s>\n", i+1, platformIcon, id.Name, id.Email)
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
 	}
+
+	id := resolveIdentity(cfg, args[0])
+	if id == nil {
+		return fmt.Errorf("identity not found: %s", args[0])
+	}
+
+	if len(args) < 2 {
+		if id.Username == "" {
+			fmt.Fprintln(w, "No username set for", id.Email)
+			return nil
+		}
+		fmt.Fprintln(w, id.Username)
+		if noreply := id.NoreplyEmail(); noreply != "" {
+			fmt.Fprintln(w, DimStyle.Render("noreply: "+noreply))
+		}
+		return nil
+	}
+
+	id.Username = strings.TrimPrefix(args[1], "@")
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	fmt.Fprintln(w, SuccessStyle.Render("Set username:"), id.Email, "→", "@"+id.Username)
+	if noreply := id.NoreplyEmail(); noreply != "" {
+		fmt.Fprintln(w, DimStyle.Render("  noreply email: "+noreply))
+	}
+	return nil
 }
 
-// Helper functions
+// Color shows or sets the color an identity is shown in. "auto" goes back to
+// the color picked from its email.
+func Color(w io.Writer, args []string) error {
+	if len(args) < 1 {
+		return usageErr("gitme color <email|alias> [<0-255|#rrggbb>|auto]")
+	}
 
-func getPlatformIcon(platform identity.Platform) string {
-	switch platform {
-	case identity.PlatformGitHub:
-		return "[GitHub] "
-	case identity.PlatformGitLab:
-		return "[GitLab] "
-	case identity.PlatformBitbucket:
-		return "[Bitbucket] "
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	id := resolveIdentity(cfg, args[0])
+	if id == nil {
+		return fmt.Errorf("identity not found: %s", args[0])
+	}
+
+	out := newRenderer(w)
+	if len(args) < 2 {
+		source := "chosen"
+		if id.Color == "" {
+			source = "auto"
+		}
+		fmt.Fprintln(w, out.Color(id.DisplayColor(), id.DisplayColor()+" ■"), DimStyle.Render("("+source+")"))
+		return nil
+	}
+
+	switch color := args[1]; {
+	case color == "auto":
+		id.Color = ""
+	case identity.ValidColor(color):
+		id.Color = color
 	default:
-		return ""
+		return fmt.Errorf("invalid color %q: use an ANSI code 0-255 or #rrggbb", color)
 	}
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	fmt.Fprintln(w, SuccessStyle.Render("Set color:"), out.Color(id.DisplayColor(), id.String()))
+	return nil
 }
 
-func printIdentities(identities []identity.Identity) {
-	for i, id := range identities {
-		platformIcon := getPlatformIcon(id.Platform)
-		fmt.Printf("  %d. %s%s <%s>\n", i+1, platformIcon, id.Name, id.Email)
-		if len(id.Sources) > 0 {
-			for _, src := range id.Sources {
-				fmt.Printf("     %s\n", DimStyle.Render(src))
-			}
-		} else if id.Source != "" {
-			fmt.Printf("     %s\n", DimStyle.Render(id.Source))
+// Helper functions
+
+// resolveIdentity finds an identity by alias or exact (case-insensitive) email
+func resolveIdentity(cfg *config.Config, nameOrEmail string) *identity.Identity {
+	ref := nameOrEmail
+	if aliases, err := config.LoadAliases(); err == nil {
+		ref = aliases.ResolveAlias(nameOrEmail)
+	}
+	return cfg.IdentityByRef(ref)
+}
+
+// iconSet returns the platform icon set chosen in the settings
+func iconSet() string {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return identity.IconsText
+	}
+	return cmp.Or(settings.Icons, identity.IconsText)
+}
+
+// platformIcon returns the icon of platform in set followed by a space, or ""
+func platformIcon(set string, platform identity.Platform) string {
+	if icon := platform.Icon(set); icon != "" {
+		return icon + " "
+	}
+	return ""
+}
+
+// scanIdentities runs a resumable scan: it continues an interrupted scan and
+// persists progress after every step. onProgress may be nil.
+func scanIdentities(onProgress func(*identity.Checkpoint)) (*identity.Checkpoint, error) {
+	return scanIdentitiesUntil(nil, onProgress)
+}
+
+// scanIdentitiesUntil is scanIdentities ending early, with
+// identity.ErrStopped, once stop is closed; the next scan resumes it
+func scanIdentitiesUntil(stop <-chan struct{}, onProgress func(*identity.Checkpoint)) (*identity.Checkpoint, error) {
+	cp, err := config.LoadScanCheckpoint()
+	if err != nil {
+		cp = nil // unreadable checkpoint, start over
+	}
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return nil, fmt.Errorf("loading settings: %w", err)
+	}
+	forgotten := forgottenMatcher(settings.Forgotten)
+	opts := identity.Options{ReferenceDirs: settings.ReferenceDirs, Exclude: settings.ScanExclude, Stop: stop, Progress: func(cp *identity.Checkpoint) {
+		config.SaveScanCheckpoint(cp)
+		if onProgress != nil {
+			cp.Identities, _ = forgetIdentities(cp.Identities, forgotten)
+			cp.Identities = ignoreIdentities(cp.Identities, settings.Ignored)
+			onProgress(cp)
 		}
+	}}
+	for _, name := range settings.DisabledScanners {
+		if !slices.Contains(identity.Phases, identity.Phase(name)) {
+			return nil, fmt.Errorf("unknown scanner in disabled_scanners: %s (scanners: %s)", name, scannerNames())
+		}
+		opts.Disabled = append(opts.Disabled, identity.Phase(name))
+	}
+	if cfg, err := config.Load(); err == nil {
+		opts.Usernames = identityUsernames(cfg)
 	}
+	scanned, err := identity.Resume(cp, opts)
+	if err != nil {
+		return nil, err
+	}
+	config.ClearScanCheckpoint()
+	scanned.Identities, _ = forgetIdentities(scanned.Identities, forgotten)
+	scanned.Identities = ignoreIdentities(scanned.Identities, settings.Ignored)
+	return scanned, nil
+}
+
+// printFoundIdentities reports the result of a scan
+func printFoundIdentities(w io.Writer, identities []identity.Identity) error {
+	out := newRenderer(w)
+	return out.Render(identities,
+		render.Line(out.Style(SuccessStyle, fmt.Sprintf("Found %d identities", len(identities)))),
+		render.Line(""),
+		identityList(out, identities, nil))
 }
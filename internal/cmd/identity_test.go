@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/platform"
+)
+
+func TestListRemoteReportsEmailsMissingFromTheAccount(t *testing.T) {
+	newSwitchRepo(t)
+	cfg, _ := config.Load()
+	for i := range cfg.Identities {
+		cfg.Identities[i].Platform = identity.PlatformGitHub
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("saving config: %v", err)
+	}
+	storedToken = func(string) string { return "token" }
+	fetchAccount = func(identity.Platform, string) (*platform.Account, error) {
+		return &platform.Account{Platform: identity.PlatformGitHub, Login: "me", Emails: []platform.Email{{Address: "me@corp.com"}}}, nil
+	}
+	t.Cleanup(func() { storedToken, fetchAccount = identityToken, platform.FetchAccount })
+
+	var out bytes.Buffer
+	if err := List(&out, []string{"--remote"}); err != nil {
+		t.Fatalf("List --remote failed: %v", err)
+	}
+	for _, want := range []string{"<me@corp.com> @me [unverified]", "<me@example.com> @me [not on account]"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("want %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRemoveByIndexAndEmail(t *testing.T) {
+	newSwitchRepo(t)
+
+	var out bytes.Buffer
+	if err := Remove(&out, []string{"2"}); err != nil {
+		t.Fatalf("remove by index failed: %v", err)
+	}
+	if err := Remove(&out, []string{"corp"}); err != nil {
+		t.Fatalf("remove by email failed: %v", err)
+	}
+	cfg, _ := config.Load()
+	if len(cfg.Identities) != 0 {
+		t.Fatalf("expected no identities left, got %+v", cfg.Identities)
+	}
+	if err := Remove(&out, []string{"missing"}); err == nil {
+		t.Fatalf("expected error for unknown identity")
+	}
+}
+
+func TestListSuggestsPruningStaleIdentities(t *testing.T) {
+	newSwitchRepo(t)
+	cfg, _ := config.Load()
+	cfg.MarkUsedAt("me@corp.com", time.Now().AddDate(-2, 0, 0))
+	cfg.MarkUsed("me@example.com")
+	cfg.Save()
+
+	var out bytes.Buffer
+	if err := List(&out, nil); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	stale := out.String()[strings.Index(out.String(), "Unused for over a year:"):]
+	if !strings.Contains(stale, "me@corp.com") || strings.Contains(stale, "me@example.com") {
+		t.Fatalf("expected only the work identity to be stale:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "used today") || !strings.Contains(out.String(), "used 2 years ago") {
+		t.Fatalf("expected last-used labels:\n%s", out.String())
+	}
+}
+
+func TestColorIsChosenOrStableFromEmail(t *testing.T) {
+	newSwitchRepo(t)
+
+	var out bytes.Buffer
+	if err := Color(&out, []string{"me@corp.com", "purple"}); err == nil {
+		t.Fatal("expected an error for a color name")
+	}
+	if err := Color(&out, []string{"me@corp.com", "#ff8800"}); err != nil {
+		t.Fatalf("color failed: %v", err)
+	}
+	cfg, _ := config.Load()
+	colors := identityColors(cfg.Identities)
+	if colors["me@corp.com"] != "#ff8800" {
+		t.Fatalf("expected the chosen color, got %q", colors["me@corp.com"])
+	}
+	auto := identity.Identity{Email: "Me@Example.com"}.DisplayColor()
+	if colors["me@example.com"] != auto {
+		t.Fatalf("expected the color picked from the email, got %q and %q", colors["me@example.com"], auto)
+	}
+
+	if err := Color(&out, []string{"me@corp.com", "auto"}); err != nil {
+		t.Fatalf("color auto failed: %v", err)
+	}
+	cfg, _ = config.Load()
+	if cfg.Identities[0].Color != "" {
+		t.Fatalf("expected auto to clear the color, got %q", cfg.Identities[0].Color)
+	}
+}
+
+func TestIconsSettingPicksPlatformMarkers(t *testing.T) {
+	newSwitchRepo(t)
+	ids := []identity.Identity{{Name: "Personal", Email: "me@example.com", Platform: identity.PlatformGitHub}}
+	out := newRenderer(nil)
+
+	if got := identityList(out, ids, nil)[0].Text; !strings.HasPrefix(got, "[GitHub] ") {
+		t.Fatalf("expected the text marker by default, got %q", got)
+	}
+	if err := Config(&bytes.Buffer{}, []string{"icons", "emoji"}); err != nil {
+		t.Fatalf("config icons failed: %v", err)
+	}
+	if got := identityList(out, ids, nil)[0].Text; !strings.HasPrefix(got, "🐙 ") {
+		t.Fatalf("expected the emoji marker, got %q", got)
+	}
+	if err := Config(&bytes.Buffer{}, []string{"icons", "none"}); err != nil {
+		t.Fatalf("config icons failed: %v", err)
+	}
+	if got := identityList(out, ids, nil)[0].Text; strings.Contains(got, "GitHub") || strings.HasPrefix(got, " ") {
+		t.Fatalf("expected no marker, got %q", got)
+	}
+	if err := Config(&bytes.Buffer{}, []string{"icons", "wingdings"}); err == nil {
+		t.Fatal("expected an error for an unknown icon set")
+	}
+}
+
+func TestScanDiffReportsWhatChanged(t *testing.T) {
+	previous := []identity.Identity{
+		{Name: "Work", Email: "me@corp.com", Sources: []string{"~/.gitconfig", "~/work/api"}},
+		{Name: "Old", Email: "old@example.com", Sources: []string{"~/old"}},
+		{Name: "Personal", Email: "me@example.com", Sources: []string{"~/code/site"}},
+	}
+	current := []identity.Identity{
+		{Name: "Work", Email: "me@corp.com", Sources: []string{"~/.gitconfig", "~/work/web"}},
+		{Name: "Personal", Email: "me@example.com", Sources: []string{"~/code/site"}},
+		{Name: "New", Email: "new@example.com", Sources: []string{"~/new"}},
+	}
+	renames := []config.Rename{{Email: "me@example.com", Name: "Personal", Scanned: "Me"}}
+
+	diff := diffScan(previous, current, renames)
+	if len(diff.Added) != 1 || diff.Added[0].Email != "new@example.com" {
+		t.Fatalf("expected new@example.com added, got %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Email != "old@example.com" {
+		t.Fatalf("expected old@example.com removed, got %+v", diff.Removed)
+	}
+	if len(diff.Sources) != 1 || diff.Sources[0].Email != "me@corp.com" ||
+		diff.Sources[0].Added[0] != "~/work/web" || diff.Sources[0].Removed[0] != "~/work/api" {
+		t.Fatalf("expected the sources of me@corp.com to change, got %+v", diff.Sources)
+	}
+
+	var buf bytes.Buffer
+	if err := printScanDiff(&buf, diff); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1 added, 1 removed, 1 renamed, 1 with changed sources", "Personal → Me", "+ ~/work/web", "- ~/work/api"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q in:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := printScanDiff(&buf, diffScan(current, current, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "nothing changed") {
+		t.Fatalf("expected no changes, got:\n%s", buf.String())
+	}
+}
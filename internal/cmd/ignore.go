@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+const ignoreUsage = "gitme ignore <email>|list|rm <email>"
+
+// Ignore manages the emails scans leave out. Ignoring an email also removes
+// its stored identity, which scans would otherwise bring back.
+func Ignore(w io.Writer, args []string) error {
+	if len(args) < 1 {
+		return usageErr(ignoreUsage)
+	}
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		out := newRenderer(w)
+		if len(settings.Ignored) == 0 && out.Format() != render.JSON {
+			fmt.Fprintln(w, "No ignored emails.")
+			fmt.Fprintln(w, DimStyle.Render("Ignore one with: gitme ignore <email>"))
+			return nil
+		}
+		ignored := []string{}
+		var list render.List
+		for _, email := range settings.Ignored {
+			ignored = append(ignored, email)
+			list = append(list, render.Item{Text: email})
+		}
+		return out.Render(ignored, render.Header("Ignored emails:"), list)
+
+	case "rm", "remove":
+		if len(args) != 2 {
+			return usageErr("gitme ignore rm <email>")
+		}
+		i := slices.Index(settings.Ignored, strings.ToLower(args[1]))
+		if i < 0 {
+			return fmt.Errorf("not ignored: %s", args[1])
+		}
+		settings.Ignored = slices.Delete(settings.Ignored, i, i+1)
+		if err := settings.Save(); err != nil {
+			return fmt.Errorf("saving settings: %w", err)
+		}
+		fmt.Fprintf(w, "%s Scans pick up %s again\n", SuccessStyle.Render("✓"), args[1])
+		fmt.Fprintln(w, DimStyle.Render("Run 'gitme scan' to find it"))
+		return nil
+	}
+
+	if len(args) != 1 || !strings.Contains(args[0], "@") {
+		return usageErr(ignoreUsage)
+	}
+	email := strings.ToLower(args[0])
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	removed := cfg.IdentityByRef(email)
+	if removed != nil {
+		removeIdentities(cfg, map[string]bool{removed.Email: true})
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+	}
+	if !slices.Contains(settings.Ignored, email) {
+		settings.Ignored = append(settings.Ignored, email)
+	}
+	if err := settings.Save(); err != nil {
+		return fmt.Errorf("saving settings: %w", err)
+	}
+
+	fmt.Fprintf(w, "%s Scans ignore %s\n", SuccessStyle.Render("✓"), email)
+	if removed != nil {
+		fmt.Fprintln(w, DimStyle.Render("  removed: "+removed.String()))
+	}
+	fmt.Fprintln(w, DimStyle.Render("Undo with: gitme ignore rm "+email))
+	return nil
+}
+
+// ignoreIdentities drops the identities whose email is in ignored
+func ignoreIdentities(identities []identity.Identity, ignored []string) []identity.Identity {
+	if len(ignored) == 0 {
+		return identities
+	}
+	return slices.DeleteFunc(identities, func(id identity.Identity) bool {
+		return slices.Contains(ignored, strings.ToLower(id.Email))
+	})
+}
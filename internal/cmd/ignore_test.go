@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+func TestIgnoredEmailsStayOutOfScans(t *testing.T) {
+	newSwitchRepo(t)
+	if err := Ignore(&bytes.Buffer{}, []string{"Me@Corp.com"}); err != nil {
+		t.Fatalf("ignore failed: %v", err)
+	}
+	cfg, _ := config.Load()
+	if cfg.IdentityByRef("me@corp.com") != nil {
+		t.Fatal("expected the ignored identity to be removed")
+	}
+	settings, _ := config.LoadSettings()
+	scanned := ignoreIdentities([]identity.Identity{{Email: "me@corp.com"}, {Email: "me@example.com"}}, settings.Ignored)
+	if len(scanned) != 1 || scanned[0].Email != "me@example.com" {
+		t.Fatalf("expected scans to leave out me@corp.com, got %+v", scanned)
+	}
+
+	var out bytes.Buffer
+	if err := Ignore(&out, []string{"list"}); err != nil || !strings.Contains(out.String(), "me@corp.com") {
+		t.Fatalf("expected me@corp.com listed, got %q (%v)", out.String(), err)
+	}
+	if err := Ignore(&bytes.Buffer{}, []string{"rm", "me@corp.com"}); err != nil {
+		t.Fatalf("ignore rm failed: %v", err)
+	}
+	if settings, _ := config.LoadSettings(); len(settings.Ignored) != 0 {
+		t.Fatalf("expected no ignored emails, got %v", settings.Ignored)
+	}
+	if err := Ignore(&bytes.Buffer{}, []string{"rm", "me@corp.com"}); err == nil {
+		t.Fatal("expected removing an email that is not ignored to fail")
+	}
+}
@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/remoteurl"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+const importUsage = "gitme import identities <file.csv|file.json>\n" +
+	"       gitme import from <git-profile|git-user-switch|gitconfig-profiles> [file]"
+
+// importRow is one identity in an import file; CSV files name these fields
+// in their header row
+type importRow struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Platform string `json:"platform"`
+	Username string `json:"username"`
+	Alias    string `json:"alias"`
+	SSHHost  string `json:"ssh_host"`
+	SSHKey   string `json:"ssh_key"`
+	Color    string `json:"color"`
+	// SigningKey is a gpg key ID or the path of an ssh public key; without a
+	// SigningFormat, paths are taken as ssh keys
+	SigningKey    string `json:"signing_key"`
+	SigningFormat string `json:"signing_format"`
+	// Rule is a path pattern whose repos use the identity, set by the tools
+	// gitme imports from
+	Rule string `json:"-"`
+}
+
+// importResult is what happened to one row of an import
+type importResult struct {
+	Email  string `json:"email"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// importSummary is what an import did
+type importSummary struct {
+	Added   []importResult `json:"added"`
+	Merged  []importResult `json:"merged"`
+	Skipped []importResult `json:"skipped"`
+	Rules   []importResult `json:"rules,omitempty"`
+}
+
+// Import adds identities from a CSV or JSON file, or from the profiles of
+// another identity switcher, merging them into the identities with the same
+// email and skipping ones with nothing new
+func Import(w io.Writer, args []string) error {
+	var rows []importRow
+	var err error
+	switch {
+	case len(args) == 2 && args[0] == "identities":
+		rows, err = readImportFile(args[1])
+	case len(args) >= 2 && len(args) <= 3 && args[0] == "from":
+		rows, err = readProfiles(args[1], args[2:])
+	default:
+		return usageErr(importUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	aliases, err := config.LoadAliases()
+	if err != nil {
+		return fmt.Errorf("loading aliases: %w", err)
+	}
+	rules, err := loadRules(cfg)
+	if err != nil {
+		return fmt.Errorf("loading rules: %w", err)
+	}
+
+	summary := importRows(cfg, aliases, rules, rows)
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	if err := aliases.Save(); err != nil {
+		return fmt.Errorf("saving aliases: %w", err)
+	}
+	if len(summary.Rules) > 0 {
+		if err := rules.Save(); err != nil {
+			return fmt.Errorf("saving rules: %w", err)
+		}
+	}
+
+	title := fmt.Sprintf("%s Imported %s: %d added, %d merged, %d skipped", SuccessStyle.Render("✓"),
+		filepath.Base(args[1]), len(summary.Added), len(summary.Merged), len(summary.Skipped))
+	if len(summary.Rules) > 0 {
+		title += fmt.Sprintf(", %d rules", len(summary.Rules))
+	}
+	blocks := []render.Block{render.Line(title)}
+	for _, group := range []struct {
+		title   string
+		results []importResult
+	}{{"Added:", summary.Added}, {"Merged:", summary.Merged}, {"Skipped:", summary.Skipped}, {"Rules:", summary.Rules}} {
+		if len(group.results) == 0 {
+			continue
+		}
+		var list render.List
+		for _, r := range group.results {
+			item := render.Item{Text: r.Email}
+			if r.Detail != "" {
+				item.Detail = []string{r.Detail}
+			}
+			list = append(list, item)
+		}
+		blocks = append(blocks, render.Line(""), render.Header(group.title), list)
+	}
+	return newRenderer(w).Render(summary, blocks...)
+}
+
+// importRows adds or merges rows into cfg, their aliases into aliases and
+// their rules into rules; rules for patterns that already have one are left
+// alone
+func importRows(cfg *config.Config, aliases *config.AliasConfig, rules *config.RulesConfig, rows []importRow) importSummary {
+	var summary importSummary
+	added, merged, skipped := &summary.Added, &summary.Merged, &summary.Skipped
+	for i, row := range rows {
+		row.Email = strings.TrimSpace(row.Email)
+		if row.Email == "" || !strings.Contains(row.Email, "@") {
+			*skipped = append(*skipped, importResult{Email: row.Email, Detail: fmt.Sprintf("row %d: no valid email", i+1)})
+			continue
+		}
+		if row.Color != "" && !identity.ValidColor(row.Color) {
+			*skipped = append(*skipped, importResult{Email: row.Email, Detail: "invalid color " + row.Color})
+			continue
+		}
+		imported, err := row.identity()
+		if err != nil {
+			*skipped = append(*skipped, importResult{Email: row.Email, Detail: err.Error()})
+			continue
+		}
+		aliasNote := ""
+		if row.Alias != "" {
+			if taken := aliases.Aliases[row.Alias]; taken != "" && !strings.EqualFold(taken, row.Email) {
+				aliasNote = fmt.Sprintf("alias %s already names %s", row.Alias, taken)
+			} else if taken == "" {
+				aliases.SetAlias(row.Alias, row.Email)
+				aliasNote = "alias " + row.Alias
+			}
+		}
+
+		existing := cfg.IdentityByRef(row.Email)
+		if existing == nil {
+			for j := range cfg.Identities {
+				if slices.ContainsFunc(cfg.Identities[j].AltEmails, func(e string) bool { return strings.EqualFold(e, row.Email) }) {
+					existing = &cfg.Identities[j]
+				}
+			}
+		}
+		switch {
+		case existing == nil:
+			if imported.Name == "" {
+				imported.Name, _, _ = strings.Cut(imported.Email, "@")
+			}
+			imported.ID = identity.NewID()
+			cfg.Identities = append(cfg.Identities, imported)
+			existing = &cfg.Identities[len(cfg.Identities)-1]
+			*added = append(*added, importResult{Email: imported.Email, Detail: aliasNote})
+		default:
+			filled := fillIdentity(existing, imported)
+			if len(filled) == 0 && !strings.HasPrefix(aliasNote, "alias ") {
+				detail := "already known"
+				if aliasNote != "" {
+					detail += "; " + aliasNote
+				}
+				*skipped = append(*skipped, importResult{Email: existing.Email, Detail: detail})
+				break
+			}
+			var notes []string
+			if len(filled) > 0 {
+				notes = append(notes, "filled "+strings.Join(filled, ", "))
+			}
+			if aliasNote != "" {
+				notes = append(notes, aliasNote)
+			}
+			*merged = append(*merged, importResult{Email: existing.Email, Detail: strings.Join(notes, "; ")})
+		}
+
+		if row.Rule == "" {
+			continue
+		}
+		if rule := findRule(rules, row.Rule); rule != nil {
+			if cfg.IdentityByRef(rule.Ref()) != existing {
+				summary.Rules = append(summary.Rules, importResult{Email: existing.Email,
+					Detail: fmt.Sprintf("%s already has a rule for %s, left alone", row.Rule, ruleTarget(cfg, *rule))})
+			}
+			continue
+		}
+		rules.AddRule(row.Rule, existing.ID)
+		summary.Rules = append(summary.Rules, importResult{Email: existing.Email, Detail: row.Rule})
+	}
+	return summary
+}
+
+// findRule returns the rule for exactly pattern, or nil
+func findRule(rules *config.RulesConfig, pattern string) *config.Rule {
+	for i := range rules.Rules {
+		if rules.Rules[i].Pattern == pattern {
+			return &rules.Rules[i]
+		}
+	}
+	return nil
+}
+
+// readImportFile reads the rows of a CSV file with a header row, or of a
+// JSON file holding a list of identities or, like identities.json, an
+// object with an "identities" list
+func readImportFile(path string) ([]importRow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading import file: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var rows []importRow
+		if err := json.Unmarshal(data, &rows); err == nil {
+			return rows, nil
+		}
+		var wrapped struct {
+			Identities []importRow `json:"identities"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		return wrapped.Identities, nil
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("%s: the header row has no email column", path)
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var rows []importRow
+	for _, record := range records[1:] {
+		rows = append(rows, importRow{
+			Name:          field(record, "name"),
+			Email:         field(record, "email"),
+			Platform:      field(record, "platform"),
+			Username:      field(record, "username"),
+			Alias:         field(record, "alias"),
+			SSHHost:       field(record, "ssh_host"),
+			SSHKey:        field(record, "ssh_key"),
+			Color:         field(record, "color"),
+			SigningKey:    field(record, "signing_key"),
+			SigningFormat: field(record, "signing_format"),
+		})
+	}
+	return rows, nil
+}
+
+// identity returns the identity a row describes; unknown platforms are left
+// for scans to detect. Key paths must exist, as for gitme key and gitme
+// signing.
+func (r importRow) identity() (identity.Identity, error) {
+	id := identity.Identity{
+		Name:     strings.TrimSpace(r.Name),
+		Email:    r.Email,
+		Source:   "manual",
+		Username: r.Username,
+		Color:    r.Color,
+	}
+	switch p := identity.Platform(strings.ToLower(r.Platform)); p {
+	case identity.PlatformGitHub, identity.PlatformGitLab, identity.PlatformBitbucket:
+		id.Platform = p
+	}
+	if r.SSHHost != "" {
+		id.Protocol, id.SSHHost = remoteurl.SSH, r.SSHHost
+	}
+	if r.SSHKey != "" {
+		key, err := sshKeyPath(r.SSHKey)
+		if err != nil {
+			return id, err
+		}
+		id.SSHKey = key
+	}
+	if r.SigningKey == "" {
+		return id, nil
+	}
+	format := strings.ToLower(r.SigningFormat)
+	if format == "" {
+		format = signingGPG
+		if strings.ContainsAny(r.SigningKey, `/\`) || strings.HasSuffix(r.SigningKey, ".pub") {
+			format = signingSSH
+		}
+	}
+	switch format {
+	case signingGPG:
+		id.SigningKey = r.SigningKey
+	case signingSSH:
+		key, err := sshKeyPath(r.SigningKey)
+		if err != nil {
+			return id, err
+		}
+		id.SigningKey = key
+	default:
+		return id, fmt.Errorf("invalid signing format %s", r.SigningFormat)
+	}
+	id.SigningFormat = format
+	return id, nil
+}
+
+// fillIdentity copies the fields id lacks from imported and returns the
+// import columns it copied; fields already set are kept
+func fillIdentity(id *identity.Identity, imported identity.Identity) []string {
+	var filled []string
+	fill := func(column string, field *string, value string) {
+		if *field == "" && value != "" {
+			*field = value
+			filled = append(filled, column)
+		}
+	}
+	fill("name", &id.Name, imported.Name)
+	fill("username", &id.Username, imported.Username)
+	fill("color", &id.Color, imported.Color)
+	fill("ssh_key", &id.SSHKey, imported.SSHKey)
+	if id.Platform == identity.PlatformUnknown && imported.Platform != identity.PlatformUnknown {
+		id.Platform = imported.Platform
+		filled = append(filled, "platform")
+	}
+	if id.SSHHost == "" && imported.SSHHost != "" {
+		id.Protocol, id.SSHHost = imported.Protocol, imported.SSHHost
+		filled = append(filled, "ssh_host")
+	}
+	if id.SigningKey == "" && imported.SigningKey != "" {
+		id.SigningKey, id.SigningFormat = imported.SigningKey, imported.SigningFormat
+		filled = append(filled, "signing_key")
+	}
+	return filled
+}
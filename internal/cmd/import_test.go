@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+func TestImportIdentitiesAddsMergesAndSkips(t *testing.T) {
+	newSwitchRepo(t)
+	file := filepath.Join(t.TempDir(), "team.csv")
+	csv := "email,name,platform,alias,ssh_host\n" +
+		"me@corp.com,,github,work,github-work\n" +
+		"me@example.com,Personal,,,\n" +
+		"new@corp.com,New,gitlab,new,\n" +
+		"not-an-email,Broken,,,\n"
+	if err := os.WriteFile(file, []byte(csv), 0644); err != nil {
+		t.Fatalf("writing csv: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Import(&out, []string{"identities", file}); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "1 added, 1 merged, 2 skipped") {
+		t.Fatalf("unexpected summary: %q", out.String())
+	}
+	cfg, _ := config.Load()
+	work := cfg.IdentityByRef("me@corp.com")
+	if work.Name != "Work" || work.Platform != identity.PlatformGitHub || work.SSHHost != "github-work" {
+		t.Fatalf("merged identity = %+v", work)
+	}
+	if added := cfg.IdentityByRef("new@corp.com"); added == nil || added.Platform != identity.PlatformGitLab || added.ID == "" {
+		t.Fatalf("added identity = %+v", added)
+	}
+	if aliases, _ := config.LoadAliases(); aliases.Aliases["work"] != "me@corp.com" || aliases.Aliases["new"] != "new@corp.com" {
+		t.Fatalf("aliases = %v", aliases.Aliases)
+	}
+
+	out.Reset()
+	if err := Import(&out, []string{"identities", file}); err != nil {
+		t.Fatalf("second import failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "0 added, 0 merged, 4 skipped") {
+		t.Fatalf("expected a repeated import to change nothing: %q", out.String())
+	}
+}
+
+func TestImportIdentitiesCarriesKeys(t *testing.T) {
+	newSwitchRepo(t)
+	sshDir := filepath.Join(os.Getenv("HOME"), ".ssh")
+	for _, name := range []string{"id_work", "id_work.pub"} {
+		if err := os.MkdirAll(sshDir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(sshDir, name), []byte("key"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(t.TempDir(), "team.csv")
+	csv := "email,ssh_key,signing_key\n" +
+		"me@corp.com,~/.ssh/id_work,~/.ssh/id_work.pub\n" +
+		"new@corp.com,,ABCDEF0123456789\n" +
+		"gone@corp.com,~/.ssh/id_gone,\n"
+	if err := os.WriteFile(file, []byte(csv), 0644); err != nil {
+		t.Fatalf("writing csv: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Import(&out, []string{"identities", file}); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	for _, want := range []string{"1 added, 1 merged, 1 skipped", "filled ssh_key, signing_key", "id_gone"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in the summary: %q", want, out.String())
+		}
+	}
+	cfg, _ := config.Load()
+	work := cfg.IdentityByRef("me@corp.com")
+	if work.SSHKey != filepath.Join(sshDir, "id_work") || work.SigningKey != filepath.Join(sshDir, "id_work.pub") || work.SigningFormat != signingSSH {
+		t.Fatalf("merged identity = %+v", work)
+	}
+	if added := cfg.IdentityByRef("new@corp.com"); added == nil || added.SigningKey != "ABCDEF0123456789" || added.SigningFormat != signingGPG {
+		t.Fatalf("added identity = %+v", added)
+	}
+	if cfg.IdentityByRef("gone@corp.com") != nil {
+		t.Fatal("expected the row with a missing ssh key to be skipped")
+	}
+
+	// identities.json carries the signing format, which wins over the path guess
+	data := `{"identities": [{"email": "me@example.com", "signing_key": "~/.ssh/id_work.pub", "signing_format": "gpg"}]}`
+	file = filepath.Join(t.TempDir(), "identities.json")
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatalf("writing json: %v", err)
+	}
+	out.Reset()
+	if err := Import(&out, []string{"identities", file}); err != nil {
+		t.Fatalf("json import failed: %v", err)
+	}
+	cfg, _ = config.Load()
+	if personal := cfg.IdentityByRef("me@example.com"); personal.SigningKey != "~/.ssh/id_work.pub" || personal.SigningFormat != signingGPG {
+		t.Fatalf("personal identity = %+v", personal)
+	}
+}
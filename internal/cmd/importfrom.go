@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Identity switchers gitme imports profiles from
+const (
+	toolGitProfile        = "git-profile"
+	toolGitUserSwitch     = "git-user-switch"
+	toolGitconfigProfiles = "gitconfig-profiles"
+)
+
+// readProfiles reads the profiles of another identity switcher from its
+// config file, or from file when given
+func readProfiles(tool string, file []string) ([]importRow, error) {
+	home, _ := os.UserHomeDir()
+	var path string
+	var read func(string) ([]importRow, error)
+	switch tool {
+	case toolGitProfile:
+		path, read = filepath.Join(home, ".gitprofile"), readGitProfile
+	case toolGitUserSwitch:
+		path, read = gitUserSwitchConfig(home), readGitUserSwitch
+	case toolGitconfigProfiles:
+		path, read = filepath.Join(home, ".gitconfig"), readGitconfigProfiles
+	default:
+		return nil, usageErr(importUsage)
+	}
+	if len(file) > 0 {
+		path = file[0]
+	}
+	rows, err := read(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s profiles: %w", tool, err)
+	}
+	return rows, nil
+}
+
+// readGitProfile reads ~/.gitprofile of git-profile: profiles by name, each
+// a list of git config entries ({"key": "user.email", "value": ...})
+func readGitProfile(path string) ([]importRow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Profiles map[string][]struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"profiles"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(file.Profiles))
+	for name := range file.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var rows []importRow
+	for _, name := range names {
+		row := importRow{Alias: name}
+		for _, entry := range file.Profiles[name] {
+			switch strings.ToLower(entry.Key) {
+			case "user.name":
+				row.Name = entry.Value
+			case "user.email":
+				row.Email = entry.Value
+			case "credential.username":
+				row.Username = entry.Value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// gitUserSwitchConfig returns where git-user-switch keeps its users, the
+// config file of its Node.js conf store
+func gitUserSwitchConfig(home string) string {
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(home, "Library", "Preferences", "git-user-switch-nodejs", "config.json")
+	}
+	return filepath.Join(home, ".config", "git-user-switch-nodejs", "config.json")
+}
+
+// readGitUserSwitch reads the users git-user-switch stores, a "users" list
+// of names and emails
+func readGitUserSwitch(path string) ([]importRow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Users []struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		} `json:"users"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	var rows []importRow
+	for _, user := range file.Users {
+		rows = append(rows, importRow{Name: user.Name, Email: user.Email})
+	}
+	return rows, nil
+}
+
+// readGitconfigProfiles reads the profiles of a gitconfig split with
+// includeIf: each included file's user becomes an identity, and a gitdir
+// condition a rule for that directory. Other conditions import just the
+// identity.
+func readGitconfigProfiles(path string) ([]importRow, error) {
+	out, err := exec.Command("git", "config", "--file", path, "--get-regexp", `^includeif\..*\.path$`).Output()
+	if err != nil {
+		if _, statErr := os.Stat(path); statErr != nil {
+			return nil, statErr
+		}
+		return nil, nil // no includeIf sections
+	}
+	home, _ := os.UserHomeDir()
+	var rows []importRow
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		key, include, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		condition := strings.TrimSuffix(strings.TrimPrefix(key, "includeif."), ".path")
+		if rest, ok := strings.CutPrefix(include, "~/"); ok {
+			include = filepath.Join(home, rest)
+		} else if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		get := func(key string) string {
+			value, _ := exec.Command("git", "config", "--file", include, "--get", key).Output()
+			return strings.TrimSpace(string(value))
+		}
+		row := importRow{Name: get("user.name"), Email: get("user.email"), Username: get("credential.username")}
+		if row.Email == "" {
+			continue
+		}
+		row.Rule = gitdirPattern(condition)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// gitdirPattern turns an includeIf gitdir condition into a rule pattern:
+// gitdir:~/work/ becomes ~/work. Other conditions have none.
+func gitdirPattern(condition string) string {
+	dir, ok := strings.CutPrefix(condition, "gitdir:")
+	if !ok {
+		if dir, ok = strings.CutPrefix(condition, "gitdir/i:"); !ok {
+			return ""
+		}
+	}
+	dir = strings.TrimSuffix(dir, "**")
+	dir = strings.TrimSuffix(dir, "/")
+	dir = strings.TrimPrefix(dir, "**/")
+	if dir == "" || dir == "~" || strings.ContainsAny(dir, "*?[") {
+		return ""
+	}
+	return dir
+}
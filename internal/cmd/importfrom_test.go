@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+func TestImportFromGitconfigProfilesAddsRules(t *testing.T) {
+	newSwitchRepo(t)
+	home := os.Getenv("HOME")
+	files := map[string]string{
+		".gitconfig": "[includeIf \"gitdir:~/work/\"]\n\tpath = ~/.gitconfig-work\n" +
+			"[includeIf \"hasconfig:remote.*.url:https://github.com/oss/**\"]\n\tpath = .gitconfig-oss\n",
+		".gitconfig-work": "[user]\n\tname = Work\n\temail = me@corp.com\n",
+		".gitconfig-oss":  "[user]\n\tname = OSS\n\temail = oss@example.com\n",
+		".gitprofile":     `{"profiles": {"client": [{"key": "user.email", "value": "me@client.com"}, {"key": "user.name", "value": "Client"}]}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(home, name), []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Import(&out, []string{"from", "gitconfig-profiles"}); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "1 added, 0 merged, 1 skipped, 1 rules") {
+		t.Fatalf("unexpected summary: %q", out.String())
+	}
+	cfg, _ := config.Load()
+	rules, _ := config.LoadRules()
+	if rule := findRule(rules, "~/work"); rule == nil || cfg.IdentityByRef(rule.Ref()).Email != "me@corp.com" {
+		t.Fatalf("expected a rule for ~/work, got %+v", rules.Rules)
+	}
+	if cfg.IdentityByRef("oss@example.com") == nil {
+		t.Fatalf("expected the hasconfig profile to be imported without a rule")
+	}
+
+	if err := Import(&out, []string{"from", "git-profile"}); err != nil {
+		t.Fatalf("import from git-profile failed: %v", err)
+	}
+	if aliases, _ := config.LoadAliases(); aliases.Aliases["client"] != "me@client.com" {
+		t.Fatalf("expected the profile name as alias, got %v", aliases.Aliases)
+	}
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+const syncGitconfigUsage = "gitme sync-gitconfig [--dry-run|--remove]"
+
+// includeSection is an includeIf section gitme writes to the global git
+// config: repos matching Condition take the identity in Path
+type includeSection struct {
+	Condition string            `json:"condition"`
+	Path      string            `json:"path"`
+	Identity  identity.Identity `json:"identity"`
+}
+
+// SyncGitconfig writes the folder mappings and path rules as includeIf
+// sections of the global git config, each including a file with the config
+// of its identity, so git picks the identity natively instead of gitme
+// setting it in every repo
+func SyncGitconfig(w io.Writer, args []string) error {
+	if len(positionalArgs(args)) > 0 {
+		return usageErr(syncGitconfigUsage)
+	}
+	if hasFlag(args, "--remove") {
+		if readOnlySkip("remove the includeIf sections gitme wrote from the global git config") {
+			return nil
+		}
+		removed, err := removeIncludeSections()
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			fmt.Fprintln(w, "No includeIf sections written by gitme.")
+			return nil
+		}
+		fmt.Fprintf(w, "%s Removed %d includeIf sections from the global git config\n", SuccessStyle.Render("✓"), removed)
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	rules, err := config.LoadRules()
+	if err != nil {
+		return fmt.Errorf("loading rules: %w", err)
+	}
+	sections := includeSections(cfg, rules)
+
+	out := newRenderer(w)
+	if len(sections) == 0 && out.Format() != render.JSON {
+		fmt.Fprintln(w, "No folder mappings or path rules to write.")
+		fmt.Fprintln(w, DimStyle.Render("Map a folder with 'gitme set' or add a rule with 'gitme rule add'"))
+		return nil
+	}
+	if !hasFlag(args, "--dry-run") && !readOnlySkip("write %d includeIf sections to the global git config", len(sections)) {
+		if err := writeIncludeSections(sections); err != nil {
+			return err
+		}
+	}
+
+	header := fmt.Sprintf("Wrote %d includeIf sections to the global git config:", len(sections))
+	if hasFlag(args, "--dry-run") {
+		header = fmt.Sprintf("Would write %d includeIf sections to the global git config:", len(sections))
+	}
+	var list render.List
+	for _, s := range sections {
+		list = append(list, render.Item{Marker: out.Style(SuccessStyle, "+"), Text: s.Condition + " → " + s.Identity.String(), Detail: []string{s.Path}})
+	}
+	if sections == nil {
+		sections = []includeSection{}
+	}
+	return out.Render(sections, render.Header(header), list,
+		render.Note("Identity config set in a repo itself still wins; undo with: gitme sync-gitconfig --remove"))
+}
+
+// includeSections turns folder mappings and rules into includeIf sections,
+// parents before the directories in them so the more specific ones win. A
+// folder mapping wins over a rule for the same directory.
+func includeSections(cfg *config.Config, rules *config.RulesConfig) []includeSection {
+	home, _ := os.UserHomeDir()
+	byCondition := make(map[string]includeSection)
+	add := func(condition string, id *identity.Identity) {
+		if id == nil {
+			return
+		}
+		if _, ok := byCondition[condition]; ok {
+			return
+		}
+		byCondition[condition] = includeSection{
+			Condition: condition,
+			Path:      filepath.Join(config.Dir(), "includes", cmp.Or(id.ID, strings.ToLower(id.Email))+".gitconfig"),
+			Identity:  *id,
+		}
+	}
+	for folder, ref := range cfg.Folders {
+		add(gitdirCondition(folder, home), cfg.IdentityByRef(ref))
+	}
+	for _, rule := range rules.Rules {
+		add(gitdirCondition(rule.Pattern, home), resolveIdentity(cfg, rule.Ref()))
+	}
+
+	var sections []includeSection
+	for _, s := range byCondition {
+		sections = append(sections, s)
+	}
+	slices.SortFunc(sections, func(a, b includeSection) int {
+		return cmp.Or(cmp.Compare(strings.Count(a.Condition, "/"), strings.Count(b.Condition, "/")),
+			strings.Compare(a.Condition, b.Condition))
+	})
+	return sections
+}
+
+// gitdirCondition is the includeIf condition matching repos under dir:
+// absolute paths and ~/ paths as they are, and rule patterns such as
+// github.com/org wherever they appear in a path
+func gitdirCondition(dir, home string) string {
+	dir = filepath.ToSlash(strings.TrimSuffix(dir, "/"))
+	switch {
+	case strings.HasPrefix(dir, "~"):
+	case filepath.IsAbs(dir) || strings.HasPrefix(dir, "/"):
+		if rel, err := filepath.Rel(home, dir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			dir = "~/" + filepath.ToSlash(rel)
+		}
+	default:
+		dir = "**/" + dir
+	}
+	return "gitdir:" + dir + "/"
+}
+
+// identityConfig is the git config gitme sets for id
+func identityConfig(id identity.Identity) [][2]string {
+	set := [][2]string{{"user.name", id.Name}, {"user.email", id.Email}}
+	if id.Username != "" {
+		set = append(set, [2]string{"credential.username", id.Username})
+	}
+	if id.SSHKey != "" {
+		set = append(set, [2]string{"core.sshCommand", sshCommand(id.SSHKey)})
+	}
+	set = append(set, signingConfig(id)...)
+	profile := profileConfig(id)
+	for _, key := range profileKeys(id) {
+		for _, value := range profile[key] {
+			set = append(set, [2]string{key, value})
+		}
+	}
+	return set
+}
+
+// writeIncludeSections replaces the includeIf sections gitme wrote before,
+// and their include files, with sections
+func writeIncludeSections(sections []includeSection) error {
+	if _, err := removeIncludeSections(); err != nil {
+		return err
+	}
+	for _, s := range sections {
+		if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+			return fmt.Errorf("creating includes directory: %w", err)
+		}
+		if _, err := os.Stat(s.Path); os.IsNotExist(err) {
+			for _, kv := range identityConfig(s.Identity) {
+				if out, err := exec.Command("git", "config", "--file", s.Path, "--add", kv[0], kv[1]).CombinedOutput(); err != nil {
+					return fmt.Errorf("writing %s: %w: %s", s.Path, err, strings.TrimSpace(string(out)))
+				}
+			}
+		}
+		if out, err := exec.Command("git", "config", "--global", "--add", "includeIf."+s.Condition+".path", s.Path).CombinedOutput(); err != nil {
+			return fmt.Errorf("writing global git config: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// removeIncludeSections removes the includeIf sections of the global git
+// config that include files of gitme, and the files, and returns how many
+// sections it removed
+func removeIncludeSections() (int, error) {
+	dir := filepath.Join(config.Dir(), "includes")
+	out, _ := exec.Command("git", "config", "--global", "--get-regexp", `^includeif\..*\.path$`).Output()
+	removed := 0
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		key, path, ok := strings.Cut(line, " ")
+		if !ok || filepath.Dir(path) != dir {
+			continue
+		}
+		// git matches the section name as written, which --get-regexp lowercased
+		section := "includeIf." + strings.TrimSuffix(strings.TrimPrefix(key, "includeif."), ".path")
+		if out, err := exec.Command("git", "config", "--global", "--remove-section", section).CombinedOutput(); err != nil {
+			return removed, fmt.Errorf("removing %s from the global git config: %w: %s", section, err, strings.TrimSpace(string(out)))
+		}
+		removed++
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return removed, fmt.Errorf("removing include files: %w", err)
+	}
+	return removed, nil
+}
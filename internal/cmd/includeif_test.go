@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+func TestSyncGitconfigWritesIncludeIfSections(t *testing.T) {
+	repo := newSwitchRepo(t)
+	cfg, _ := config.Load()
+	cfg.SetIdentityForFolder(filepath.Dir(repo), *cfg.IdentityByRef("me@corp.com"))
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	rules := &config.RulesConfig{}
+	rules.AddRule("github.com/acme", cfg.IdentityByRef("me@example.com").ID)
+	if err := rules.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := SyncGitconfig(&bytes.Buffer{}, nil); err != nil {
+			t.Fatalf("sync-gitconfig failed: %v", err)
+		}
+	}
+	sections, _ := exec.Command("git", "config", "--global", "--get-regexp", `^includeif\.`).Output()
+	if lines := strings.Split(strings.TrimSpace(string(sections)), "\n"); len(lines) != 2 ||
+		!strings.HasPrefix(lines[0], "includeif.gitdir:~/work/.path ") || !strings.HasPrefix(lines[1], "includeif.gitdir:**/github.com/acme/.path ") {
+		t.Fatalf("expected one section per mapping and rule, got:\n%s", sections)
+	}
+	if got := mustGit(t, repo, "config", "user.email"); got != "me@corp.com" {
+		t.Fatalf("expected git to take the mapped identity from the include, got %q", got)
+	}
+
+	if err := SyncGitconfig(&bytes.Buffer{}, []string{"--remove"}); err != nil {
+		t.Fatalf("sync-gitconfig --remove failed: %v", err)
+	}
+	if out, _ := exec.Command("git", "config", "--global", "--get-regexp", `^includeif\.`).Output(); len(out) != 0 {
+		t.Fatalf("expected the sections removed, got:\n%s", out)
+	}
+}
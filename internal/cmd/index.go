@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/remoteurl"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+)
+
+// indexRepo reads the remotes and identity in effect of repo
+func indexRepo(repo string) config.IndexedRepo {
+	entry := config.IndexedRepo{Path: repo, Scanned: time.Now(), Modified: repoModified(repo)}
+	out, _ := repowalk.Git(repo, repowalk.GitEnv(), "config", "--get-regexp", `^(user\.(name|email)|remote\..*\.url)$`)
+	// Later scopes override earlier ones, so the last value wins
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch {
+		case key == "user.name":
+			entry.Name = value
+		case key == "user.email":
+			entry.Email = value
+		case strings.HasPrefix(key, "remote.") && strings.HasSuffix(key, ".url"):
+			if entry.Remotes == nil {
+				entry.Remotes = make(map[string]string)
+			}
+			entry.Remotes[strings.TrimSuffix(strings.TrimPrefix(key, "remote."), ".url")] = value
+		}
+	}
+
+	url, ok := entry.Remotes["origin"]
+	if !ok {
+		for _, u := range entry.Remotes {
+			url = u
+			break
+		}
+	}
+	if u, err := remoteurl.Parse(url); err == nil {
+		entry.Platform = identity.HostPlatform(u.Host)
+	}
+	return entry
+}
+
+// rebuildRepoIndex walks every mapped folder and the workspace dirs and
+// saves what it finds as the new repo index, leaving out forgotten paths and
+// third-party repos
+func rebuildRepoIndex(cfg *config.Config) *config.RepoIndex {
+	idx := &config.RepoIndex{Updated: time.Now(), Repos: []config.IndexedRepo{}, Dirs: make(map[string]config.IndexedDir)}
+	home, _ := os.UserHomeDir()
+	settings, err := config.LoadSettings()
+	if err != nil {
+		settings = &config.Settings{}
+	}
+	walker := repowalk.Walker{Visit: recordDir(idx), Exclude: settings.ScanExclude}
+	forgotten := forgottenMatcher(settings.Forgotten)
+
+	var mapped, workspace []string
+	for folder := range cfg.Folders {
+		walker.Walk(folder, 0, func(repo string) { mapped = append(mapped, repo) })
+	}
+	for _, dir := range identity.WorkspaceDirs(home) {
+		walker.Walk(dir, repoIndexDepth, func(repo string) { workspace = append(workspace, repo) })
+	}
+	// Mapped folders are the user's by definition
+	thirdParty := identity.ThirdParty(workspace, identityEmails(cfg), identityUsernames(cfg), settings.ReferenceDirs)
+	for _, repo := range append(mapped, workspace...) {
+		switch {
+		case forgotten(repo):
+		case thirdParty[repo] && !slices.Contains(mapped, repo):
+			idx.ThirdParty = append(idx.ThirdParty, repo)
+		default:
+			idx.Repos = append(idx.Repos, indexRepo(repo))
+		}
+	}
+	slices.SortFunc(idx.Repos, byRepoPath)
+	idx.Skipped = walker.Skipped
+	idx.Save() // a read-only config dir only costs the next command a walk
+	return idx
+}
+
+// repoIndexDepth is how many levels below each workspace dir are walked
+const repoIndexDepth = 4
+
+// refreshRepoIndex brings idx up to date without walking every tree again:
+// repos whose git config changed are read again and gone ones dropped, and
+// only the walked directories whose modification time changed are listed
+// for new repos. New repos are checked for being third-party like in a full
+// walk. It reports whether anything changed.
+func refreshRepoIndex(cfg *config.Config, idx *config.RepoIndex) bool {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		settings = &config.Settings{}
+	}
+	forgotten := forgottenMatcher(settings.Forgotten)
+	left := func(path string) bool {
+		return forgotten(path) || repowalk.Excluded(path, settings.ScanExclude)
+	}
+	changed := false
+
+	known := make(map[string]bool)
+	kept := idx.Repos[:0]
+	for _, entry := range idx.Repos {
+		modified := repoModified(entry.Path)
+		if modified.IsZero() || left(entry.Path) {
+			changed = true
+			continue
+		}
+		if !modified.Equal(entry.Modified) {
+			entry, changed = indexRepo(entry.Path), true
+		}
+		known[entry.Path] = true
+		kept = append(kept, entry)
+	}
+	idx.Repos = kept
+	for _, repo := range idx.ThirdParty {
+		known[repo] = true
+	}
+
+	var found []string
+	add := func(repo string) {
+		if !known[repo] && !left(repo) {
+			known[repo] = true
+			found = append(found, repo)
+		}
+	}
+	walker := repowalk.Walker{Visit: recordDir(idx), Exclude: settings.ScanExclude}
+	for _, dir := range slices.Sorted(maps.Keys(idx.Dirs)) {
+		walked := idx.Dirs[dir]
+		info, err := os.Stat(dir)
+		if err != nil || repowalk.Excluded(dir, settings.ScanExclude) {
+			delete(idx.Dirs, dir)
+			changed = true
+			continue
+		}
+		if info.ModTime().Equal(walked.Modified) {
+			continue
+		}
+		changed = true
+		idx.Dirs[dir] = config.IndexedDir{Modified: info.ModTime(), Depth: walked.Depth}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			add(dir)
+		}
+		if walked.Depth == 0 {
+			continue
+		}
+		for _, sub := range walker.Dirs(dir) {
+			if _, ok := idx.Dirs[sub]; !ok {
+				walker.Walk(sub, walked.Depth-1, add)
+			}
+		}
+	}
+	// Folders mapped and workspace dirs made since the last walk
+	home, _ := os.UserHomeDir()
+	for folder := range cfg.Folders {
+		if _, ok := idx.Dirs[folder]; !ok {
+			walker.Walk(folder, 0, add)
+		}
+	}
+	for _, dir := range identity.WorkspaceDirs(home) {
+		if _, ok := idx.Dirs[dir]; !ok {
+			walker.Walk(dir, repoIndexDepth, add)
+		}
+	}
+
+	if len(found) > 0 {
+		changed = true
+		var workspace []string
+		for _, repo := range found {
+			if _, ok := cfg.Folders[repo]; !ok {
+				workspace = append(workspace, repo)
+			}
+		}
+		thirdParty := identity.ThirdParty(workspace, identityEmails(cfg), identityUsernames(cfg), settings.ReferenceDirs)
+		for _, repo := range found {
+			if thirdParty[repo] {
+				idx.ThirdParty = append(idx.ThirdParty, repo)
+			} else {
+				idx.Repos = append(idx.Repos, indexRepo(repo))
+			}
+		}
+		slices.SortFunc(idx.Repos, byRepoPath)
+	}
+	for _, s := range walker.Skipped {
+		if !slices.Contains(idx.Skipped, s) {
+			idx.Skipped, changed = append(idx.Skipped, s), true
+		}
+	}
+	return changed
+}
+
+func byRepoPath(a, b config.IndexedRepo) int {
+	return strings.Compare(a.Path, b.Path)
+}
+
+// recordDir returns a walker's Visit that records each directory in idx
+// with its modification time
+func recordDir(idx *config.RepoIndex) func(dir string, depth int) {
+	return func(dir string, depth int) {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return
+		}
+		if walked, ok := idx.Dirs[dir]; ok && walked.Depth > depth {
+			depth = walked.Depth
+		}
+		idx.Dirs[dir] = config.IndexedDir{Modified: info.ModTime(), Depth: depth}
+	}
+}
+
+// repoModified returns the modification time of the git config of repo,
+// shared with its main repo for a worktree, the zero time when it is no repo
+// anymore
+func repoModified(repo string) time.Time {
+	git := repowalk.GitDir(repo)
+	if git == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(filepath.Join(git, "config"))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// repoIndex returns the saved repo index brought up to date, walking every
+// tree again when it is missing, from before directories were recorded, or
+// reindex is set
+func repoIndex(cfg *config.Config, reindex bool) *config.RepoIndex {
+	idx, err := config.LoadRepoIndex()
+	if err != nil || reindex || idx.Updated.IsZero() || idx.Dirs == nil {
+		return rebuildRepoIndex(cfg)
+	}
+	if refreshRepoIndex(cfg, idx) {
+		idx.Save()
+	}
+	return idx
+}
+
+// indexedRepos returns the paths of the indexed repos that still exist and
+// the paths the last walk could not read. --reindex in args forces a walk.
+func indexedRepos(cfg *config.Config, args []string) ([]string, []repowalk.Skipped) {
+	entries, skipped := indexedEntries(cfg, args)
+	repos := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		repos = append(repos, entry.Path)
+	}
+	return repos, skipped
+}
+
+// indexedEntries is indexedRepos with the remotes and platform indexed for
+// each repo
+func indexedEntries(cfg *config.Config, args []string) ([]config.IndexedRepo, []repowalk.Skipped) {
+	idx := repoIndex(cfg, hasFlag(args, "--reindex"))
+	var entries []config.IndexedRepo
+	for _, repo := range idx.Repos {
+		if _, err := os.Stat(filepath.Join(repo.Path, ".git")); err == nil {
+			entries = append(entries, repo)
+		}
+	}
+	return entries, idx.Skipped
+}
+
+// identityEmails returns every address of the configured identities
+func identityEmails(cfg *config.Config) []string {
+	var emails []string
+	for _, id := range cfg.Identities {
+		emails = append(emails, id.Email)
+		emails = append(emails, id.AltEmails...)
+	}
+	return emails
+}
+
+// identityUsernames returns the platform logins of the configured identities
+func identityUsernames(cfg *config.Config) []string {
+	var usernames []string
+	for _, id := range cfg.Identities {
+		if id.Username != "" {
+			usernames = append(usernames, id.Username)
+		}
+	}
+	return usernames
+}
+
+// indexClone adds a freshly cloned repo to the index so commands see it
+// before the next walk
+func indexClone(repo string) {
+	if idx, err := config.LoadRepoIndex(); err == nil && !idx.Updated.IsZero() {
+		idx.Put(indexRepo(repo))
+		idx.Save()
+	}
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+func TestRepoIndexIsRefreshedIncrementally(t *testing.T) {
+	repo := newSwitchRepo(t)
+	cfg, _ := config.Load()
+	dev := filepath.Join(os.Getenv("HOME"), "Developer")
+	initRepo := func(name string) string {
+		repo := filepath.Join(dev, name)
+		if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+			t.Fatalf("git init failed: %v (%s)", err, out)
+		}
+		return repo
+	}
+
+	api := initRepo("api")
+	mustGit(t, api, "config", "remote.origin.url", "git@github.com:acme/api.git")
+	mustGit(t, api, "config", "user.email", "me@corp.com")
+	if repos, _ := indexedRepos(cfg, nil); !slices.Equal(repos, []string{api, repo}) {
+		t.Fatalf("indexed repos = %v, want [%s %s]", repos, api, repo)
+	}
+	idx, _ := config.LoadRepoIndex()
+	if entry := idx.Repos[0]; entry.Platform != identity.PlatformGitHub || entry.Email != "me@corp.com" || entry.Remotes["origin"] == "" {
+		t.Fatalf("index entry = %+v", entry)
+	}
+
+	scanned := idx.Repos[0].Scanned
+	web := initRepo("web")
+	if repos, _ := indexedRepos(cfg, nil); !slices.Equal(repos, []string{api, web, repo}) {
+		t.Fatalf("indexed repos = %v, want the new repo found", repos)
+	}
+	idx, _ = config.LoadRepoIndex()
+	if !idx.Repos[0].Scanned.Equal(scanned) {
+		t.Fatalf("expected the unchanged repo not read again")
+	}
+
+	mustGit(t, web, "config", "user.email", "me@example.com")
+	indexedRepos(cfg, nil)
+	if idx, _ := config.LoadRepoIndex(); idx.Repos[1].Email != "me@example.com" {
+		t.Fatalf("index entry = %+v, want the changed config read again", idx.Repos[1])
+	}
+	if repos, _ := indexedRepos(cfg, []string{"--reindex"}); !slices.Equal(repos, []string{api, web, repo}) {
+		t.Fatalf("indexed repos after --reindex = %v", repos)
+	}
+
+	os.RemoveAll(api)
+	if repos, _ := indexedRepos(cfg, nil); !slices.Equal(repos, []string{web, repo}) {
+		t.Fatalf("indexed repos = %v, want the removed repo left out", repos)
+	}
+}
+
+func TestMixedListsEveryMixedRepo(t *testing.T) {
+	newSwitchRepo(t)
+	dev := filepath.Join(os.Getenv("HOME"), "Developer")
+	commitAs := func(name string, emails ...string) string {
+		repo := filepath.Join(dev, name)
+		if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+			t.Fatalf("git init failed: %v (%s)", err, out)
+		}
+		for _, email := range emails {
+			mustGit(t, repo, "-c", "user.name=A", "-c", "user.email="+email, "commit", "-q", "--allow-empty", "-m", email)
+		}
+		return repo
+	}
+	var want []string
+	for i := range 6 {
+		if i%2 == 0 {
+			want = append(want, commitAs(fmt.Sprint("mixed", i), "me@corp.com", "me@example.com"))
+		} else {
+			commitAs(fmt.Sprint("single", i), "me@corp.com")
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Mixed(&out, nil); err != nil {
+		t.Fatalf("mixed failed: %v", err)
+	}
+	for _, repo := range want {
+		if !strings.Contains(out.String(), repo) {
+			t.Fatalf("expected %s in the output:\n%s", repo, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "single") || !strings.Contains(out.String(), "3 of 7 repos have multiple identities") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := Mixed(&out, []string{"--max-commits", "1"}); err != nil {
+		t.Fatalf("mixed --max-commits failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "No repos with mixed identities found") {
+		t.Fatalf("expected only the last commit of each repo to be read:\n%s", out.String())
+	}
+}
+
+func TestMixedLabelsAuthorsThatResembleAnIdentity(t *testing.T) {
+	newSwitchRepo(t)
+	repo := filepath.Join(os.Getenv("HOME"), "Developer", "old")
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v (%s)", err, out)
+	}
+	for _, author := range [][2]string{{"Work", "work@old-corp.com"}, {"Bob", "bob@corp.com"}, {"Work", "me@corp.com"}} {
+		mustGit(t, repo, "-c", "user.name="+author[0], "-c", "user.email="+author[1], "commit", "-q", "--allow-empty", "-m", author[1])
+	}
+
+	var out bytes.Buffer
+	if err := Mixed(&out, nil); err != nil {
+		t.Fatalf("mixed failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Work <work@old-corp.com>") || !strings.Contains(out.String(), "probably me@corp.com: same name") {
+		t.Fatalf("expected the old address to be labelled as Work:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "bob@corp.com") {
+		t.Fatalf("expected an author like no identity to be left out:\n%s", out.String())
+	}
+	queue, _ := config.LoadCandidates()
+	if len(queue.Pending) != 1 || queue.Pending[0].Email != "work@old-corp.com" || queue.Pending[0].Like != "me@corp.com" {
+		t.Fatalf("expected the old address queued for review, got %+v", queue.Pending)
+	}
+}
+
+func TestAuditResolvesEachUnknownEmailOnce(t *testing.T) {
+	newSwitchRepo(t)
+	repo := filepath.Join(os.Getenv("HOME"), "Developer", "audited")
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v (%s)", err, out)
+	}
+	for _, author := range [][2]string{{"Bob", "bob@corp.com"}, {"Bob", "bob@corp.com"}, {"Work", "work@old-corp.com"}, {"Work", "me@corp.com"}} {
+		mustGit(t, repo, "-c", "user.name="+author[0], "-c", "user.email="+author[1], "commit", "-q", "--allow-empty", "-m", author[1])
+	}
+
+	// The address like Work comes first though Bob has more commits
+	Stdin, stdinReader = strings.NewReader("m\n\ni\n"), nil
+	t.Cleanup(func() { Stdin, stdinReader = os.Stdin, nil })
+	var out bytes.Buffer
+	if err := Audit(&out, []string{"emails", "--resolve"}); err != nil {
+		t.Fatalf("audit emails --resolve failed: %v\n%s", err, out.String())
+	}
+	cfg, _ := config.Load()
+	if alt := cfg.IdentityByRef("me@corp.com").AltEmails; !slices.Contains(alt, "work@old-corp.com") {
+		t.Fatalf("expected the old address mapped to Work, got %v\n%s", alt, out.String())
+	}
+	mailmap, _ := os.ReadFile(filepath.Join(config.Dir(), "mailmap"))
+	if !strings.Contains(string(mailmap), "Work <me@corp.com> <work@old-corp.com>") {
+		t.Fatalf("expected a mailmap entry, got %q", mailmap)
+	}
+	if got := mustGit(t, repo, "log", "-1", "--skip=1", "--format=%aE"); got != "me@corp.com" {
+		t.Fatalf("expected git to read the mailmap, got %q", got)
+	}
+	if settings, _ := config.LoadSettings(); !slices.Contains(settings.Ignored, "bob@corp.com") {
+		t.Fatalf("expected bob@corp.com ignored, got %v", settings.Ignored)
+	}
+
+	out.Reset()
+	if err := Audit(&out, []string{"emails"}); err != nil {
+		t.Fatalf("audit emails failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "No unknown emails") {
+		t.Fatalf("expected every email to be resolved:\n%s", out.String())
+	}
+}
+
+func TestMixedFixRewritesToTheExpectedIdentity(t *testing.T) {
+	newSwitchRepo(t)
+	repo := filepath.Join(os.Getenv("HOME"), "Developer", "mixed")
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v (%s)", err, out)
+	}
+	for _, email := range []string{"me@corp.com", "me@example.com"} {
+		mustGit(t, repo, "-c", "user.name=A", "-c", "user.email="+email, "commit", "-q", "--allow-empty", "-m", email)
+	}
+	t.Chdir(repo)
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+
+	Stdin, stdinReader = strings.NewReader("y\n"), nil
+	t.Cleanup(func() { Stdin, stdinReader = os.Stdin, nil })
+	var out bytes.Buffer
+	if err := Mixed(&out, []string{"--fix", "--include-protected"}); err != nil {
+		t.Fatalf("mixed --fix failed: %v", err)
+	}
+	if authors := mustGit(t, repo, "log", "--format=%ae"); strings.Contains(authors, "me@example.com") {
+		t.Fatalf("expected every commit to be by me@corp.com, got:\n%s\n%s", authors, out.String())
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+const insteadOfUsage = "gitme insteadof <email|alias> [add <url-prefix> [<rewrite>] | rm <url-prefix>]"
+
+// insteadOfRule is a URL rewrite of an identity
+type insteadOfRule struct {
+	Prefix  string `json:"prefix"`
+	Rewrite string `json:"rewrite"`
+}
+
+// InsteadOf shows or changes the URL rewrites switching a repo to an
+// identity sets as url.<rewrite>.insteadOf, so one clone or fetch URL
+// reaches the host alias the identity needs. The rewrite defaults to the
+// ssh host alias of the identity.
+func InsteadOf(w io.Writer, args []string) error {
+	positional := positionalArgs(args)
+	if len(positional) < 1 {
+		return usageErr(insteadOfUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	id := resolveIdentity(cfg, positional[0])
+	if id == nil {
+		return fmt.Errorf("identity not found: %s", positional[0])
+	}
+
+	switch {
+	case len(positional) == 1:
+		var rules []insteadOfRule
+		var table render.Table
+		for _, prefix := range slices.Sorted(maps.Keys(id.InsteadOf)) {
+			rules = append(rules, insteadOfRule{Prefix: prefix, Rewrite: id.InsteadOf[prefix]})
+			table.Rows = append(table.Rows, []string{prefix, "→ " + id.InsteadOf[prefix]})
+		}
+		out := newRenderer(w)
+		if len(rules) == 0 && out.Format() != render.JSON {
+			fmt.Fprintln(w, "No URL rewrites for", id.Email)
+			fmt.Fprintln(w, DimStyle.Render("Add one with: gitme insteadof "+id.Email+" add https://gitlab.company.com/ git@work-gitlab:"))
+			return nil
+		}
+		return out.Render(rules, render.Header("URL rewrites of "+id.String()+":"), table)
+	case (len(positional) == 3 || len(positional) == 4) && positional[1] == "add":
+		prefix := positional[2]
+		rewrite := ""
+		if len(positional) == 4 {
+			rewrite = positional[3]
+		} else if id.SSHHost != "" {
+			rewrite = "git@" + id.SSHHost + ":"
+		} else {
+			return fmt.Errorf("%s has no ssh host alias; give the rewrite, e.g. git@work-gitlab:", id.Email)
+		}
+		if readOnlySkip("rewrite %s to %s for %s", prefix, rewrite, id.Email) {
+			return nil
+		}
+		if id.InsteadOf == nil {
+			id.InsteadOf = make(map[string]string)
+		}
+		id.InsteadOf[prefix] = rewrite
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Fprintln(w, SuccessStyle.Render("Added URL rewrite:"), prefix, "→", rewrite)
+	case len(positional) == 3 && (positional[1] == "rm" || positional[1] == "remove"):
+		prefix := positional[2]
+		if _, ok := id.InsteadOf[prefix]; !ok {
+			return fmt.Errorf("%s has no rewrite of %s", id.Email, prefix)
+		}
+		if readOnlySkip("remove the rewrite of %s for %s", prefix, id.Email) {
+			return nil
+		}
+		delete(id.InsteadOf, prefix)
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Fprintln(w, SuccessStyle.Render("Removed URL rewrite:"), prefix)
+	default:
+		return usageErr(insteadOfUsage)
+	}
+	fmt.Fprintln(w, DimStyle.Render("Re-apply it to a repo with: gitme set "+id.Email))
+	return nil
+}
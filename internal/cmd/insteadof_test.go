@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+func TestInsteadOfRewritesFollowTheIdentity(t *testing.T) {
+	repo := newSwitchRepo(t)
+	cfg, _ := config.Load()
+	cfg.IdentityByRef("me@corp.com").SSHHost = "work-gitlab"
+	cfg.Save()
+	for _, args := range [][]string{
+		{"me@corp.com", "add", "https://gitlab.company.com/"},
+		{"me@corp.com", "add", "git@gitlab.company.com:", "git@work-gitlab:"},
+	} {
+		if err := InsteadOf(&bytes.Buffer{}, args); err != nil {
+			t.Fatalf("insteadof %v failed: %v", args, err)
+		}
+	}
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	got := mustGit(t, repo, "config", "--local", "--get-all", "url.git@work-gitlab:.insteadOf")
+	if got != "git@gitlab.company.com:\nhttps://gitlab.company.com/" {
+		t.Fatalf("expected both prefixes rewritten to the host alias, got %q", got)
+	}
+
+	if err := Set(&bytes.Buffer{}, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "--get-all", "url.git@work-gitlab:.insteadOf"); got != "" {
+		t.Fatalf("expected the rewrites to be removed, got %q", got)
+	}
+}
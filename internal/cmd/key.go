@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+const keyUsage = "gitme key <email|alias> [path|none]"
+
+// sshKeyOptions follows the key in the core.sshCommand gitme writes, so ssh
+// offers only that key; it also marks the command as gitme's
+const sshKeyOptions = " -o IdentitiesOnly=yes"
+
+// Key shows or sets the ssh key of an identity, which switching a repo to
+// it sets as core.sshCommand
+func Key(w io.Writer, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageErr(keyUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	id := resolveIdentity(cfg, args[0])
+	if id == nil {
+		return fmt.Errorf("identity not found: %s", args[0])
+	}
+
+	if len(args) < 2 {
+		if id.SSHKey == "" {
+			fmt.Fprintln(w, "No ssh key set for", id.Email)
+			return nil
+		}
+		fmt.Fprintln(w, id.SSHKey)
+		return nil
+	}
+
+	if args[1] == "none" {
+		id.SSHKey = ""
+	} else {
+		key, err := sshKeyPath(args[1])
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(key, ".pub") {
+			return fmt.Errorf("%s is a public key; give the private key next to it", args[1])
+		}
+		id.SSHKey = key
+	}
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	if id.SSHKey == "" {
+		fmt.Fprintln(w, SuccessStyle.Render("Cleared ssh key:"), id.Email)
+	} else {
+		fmt.Fprintln(w, SuccessStyle.Render("Set ssh key:"), id.Email, "→", id.SSHKey)
+	}
+	fmt.Fprintln(w, DimStyle.Render("Re-apply it to a repo with: gitme set "+id.Email))
+	return nil
+}
+
+// sshKeyPath makes the path of an ssh key absolute, expanding ~, and
+// checks the key exists
+func sshKeyPath(arg string) (string, error) {
+	path := arg
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, rest)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("reading ssh key: %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory, not an ssh key", arg)
+	}
+	return path, nil
+}
+
+// sshCommand is the core.sshCommand that makes git use key
+func sshCommand(key string) string {
+	if strings.ContainsAny(key, " \t'\"\\") {
+		key = "'" + strings.ReplaceAll(key, "'", `'\''`) + "'"
+	}
+	return "ssh -i " + key + sshKeyOptions
+}
+
+// gitmeSSHCommand reports whether command is one sshCommand wrote, as opposed
+// to one the user set
+func gitmeSSHCommand(command string) bool {
+	return strings.HasPrefix(command, "ssh -i ") && strings.HasSuffix(command, sshKeyOptions)
+}
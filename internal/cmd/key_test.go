@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+func TestIdentityKeysSetSSHCommand(t *testing.T) {
+	repo := newSwitchRepo(t)
+	key := filepath.Join(os.Getenv("HOME"), ".ssh", "id work")
+	if err := os.MkdirAll(filepath.Dir(key), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(key, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Key(&bytes.Buffer{}, []string{"me@corp.com", key + ".pub"}); err == nil {
+		t.Fatal("expected a public key to be rejected")
+	}
+	if err := Key(&bytes.Buffer{}, []string{"me@corp.com", "~/.ssh/id work"}); err != nil {
+		t.Fatalf("key failed: %v", err)
+	}
+
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	want := "ssh -i '" + key + "' -o IdentitiesOnly=yes"
+	if got := mustGit(t, repo, "config", "--local", "core.sshCommand"); got != want {
+		t.Fatalf("expected core.sshCommand %q, got %q", want, got)
+	}
+	if err := Set(&bytes.Buffer{}, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "core.sshCommand"); got != "" {
+		t.Fatalf("expected core.sshCommand to be removed, got %q", got)
+	}
+
+	// A command the user set stays
+	mustGit(t, repo, "config", "--local", "core.sshCommand", "ssh -F ~/.ssh/other")
+	if err := ApplyIdentity(repo, identity.Identity{Name: "Personal", Email: "me@example.com"}); err != nil {
+		t.Fatalf("ApplyIdentity failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "core.sshCommand"); got != "ssh -F ~/.ssh/other" {
+		t.Fatalf("expected the user's core.sshCommand to stay, got %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/platform"
+)
+
+const keysUsage = "gitme keys push <email|alias>"
+
+var (
+	// uploadSigningKey adds a signing key to a platform account
+	uploadSigningKey = platform.UploadSigningKey
+	// storedToken returns the API token stored for an identity
+	storedToken = identityToken
+)
+
+// Keys uploads the public signing key of an identity to the account of its
+// token, so commits it signs show as verified; a key the account has already
+// is left alone
+func Keys(w io.Writer, args []string) error {
+	if len(args) != 2 || args[0] != "push" {
+		return usageErr(keysUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	id := resolveIdentity(cfg, args[1])
+	if id == nil {
+		return fmt.Errorf("identity not found: %s", args[1])
+	}
+	pages, ok := platformSettings[id.Platform]
+	switch {
+	case !ok:
+		return fmt.Errorf("%s is not a GitHub or GitLab identity", id.Email)
+	case id.SigningKey == "":
+		return fmt.Errorf("%s has no signing key; set one with: gitme signing %s ssh|gpg <key>", id.Email, id.Email)
+	}
+	token := storedToken(id.Email)
+	if token == "" {
+		return fmt.Errorf("no token for %s; store one with: gitme token set %s", id.Email, id.Email)
+	}
+
+	key, err := signingKeyUpload(id)
+	if err != nil {
+		return err
+	}
+	acct, err := fetchPlatformAccount(id.Platform, token)
+	if err != nil {
+		return fmt.Errorf("fetching the account of %s: %w", id.Email, err)
+	}
+	keys, err := fetchKeys(id.Platform, token, acct.Login)
+	if err != nil {
+		return fmt.Errorf("listing the keys of %s: %w", acct.Login, err)
+	}
+	uploaded := localSigningKey(id)
+	if key.Format == signingSSH {
+		if slices.Contains(keys.SSHSigning, uploaded) {
+			fmt.Fprintln(w, SuccessStyle.Render("Already uploaded:"), "the signing key of", id.Email, "to", acct.Login)
+			return nil
+		}
+		if id.Platform == identity.PlatformGitLab && slices.Contains(keys.SSH, uploaded) {
+			return fmt.Errorf("%s has the key for authentication only; let it sign too at %s", acct.Login, pages.ssh)
+		}
+	} else if uploaded != "" && slices.ContainsFunc(keys.GPG, func(k string) bool {
+		return strings.HasSuffix(uploaded, k) || strings.HasSuffix(k, uploaded)
+	}) {
+		fmt.Fprintln(w, SuccessStyle.Render("Already uploaded:"), "the signing key of", id.Email, "to", acct.Login)
+		return nil
+	}
+
+	if readOnlySkip("upload the signing key of %s to %s", id.Email, acct.Login) {
+		return nil
+	}
+	err = uploadSigningKey(id.Platform, token, key)
+	var status *platform.StatusError
+	switch {
+	case errors.Is(err, platform.ErrKeyExists):
+		fmt.Fprintln(w, SuccessStyle.Render("Already uploaded:"), "the signing key of", id.Email, "to", acct.Login)
+		return nil
+	case errors.As(err, &status) && (status.Code == http.StatusUnauthorized || status.Code == http.StatusForbidden || status.Code == http.StatusNotFound):
+		return fmt.Errorf("uploading the signing key: %w (the token needs the %s scope)", err, keyScope(id.Platform, key.Format))
+	case err != nil:
+		return fmt.Errorf("uploading the signing key: %w", err)
+	}
+	fmt.Fprintln(w, SuccessStyle.Render("Uploaded signing key:"), id.Email, "→", acct.Login)
+	if found, verified := acct.HasEmail(id.Email); !found || !verified {
+		fmt.Fprintln(w, DimStyle.Render("Commits show as verified once "+id.Email+" is verified at "+pages.emails))
+	}
+	return nil
+}
+
+// signingKeyUpload reads the public half of the signing key of id: the ssh
+// public key, or the armored export of the gpg key
+func signingKeyUpload(id *identity.Identity) (platform.SigningKey, error) {
+	key := platform.SigningKey{Format: signingGPG, Title: id.Email + " (gitme)"}
+	if id.SigningFormat == signingSSH {
+		key.Format, key.Key = signingSSH, localSigningKey(id)
+		if key.Key == "" {
+			return key, fmt.Errorf("cannot read the public key of %s", id.SigningKey)
+		}
+		return key, nil
+	}
+	out, err := exec.Command("gpg", "--armor", "--export", id.SigningKey).Output()
+	if err != nil {
+		return key, fmt.Errorf("exporting gpg key %s: %w", id.SigningKey, err)
+	}
+	if key.Key = strings.TrimSpace(string(out)); key.Key == "" {
+		return key, fmt.Errorf("gpg has no public key %s", id.SigningKey)
+	}
+	return key, nil
+}
+
+// keyScope is the token scope adding a signing key of format needs on p
+func keyScope(p identity.Platform, format string) string {
+	switch {
+	case p == identity.PlatformGitLab:
+		return "api"
+	case format == signingSSH:
+		return "write:ssh_signing_key"
+	}
+	return "write:gpg_key"
+}
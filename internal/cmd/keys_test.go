@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/platform"
+)
+
+func TestKeysPushUploadsOnlyMissingSigningKeys(t *testing.T) {
+	repo := newSwitchRepo(t)
+	key := filepath.Join(repo, "signing.pub")
+	os.WriteFile(key, []byte("ssh-ed25519 AAAAC3Nza me@example.com\n"), 0644)
+	cfg, _ := config.Load()
+	personal := &cfg.Identities[1]
+	personal.Platform, personal.SigningFormat, personal.SigningKey = identity.PlatformGitHub, "ssh", key
+	cfg.Save()
+
+	storedToken = func(string) string { return "token" }
+	fetchAccount = func(identity.Platform, string) (*platform.Account, error) {
+		return &platform.Account{Platform: identity.PlatformGitHub, Login: "me",
+			Emails: []platform.Email{{Address: "me@example.com", Verified: true}}}, nil
+	}
+	keys := &platform.Keys{}
+	fetchKeys = func(identity.Platform, string, string) (*platform.Keys, error) { return keys, nil }
+	var pushed []platform.SigningKey
+	uploadSigningKey = func(_ identity.Platform, _ string, k platform.SigningKey) error {
+		pushed = append(pushed, k)
+		keys.SSHSigning = append(keys.SSHSigning, k.Key)
+		return nil
+	}
+	t.Cleanup(func() {
+		storedToken, fetchAccount, fetchKeys, uploadSigningKey = identityToken, platform.FetchAccount, platform.FetchKeys, platform.UploadSigningKey
+	})
+
+	var out bytes.Buffer
+	for range 2 {
+		if err := Keys(&out, []string{"push", "me@example.com"}); err != nil {
+			t.Fatalf("keys push failed: %v", err)
+		}
+	}
+	if len(pushed) != 1 || pushed[0].Format != "ssh" || pushed[0].Key != "ssh-ed25519 AAAAC3Nza" {
+		t.Errorf("expected the key uploaded once, got %+v", pushed)
+	}
+	if !strings.Contains(out.String(), "Uploaded signing key:") || !strings.Contains(out.String(), "Already uploaded:") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+	if err := Keys(&out, []string{"push", "me@corp.com"}); err == nil {
+		t.Error("expected an identity without a platform to be refused")
+	}
+}
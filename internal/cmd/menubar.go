@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+)
+
+// Menubar prints an xbar/SwiftBar plugin menu: the identity in effect, the
+// repos left on the wrong identity and actions that switch them
+func Menubar(w io.Writer, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating gitme: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("getting current directory: %w", err)
+	}
+	root, err := RepoRoot(cwd)
+	inRepo := err == nil
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	rules, err := loadRules(cfg)
+	if err != nil {
+		return fmt.Errorf("loading rules: %w", err)
+	}
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+
+	// Only look: the watcher or auto is what applies identities
+	readOnly := *settings
+	readOnly.AutoApply = false
+	var mismatches []Mismatch
+	repos, _ := indexedRepos(cfg, nil)
+	for _, m := range repowalk.Collect(repos, func(repo string) *Mismatch {
+		m, _, _ := autoRepo(io.Discard, repo, cfg, rules, &readOnly, false)
+		return m
+	}) {
+		if m != nil {
+			mismatches = append(mismatches, *m)
+		}
+	}
+
+	// switchAction runs gitme set in repo from the menu, on the config in use
+	// here rather than the one the plugin host would find
+	switchAction := func(repo, email string) string {
+		params := []string{"-C", repo, "set", email}
+		if config.DirOverridden() {
+			params = append([]string{"--config-dir", config.Dir()}, params...)
+		}
+		action := "shell=" + menubarParam(exe)
+		for i, p := range params {
+			action += fmt.Sprintf(" param%d=%s", i+1, menubarParam(p))
+		}
+		return action + " terminal=false refresh=true"
+	}
+
+	email := gitConfigValue(cwd, "user.email")
+	name := gitConfigValue(cwd, "user.name")
+
+	title := email
+	if title == "" {
+		title = "gitme"
+	}
+	if len(mismatches) > 0 {
+		title += fmt.Sprintf(" ⚠ %d", len(mismatches))
+	}
+	fmt.Fprintln(w, title)
+	fmt.Fprintln(w, "---")
+
+	where := "global"
+	if inRepo {
+		where = filepath.Base(root)
+	}
+	if email != "" {
+		fmt.Fprintf(w, "%s <%s> (%s) | color=gray\n", name, email, where)
+	} else {
+		fmt.Fprintln(w, "No identity configured | color=gray")
+	}
+
+	if len(mismatches) > 0 {
+		fmt.Fprintln(w, "---")
+		fmt.Fprintf(w, "%d repos with the wrong identity\n", len(mismatches))
+		for _, m := range mismatches {
+			fmt.Fprintf(w, "--%s: %s → %s | %s\n", filepath.Base(m.Repo), m.Current, m.Expected, switchAction(m.Repo, m.Expected))
+		}
+	}
+
+	if inRepo && len(cfg.Identities) > 0 {
+		fmt.Fprintln(w, "---")
+		fmt.Fprintf(w, "Switch %s to\n", filepath.Base(root))
+		for _, id := range cfg.Identities {
+			fmt.Fprintf(w, "--%s <%s> | %s\n", id.Name, id.Email, switchAction(root, id.Email))
+		}
+	}
+
+	fmt.Fprintln(w, "---")
+	fmt.Fprintln(w, "Refresh | refresh=true")
+	return nil
+}
+
+// menubarParam quotes a plugin action parameter when it contains spaces
+func menubarParam(value string) string {
+	if !strings.ContainsAny(value, " \"|") {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+func TestMenubarListsMismatchesWithSwitchActions(t *testing.T) {
+	repo := newSwitchRepo(t)
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(repo, ".gitme"), []byte("me@example.com\n"), 0644)
+
+	var out bytes.Buffer
+	if err := Menubar(&out, nil); err != nil {
+		t.Fatalf("menubar failed: %v", err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if lines[0] != "me@corp.com ⚠ 1" {
+		t.Fatalf("unexpected title %q", lines[0])
+	}
+	for _, want := range []string{
+		"--" + filepath.Base(repo) + ": me@corp.com → me@example.com | shell=",
+		"param1=--config-dir param2=" + config.Dir() + " param3=-C param4=" + repo + " param5=set param6=me@example.com terminal=false refresh=true",
+		"--Personal <me@example.com> | shell=",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("menu missing %q:\n%s", want, out.String())
+		}
+	}
+	if got := mustGit(t, repo, "config", "--local", "user.email"); got != "me@corp.com" {
+		t.Fatalf("menubar must not switch identities, got %q", got)
+	}
+}
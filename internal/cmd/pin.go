@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+)
+
+// Pin pins a repository so it is listed first
+func Pin(w io.Writer, args []string) error {
+	path, err := pinTarget(args)
+	if err != nil {
+		return err
+	}
+
+	pins, err := config.LoadPins()
+	if err != nil {
+		return fmt.Errorf("loading pins: %w", err)
+	}
+
+	if !pins.Pin(path) {
+		fmt.Fprintln(w, "Already pinned:", path)
+		return nil
+	}
+	if err := pins.Save(); err != nil {
+		return fmt.Errorf("saving pins: %w", err)
+	}
+	fmt.Fprintln(w, SuccessStyle.Render("Pinned:"), path)
+	return nil
+}
+
+// Unpin removes a pinned repository
+func Unpin(w io.Writer, args []string) error {
+	path, err := pinTarget(args)
+	if err != nil {
+		return err
+	}
+
+	pins, err := config.LoadPins()
+	if err != nil {
+		return fmt.Errorf("loading pins: %w", err)
+	}
+
+	if !pins.Unpin(path) {
+		return fmt.Errorf("not pinned: %s", path)
+	}
+	if err := pins.Save(); err != nil {
+		return fmt.Errorf("saving pins: %w", err)
+	}
+	fmt.Fprintln(w, SuccessStyle.Render("Unpinned:"), path)
+	return nil
+}
+
+// pinTarget resolves the repo root of the path argument (or cwd)
+func pinTarget(args []string) (string, error) {
+	path, _ := os.Getwd()
+	if len(args) >= 1 {
+		path, _ = filepath.Abs(args[0])
+	}
+	root, err := RepoRoot(path)
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %s", path)
+	}
+	return root, nil
+}
+
+// pinnedRepos resolves the identity each pinned repo uses
+func pinnedRepos(pins []string, globalIdentity string) []PinnedRepo {
+	repos := make([]PinnedRepo, 0, len(pins))
+	for _, path := range pins {
+		repo := PinnedRepo{Path: path, Identity: globalIdentity}
+		if email, name := parseGitConfig(filepath.Join(repowalk.GitDir(path), "config")); email != "" {
+			repo.Identity = fmt.Sprintf("%s <%s>", name, email)
+		}
+		if _, err := os.Stat(path); err != nil {
+			repo.Missing = true
+		}
+		repos = append(repos, repo)
+	}
+	return repos
+}
+
+// pinnedBlocks lists pinned repos with the identity each one uses
+func pinnedBlocks(out *render.Renderer, pinned []PinnedRepo, colors map[string]string) []render.Block {
+	list := make(render.List, 0, len(pinned))
+	for _, repo := range pinned {
+		ident := colorIdentity(out, colors, identityEmail(repo.Identity), repo.Identity)
+		if repo.Missing {
+			ident = "missing"
+		}
+		list = append(list, render.Item{Marker: "★", Text: repo.Path, Detail: []string{ident}})
+	}
+	return []render.Block{render.Header("Pinned:"), list, render.Line("")}
+}
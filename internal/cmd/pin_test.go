@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReposListsPinnedReposOnce(t *testing.T) {
+	repo := newSwitchRepo(t)
+	other := filepath.Join(filepath.Dir(repo), "other")
+	if out, err := exec.Command("git", "init", other).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v (%s)", err, out)
+	}
+	if err := Pin(&bytes.Buffer{}, []string{repo}); err != nil {
+		t.Fatalf("Pin failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Repos(&out, nil); err != nil {
+		t.Fatalf("Repos failed: %v", err)
+	}
+	pinned, all, ok := strings.Cut(out.String(), "All repositories:")
+	if !ok || !strings.Contains(pinned, repo) {
+		t.Fatalf("want %s in the pinned block, got:\n%s", repo, out.String())
+	}
+	if strings.Contains(all, "repo") || !strings.Contains(all, "other") {
+		t.Fatalf("want only the unpinned repo in the main list, got:\n%s", all)
+	}
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+const profileUsage = "gitme profile <email|alias> [set <key> <value> | unset <key>]"
+
+// profileMarker lists the profile keys gitme wrote to a repo's local config,
+// so switching to another identity removes only those
+const profileMarker = "gitme.profile"
+
+// profileEntry is a git config key of an identity's profile
+type profileEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Profile shows or changes the extra git config keys switching a repo to an
+// identity sets, e.g. credential.helper or url.<base>.insteadOf
+func Profile(w io.Writer, args []string) error {
+	positional := positionalArgs(args)
+	if len(positional) < 1 {
+		return usageErr(profileUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	id := resolveIdentity(cfg, positional[0])
+	if id == nil {
+		return fmt.Errorf("identity not found: %s", positional[0])
+	}
+
+	switch {
+	case len(positional) == 1:
+		var entries []profileEntry
+		var table render.Table
+		for _, key := range profileKeys(*id) {
+			entries = append(entries, profileEntry{Key: key, Value: id.Config[key]})
+			table.Rows = append(table.Rows, []string{key, id.Config[key]})
+		}
+		out := newRenderer(w)
+		if len(entries) == 0 && out.Format() != render.JSON {
+			fmt.Fprintln(w, "No extra git config for", id.Email)
+			fmt.Fprintln(w, DimStyle.Render("Add some with: gitme profile "+id.Email+" set <key> <value>"))
+			return nil
+		}
+		return out.Render(entries, render.Header("Git config of "+id.String()+":"), table)
+	case len(positional) == 4 && positional[1] == "set":
+		key := positional[2]
+		if err := checkProfileKey(key); err != nil {
+			return err
+		}
+		if readOnlySkip("set %s of %s to %s", key, id.Email, positional[3]) {
+			return nil
+		}
+		if id.Config == nil {
+			id.Config = make(map[string]string)
+		}
+		id.Config[key] = positional[3]
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Fprintln(w, SuccessStyle.Render("Set "+key+":"), id.Email, "→", positional[3])
+	case len(positional) == 3 && positional[1] == "unset":
+		key := positional[2]
+		if _, ok := id.Config[key]; !ok {
+			return fmt.Errorf("%s has no %s", id.Email, key)
+		}
+		if readOnlySkip("unset %s of %s", key, id.Email) {
+			return nil
+		}
+		delete(id.Config, key)
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Fprintln(w, SuccessStyle.Render("Unset "+key+":"), id.Email)
+	default:
+		return usageErr(profileUsage)
+	}
+	fmt.Fprintln(w, DimStyle.Render("Re-apply it to a repo with: gitme set "+id.Email))
+	return nil
+}
+
+// checkProfileKey rejects keys that are not git config keys, and those gitme
+// sets from other fields of an identity or for itself
+func checkProfileKey(key string) error {
+	section, name, ok := strings.Cut(key, ".")
+	if !ok || section == "" || name == "" || strings.HasSuffix(key, ".") || strings.ContainsAny(key, " \t\n=") {
+		return fmt.Errorf("not a git config key: %s (e.g. credential.helper)", key)
+	}
+	switch strings.ToLower(key) {
+	case "user.name", "user.email":
+		return fmt.Errorf("%s is the identity itself, not a profile key", key)
+	}
+	if strings.EqualFold(section, "gitme") {
+		return fmt.Errorf("%s is gitme's own config", key)
+	}
+	return nil
+}
+
+// profileConfig is the extra git config switching to id sets: its profile
+// and its url rewrites, by key. Keys can have several values.
+func profileConfig(id identity.Identity) map[string][]string {
+	set := make(map[string][]string)
+	for key, value := range id.Config {
+		set[key] = append(set[key], value)
+	}
+	for _, prefix := range slices.Sorted(maps.Keys(id.InsteadOf)) {
+		key := "url." + id.InsteadOf[prefix] + ".insteadOf"
+		set[key] = append(set[key], prefix)
+	}
+	return set
+}
+
+// profileKeys returns the keys of profileConfig, sorted
+func profileKeys(id identity.Identity) []string {
+	return slices.Sorted(maps.Keys(profileConfig(id)))
+}
+
+// appliedProfileKeys returns the profile keys gitme wrote to the repo at dir
+func appliedProfileKeys(dir string) []string {
+	return strings.Fields(localConfigValue(dir, profileMarker))
+}
+
+// removeStaleProfile removes the keys of the profile applied to the repo at
+// dir before that the profile of id lacks
+func removeStaleProfile(dir string, id identity.Identity) {
+	set := profileConfig(id)
+	for _, key := range appliedProfileKeys(dir) {
+		if _, ok := set[key]; !ok {
+			cmd := exec.Command("git", "config", "--local", "--unset-all", key)
+			cmd.Dir = dir
+			cmd.Run() // unset fails harmlessly when the key is absent
+		}
+	}
+}
+
+// applyProfile writes the profile of id to the local config of the repo at
+// dir and records its keys
+func applyProfile(dir string, id identity.Identity) error {
+	set := profileConfig(id)
+	keys := slices.Sorted(maps.Keys(set))
+	for _, key := range keys {
+		cmd := exec.Command("git", "config", "--local", "--unset-all", key)
+		cmd.Dir = dir
+		cmd.Run() // unset fails harmlessly when the key is absent
+		for _, value := range set[key] {
+			cmd := exec.Command("git", "config", "--local", "--add", key, value)
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("setting %s: %s", key, strings.TrimSpace(string(out)))
+			}
+		}
+	}
+	var cmd *exec.Cmd
+	if len(keys) > 0 {
+		cmd = exec.Command("git", "config", "--local", profileMarker, strings.Join(keys, " "))
+	} else {
+		cmd = exec.Command("git", "config", "--local", "--unset", profileMarker)
+	}
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil && len(keys) > 0 {
+		return fmt.Errorf("setting %s: %s", profileMarker, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// localConfigValues returns all values of key in the local config of the
+// repo at dir
+func localConfigValues(dir, key string) []string {
+	cmd := exec.Command("git", "config", "--local", "--get-all", key)
+	cmd.Dir = dir
+	out, _ := cmd.Output()
+	if s := strings.TrimSuffix(string(out), "\n"); s != "" {
+		return strings.Split(s, "\n")
+	}
+	return nil
+}
+
+// configSnapshot holds the values keys had in a repo's local config, nil for
+// keys that were not set
+type configSnapshot map[string][]string
+
+// snapshotConfig records the local values of keys in the repo at dir
+func snapshotConfig(dir string, keys []string) configSnapshot {
+	snapshot := make(configSnapshot)
+	for _, key := range keys {
+		snapshot[key] = localConfigValues(dir, key)
+	}
+	return snapshot
+}
+
+// restore puts the recorded values back into the repo at dir
+func (s configSnapshot) restore(dir string) error {
+	for key, values := range s {
+		cmd := exec.Command("git", "config", "--local", "--unset-all", key)
+		cmd.Dir = dir
+		cmd.Run() // unset fails harmlessly when the key is absent
+		for _, value := range values {
+			cmd := exec.Command("git", "config", "--local", "--add", key, value)
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("restoring %s: %s", key, strings.TrimSpace(string(out)))
+			}
+		}
+	}
+	return nil
+}
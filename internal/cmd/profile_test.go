@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+func TestProfileKeysAreAppliedAllOrNothing(t *testing.T) {
+	repo := newSwitchRepo(t)
+	for _, kv := range [][2]string{{"tag.gpgSign", "true"}, {"url.git@github-work:.insteadOf", "https://github.com/"}} {
+		if err := Profile(&bytes.Buffer{}, []string{"me@corp.com", "set", kv[0], kv[1]}); err != nil {
+			t.Fatalf("profile set failed: %v", err)
+		}
+	}
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "url.git@github-work:.insteadOf"); got != "https://github.com/" {
+		t.Fatalf("expected the insteadOf rewrite, got %q", got)
+	}
+
+	// A key git rejects leaves the config as it was
+	bad := identity.Identity{Name: "Personal", Email: "me@example.com", Config: map[string]string{"credential.helper": "store", "bad key": "x"}}
+	if err := ApplyIdentity(repo, bad); err == nil {
+		t.Fatalf("expected ApplyIdentity to fail on an invalid key")
+	}
+	for key, want := range map[string]string{"user.email": "me@corp.com", "tag.gpgSign": "true", "credential.helper": ""} {
+		if got := mustGit(t, repo, "config", "--local", key); got != want {
+			t.Fatalf("expected %s %q after the failed switch, got %q", key, want, got)
+		}
+	}
+
+	if err := Set(&bytes.Buffer{}, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	for _, key := range []string{"tag.gpgSign", "url.git@github-work:.insteadOf", profileMarker} {
+		if got := mustGit(t, repo, "config", "--local", key); got != "" {
+			t.Fatalf("expected %s to be removed, got %q", key, got)
+		}
+	}
+}
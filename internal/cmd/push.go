@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// trackedBranch is a local branch with an upstream on a remote
+type trackedBranch struct {
+	Name         string // local branch name
+	Remote       string // remote name, e.g. origin
+	RemoteRef    string // full ref on the remote, e.g. refs/heads/main
+	Upstream     string // remote-tracking ref, e.g. origin/main
+	UpstreamHash string // what Upstream pointed at when the branch was listed
+}
+
+// trackedBranches returns the local branches with an upstream, recording the
+// hash each upstream is at now. If only is non-empty, other branches are
+// ignored.
+func trackedBranches(repoPath string, only []string) ([]trackedBranch, error) {
+	cmd := exec.Command("git", "for-each-ref",
+		"--format=%(refname:short)|%(upstream:remotename)|%(upstream:remoteref)|%(upstream:short)",
+		"refs/heads")
+	cmd.Dir = repoPath
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []trackedBranch
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.Split(strings.TrimSpace(line), "|")
+		if len(parts) != 4 || parts[1] == "" || parts[2] == "" {
+			continue
+		}
+		b := trackedBranch{Name: parts[0], Remote: parts[1], RemoteRef: parts[2], Upstream: parts[3]}
+		if len(only) > 0 && !containsString(only, b.Name) {
+			continue
+		}
+		b.UpstreamHash = revParse(repoPath, b.Upstream)
+		result = append(result, b)
+	}
+	return result, nil
+}
+
+// branchesNeedingPush returns the tracked branches whose local tip differs
+// from the upstream hash recorded for them
+func branchesNeedingPush(repoPath string, tracked []trackedBranch) []trackedBranch {
+	var result []trackedBranch
+	for _, b := range tracked {
+		if revParse(repoPath, b.Name) != b.UpstreamHash {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+// offerForcePush walks the user through force-pushing rewritten branches and
+// verifies each remote tip afterwards. tracked is listed before the rewrite,
+// so pushes lease on the commits the remote had then.
+func offerForcePush(w io.Writer, repoPath string, tracked []trackedBranch) {
+	branches := branchesNeedingPush(repoPath, tracked)
+	if len(branches) == 0 {
+		fmt.Fprintln(w, DimStyle.Render("No tracked branches need a force push."))
+		return
+	}
+
+	fmt.Fprintln(w, HeaderStyle.Render("Branches needing force push:"))
+	fmt.Fprintln(w)
+	for _, b := range branches {
+		fmt.Fprintf(w, "  %s → %s\n", b.Name, b.Upstream)
+	}
+	fmt.Fprintln(w)
+
+	for _, b := range branches {
+		if !confirm(w, fmt.Sprintf("Force push %s to %s?", b.Name, b.Upstream)) {
+			fmt.Fprintln(w, DimStyle.Render("  skipped"))
+			continue
+		}
+
+		if readOnlySkip("force push %s to %s", b.Name, b.Upstream) {
+			continue
+		}
+		lease := "--force-with-lease=" + b.RemoteRef
+		if b.UpstreamHash != "" {
+			lease += ":" + b.UpstreamHash
+		}
+		push := exec.Command("git", "push", lease, b.Remote, b.Name+":"+b.RemoteRef)
+		push.Dir = repoPath
+		push.Stdout = w
+		push.Stderr = os.Stderr
+		if err := push.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "  %s push failed: %v\n", WarnStyle.Render("✗"), err)
+			continue
+		}
+
+		local := revParse(repoPath, b.Name)
+		remote := remoteTip(repoPath, b.Remote, b.RemoteRef)
+		if local != "" && local == remote {
+			fmt.Fprintf(w, "  %s %s is at %s\n", SuccessStyle.Render("✓"), b.Upstream, local[:10])
+		} else {
+			fmt.Fprintf(w, "  %s %s does not match local %s (remote: %s)\n",
+				WarnStyle.Render("⚠"), b.Upstream, b.Name, remote)
+		}
+	}
+}
+
+// revParse resolves a revision to its full hash, or "" if it does not exist
+func revParse(repoPath, rev string) string {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", rev)
+	cmd.Dir = repoPath
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// remoteTip asks the remote for the current hash of ref
+func remoteTip(repoPath, remote, ref string) string {
+	cmd := exec.Command("git", "ls-remote", remote, ref)
+	cmd.Dir = repoPath
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
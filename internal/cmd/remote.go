@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/remoteurl"
+)
+
+// Remote manages per-identity remote preferences and applies them to remotes
+func Remote(w io.Writer, args []string) error {
+	if len(args) < 1 {
+		return usageErr("gitme remote <prefer|fix>")
+	}
+	switch args[0] {
+	case "prefer":
+		return remotePrefer(w, args[1:])
+	case "fix":
+		return remoteFix(w, args[1:])
+	}
+	return usageErr("gitme remote <prefer|fix>")
+}
+
+// remotePrefer shows or sets the protocol and ssh host alias of an identity
+func remotePrefer(w io.Writer, args []string) error {
+	if len(args) < 1 {
+		return usageErr("gitme remote prefer <email|alias> [ssh [host-alias]|https|none]")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	id := resolveIdentity(cfg, args[0])
+	if id == nil {
+		return fmt.Errorf("identity not found: %s", args[0])
+	}
+
+	if len(args) < 2 {
+		if id.Protocol == "" {
+			fmt.Fprintln(w, "No remote preference set for", id.Email)
+			return nil
+		}
+		fmt.Fprintln(w, describePreference(id))
+		return nil
+	}
+
+	switch args[1] {
+	case remoteurl.SSH:
+		id.Protocol, id.SSHHost = remoteurl.SSH, ""
+		if len(args) > 2 {
+			id.SSHHost = args[2]
+		}
+	case remoteurl.HTTPS:
+		id.Protocol, id.SSHHost = remoteurl.HTTPS, ""
+	case "none":
+		id.Protocol, id.SSHHost = "", ""
+	default:
+		return usageErr("gitme remote prefer <email|alias> [ssh [host-alias]|https|none]")
+	}
+
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	if id.Protocol == "" {
+		fmt.Fprintln(w, SuccessStyle.Render("Cleared remote preference:"), id.Email)
+		return nil
+	}
+	fmt.Fprintln(w, SuccessStyle.Render("Set remote preference:"), id.Email, "→", describePreference(id))
+	return nil
+}
+
+func describePreference(id *identity.Identity) string {
+	if id.SSHHost != "" {
+		return id.Protocol + " via " + id.SSHHost
+	}
+	return id.Protocol
+}
+
+// remoteFix rewrites the remotes of the current repo to the preference of
+// the identity in effect there
+func remoteFix(w io.Writer, args []string) error {
+	root, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
+	cwd, _ := os.Getwd()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	var id *identity.Identity
+	if mapped, ok := mappedIdentity(cfg, root, cwd); ok {
+		id = resolveIdentity(cfg, mapped.Email)
+	}
+	if id == nil {
+		id = resolveIdentity(cfg, gitConfigValue(root, "user.email"))
+	}
+	if id == nil {
+		return fmt.Errorf("no known identity in effect here (run 'gitme set <email>' first)")
+	}
+	if id.Protocol == "" {
+		fmt.Fprintln(w, "No remote preference set for", id.Email)
+		fmt.Fprintln(w, DimStyle.Render("Set one with: gitme remote prefer "+id.Email+" <ssh|https> [host-alias]"))
+		return nil
+	}
+
+	dryRun := hasFlag(args, "--dry-run")
+	out, err := exec.Command("git", "-C", root, "remote").Output()
+	if err != nil {
+		return fmt.Errorf("listing remotes: %w", err)
+	}
+	changed := 0
+	for _, name := range strings.Fields(string(out)) {
+		urlOut, err := exec.Command("git", "-C", root, "remote", "get-url", name).Output()
+		if err != nil {
+			continue
+		}
+		current := strings.TrimSpace(string(urlOut))
+		u, err := remoteurl.Parse(current)
+		if err != nil || u.String() != current {
+			continue // local paths, unusual transports and remotes it cannot write back exactly are left alone
+		}
+		rewritten := u.Rewrite(id.Protocol, id.SSHHost)
+		if rewritten == current {
+			continue
+		}
+		changed++
+		if !dryRun && !readOnlySkip("set remote %s to %s", name, rewritten) {
+			if err := exec.Command("git", "-C", root, "remote", "set-url", name, rewritten).Run(); err != nil {
+				return fmt.Errorf("updating remote %s: %w", name, err)
+			}
+		}
+		fmt.Fprintf(w, "  Remote %s → %s\n", name, rewritten)
+	}
+
+	if changed == 0 {
+		fmt.Fprintf(w, "%s Remotes already use %s\n", SuccessStyle.Render("✓"), describePreference(id))
+	} else if dryRun {
+		fmt.Fprintln(w, DimStyle.Render("Dry run: no remotes were changed"))
+	}
+	return nil
+}
+
+// Clone clones a repo with the remote preference of the identity that applies
+// to the destination, then switches the clone to that identity
+func Clone(w io.Writer, args []string) error {
+	as, hasAs := flagValue(args, "--as")
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--as":
+			i++
+		case strings.HasPrefix(args[i], "--"):
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+	if len(positional) < 1 {
+		return usageErr("gitme clone <url> [dir] [--as <email|alias>]")
+	}
+
+	u, err := remoteurl.Parse(positional[0])
+	if err != nil {
+		return err
+	}
+	dest := filepath.Base(u.Path)
+	if len(positional) > 1 {
+		dest = positional[1]
+	}
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		return fmt.Errorf("resolving destination: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	var id *identity.Identity
+	var source string
+	if hasAs {
+		if id = resolveIdentity(cfg, as); id == nil {
+			return fmt.Errorf("identity not found: %s", as)
+		}
+		source = "--as"
+	} else {
+		rules, err := loadRules(cfg)
+		if err != nil {
+			return fmt.Errorf("loading rules: %w", err)
+		}
+		if rule := rules.FindRuleForPath(dest); rule != nil {
+			id, source = resolveIdentity(cfg, rule.Ref()), "rule: "+rule.Pattern
+		} else if derived, derivedFrom, ambiguous := deriveIdentityFromPath(dest, cfg.Identities); !ambiguous && derived != nil {
+			id, source = resolveIdentity(cfg, derived.Email), derivedFrom
+		}
+	}
+
+	remote := positional[0]
+	if id != nil && id.Protocol != "" {
+		remote = u.Rewrite(id.Protocol, id.SSHHost)
+	}
+
+	if readOnlySkip("clone %s into %s", remote, dest) {
+		return nil
+	}
+	clone := exec.Command("git", "clone", remote, dest)
+	clone.Stdout = w
+	clone.Stderr = os.Stderr
+	if err := clone.Run(); err != nil {
+		return fmt.Errorf("git clone failed: %w", err)
+	}
+	indexClone(dest)
+
+	if id == nil {
+		fmt.Fprintln(w, DimStyle.Render("No identity applies to "+dest+"; set one with 'gitme set <email>' inside it"))
+		return nil
+	}
+	if err := switchIdentity(cfg, dest, *id); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, SuccessStyle.Render("Cloned as:"), id.Name, "<"+id.Email+">", DimStyle.Render("("+source+")"))
+	return nil
+}
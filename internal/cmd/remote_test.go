@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/platform"
+	"github.com/vosamoilenko/gitme/internal/remoteurl"
+)
+
+// stubSSHAliases makes github-work an ~/.ssh/config alias of github.com
+func stubSSHAliases(t *testing.T) {
+	resolve := remoteurl.ResolveHost
+	remoteurl.ResolveHost = func(alias string) string {
+		if alias == "github-work" {
+			return "github.com"
+		}
+		return alias
+	}
+	t.Cleanup(func() { remoteurl.ResolveHost = resolve })
+}
+
+func TestRemoteFixUsesIdentityPreference(t *testing.T) {
+	repo := newSwitchRepo(t)
+	stubSSHAliases(t)
+	if err := exec.Command("git", "-C", repo, "remote", "add", "origin", "https://github.com/org/x.git").Run(); err != nil {
+		t.Fatalf("adding remote: %v", err)
+	}
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Remote(&out, []string{"prefer", "me@corp.com", "ssh", "github-work"}); err != nil {
+		t.Fatalf("prefer failed: %v", err)
+	}
+
+	if err := Remote(&out, []string{"fix", "--dry-run"}); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "remote.origin.url"); got != "https://github.com/org/x.git" {
+		t.Fatalf("dry run changed the remote to %q", got)
+	}
+
+	if err := Remote(&out, []string{"fix"}); err != nil {
+		t.Fatalf("fix failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "remote.origin.url"); got != "git@github-work:org/x.git" {
+		t.Fatalf("expected ssh alias remote, got %q", got)
+	}
+}
+
+func TestRemoteFixKeepsOtherHostsAndPorts(t *testing.T) {
+	repo := newSwitchRepo(t)
+	stubSSHAliases(t)
+	remotes := map[string]string{
+		"origin":   "https://github.com/org/x.git",
+		"upstream": "https://gitlab.com/group/x.git",
+		"mirror":   "ssh://git@github.com:2222/org/x.git",
+		"plain":    "http://ci@git.example.com:8080/org/x",
+	}
+	for name, url := range remotes {
+		exec.Command("git", "-C", repo, "remote", "add", name, url).Run()
+	}
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	var out bytes.Buffer
+	if err := Remote(&out, []string{"prefer", "me@corp.com", "ssh", "github-work"}); err != nil {
+		t.Fatalf("prefer failed: %v", err)
+	}
+	if err := Remote(&out, []string{"fix"}); err != nil {
+		t.Fatalf("fix failed: %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"origin":   "git@github-work:org/x.git",
+		"upstream": "git@gitlab.com:group/x.git",
+		"mirror":   "ssh://git@github-work:2222/org/x.git",
+		"plain":    "git@git.example.com:org/x",
+	} {
+		if got := mustGit(t, repo, "config", "remote."+name+".url"); got != want {
+			t.Errorf("remote %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCheckRemoteAsksTheRemotesPort(t *testing.T) {
+	repo := newSwitchRepo(t)
+	exec.Command("git", "-C", repo, "remote", "add", "origin", "ssh://git@github.com:2222/org/x.git").Run()
+	if err := Set(&bytes.Buffer{}, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	var asked string
+	sshAccount = func(host string) (string, error) {
+		asked = host
+		return "me", nil
+	}
+	t.Cleanup(func() { sshAccount = platform.SSHAccount })
+
+	if err := CheckRemote(&bytes.Buffer{}, nil); err != nil {
+		t.Fatalf("check-remote failed: %v", err)
+	}
+	if asked != "ssh://git@github.com:2222" {
+		t.Errorf("expected the port kept, asked %q", asked)
+	}
+}
+
+func TestCheckRemoteCatchesSplitBrain(t *testing.T) {
+	repo := newSwitchRepo(t)
+	exec.Command("git", "-C", repo, "remote", "add", "origin", "git@github-personal:org/x.git").Run()
+	if err := Set(&bytes.Buffer{}, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+
+	var asked string
+	sshAccount = func(host string) (string, error) {
+		asked = host
+		return "work-login", nil
+	}
+	t.Cleanup(func() { sshAccount = platform.SSHAccount })
+
+	var out bytes.Buffer
+	err := CheckRemote(&out, nil)
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || !strings.Contains(out.String(), "work-login") {
+		t.Fatalf("expected a mismatch, got %v: %q", err, out.String())
+	}
+	if asked != "git@github-personal" {
+		t.Fatalf("expected the ssh alias to be asked, got %q", asked)
+	}
+
+	sshAccount = func(string) (string, error) { return "me", nil }
+	out.Reset()
+	if err := CheckRemote(&out, nil); err != nil {
+		t.Fatalf("expected a match, got %v: %q", err, out.String())
+	}
+}
@@ -1,149 +1,505 @@
 package cmd
 
 import (
+	"cmp"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/vosamoilenko/gitme/internal/config"
 	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/remoteurl"
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
 )
 
 // MixedRepo holds info about a repo with multiple identities
 type MixedRepo struct {
-	Path       string
-	Identities []string
+	Path       string   `json:"path"`
+	Identities []string `json:"identities"`
+	Emails     []string `json:"emails"`             // of Identities, in the same order
+	Expected   string   `json:"expected,omitempty"` // email of the identity the repo should use
+	Team       []string `json:"team,omitempty"`     // members of the team directory who committed too
+	// Likely are unknown authors who are probably one of the identities
+	// with an old or other address
+	Likely []LikelyAuthor `json:"likely,omitempty"`
 }
 
-// Repos shows all repos grouped by identity
-func Repos() {
+// LikelyAuthor is an author gitme does not know who resembles an identity,
+// as identity.Resemble tells
+type LikelyAuthor struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Like    string `json:"like"` // email of the identity
+	Reason  string `json:"reason"`
+	Commits int    `json:"commits"`
+}
+
+// RepoGroup is a set of repos that commit as the same identity, or with
+// --group share a platform or an org
+type RepoGroup struct {
+	Identity string            `json:"identity,omitempty"`
+	Platform identity.Platform `json:"platform,omitempty"`
+	Org      string            `json:"org,omitempty"` // host/org of the origin remote
+	Repos    []string          `json:"repos"`
+	paths    []string
+}
+
+// Ways gitme repos can group repos
+const (
+	groupIdentity = "identity"
+	groupPlatform = "platform"
+	groupOrg      = "org"
+)
+
+// noRemote labels the group of repos without a parseable remote
+const noRemote = "(no remote)"
+
+// PinnedRepo is a pinned repo and the identity it uses
+type PinnedRepo struct {
+	Path     string `json:"path"`
+	Identity string `json:"identity"`
+	Missing  bool   `json:"missing,omitempty"`
+}
+
+// Repos shows all repos grouped by identity, or with --group by platform or
+// by the org of their remote
+func Repos(w io.Writer, args []string) error {
+	group := groupIdentity
+	if value, ok := flagValue(args, "--group"); ok {
+		group = value
+	}
+	switch group {
+	case groupIdentity, groupPlatform, groupOrg:
+	default:
+		return usageErr("gitme repos [--group platform|org|identity]")
+	}
 	home, _ := os.UserHomeDir()
 
 	globalEmail, globalName := getGlobalIdentity(home)
 	globalIdentity := fmt.Sprintf("%s <%s>", globalName, globalEmail)
 
-	reposByIdentity := make(map[string][]string)
-	identityOrder := []string{globalIdentity}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	colors := identityColors(cfg.Identities)
+	platforms := make(map[string]identity.Platform)
+	for _, id := range cfg.Identities {
+		platforms[strings.ToLower(id.Email)] = id.Platform
+	}
 
-	for _, dir := range getWorkspaceDirs(home) {
-		if _, err := os.Stat(dir); err == nil {
-			collectRepos(dir, 4, globalIdentity, reposByIdentity, &identityOrder)
+	pins, err := config.LoadPins()
+	if err != nil {
+		return fmt.Errorf("loading pins: %w", err)
+	}
+	out := newRenderer(w)
+	pinned := pinnedRepos(pins.Pins, globalIdentity)
+	if hasFlag(args, "--pinned") {
+		if len(pinned) == 0 && out.Format() != render.JSON {
+			fmt.Fprintln(w, "No pinned repositories.")
+			fmt.Fprintln(w, DimStyle.Render("Pin one with: gitme pin [path]"))
+			return nil
 		}
+		return out.Render(pinned, pinnedBlocks(out, pinned, colors)...)
 	}
 
-	fmt.Println(HeaderStyle.Render("All repositories:"))
-	fmt.Println()
-
-	for _, ident := range identityOrder {
-		repos := reposByIdentity[ident]
-		if len(repos) == 0 {
-			continue
+	entries, skipped := indexedEntries(cfg, args)
+	// Pinned repos are listed in their own block, not again below it
+	entries = slices.DeleteFunc(entries, func(entry config.IndexedRepo) bool {
+		return pins.IsPinned(entry.Path)
+	})
+	var groups []RepoGroup
+	var list render.List
+	icons := iconSet()
+	if group == groupIdentity {
+		reposByIdentity := make(map[string][]string)
+		identityOrder := []string{globalIdentity}
+		for _, entry := range entries {
+			ident := repoIdentity(entry.Path, globalIdentity)
+			if _, ok := reposByIdentity[ident]; !ok && ident != globalIdentity {
+				identityOrder = append(identityOrder, ident)
+			}
+			reposByIdentity[ident] = append(reposByIdentity[ident], filepath.Base(entry.Path))
 		}
-		fmt.Printf("%s\n", ident)
-		for _, repo := range repos {
-			fmt.Printf("  %s\n", DimStyle.Render(repo))
+		for _, ident := range identityOrder {
+			repos := reposByIdentity[ident]
+			if len(repos) == 0 {
+				continue
+			}
+			groups = append(groups, RepoGroup{Identity: ident, Repos: repos})
+			email := identityEmail(ident)
+			text := platformIcon(icons, platforms[strings.ToLower(email)]) + colorIdentity(out, colors, email, ident)
+			list = append(list, render.Item{Text: text, Detail: repos})
+		}
+	} else {
+		groups = groupRepos(entries, group)
+		for _, g := range groups {
+			text := cmp.Or(g.Org, string(g.Platform), "other")
+			if g.Platform != identity.PlatformUnknown {
+				text = platformIcon(icons, g.Platform) + text
+			}
+			var detail []string
+			for i, repo := range g.Repos {
+				ident := repoIdentity(g.paths[i], globalIdentity)
+				detail = append(detail, repo+" "+colorIdentity(out, colors, identityEmail(ident), "("+identityEmail(ident)+")"))
+			}
+			list = append(list, render.Item{Text: text, Detail: detail})
 		}
-		fmt.Println()
 	}
-}
 
-// Mixed shows repos with multiple identities in history
-func Mixed() {
-	home, _ := os.UserHomeDir()
+	var blocks []render.Block
+	if len(pinned) > 0 {
+		blocks = pinnedBlocks(out, pinned, colors)
+	}
+	blocks = append(blocks, render.Header("All repositories:"), list)
+	if idx, err := config.LoadRepoIndex(); err == nil && len(idx.ThirdParty) > 0 {
+		blocks = append(blocks, render.Line(""), render.Note(fmt.Sprintf(
+			"%d third-party repos not shown (see reference_dirs in gitme config)", len(idx.ThirdParty))))
+	}
+	if groups == nil {
+		groups = []RepoGroup{}
+	}
+	if pinned == nil {
+		pinned = []PinnedRepo{}
+	}
+	err = out.Render(struct {
+		Pinned  []PinnedRepo       `json:"pinned"`
+		Groups  []RepoGroup        `json:"groups"`
+		Skipped []repowalk.Skipped `json:"skipped"`
+	}{pinned, groups, skipped}, blocks...)
+	if err != nil {
+		return err
+	}
+	return reportSkipped(w, skipped, hasFlag(args, "--strict"))
+}
 
+// Mixed shows repos with multiple identities in history. With --fix it
+// offers to rewrite the commits of each one to the identity it should use.
+func Mixed(w io.Writer, args []string) error {
+	const usage = "gitme mixed [--max-commits <n>] [--fix [--include-protected]] [--reindex] [--strict]"
+	maxCommits := 0
+	if value, ok := flagValue(args, "--max-commits"); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return usageErr(usage)
+		}
+		maxCommits = n
+	}
+	fix := hasFlag(args, "--fix")
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if len(cfg.Identities) < 2 {
+		fmt.Fprintln(w, "You need at least 2 identities configured to check for mixed repos.")
+		return nil
+	}
+	rules, err := loadRules(cfg)
+	if err != nil {
+		return fmt.Errorf("loading rules: %w", err)
 	}
 
-	knownEmails := make(map[string]string)
+	// Text output lists each repo as soon as its history is read; JSON
+	// output waits for all of them
+	out := newRenderer(w)
+	stream := out.Format() != render.JSON && !fix
+	scan := findMixedRepos(cfg, rules, args, maxCommits, func(repo MixedRepo, first bool) {
+		if !stream {
+			return
+		}
+		if first {
+			out.Render(nil, render.Header("Repos with multiple identities:"))
+		}
+		out.Render(nil, mixedList(repo))
+	})
+
+	queued, err := queueLikelyAuthors(scan.Repos)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fix:
+		err = fixMixedRepos(w, scan.Repos, hasFlag(args, "--include-protected"))
+	case !stream:
+		err = out.Render(scan.Repos)
+	case len(scan.Repos) == 0:
+		fmt.Fprintf(w, "No repos with mixed identities found in %d repos.\n", scan.Checked)
+	default:
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, DimStyle.Render(fmt.Sprintf("%d of %d repos have multiple identities", len(scan.Repos), scan.Checked)))
+		fmt.Fprintln(w, DimStyle.Render("Rewrite them to the identity each should use with: gitme mixed --fix"))
+		if queued > 0 {
+			fmt.Fprintln(w, DimStyle.Render(fmt.Sprintf("%d unknown addresses look like yours; add or merge them with: gitme review", queued)))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return reportSkipped(w, scan.Skipped, hasFlag(args, "--strict"))
+}
+
+// mixedScan is what findMixedRepos found
+type mixedScan struct {
+	Repos   []MixedRepo // sorted by path
+	Checked int
+	Skipped []repowalk.Skipped
+}
+
+// findMixedRepos reads the history of every indexed repo, up to maxCommits
+// commits each when positive, for commits by more than one known identity,
+// or by one and an unknown author who resembles one.
+// found is called with each such repo as soon as it is known, first marking
+// the first one; the result holds them all.
+func findMixedRepos(cfg *config.Config, rules *config.RulesConfig, args []string, maxCommits int, found func(repo MixedRepo, first bool)) mixedScan {
+	emailOf := make(map[string]string)
 	for _, id := range cfg.Identities {
-		key := strings.ToLower(id.Email)
-		knownEmails[key] = fmt.Sprintf("%s <%s>", id.Name, id.Email)
+		emailOf[fmt.Sprintf("%s <%s>", id.Name, id.Email)] = id.Email
+	}
+	team := teamEmails(teamBook())
+	var dismissed []string // candidates the user said are not them
+	if queue, err := config.LoadCandidates(); err == nil {
+		dismissed = queue.Dismissed
 	}
 
-	if len(knownEmails) < 2 {
-		fmt.Println("You need at least 2 identities configured to check for mixed repos.")
-		return
+	repos, skipped := indexedRepos(cfg, args)
+	type result struct {
+		repo    string
+		history mixedHistory
+		err     error
+	}
+	results := repowalk.Map(repos, func(repo string) result {
+		history, err := mixedAuthors(repo, cfg.Identities, team, maxCommits)
+		history.Likely = slices.DeleteFunc(history.Likely, func(a LikelyAuthor) bool {
+			return slices.Contains(dismissed, strings.ToLower(a.Email))
+		})
+		return result{repo, history, err}
+	})
+
+	scan := mixedScan{Repos: []MixedRepo{}, Checked: len(repos), Skipped: skipped}
+	for r := range results {
+		if s, ok := repowalk.Failed(r.repo, r.err); ok {
+			scan.Skipped = append(scan.Skipped, s)
+		}
+		if len(r.history.Identities) == 0 || len(r.history.Identities)+len(r.history.Likely) < 2 {
+			continue
+		}
+		repo := MixedRepo{Path: r.repo, Identities: r.history.Identities, Team: r.history.Team, Likely: r.history.Likely}
+		for _, display := range repo.Identities {
+			repo.Emails = append(repo.Emails, emailOf[display])
+		}
+		if id, ok := cfg.GetIdentityForFolder(r.repo); ok {
+			repo.Expected = id.Email
+		} else if id, _, err := expectedIdentity(cfg, rules, r.repo); err == nil && id != nil {
+			repo.Expected = id.Email
+		}
+		found(repo, len(scan.Repos) == 0)
+		scan.Repos = append(scan.Repos, repo)
 	}
+	slices.SortFunc(scan.Repos, func(a, b MixedRepo) int { return strings.Compare(a.Path, b.Path) })
+	return scan
+}
 
-	var mixed []MixedRepo
-	for _, dir := range getWorkspaceDirs(home) {
-		if _, err := os.Stat(dir); err == nil {
-			findMixedRepos(dir, 4, knownEmails, &mixed)
+// queueLikelyAuthors queues the unknown authors of repos that resemble an
+// identity as candidates for gitme review, to add or merge into it; it
+// returns how many there are
+func queueLikelyAuthors(repos []MixedRepo) (int, error) {
+	byEmail := make(map[string]*identity.Candidate)
+	var candidates []*identity.Candidate
+	for _, repo := range repos {
+		for _, a := range repo.Likely {
+			c, ok := byEmail[strings.ToLower(a.Email)]
+			if !ok {
+				c = &identity.Candidate{
+					Identity: identity.Identity{Name: a.Name, Email: a.Email, Source: identity.SourceHistory, Platform: identity.DetectPlatform(a.Email)},
+					Like:     a.Like, Reason: a.Reason,
+				}
+				byEmail[strings.ToLower(a.Email)] = c
+				candidates = append(candidates, c)
+			}
+			c.Commits += a.Commits
+			c.Repos = append(c.Repos, repo.Path)
+			c.Sources = append(c.Sources, repo.Path)
 		}
 	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+	queue, err := config.LoadCandidates()
+	if err != nil {
+		return 0, fmt.Errorf("loading candidates: %w", err)
+	}
+	var list []identity.Candidate
+	for _, c := range candidates {
+		list = append(list, *c)
+	}
+	queue.Queue(list)
+	if err := queue.Save(); err != nil {
+		return 0, fmt.Errorf("saving candidates: %w", err)
+	}
+	return len(list), nil
+}
 
-	if len(mixed) == 0 {
-		fmt.Println("No repos with mixed identities found.")
-		return
+// mixedList shows a mixed repo with its identities, marking the expected one
+func mixedList(repo MixedRepo) render.List {
+	var detail []string
+	for i, display := range repo.Identities {
+		if strings.EqualFold(repo.Emails[i], repo.Expected) {
+			display += " " + SuccessStyle.Render("(expected)")
+		}
+		detail = append(detail, display)
+	}
+	for _, a := range repo.Likely {
+		detail = append(detail, fmt.Sprintf("%s <%s> %s", a.Name, a.Email,
+			WarnStyle.Render(fmt.Sprintf("(unknown, probably %s: %s)", a.Like, a.Reason))))
+	}
+	if len(repo.Team) > 0 {
+		detail = append(detail, DimStyle.Render("with team: "+strings.Join(repo.Team, ", ")))
 	}
+	return render.List{{Text: repo.Path, Detail: detail}}
+}
 
-	fmt.Println(HeaderStyle.Render("Repos with multiple identities:"))
-	fmt.Println()
+// fixMixedRepos runs fix:rewrite in each mixed repo for every identity other
+// than the expected one, which asks before rewriting, backs the repo up and
+// verifies the result. Repos without an expected identity are left alone.
+func fixMixedRepos(w io.Writer, repos []MixedRepo, includeProtected bool) error {
+	if len(repos) == 0 {
+		fmt.Fprintln(w, "No repos with mixed identities found.")
+		return nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("getting current directory: %w", err)
+	}
+	defer os.Chdir(cwd)
 
-	for _, repo := range mixed {
-		fmt.Printf("%s\n", repo.Path)
-		for _, id := range repo.Identities {
-			fmt.Printf("  %s\n", DimStyle.Render(id))
+	var unknown []string
+	for _, repo := range repos {
+		if repo.Expected == "" {
+			unknown = append(unknown, repo.Path)
+			continue
+		}
+		for _, email := range repo.Emails {
+			if strings.EqualFold(email, repo.Expected) {
+				continue
+			}
+			fmt.Fprintln(w, HeaderStyle.Render(repo.Path))
+			if err := os.Chdir(repo.Path); err != nil {
+				return fmt.Errorf("changing to %s: %w", repo.Path, err)
+			}
+			rewriteArgs := []string{email, repo.Expected}
+			if includeProtected {
+				rewriteArgs = append(rewriteArgs, "--include-protected")
+			}
+			if err := FixRewrite(w, rewriteArgs); err != nil {
+				fmt.Fprintf(w, "%s %s: %v\n", WarnStyle.Render("⚠"), repo.Path, err)
+			}
+			fmt.Fprintln(w)
 		}
-		fmt.Println()
 	}
+	if len(unknown) > 0 {
+		fmt.Fprintf(w, "%s No identity expected for %d repos, left as they are:\n", WarnStyle.Render("⚠"), len(unknown))
+		for _, path := range unknown {
+			fmt.Fprintf(w, "  %s\n", path)
+		}
+		fmt.Fprintln(w, DimStyle.Render("Map them with 'gitme set' or a rule, then run gitme mixed --fix again"))
+	}
+	return nil
 }
 
-// Current shows the current identity for the folder
-func Current() {
-	cwd, _ := os.Getwd()
+// Current shows the current identity for the folder. With --copy it also
+// copies it to the clipboard as Name <email>, e.g. for Co-authored-by
+// trailers.
+func Current(w io.Writer, args []string) error {
+	cwd, root, err := workingRepo()
+	if err != nil {
+		return err
+	}
 
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
 	}
 
-	if id, ok := cfg.GetIdentityForFolder(cwd); ok {
-		fmt.Printf("%s <%s>\n", id.Name, id.Email)
-		fmt.Println(DimStyle.Render("(from gitme config)"))
-		return
+	report := currentReport{Repo: root, Source: "none"}
+	if author := gitConfigValue(cwd, "author.email"); author != "" {
+		report.Author = &currentAuthor{Name: gitConfigValue(cwd, "author.name"), Email: author}
 	}
-
-	// Check git config
-	cmd := exec.Command("git", "config", "user.email")
-	cmd.Dir = cwd
-	emailOut, err := cmd.Output()
-	if err != nil {
-		fmt.Println("No identity configured for this folder")
-		return
+	if id, ok := mappedIdentity(cfg, root, cwd); ok {
+		report.Name, report.Email, report.Source = id.Name, id.Email, "gitme"
+	} else if email := gitConfigValue(cwd, "user.email"); email != "" {
+		report.Name, report.Email, report.Source = gitConfigValue(cwd, "user.name"), email, "git"
 	}
 
-	cmd = exec.Command("git", "config", "user.name")
-	cmd.Dir = cwd
-	nameOut, _ := cmd.Output()
+	out := newRenderer(w)
+	colors := identityColors(cfg.Identities)
+	var blocks []render.Block
+	if report.Author != nil {
+		text := fmt.Sprintf("%s <%s>", report.Author.Name, report.Author.Email)
+		blocks = append(blocks, render.Line("Author: "+colorIdentity(out, colors, report.Author.Email, text)+" "+out.Style(DimStyle, "(--author-only; committer below)")))
+	}
+	switch report.Source {
+	case "none":
+		blocks = append(blocks, render.Line("No identity configured for this folder"))
+	default:
+		blocks = append(blocks, render.Line(colorIdentity(out, colors, report.Email, fmt.Sprintf("%s <%s>", report.Name, report.Email))),
+			render.Note("(from "+report.Source+" config)"))
+	}
+	if hasFlag(args, "--copy") {
+		if report.Source == "none" {
+			return fmt.Errorf("no identity configured for this folder to copy")
+		}
+		if err := writeClipboard(fmt.Sprintf("%s <%s>", report.Name, report.Email)); err != nil {
+			return fmt.Errorf("copying to clipboard: %w", err)
+		}
+		blocks = append(blocks, render.Note("(copied to clipboard)"))
+	}
+	return out.Render(report, blocks...)
+}
 
-	email := strings.TrimSpace(string(emailOut))
-	name := strings.TrimSpace(string(nameOut))
+// currentReport is the identity in effect in a repo and where it comes from:
+// gitme's folder mappings, git config, or none
+type currentReport struct {
+	Repo   string         `json:"repo"`
+	Name   string         `json:"name,omitempty"`
+	Email  string         `json:"email,omitempty"`
+	Source string         `json:"source"`
+	Author *currentAuthor `json:"author,omitempty"` // set with --author-only
+}
 
-	fmt.Printf("%s <%s>\n", name, email)
-	fmt.Println(DimStyle.Render("(from git config)"))
+// currentAuthor is the author a repo commits as when set apart from the
+// committer
+type currentAuthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
 
-// Set sets the identity for the current folder
-func Set() {
-	if len(os.Args) < 3 {
-		fmt.Fprintf(os.Stderr, "Usage: gitme set <email>\n")
-		os.Exit(1)
+// Set sets the identity for the current folder. With --author-only it sets
+// just the author, leaving the committer on the repo's identity.
+func Set(w io.Writer, args []string) error {
+	authorOnly := hasFlag(args, "--author-only")
+	allowOutside := hasFlag(args, allowOutsideFlag)
+	args = positionalArgs(args)
+	if len(args) < 1 {
+		return usageErr("gitme set <email> [--author-only] [--allow-outside]")
 	}
 
-	email := os.Args[2]
-	cwd, _ := os.Getwd()
+	email := args[0]
+	root, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
 
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
 	}
 
 	var found *identity.Identity
@@ -155,33 +511,254 @@ func Set() {
 	}
 
 	if found == nil {
-		fmt.Fprintf(os.Stderr, "Identity not found: %s\n", email)
-		fmt.Fprintf(os.Stderr, "Run 'gitme list' to see available identities\n")
-		os.Exit(1)
+		return fmt.Errorf("identity not found: %s (run 'gitme list' to see available identities)", email)
+	}
+	if err := trustRepo(w, cfg, root, allowOutside); err != nil {
+		return err
+	}
+
+	if authorOnly {
+		if err := ApplyAuthor(root, found); err != nil {
+			return fmt.Errorf("applying author: %w", err)
+		}
+		cfg.MarkUsed(found.Email)
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Fprintln(w, SuccessStyle.Render("Author set to:"), found.Name, "<"+found.Email+">")
+		fmt.Fprintln(w, DimStyle.Render("Commits keep "+cmp.Or(gitConfigValue(root, "user.email"), "the default identity")+" as committer"))
+		return nil
+	}
+
+	if err := switchIdentity(cfg, root, *found); err != nil {
+		return err
+	}
+	if gitConfigValue(root, "author.email") != "" {
+		if err := ApplyAuthor(root, nil); err != nil {
+			return fmt.Errorf("clearing author: %w", err)
+		}
+		fmt.Fprintln(w, DimStyle.Render("Cleared the --author-only override"))
+	}
+
+	fmt.Fprintln(w, SuccessStyle.Render("Switched to:"), found.Name, "<"+found.Email+">")
+	return offerRule(w, root, found)
+}
+
+// offerRule asks whether to add a rule so repos like the one at root switch
+// to id on their own, unless a rule already covers root or nobody is there
+// to answer
+func offerRule(w io.Writer, root string, id *identity.Identity) error {
+	if !interactive() {
+		return nil
+	}
+	rules, err := config.LoadRules()
+	if err != nil {
+		return fmt.Errorf("loading rules: %w", err)
+	}
+	email := id.Email
+	pattern := suggestRulePattern(root)
+	if pattern == "" || rules.FindRuleForPath(root) != nil {
+		return nil
 	}
+	if !confirm(w, fmt.Sprintf("Create rule %s → %s so repos like this auto-switch next time?", pattern, email)) {
+		fmt.Fprintln(w, DimStyle.Render("Add one later with: gitme rule add <pattern> "+email))
+		return nil
+	}
+	rules.AddRule(pattern, id.ID)
+	if err := rules.Save(); err != nil {
+		return fmt.Errorf("saving rules: %w", err)
+	}
+	fmt.Fprintf(w, "%s Added rule: %s → %s\n", SuccessStyle.Render("✓"), pattern, email)
+	return nil
+}
 
-	if err := ApplyIdentity(cwd, *found); err != nil {
-		fmt.Fprintf(os.Stderr, "Error applying identity: %v\n", err)
-		os.Exit(1)
+// suggestRulePattern proposes a rule pattern for repos like the one at root:
+// the host/org of its origin remote when the path is laid out that way (as
+// ghq does), else its parent directory. Home itself is too broad to suggest.
+func suggestRulePattern(root string) string {
+	if u, err := remoteurl.Parse(gitConfigValue(root, "remote.origin.url")); err == nil {
+		if org, _, ok := strings.Cut(u.Path, "/"); ok {
+			pattern := u.Host + "/" + org
+			if strings.Contains(root+"/", "/"+pattern+"/") {
+				return pattern
+			}
+		}
 	}
 
-	cfg.SetIdentityForFolder(cwd, *found)
-	cfg.Save()
+	parent := filepath.Dir(root)
+	home, _ := os.UserHomeDir()
+	if parent == home || parent == filepath.Dir(parent) {
+		return ""
+	}
+	if rel, err := filepath.Rel(home, parent); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+		return "~/" + filepath.ToSlash(rel)
+	}
+	return parent
+}
 
-	fmt.Println(SuccessStyle.Render("Switched to:"), found.Name, "<"+found.Email+">")
+// switchIdentity applies id to the repo at root and records the folder mapping
+// under the repo root, replacing mappings made from its subdirectories
+func switchIdentity(cfg *config.Config, root string, id identity.Identity) error {
+	if err := ApplyIdentity(root, id); err != nil {
+		return fmt.Errorf("applying identity: %w", err)
+	}
+	for folder := range cfg.Folders {
+		if strings.HasPrefix(folder, root+string(filepath.Separator)) {
+			delete(cfg.Folders, folder)
+		}
+	}
+	cfg.SetIdentityForFolder(root, id)
+	cfg.MarkUsed(id.Email)
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	return nil
 }
 
-// ApplyIdentity applies the identity to git config
+// allowOutsideFlag lets commands change repos outside the workspace dirs
+const allowOutsideFlag = "--allow-outside"
+
+// insideWorkspace reports whether the repo at root is below a workspace dir
+// or already mapped to an identity, the repos gitme changes without asking
+func insideWorkspace(cfg *config.Config, root string) bool {
+	if _, ok := cfg.Folders[root]; ok {
+		return true
+	}
+	home, _ := os.UserHomeDir()
+	for _, dir := range identity.WorkspaceDirs(home) {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		if root == resolved || strings.HasPrefix(root, resolved+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustRepo checks that the repo at root may be changed. Repos outside the
+// workspace dirs, like checkouts in /tmp, take --allow-outside or a yes
+// from someone at the terminal.
+func trustRepo(w io.Writer, cfg *config.Config, root string, allowOutside bool) error {
+	if allowOutside || insideWorkspace(cfg, root) {
+		return nil
+	}
+	if interactive() && confirm(w, fmt.Sprintf("%s is outside your workspace dirs. Change its identity anyway?", root)) {
+		return nil
+	}
+	return fmt.Errorf("%s is outside your workspace dirs, left alone (pass %s to change it)", root, allowOutsideFlag)
+}
+
+// mappedIdentity returns the folder mapping of the repo at root. Mappings
+// recorded for a subdirectory by older versions are found by walking up from cwd.
+func mappedIdentity(cfg *config.Config, root, cwd string) (identity.Identity, bool) {
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		if id, ok := cfg.GetIdentityForFolder(dir); ok {
+			return id, true
+		}
+		if dir == root || dir == filepath.Dir(dir) || !strings.HasPrefix(dir, root) {
+			return cfg.GetIdentityForFolder(root)
+		}
+	}
+}
+
+// ApplyIdentity applies the identity to the repository's local git config,
+// with its ssh key, signing config and profile. It changes all of them or,
+// when one fails, none.
 func ApplyIdentity(cwd string, id identity.Identity) error {
-	cmd := exec.Command("git", "config", "user.email", id.Email)
+	if readOnlySkip("set user.name and user.email of %s to %s", cwd, id.String()) {
+		return nil
+	}
+	keys := []string{"user.email", "user.name", "credential.username", "core.sshCommand",
+		"user.signingkey", "gpg.format", "commit.gpgsign", signingMarker, profileMarker}
+	snapshot := snapshotConfig(cwd, slices.Concat(keys, appliedProfileKeys(cwd), profileKeys(id)))
+	if err := applyIdentity(cwd, id); err != nil {
+		if rerr := snapshot.restore(cwd); rerr != nil {
+			return fmt.Errorf("%w (and %v)", err, rerr)
+		}
+		return err
+	}
+	if err := config.BumpGeneration(); err != nil {
+		return fmt.Errorf("bumping the switch generation: %w", err)
+	}
+	return nil
+}
+
+// applyIdentity writes the config ApplyIdentity applies, stopping at the
+// first key that fails
+func applyIdentity(cwd string, id identity.Identity) error {
+	removeStaleProfile(cwd, id)
+	cmd := exec.Command("git", "config", "--local", "user.email", id.Email)
+	cmd.Dir = cwd
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	cmd = exec.Command("git", "config", "--local", "user.name", id.Name)
 	cmd.Dir = cwd
 	if err := cmd.Run(); err != nil {
 		return err
 	}
 
-	cmd = exec.Command("git", "config", "user.name", id.Name)
+	if id.Username != "" {
+		cmd = exec.Command("git", "config", "--local", "credential.username", id.Username)
+	} else {
+		cmd = exec.Command("git", "config", "--local", "--unset", "credential.username")
+	}
 	cmd.Dir = cwd
-	return cmd.Run()
+	cmd.Run() // unset fails harmlessly when the key is absent
+
+	// Leave an ssh command the user set themselves alone
+	if id.SSHKey != "" {
+		cmd = exec.Command("git", "config", "--local", "core.sshCommand", sshCommand(id.SSHKey))
+		cmd.Dir = cwd
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	} else if gitmeSSHCommand(localConfigValue(cwd, "core.sshCommand")) {
+		cmd = exec.Command("git", "config", "--local", "--unset", "core.sshCommand")
+		cmd.Dir = cwd
+		cmd.Run()
+	}
+	if err := applySigning(cwd, id); err != nil {
+		return err
+	}
+	return applyProfile(cwd, id)
+}
+
+// ApplyAuthor sets author.name and author.email in the repository's local git
+// config, which git uses for authorship only; the committer stays user.name
+// and user.email. A nil id removes them.
+func ApplyAuthor(dir string, id *identity.Identity) error {
+	if id == nil {
+		if readOnlySkip("unset author.name and author.email of %s", dir) {
+			return nil
+		}
+		for _, key := range []string{"author.email", "author.name"} {
+			cmd := exec.Command("git", "config", "--local", "--unset", key)
+			cmd.Dir = dir
+			cmd.Run() // unset fails harmlessly when the key is absent
+		}
+		if err := config.BumpGeneration(); err != nil {
+			return fmt.Errorf("bumping the switch generation: %w", err)
+		}
+		return nil
+	}
+	if readOnlySkip("set author.name and author.email of %s to %s", dir, id.String()) {
+		return nil
+	}
+	for key, value := range map[string]string{"author.email": id.Email, "author.name": id.Name} {
+		cmd := exec.Command("git", "config", "--local", key, value)
+		cmd.Dir = dir
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+	if err := config.BumpGeneration(); err != nil {
+		return fmt.Errorf("bumping the switch generation: %w", err)
+	}
+	return nil
 }
 
 // Helper functions
@@ -222,57 +799,71 @@ func getGlobalIdentity(home string) (email, name string) {
 	return
 }
 
-func getWorkspaceDirs(home string) []string {
-	return []string{
-		filepath.Join(home, "Developer"),
-		filepath.Join(home, "Projects"),
-		filepath.Join(home, "Code"),
-		filepath.Join(home, "workspace"),
-		filepath.Join(home, "src"),
-		filepath.Join(home, "work"),
+// repoIdentity returns "Name <email>" of the repo's local identity, or
+// globalIdentity when it has none
+// groupRepos groups indexed repos by platform or by org, sorted by name with
+// repos of unknown platforms or without remotes last
+func groupRepos(entries []config.IndexedRepo, by string) []RepoGroup {
+	byKey := make(map[string]*RepoGroup)
+	var keys []string
+	for _, entry := range entries {
+		g := RepoGroup{Platform: entry.Platform}
+		if by == groupOrg {
+			g.Org = repoOrg(entry)
+		}
+		key := cmp.Or(g.Org, string(g.Platform))
+		if byKey[key] == nil {
+			byKey[key] = &g
+			keys = append(keys, key)
+		}
+		byKey[key].Repos = append(byKey[key].Repos, filepath.Base(entry.Path))
+		byKey[key].paths = append(byKey[key].paths, entry.Path)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		last := func(key string) bool { return key == "" || key == noRemote }
+		return cmp.Or(cmp.Compare(boolInt(last(a)), boolInt(last(b))), cmp.Compare(a, b))
+	})
+	groups := make([]RepoGroup, 0, len(keys))
+	for _, key := range keys {
+		groups = append(groups, *byKey[key])
 	}
+	return groups
 }
 
-func collectRepos(dir string, maxDepth int, globalIdentity string, reposByIdentity map[string][]string, identityOrder *[]string) {
-	if maxDepth <= 0 {
-		return
+// repoOrg returns host/org of the origin remote of a repo, or of another
+// remote without one. ssh aliases such as github-work become the platform's
+// host; GitLab subgroups stay part of the org.
+func repoOrg(entry config.IndexedRepo) string {
+	names := slices.Sorted(maps.Keys(entry.Remotes))
+	if i := slices.Index(names, "origin"); i > 0 {
+		names[0], names[i] = names[i], names[0]
 	}
-
-	entries, _ := os.ReadDir(dir)
-	for _, entry := range entries {
-		if !entry.IsDir() {
+	for _, name := range names {
+		u, err := remoteurl.Parse(entry.Remotes[name])
+		if err != nil || !strings.Contains(u.Path, "/") {
 			continue
 		}
-
-		subdir := filepath.Join(dir, entry.Name())
-		gitDir := filepath.Join(subdir, ".git")
-
-		if _, err := os.Stat(gitDir); err == nil {
-			configPath := filepath.Join(gitDir, "config")
-			localEmail, localName := parseGitConfig(configPath)
-
-			repoName := filepath.Base(subdir)
-			ident := globalIdentity
-			if localEmail != "" {
-				ident = fmt.Sprintf("%s <%s>", localName, localEmail)
-				found := false
-				for _, id := range *identityOrder {
-					if id == ident {
-						found = true
-						break
-					}
-				}
-				if !found {
-					*identityOrder = append(*identityOrder, ident)
-				}
-			}
-			reposByIdentity[ident] = append(reposByIdentity[ident], repoName)
+		host := u.Host
+		if !strings.Contains(host, ".") {
+			host = cmp.Or(platformHost(identity.HostPlatform(host)), host)
 		}
+		return strings.ToLower(host + "/" + path.Dir(u.Path))
+	}
+	return noRemote
+}
 
-		if maxDepth > 1 {
-			collectRepos(subdir, maxDepth-1, globalIdentity, reposByIdentity, identityOrder)
-		}
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func repoIdentity(repo, globalIdentity string) string {
+	if email, name := parseGitConfig(filepath.Join(repowalk.GitDir(repo), "config")); email != "" {
+		return fmt.Sprintf("%s <%s>", name, email)
 	}
+	return globalIdentity
 }
 
 func parseGitConfig(configPath string) (email, name string) {
@@ -310,49 +901,53 @@ func parseGitConfig(configPath string) (email, name string) {
 	return
 }
 
-func findMixedRepos(dir string, maxDepth int, knownEmails map[string]string, mixed *[]MixedRepo) {
-	if maxDepth <= 0 {
-		return
+// mixedHistory is who committed to a repo, as gitme mixed sees it
+type mixedHistory struct {
+	Identities []string       // known identities, as Name <email>
+	Team       []string       // members of the team directory, as Name <email>
+	Likely     []LikelyAuthor // unknown authors that resemble an identity
+}
+
+// mixedAuthors reads who committed to a repo, in its whole history or its
+// last maxCommits commits when that is positive: identities of known, the
+// other authors team maps to Name <email>, and the rest that resemble one
+// of known
+func mixedAuthors(repo string, known []identity.Identity, team map[string]string, maxCommits int) (mixedHistory, error) {
+	// %aN and %aE map alternate names and emails through the repo's .mailmap
+	logArgs := []string{"log", "--format=%aN%x00%aE"}
+	if maxCommits > 0 {
+		logArgs = append(logArgs, "-n", strconv.Itoa(maxCommits))
+	}
+	output, err := repowalk.Git(repo, repowalk.GitEnv(), logArgs...)
+	if err != nil {
+		return mixedHistory{}, err
+	}
+	knownEmails := make(map[string]string)
+	for _, id := range known {
+		knownEmails[strings.ToLower(id.Email)] = fmt.Sprintf("%s <%s>", id.Name, id.Email)
 	}
 
-	entries, _ := os.ReadDir(dir)
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
+	var history mixedHistory
+	seen := make(map[string]bool)
+	likely := make(map[string]int) // index in history.Likely by email
+	for _, line := range strings.Split(string(output), "\n") {
+		name, email, ok := strings.Cut(strings.TrimSpace(line), "\x00")
+		lower := strings.ToLower(email)
+		if i, ok := likely[lower]; ok {
+			history.Likely[i].Commits++
 		}
-
-		subdir := filepath.Join(dir, entry.Name())
-		gitDir := filepath.Join(subdir, ".git")
-
-		if _, err := os.Stat(gitDir); err == nil {
-			cmd := exec.Command("git", "-C", subdir, "log", "--format=%ae")
-			output, err := cmd.Output()
-			if err != nil {
-				continue
-			}
-
-			foundIdentities := make(map[string]bool)
-			for _, line := range strings.Split(string(output), "\n") {
-				email := strings.ToLower(strings.TrimSpace(line))
-				if displayIdentity, ok := knownEmails[email]; ok {
-					foundIdentities[displayIdentity] = true
-				}
-			}
-
-			if len(foundIdentities) > 1 {
-				var identities []string
-				for id := range foundIdentities {
-					identities = append(identities, id)
-				}
-				*mixed = append(*mixed, MixedRepo{
-					Path:       subdir,
-					Identities: identities,
-				})
-			}
+		if !ok || lower == "" || seen[lower] {
+			continue
 		}
-
-		if maxDepth > 1 {
-			findMixedRepos(subdir, maxDepth-1, knownEmails, mixed)
+		seen[lower] = true
+		if display, ok := knownEmails[lower]; ok {
+			history.Identities = append(history.Identities, display)
+		} else if member, ok := team[lower]; ok {
+			history.Team = append(history.Team, member)
+		} else if like, ok := identity.Resemble(name, email, known); ok {
+			likely[lower] = len(history.Likely)
+			history.Likely = append(history.Likely, LikelyAuthor{Name: name, Email: email, Like: like.Identity.Email, Reason: like.Reason, Commits: 1})
 		}
 	}
+	return history, nil
 }
@@ -0,0 +1,463 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/atotto/clipboard"
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+// newSwitchRepo creates a repo with an isolated global git config and gitme
+// config dir, makes it the working directory and returns its path
+func newSwitchRepo(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(home, ".gitconfig"))
+	config.SetDir(filepath.Join(home, "gitme"))
+	t.Cleanup(func() { config.SetDir("") })
+
+	home, _ = filepath.EvalSymlinks(home)
+	repo := filepath.Join(home, "work", "repo")
+	if out, err := exec.Command("git", "init", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v (%s)", err, out)
+	}
+	t.Chdir(repo)
+
+	cfg := &config.Config{
+		Identities: []identity.Identity{
+			{Name: "Work", Email: "me@corp.com"},
+			{Name: "Personal", Email: "me@example.com", Username: "me"},
+		},
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("saving config: %v", err)
+	}
+	return repo
+}
+
+// mustGit runs git in repo and returns its trimmed output, failing the test
+// when git fails; a git config read of a missing key returns ""
+func mustGit(t *testing.T, repo string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).Output()
+	var exitErr *exec.ExitError
+	if err != nil && !(args[0] == "config" && errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		t.Fatalf("git %v failed: %v", args, err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestApplyIdentityWritesLocalConfig(t *testing.T) {
+	repo := newSwitchRepo(t)
+
+	if err := ApplyIdentity(repo, identity.Identity{Name: "Personal", Email: "me@example.com", Username: "me"}); err != nil {
+		t.Fatalf("ApplyIdentity failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "user.email"); got != "me@example.com" {
+		t.Fatalf("expected local user.email, got %q", got)
+	}
+	if got := mustGit(t, repo, "config", "--local", "credential.username"); got != "me" {
+		t.Fatalf("expected local credential.username, got %q", got)
+	}
+	if got := mustGit(t, repo, "config", "--global", "user.email"); got != "" {
+		t.Fatalf("global config was modified: %q", got)
+	}
+}
+
+func TestSetAndUseSwitchTheSameWay(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		run  func(w *bytes.Buffer) error
+	}{
+		{"set", func(w *bytes.Buffer) error { return Set(w, []string{"me@example.com"}) }},
+		{"use", func(w *bytes.Buffer) error {
+			aliases, _ := config.LoadAliases()
+			aliases.SetAlias("home", "me@example.com")
+			if err := aliases.Save(); err != nil {
+				return err
+			}
+			return Use(w, []string{"home"})
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newSwitchRepo(t)
+
+			var out bytes.Buffer
+			if err := tc.run(&out); err != nil {
+				t.Fatalf("%s failed: %v", tc.name, err)
+			}
+			if !strings.Contains(out.String(), "Switched to: Personal <me@example.com>") {
+				t.Fatalf("unexpected output: %q", out.String())
+			}
+			if got := mustGit(t, repo, "config", "--local", "user.email"); got != "me@example.com" {
+				t.Fatalf("expected local user.email, got %q", got)
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatalf("loading config: %v", err)
+			}
+			if id, ok := cfg.GetIdentityForFolder(repo); !ok || id.Email != "me@example.com" {
+				t.Fatalf("expected folder mapping, got %+v", cfg.Folders)
+			}
+			if cfg.Identities[1].LastUsed.IsZero() {
+				t.Fatalf("expected identity to be marked used")
+			}
+		})
+	}
+}
+
+func TestSetFromSubdirectoryMapsRepoRoot(t *testing.T) {
+	repo := newSwitchRepo(t)
+
+	// A mapping left behind by an older version that stored subdirectories
+	cfg, _ := config.Load()
+	sub := filepath.Join(repo, "pkg", "sub")
+	cfg.SetIdentityForFolder(filepath.Join(repo, "pkg"), cfg.Identities[0])
+	cfg.Save()
+
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("creating subdir: %v", err)
+	}
+	t.Chdir(sub)
+
+	var out bytes.Buffer
+	if err := Current(&out, nil); err != nil || !strings.HasPrefix(out.String(), "Work <me@corp.com>") {
+		t.Fatalf("expected legacy subdirectory mapping, got %q (%v)", out.String(), err)
+	}
+
+	if err := Set(&out, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	cfg, _ = config.Load()
+	if len(cfg.Folders) != 1 {
+		t.Fatalf("expected a single mapping for the repo root, got %+v", cfg.Folders)
+	}
+	if id, ok := cfg.GetIdentityForFolder(repo); !ok || id.Email != "me@example.com" {
+		t.Fatalf("expected mapping for %s, got %+v", repo, cfg.Folders)
+	}
+
+	out.Reset()
+	if err := Current(&out, nil); err != nil || !strings.HasPrefix(out.String(), "Personal <me@example.com>") {
+		t.Fatalf("expected root mapping from subdirectory, got %q (%v)", out.String(), err)
+	}
+}
+
+func TestSetHonorsGitDirEnvironment(t *testing.T) {
+	repo := newSwitchRepo(t)
+
+	// Run from an unrelated directory with relative paths, as scripts do
+	elsewhere, _ := filepath.EvalSymlinks(t.TempDir())
+	elsewhere = filepath.Join(elsewhere, "scripts", "ci")
+	if err := os.MkdirAll(elsewhere, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(elsewhere)
+	rel, _ := filepath.Rel(elsewhere, repo)
+	t.Setenv("GIT_DIR", filepath.Join(rel, ".git"))
+	t.Setenv("GIT_WORK_TREE", rel)
+	if err := ResolveGitEnv(); err != nil {
+		t.Fatalf("ResolveGitEnv failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Set(&out, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "user.email"); got != "me@example.com" {
+		t.Fatalf("expected identity in %s, got %q", repo, got)
+	}
+	cfg, _ := config.Load()
+	if _, ok := cfg.GetIdentityForFolder(repo); !ok {
+		t.Fatalf("expected mapping for %s, got %+v", repo, cfg.Folders)
+	}
+}
+
+func TestSetOffersRuleForReposLikeThis(t *testing.T) {
+	newSwitchRepo(t)
+	home, _ := os.UserHomeDir()
+	repo := filepath.Join(home, "src", "github.com", "acme", "app")
+	if out, err := exec.Command("git", "init", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v (%s)", err, out)
+	}
+	mustGit(t, repo, "config", "remote.origin.url", "git@github.com:acme/app.git")
+	t.Chdir(repo)
+
+	Stdin, stdinReader = strings.NewReader("y\n"), nil
+	t.Cleanup(func() { Stdin, stdinReader = os.Stdin, nil })
+
+	var out bytes.Buffer
+	if err := Set(&out, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	rules, _ := config.LoadRules()
+	cfg, _ := config.Load()
+	if rule := rules.FindRuleForPath(repo); rule == nil || rule.Pattern != "github.com/acme" || rule.Ref() != cfg.Identities[0].ID {
+		t.Fatalf("expected a rule for github.com/acme, got %+v\n%s", rule, out.String())
+	}
+
+	out.Reset()
+	if err := Set(&out, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if strings.Contains(out.String(), "Create rule") {
+		t.Fatalf("expected no offer once a rule covers the repo:\n%s", out.String())
+	}
+
+	if got := suggestRulePattern(filepath.Join(home, "work", "api")); got != "~/work" {
+		t.Fatalf("expected the parent directory, got %q", got)
+	}
+	if got := suggestRulePattern(filepath.Join(home, "api")); got != "" {
+		t.Fatalf("expected no pattern directly under home, got %q", got)
+	}
+}
+
+func TestSetAuthorOnlyKeepsCommitter(t *testing.T) {
+	repo := newSwitchRepo(t)
+
+	var out bytes.Buffer
+	if err := Set(&out, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if err := Set(&out, []string{"--author-only", "example"}); err != nil {
+		t.Fatalf("set --author-only failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "author.email"); got != "me@example.com" {
+		t.Fatalf("expected local author.email, got %q", got)
+	}
+	if got := mustGit(t, repo, "config", "--local", "user.email"); got != "me@corp.com" {
+		t.Fatalf("committer changed to %q", got)
+	}
+	cfg, _ := config.Load()
+	if id, ok := cfg.GetIdentityForFolder(repo); !ok || id.Email != "me@corp.com" {
+		t.Fatalf("folder mapping changed: %+v", cfg.Folders)
+	}
+
+	out.Reset()
+	if err := Current(&out, nil); err != nil {
+		t.Fatalf("current failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Author: Personal <me@example.com>") {
+		t.Fatalf("expected author override in current, got %q", out.String())
+	}
+
+	if err := Set(&out, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "author.email"); got != "" {
+		t.Fatalf("expected author override cleared, got %q", got)
+	}
+}
+
+func TestReadOnlySetDescribesInsteadOfWriting(t *testing.T) {
+	repo := newSwitchRepo(t)
+	before, err := os.ReadFile(filepath.Join(config.Dir(), "identities.json"))
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+
+	var log bytes.Buffer
+	ReadOnlyLog = &log
+	SetReadOnly()
+	t.Cleanup(func() {
+		ReadOnly, ReadOnlyLog = false, os.Stderr
+		config.SetReadOnly(nil)
+	})
+
+	var out bytes.Buffer
+	if err := Set(&out, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "user.email"); got != "" {
+		t.Fatalf("read-only set wrote user.email %q", got)
+	}
+	after, _ := os.ReadFile(filepath.Join(config.Dir(), "identities.json"))
+	if !bytes.Equal(before, after) {
+		t.Fatalf("read-only set changed the config:\n%s", after)
+	}
+	if !strings.Contains(log.String(), "would set user.name and user.email of "+repo+" to Work <me@corp.com>") {
+		t.Fatalf("expected the skipped change described, got %q", log.String())
+	}
+}
+
+func TestReposOutsideWorkspaceNeedAllowOutside(t *testing.T) {
+	newSwitchRepo(t)
+	outside := t.TempDir()
+	if out, err := exec.Command("git", "init", outside).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v (%s)", err, out)
+	}
+	outside, _ = filepath.EvalSymlinks(outside)
+	t.Chdir(outside)
+
+	var out bytes.Buffer
+	if err := Set(&out, []string{"me@corp.com"}); err == nil || !strings.Contains(err.Error(), allowOutsideFlag) {
+		t.Fatalf("expected set outside the workspace to be refused, got %v", err)
+	}
+	if got := mustGit(t, outside, "config", "--local", "user.email"); got != "" {
+		t.Fatalf("refused set wrote user.email %q", got)
+	}
+
+	if err := Rule(&out, []string{"add", outside, "me@corp.com"}); err != nil {
+		t.Fatalf("rule add failed: %v", err)
+	}
+	if err := Config(&out, []string{"auto_apply", "on"}); err != nil {
+		t.Fatalf("config failed: %v", err)
+	}
+	out.Reset()
+	if err := Auto(&out, nil); err != nil {
+		t.Fatalf("auto failed: %v", err)
+	}
+	if got := mustGit(t, outside, "config", "--local", "user.email"); got != "" || !strings.Contains(out.String(), "outside your workspace dirs") {
+		t.Fatalf("expected auto to leave the repo alone, got %q:\n%s", got, out.String())
+	}
+	if err := Auto(&out, []string{allowOutsideFlag}); err != nil {
+		t.Fatalf("auto failed: %v", err)
+	}
+	if got := mustGit(t, outside, "config", "--local", "user.email"); got != "me@corp.com" {
+		t.Fatalf("expected auto --allow-outside to switch, got %q", got)
+	}
+
+	if err := Set(&out, []string{"me@example.com", allowOutsideFlag}); err != nil {
+		t.Fatalf("set --allow-outside failed: %v", err)
+	}
+	if got := mustGit(t, outside, "config", "--local", "user.email"); got != "me@example.com" {
+		t.Fatalf("expected set --allow-outside to switch, got %q", got)
+	}
+	// Once mapped, the repo is trusted
+	if err := Set(&out, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set of a mapped repo failed: %v", err)
+	}
+}
+
+func TestGroupReposByPlatformAndOrg(t *testing.T) {
+	entries := []config.IndexedRepo{
+		{Path: "/src/api", Platform: identity.PlatformGitHub, Remotes: map[string]string{
+			"origin": "git@github-work:Acme/api.git", "upstream": "https://github.com/oss/api"}},
+		{Path: "/src/notes"},
+		{Path: "/src/web", Platform: identity.PlatformGitHub, Remotes: map[string]string{"origin": "https://github.com/acme/web"}},
+		{Path: "/src/infra", Platform: identity.PlatformGitLab, Remotes: map[string]string{"origin": "git@gitlab.com:client/ops/infra.git"}},
+	}
+
+	var got []string
+	for _, g := range groupRepos(entries, groupOrg) {
+		got = append(got, g.Org+"="+strings.Join(g.Repos, ","))
+	}
+	want := []string{"github.com/acme=api,web", "gitlab.com/client/ops=infra", "(no remote)=notes"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("org groups = %v, want %v", got, want)
+	}
+
+	got = nil
+	for _, g := range groupRepos(entries, groupPlatform) {
+		got = append(got, string(g.Platform)+"="+strings.Join(g.Repos, ","))
+	}
+	want = []string{"github=api,web", "gitlab=infra", "=notes"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("platform groups = %v, want %v", got, want)
+	}
+}
+
+func TestSwitchesBumpTheGeneration(t *testing.T) {
+	newSwitchRepo(t)
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if got := config.Generation(); got != 1 {
+		t.Fatalf("expected generation 1 after set, got %d", got)
+	}
+	if err := Set(&bytes.Buffer{}, []string{"--author-only", "me@example.com"}); err != nil {
+		t.Fatalf("set --author-only failed: %v", err)
+	}
+	if got := config.Generation(); got != 2 {
+		t.Fatalf("expected generation 2 after set --author-only, got %d", got)
+	}
+
+	// A generation that cannot be bumped fails the switch instead of leaving
+	// prompts stale
+	path := filepath.Join(config.Dir(), "generation")
+	os.Remove(path)
+	if err := os.MkdirAll(filepath.Join(path, "blocked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(&bytes.Buffer{}, []string{"me@example.com"}); err == nil || !strings.Contains(err.Error(), "switch generation") {
+		t.Fatalf("set = %v, want the failed bump reported", err)
+	}
+}
+
+func TestCurrentAndMixedAsJSON(t *testing.T) {
+	repo := newSwitchRepo(t)
+	mustGit(t, repo, "config", "user.name", "Work")
+	mustGit(t, repo, "config", "user.email", "me@corp.com")
+	OutputFormat = render.JSON
+	t.Cleanup(func() { OutputFormat = render.Styled })
+
+	var out bytes.Buffer
+	if err := Current(&out, nil); err != nil {
+		t.Fatalf("current failed: %v", err)
+	}
+	var current currentReport
+	if err := json.Unmarshal(out.Bytes(), &current); err != nil {
+		t.Fatalf("current printed no JSON: %v\n%s", err, out.String())
+	}
+	if current.Email != "me@corp.com" || current.Source != "git" || current.Repo != repo {
+		t.Fatalf("unexpected current identity: %+v", current)
+	}
+
+	out.Reset()
+	if err := Mixed(&out, nil); err != nil {
+		t.Fatalf("mixed failed: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "[]" {
+		t.Fatalf("expected an empty JSON list of mixed repos, got %s", got)
+	}
+}
+
+func TestCurrentCopiesIdentity(t *testing.T) {
+	newSwitchRepo(t)
+	var copied string
+	writeClipboard = func(text string) error { copied = text; return nil }
+	t.Cleanup(func() { writeClipboard = clipboard.WriteAll })
+
+	var out bytes.Buffer
+	if err := Current(&out, []string{"--copy"}); err == nil {
+		t.Fatalf("expected copying without an identity to fail:\n%s", out.String())
+	}
+	if err := Set(&out, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	out.Reset()
+	if err := Current(&out, []string{"--copy"}); err != nil {
+		t.Fatalf("current --copy failed: %v", err)
+	}
+	if copied != "Personal <me@example.com>" || !strings.Contains(out.String(), "copied to clipboard") {
+		t.Fatalf("expected the identity to be copied, got %q:\n%s", copied, out.String())
+	}
+}
+
+func TestRepoIdentityReadsWorktreesFromTheirRepo(t *testing.T) {
+	repo := newSwitchRepo(t)
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	mustGit(t, repo, "commit", "-q", "--allow-empty", "-m", "c")
+	worktree := filepath.Join(t.TempDir(), "wt")
+	mustGit(t, repo, "worktree", "add", "-q", worktree)
+
+	if got := repoIdentity(worktree, "global"); !strings.Contains(got, "me@corp.com") {
+		t.Errorf("repoIdentity(worktree) = %q, want the repo's local identity", got)
+	}
+	if repoModified(worktree).IsZero() {
+		t.Error("expected the worktree's config to have a modification time")
+	}
+}
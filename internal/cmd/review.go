@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/ui"
+)
+
+// Review accepts, merges or dismisses pending candidate identities and the
+// names scans found for stored ones
+func Review(w io.Writer, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	queue, err := config.LoadCandidates()
+	if err != nil {
+		return fmt.Errorf("loading candidates: %w", err)
+	}
+
+	if len(args) == 0 {
+		if len(queue.Pending) == 0 && len(queue.Renames) == 0 {
+			fmt.Fprintln(w, "No candidate identities to review.")
+			return nil
+		}
+		for _, r := range slices.Clone(queue.Renames) {
+			applyRename(w, cfg, queue, r, confirm(w, renameQuestion(r)))
+		}
+		if len(queue.Pending) == 0 {
+			return saveReview(cfg, queue)
+		}
+		p := tea.NewProgram(ui.NewReview(queue.Pending, cfg.Identities))
+		finalModel, err := p.Run()
+		if err != nil {
+			return fmt.Errorf("running TUI: %w", err)
+		}
+		for _, d := range finalModel.(ui.ReviewModel).Decisions() {
+			if d.Action != ui.ReviewSkip {
+				if err := applyReview(w, cfg, queue, d); err != nil {
+					return err
+				}
+			}
+		}
+		return saveReview(cfg, queue)
+	}
+
+	var d ui.ReviewDecision
+	switch args[0] {
+	case "list", "ls":
+		return listCandidates(w, queue)
+	case "accept":
+		d.Action = ui.ReviewAccept
+	case "dismiss":
+		d.Action = ui.ReviewDismiss
+	case "merge":
+		if len(args) < 3 {
+			return usageErr("gitme review merge <candidate-email> <email|alias>")
+		}
+		into := resolveIdentity(cfg, args[2])
+		if into == nil {
+			return fmt.Errorf("identity not found: %s", args[2])
+		}
+		d.Action, d.Into = ui.ReviewMerge, into.Email
+	default:
+		return usageErr("gitme review [list|accept <email>|merge <email> <into>|dismiss <email>]")
+	}
+	if len(args) < 2 {
+		return usageErr("gitme review %s <candidate-email>", args[0])
+	}
+	if d.Action != ui.ReviewMerge {
+		if r, ok := queue.PendingRename(args[1]); ok {
+			applyRename(w, cfg, queue, r, d.Action == ui.ReviewAccept)
+			return saveReview(cfg, queue)
+		}
+	}
+
+	for _, c := range queue.Pending {
+		if strings.EqualFold(c.Email, args[1]) {
+			d.Candidate = c
+		}
+	}
+	if d.Candidate.Email == "" {
+		return fmt.Errorf("no pending candidate: %s", args[1])
+	}
+	if err := applyReview(w, cfg, queue, d); err != nil {
+		return err
+	}
+	return saveReview(cfg, queue)
+}
+
+// applyReview carries out one decision on the config and the queue
+func applyReview(w io.Writer, cfg *config.Config, queue *config.CandidatesConfig, d ui.ReviewDecision) error {
+	c := d.Candidate
+	switch d.Action {
+	case ui.ReviewAccept:
+		queue.Take(c.Email)
+		cfg.AcceptCandidate(c)
+		fmt.Fprintln(w, SuccessStyle.Render("Accepted:"), c.Identity.String())
+	case ui.ReviewMerge:
+		if !cfg.MergeCandidate(c, d.Into) {
+			return fmt.Errorf("identity not found: %s", d.Into)
+		}
+		queue.Take(c.Email)
+		fmt.Fprintln(w, SuccessStyle.Render("Merged:"), c.Email, "→", d.Into)
+	case ui.ReviewDismiss:
+		queue.Dismiss(c.Email)
+		fmt.Fprintln(w, DimStyle.Render("Dismissed: "+c.Email))
+	}
+	return nil
+}
+
+// applyRename renames the identity of a pending rename, or keeps its name
+// and stops proposing the new one
+func applyRename(w io.Writer, cfg *config.Config, queue *config.CandidatesConfig, r config.Rename, accept bool) {
+	if !accept {
+		queue.DismissRename(r)
+		fmt.Fprintln(w, DimStyle.Render(fmt.Sprintf("Kept %s as %s", r.Email, r.Name)))
+		return
+	}
+	queue.TakeRename(r.Email)
+	cfg.RenameIdentity(r.Email, r.Scanned)
+	fmt.Fprintln(w, SuccessStyle.Render("Renamed:"), r.Email, r.Name, "→", r.Scanned)
+}
+
+// renameQuestion asks whether to take the name a scan found
+func renameQuestion(r config.Rename) string {
+	return fmt.Sprintf("%s is stored as %q but %s says %q. Use the new name?", r.Email, r.Name, cmp.Or(r.Source, "a scan"), r.Scanned)
+}
+
+// settleRenames deals with the names a scan found for stored identities:
+// with ask and someone at the terminal each is asked about, otherwise they
+// are queued for gitme review. Renames dismissed before are left out.
+func settleRenames(w io.Writer, cfg *config.Config, renames []config.Rename, ask bool) error {
+	if len(renames) == 0 {
+		return nil
+	}
+	queue, err := config.LoadCandidates()
+	if err != nil {
+		return fmt.Errorf("loading candidates: %w", err)
+	}
+	if queue.QueueRenames(renames) == 0 {
+		return nil
+	}
+	if ask && interactive() {
+		for _, r := range renames {
+			if pending, ok := queue.PendingRename(r.Email); ok {
+				applyRename(w, cfg, queue, pending, confirm(w, renameQuestion(pending)))
+			}
+		}
+	} else if ask {
+		fmt.Fprintf(w, "%s %d identities have a new name; review with: gitme review\n", WarnStyle.Render("⚠"), len(queue.Renames))
+	}
+	if err := queue.Save(); err != nil {
+		return fmt.Errorf("saving candidates: %w", err)
+	}
+	return nil
+}
+
+func saveReview(cfg *config.Config, queue *config.CandidatesConfig) error {
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	if err := queue.Save(); err != nil {
+		return fmt.Errorf("saving candidates: %w", err)
+	}
+	return nil
+}
+
+// reviewQueue is what gitme review list shows
+type reviewQueue struct {
+	Pending []identity.Candidate `json:"pending"`
+	Renames []config.Rename      `json:"renames"`
+}
+
+// listCandidates prints the pending queue
+func listCandidates(w io.Writer, queue *config.CandidatesConfig) error {
+	out := newRenderer(w)
+	if len(queue.Pending) == 0 && len(queue.Renames) == 0 && out.Format() != render.JSON {
+		fmt.Fprintln(w, "No candidate identities to review.")
+		return nil
+	}
+	var blocks []render.Block
+	if len(queue.Pending) > 0 {
+		team := teamBook()
+		var list render.List
+		for _, c := range queue.Pending {
+			detail := []string{fmt.Sprintf("%d commits in %d repos (%s)", c.Commits, len(c.Repos), c.Source)}
+			if c.Like != "" {
+				detail = append(detail, fmt.Sprintf("Probably %s with another address (%s)", c.Like, c.Reason))
+			}
+			if member, ok := team.Find(c.Email); ok {
+				detail = append(detail, WarnStyle.Render("In the team directory as "+member.Name+", likely a collaborator"))
+			}
+			list = append(list, render.Item{Text: c.Identity.String(), Detail: detail})
+		}
+		blocks = append(blocks, render.Header("Candidate identities:"), list)
+	}
+	if len(queue.Renames) > 0 {
+		var list render.List
+		for _, r := range queue.Renames {
+			list = append(list, render.Item{
+				Text:   r.Email,
+				Detail: []string{fmt.Sprintf("%s → %s (%s)", r.Name, r.Scanned, cmp.Or(r.Source, "scan"))},
+			})
+		}
+		if len(blocks) > 0 {
+			blocks = append(blocks, render.Line(""))
+		}
+		blocks = append(blocks, render.Header("New names:"), list)
+	}
+	blocks = append(blocks, render.Note("Review with: gitme review (or accept/merge/dismiss <email>)"))
+	data := reviewQueue{Pending: queue.Pending, Renames: queue.Renames}
+	if data.Renames == nil {
+		data.Renames = []config.Rename{}
+	}
+	return out.Render(data, blocks...)
+}
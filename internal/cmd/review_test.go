@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+func TestReviewMergesAndDismissesCandidates(t *testing.T) {
+	newSwitchRepo(t)
+	queue := &config.CandidatesConfig{}
+	queue.Queue([]identity.Candidate{
+		{Identity: identity.Identity{Name: "Work", Email: "me@old-corp.com", Source: identity.SourceHistory}, Commits: 5},
+		{Identity: identity.Identity{Name: "Work", Email: "root@buildbox", Source: identity.SourceHistory}, Commits: 3},
+	})
+	if err := queue.Save(); err != nil {
+		t.Fatalf("saving candidates: %v", err)
+	}
+
+	if err := Review(io.Discard, []string{"merge", "me@old-corp.com", "me@corp.com"}); err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if err := Review(io.Discard, []string{"dismiss", "root@buildbox"}); err != nil {
+		t.Fatalf("dismiss failed: %v", err)
+	}
+
+	cfg, _ := config.Load()
+	if len(cfg.Identities) != 2 {
+		t.Fatalf("got %d identities, want the original 2", len(cfg.Identities))
+	}
+	if alt := cfg.Identities[0].AltEmails; len(alt) != 1 || alt[0] != "me@old-corp.com" {
+		t.Fatalf("AltEmails = %v, want the merged address", alt)
+	}
+	queue, _ = config.LoadCandidates()
+	if len(queue.Pending) != 0 || len(queue.Dismissed) != 1 {
+		t.Fatalf("queue = %+v, want empty with one dismissal", queue)
+	}
+	if err := Review(io.Discard, []string{"accept", "root@buildbox"}); err == nil {
+		t.Fatalf("accepting a dismissed candidate should fail")
+	}
+}
+
+func TestScannedNamesAreQueuedForReview(t *testing.T) {
+	newSwitchRepo(t)
+	cfg, _ := config.Load()
+	renames := cfg.UpdateIdentities([]identity.Identity{
+		{Name: "Jane Doe", Email: "me@corp.com", Source: "git-global"},
+		{Name: "Personal", Email: "me@example.com"},
+	})
+	if len(renames) != 1 || renames[0].Name != "Work" || renames[0].Scanned != "Jane Doe" {
+		t.Fatalf("renames = %+v, want Work → Jane Doe", renames)
+	}
+	if cfg.Identities[0].Name != "Work" {
+		t.Fatalf("stored name changed to %q before review", cfg.Identities[0].Name)
+	}
+	if err := settleRenames(io.Discard, cfg, renames, true); err != nil {
+		t.Fatalf("settling renames failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Review(&out, []string{"list"}); err != nil {
+		t.Fatalf("review list failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Work → Jane Doe (git-global)") {
+		t.Fatalf("expected the rename listed, got:\n%s", out.String())
+	}
+	if err := Review(io.Discard, []string{"accept", "me@corp.com"}); err != nil {
+		t.Fatalf("accepting the rename failed: %v", err)
+	}
+	cfg, _ = config.Load()
+	if cfg.Identities[0].Name != "Jane Doe" {
+		t.Fatalf("name = %q, want the accepted one", cfg.Identities[0].Name)
+	}
+
+	// A dismissed name is not proposed again
+	renames = cfg.UpdateIdentities([]identity.Identity{{Name: "J. Doe", Email: "me@corp.com"}})
+	settleRenames(io.Discard, cfg, renames, false)
+	if err := Review(io.Discard, []string{"dismiss", "me@corp.com"}); err != nil {
+		t.Fatalf("dismissing the rename failed: %v", err)
+	}
+	settleRenames(io.Discard, cfg, renames, false)
+	queue, _ := config.LoadCandidates()
+	if len(queue.Renames) != 0 || len(queue.DismissedRenames) != 1 {
+		t.Fatalf("queue = %+v, want the dismissed rename kept out", queue)
+	}
+}
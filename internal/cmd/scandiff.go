@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+// sourceChange is where a scan newly found an identity and where it no
+// longer did
+type sourceChange struct {
+	Email   string   `json:"email"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// scanDiff is what a rescan changed about the stored identities
+type scanDiff struct {
+	Identities []identity.Identity `json:"identities"`
+	Added      []identity.Identity `json:"added"`
+	Removed    []identity.Identity `json:"removed"`
+	Renamed    []config.Rename     `json:"renamed"`
+	Sources    []sourceChange      `json:"sources"`
+}
+
+// empty reports whether the scan changed nothing
+func (d scanDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Renamed) == 0 && len(d.Sources) == 0
+}
+
+// diffScan compares the identities stored before a scan with the ones after
+// it; renames are the names the scan found for stored identities
+func diffScan(previous, current []identity.Identity, renames []config.Rename) scanDiff {
+	diff := scanDiff{
+		Identities: current,
+		Added:      []identity.Identity{},
+		Removed:    []identity.Identity{},
+		Renamed:    renames,
+		Sources:    []sourceChange{},
+	}
+	if diff.Renamed == nil {
+		diff.Renamed = []config.Rename{}
+	}
+	before := make(map[string]identity.Identity)
+	for _, id := range previous {
+		before[strings.ToLower(id.Email)] = id
+	}
+	after := make(map[string]bool)
+	for _, id := range current {
+		key := strings.ToLower(id.Email)
+		after[key] = true
+		prev, ok := before[key]
+		if !ok {
+			diff.Added = append(diff.Added, id)
+			continue
+		}
+		change := sourceChange{Email: id.Email}
+		for _, source := range id.Sources {
+			if !slices.Contains(prev.Sources, source) {
+				change.Added = append(change.Added, source)
+			}
+		}
+		for _, source := range prev.Sources {
+			if !slices.Contains(id.Sources, source) {
+				change.Removed = append(change.Removed, source)
+			}
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			diff.Sources = append(diff.Sources, change)
+		}
+	}
+	for _, id := range previous {
+		if !after[strings.ToLower(id.Email)] {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+	return diff
+}
+
+// printScanDiff reports what a rescan changed
+func printScanDiff(w io.Writer, diff scanDiff) error {
+	out := newRenderer(w)
+	if diff.empty() {
+		return out.Render(diff,
+			render.Line(out.Style(SuccessStyle, fmt.Sprintf("Found %d identities, nothing changed since the last scan", len(diff.Identities)))))
+	}
+
+	blocks := []render.Block{render.Line(out.Style(SuccessStyle, fmt.Sprintf("Found %d identities: %d added, %d removed, %d renamed, %d with changed sources",
+		len(diff.Identities), len(diff.Added), len(diff.Removed), len(diff.Renamed), len(diff.Sources))))}
+	section := func(title string, list render.List) {
+		if len(list) > 0 {
+			blocks = append(blocks, render.Line(""), render.Header(title), list)
+		}
+	}
+
+	var added, removed, renamed, sources render.List
+	for _, id := range diff.Added {
+		added = append(added, render.Item{Marker: out.Style(SuccessStyle, "+"), Text: id.String(), Detail: id.Sources})
+	}
+	for _, id := range diff.Removed {
+		removed = append(removed, render.Item{Marker: out.Style(WarnStyle, "-"), Text: id.String(), Detail: id.Sources})
+	}
+	for _, r := range diff.Renamed {
+		renamed = append(renamed, render.Item{Text: r.Email, Detail: []string{r.Name + " → " + r.Scanned}})
+	}
+	for _, c := range diff.Sources {
+		var detail []string
+		for _, source := range c.Added {
+			detail = append(detail, "+ "+source)
+		}
+		for _, source := range c.Removed {
+			detail = append(detail, "- "+source)
+		}
+		sources = append(sources, render.Item{Text: c.Email, Detail: detail})
+	}
+	section("Added:", added)
+	section("No longer found:", removed)
+	section("Renamed:", renamed)
+	section("Sources changed:", sources)
+	blocks = append(blocks, render.Note("List all identities with: gitme list"))
+	return out.Render(diff, blocks...)
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+const signingUsage = "gitme signing <email|alias> [gpg <key-id>|ssh <public-key>|none]"
+
+// Signing formats an identity's key can have
+const (
+	signingGPG = "gpg"
+	signingSSH = "ssh"
+)
+
+// signingMarker is set next to the signing config gitme writes, so switching
+// to an identity without a key removes only gitme's config
+const signingMarker = "gitme.signing"
+
+// Signing shows or sets the key commits are signed with in repos switched to
+// an identity
+func Signing(w io.Writer, args []string) error {
+	if len(args) < 1 {
+		return usageErr(signingUsage)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	id := resolveIdentity(cfg, args[0])
+	if id == nil {
+		return fmt.Errorf("identity not found: %s", args[0])
+	}
+
+	if len(args) < 2 {
+		if id.SigningKey == "" {
+			fmt.Fprintln(w, "No signing key set for", id.Email)
+			return nil
+		}
+		fmt.Fprintln(w, describeSigning(id))
+		return nil
+	}
+
+	switch {
+	case args[1] == "none" && len(args) == 2:
+		id.SigningKey, id.SigningFormat = "", ""
+	case args[1] == signingGPG && len(args) == 3:
+		id.SigningKey, id.SigningFormat = args[2], signingGPG
+	case args[1] == signingSSH && len(args) == 3:
+		key, err := sshKeyPath(args[2])
+		if err != nil {
+			return err
+		}
+		id.SigningKey, id.SigningFormat = key, signingSSH
+	default:
+		return usageErr(signingUsage)
+	}
+
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	if id.SigningKey == "" {
+		fmt.Fprintln(w, SuccessStyle.Render("Cleared signing key:"), id.Email)
+	} else {
+		fmt.Fprintln(w, SuccessStyle.Render("Set signing key:"), id.Email, "→", describeSigning(id))
+	}
+	fmt.Fprintln(w, DimStyle.Render("Re-apply it to a repo with: gitme set "+id.Email))
+	return nil
+}
+
+func describeSigning(id *identity.Identity) string {
+	return id.SigningFormat + " " + id.SigningKey
+}
+
+// signingConfig is the signing config ApplyIdentity writes for id, in the
+// order it writes it; empty for identities without a signing key
+func signingConfig(id identity.Identity) [][2]string {
+	if id.SigningKey == "" {
+		return nil
+	}
+	format := "openpgp"
+	if id.SigningFormat == signingSSH {
+		format = signingSSH
+	}
+	return [][2]string{
+		{"user.signingkey", id.SigningKey},
+		{"gpg.format", format},
+		{"commit.gpgsign", "true"},
+	}
+}
+
+// applySigning writes the signing config of id to the local config of the
+// repo at dir. For identities without a key it removes the config gitme wrote
+// before and leaves config the user set alone.
+func applySigning(dir string, id identity.Identity) error {
+	set := signingConfig(id)
+	if len(set) == 0 {
+		if localConfigValue(dir, signingMarker) != "true" {
+			return nil
+		}
+		for _, key := range []string{"user.signingkey", "gpg.format", "commit.gpgsign", signingMarker} {
+			cmd := exec.Command("git", "config", "--local", "--unset", key)
+			cmd.Dir = dir
+			cmd.Run() // unset fails harmlessly when the key is absent
+		}
+		return nil
+	}
+	for _, kv := range append(set, [2]string{signingMarker, "true"}) {
+		cmd := exec.Command("git", "config", "--local", kv[0], kv[1])
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("setting %s: %s", kv[0], strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+func TestSigningKeysFollowTheIdentity(t *testing.T) {
+	repo := newSwitchRepo(t)
+	if err := Signing(&bytes.Buffer{}, []string{"me@corp.com", "gpg", "ABCD1234"}); err != nil {
+		t.Fatalf("signing failed: %v", err)
+	}
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	for key, want := range map[string]string{"user.signingkey": "ABCD1234", "gpg.format": "openpgp", "commit.gpgsign": "true"} {
+		if got := mustGit(t, repo, "config", "--local", key); got != want {
+			t.Fatalf("expected %s %q, got %q", key, want, got)
+		}
+	}
+
+	if err := Set(&bytes.Buffer{}, []string{"me@example.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	for _, key := range []string{"user.signingkey", "gpg.format", "commit.gpgsign"} {
+		if got := mustGit(t, repo, "config", "--local", key); got != "" {
+			t.Fatalf("expected %s to be removed, got %q", key, got)
+		}
+	}
+
+	// Signing config the user set stays
+	mustGit(t, repo, "config", "--local", "user.signingkey", "USERKEY")
+	if err := ApplyIdentity(repo, identity.Identity{Name: "Personal", Email: "me@example.com"}); err != nil {
+		t.Fatalf("ApplyIdentity failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "user.signingkey"); got != "USERKEY" {
+		t.Fatalf("expected the user's user.signingkey to stay, got %q", got)
+	}
+}
@@ -2,30 +2,40 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
 	"github.com/vosamoilenko/gitme/internal/stats"
 )
 
 // Stats shows commit statistics by identity
-func Stats() {
-	cwd, err := os.Getwd()
+func Stats(w io.Writer, args []string) error {
+	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading config: %w", err)
 	}
-
-	// Check if --all flag
-	showAll := len(os.Args) >= 3 && (os.Args[2] == "--all" || os.Args[2] == "-a")
-
-	cfg, err := config.Load()
+	settings, err := config.LoadSettings()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	zone := settings.Timezone
+	if value, ok := flagValue(args, "--timezone"); ok {
+		zone = value
+	}
+	loc, err := statsLocation(zone)
+	if err != nil {
+		return err
+	}
+	path, byPath := flagValue(args, "--path")
+	args = withoutFlag(withoutFlag(args, "--timezone"), "--path")
+	if hasFlag(args, "--reindex") {
+		rebuildRepoIndex(cfg)
 	}
 
 	// Build set of known emails
@@ -34,184 +44,256 @@ func Stats() {
 		knownEmails[strings.ToLower(id.Email)] = true
 	}
 
-	if showAll {
-		statsAll(knownEmails)
-	} else {
-		statsSingle(cwd, knownEmails)
+	if byPath && hasFlag(args, "--compare", "--all", "-a") {
+		return usageErr("gitme stats --path <path> (in the current repo only)")
 	}
+	if hasFlag(args, "--compare") {
+		return statsCompare(w, cfg, positionalArgs(args), loc)
+	}
+	if hasFlag(args, "--all", "-a") {
+		return statsAll(w, cfg, knownEmails, loc, hasFlag(args, "--strict"))
+	}
+	return statsSingle(w, cfg, knownEmails, loc, path)
 }
 
-func statsSingle(cwd string, knownEmails map[string]bool) {
-	// Check if we're in a git repo
-	gitDir := filepath.Join(cwd, ".git")
-	if _, err := os.Stat(gitDir); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: not a git repository\n")
-		os.Exit(1)
+// repoPathspec turns a path given relative to the working directory into a
+// pathspec relative to the repo root
+func repoPathspec(root, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		if resolved, err := filepath.EvalSymlinks(cwd); err == nil {
+			cwd = resolved
+		}
+		path = filepath.Join(cwd, path)
 	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path outside the repository: %s", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
 
-	repoStats, err := stats.CollectRepoStats(cwd, knownEmails)
+// statsLocation resolves the zone commits are bucketed in: an IANA name,
+// "local", or "" / "commit" for each commit's own offset (nil)
+func statsLocation(zone string) (*time.Location, error) {
+	switch strings.ToLower(zone) {
+	case "", "commit":
+		return nil, nil
+	case "local":
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(zone)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error collecting stats: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("unknown timezone: %s", zone)
 	}
+	return loc, nil
+}
 
-	if repoStats.TotalCount == 0 {
-		fmt.Println("No commits found from your known identities in this repo.")
-		return
+// recordLastCommits treats each identity's latest commit as a use of it
+func recordLastCommits(repoStats *stats.RepoStats) error {
+	uses := make(map[string]time.Time)
+	for _, idStats := range repoStats.ByIdentity {
+		uses[idStats.Email] = idStats.LastCommit
 	}
-
-	printRepoStats(repoStats)
+	if err := config.RecordUses(uses); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	return nil
 }
 
-func statsAll(knownEmails map[string]bool) {
-	home, _ := os.UserHomeDir()
+// statsSingle shows stats for the current repo, limited to commits touching
+// path when it is not empty
+func statsSingle(w io.Writer, cfg *config.Config, knownEmails map[string]bool, loc *time.Location, path string) error {
+	root, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
 
-	workspaceDirs := []string{
-		filepath.Join(home, "Developer"),
-		filepath.Join(home, "Projects"),
-		filepath.Join(home, "Code"),
-		filepath.Join(home, "workspace"),
-		filepath.Join(home, "src"),
-		filepath.Join(home, "work"),
+	var pathspecs []string
+	title := "Commits by your identities:"
+	if path != "" {
+		pathspec, err := repoPathspec(root, path)
+		if err != nil {
+			return err
+		}
+		pathspecs = append(pathspecs, pathspec)
+		title = fmt.Sprintf("Commits by your identities touching %s:", path)
 	}
 
+	repoStats, err := stats.CollectRepoStats(root, knownEmails, stats.Options{Location: loc, Paths: pathspecs})
+	if err != nil {
+		return fmt.Errorf("collecting stats: %w", err)
+	}
+
+	if repoStats.TotalCount == 0 && OutputFormat != render.JSON {
+		fmt.Fprintln(w, "No commits found from your known identities in this repo.")
+		return nil
+	}
+	if err := recordLastCommits(repoStats); err != nil {
+		return err
+	}
+
+	return renderStats(w, repoStats, identityColors(cfg.Identities), 0, title, "")
+}
+
+// statsAll aggregates stats over every known repo; repos git stalled on are
+// listed at the end
+func statsAll(w io.Writer, cfg *config.Config, knownEmails map[string]bool, loc *time.Location, strict bool) error {
 	// Aggregate stats across all repos
 	aggregated := &stats.RepoStats{
 		ByIdentity: make(map[string]*stats.IdentityStats),
 	}
 
 	repoCount := 0
-	for _, dir := range workspaceDirs {
-		if _, err := os.Stat(dir); err == nil {
-			collectAllRepos(dir, 4, knownEmails, aggregated, &repoCount)
+	env := repowalk.GitEnv()
+	var failed []repowalk.Skipped
+	for r := range collectStats(cfg, knownEmails, stats.Options{Env: env, Location: loc}) {
+		if s, ok := repowalk.Failed(r.repo, r.err); ok {
+			failed = append(failed, s)
+		}
+		if r.err == nil && r.stats.TotalCount > 0 {
+			repoCount++
+			mergeRepoStats(aggregated, r.stats)
 		}
 	}
 
-	if aggregated.TotalCount == 0 {
-		fmt.Println("No commits found from your known identities.")
-		return
+	if aggregated.TotalCount == 0 && OutputFormat != render.JSON {
+		fmt.Fprintln(w, "No commits found from your known identities.")
+		return reportSkipped(w, failed, strict)
+	}
+	if err := recordLastCommits(aggregated); err != nil {
+		return err
 	}
 
-	fmt.Printf("%s (across %d repositories)\n\n", HeaderStyle.Render("Your commit statistics"), repoCount)
-	printIdentityStats(aggregated)
-	printWeekdayChart(aggregated)
-}
-
-func collectAllRepos(dir string, maxDepth int, knownEmails map[string]bool, aggregated *stats.RepoStats, repoCount *int) {
-	if maxDepth <= 0 {
-		return
+	if err := renderStats(w, aggregated, identityColors(cfg.Identities), repoCount, "Your commit statistics", fmt.Sprintf(" (across %d repositories)", repoCount)); err != nil {
+		return err
 	}
+	return reportSkipped(w, failed, strict)
+}
 
-	entries, _ := os.ReadDir(dir)
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
+// repoStatsResult is the stats of one repo, or why git failed on it
+type repoStatsResult struct {
+	repo  string
+	stats *stats.RepoStats
+	err   error
+}
 
-		subdir := filepath.Join(dir, entry.Name())
-		gitDir := filepath.Join(subdir, ".git")
-
-		if _, err := os.Stat(gitDir); err == nil {
-			// Found a repo
-			repoStats, err := stats.CollectRepoStats(subdir, knownEmails)
-			if err == nil && repoStats.TotalCount > 0 {
-				*repoCount++
-				aggregated.TotalCount += repoStats.TotalCount
-
-				// Merge identity stats
-				for email, idStats := range repoStats.ByIdentity {
-					if existing, ok := aggregated.ByIdentity[email]; ok {
-						existing.CommitCount += idStats.CommitCount
-						if idStats.FirstCommit.Before(existing.FirstCommit) {
-							existing.FirstCommit = idStats.FirstCommit
-						}
-						if idStats.LastCommit.After(existing.LastCommit) {
-							existing.LastCommit = idStats.LastCommit
-						}
-						for day, count := range idStats.ByWeekday {
-							existing.ByWeekday[day] += count
-						}
-						for hour, count := range idStats.ByHour {
-							existing.ByHour[hour] += count
-						}
-					} else {
-						// Copy the stats
-						aggregated.ByIdentity[email] = &stats.IdentityStats{
-							Name:        idStats.Name,
-							Email:       idStats.Email,
-							CommitCount: idStats.CommitCount,
-							FirstCommit: idStats.FirstCommit,
-							LastCommit:  idStats.LastCommit,
-							ByWeekday:   make(map[time.Weekday]int),
-							ByHour:      make(map[int]int),
-						}
-						for day, count := range idStats.ByWeekday {
-							aggregated.ByIdentity[email].ByWeekday[day] = count
-						}
-						for hour, count := range idStats.ByHour {
-							aggregated.ByIdentity[email].ByHour[hour] = count
-						}
-					}
-				}
-			}
-		}
+// collectStats collects the stats of every known repo concurrently and sends
+// them as they are ready
+func collectStats(cfg *config.Config, emails map[string]bool, opts stats.Options) <-chan repoStatsResult {
+	repos, _ := indexedRepos(cfg, nil)
+	return repowalk.Map(repos, func(repo string) repoStatsResult {
+		repoStats, err := stats.CollectRepoStats(repo, emails, opts)
+		return repoStatsResult{repo, repoStats, err}
+	})
+}
 
-		if maxDepth > 1 {
-			collectAllRepos(subdir, maxDepth-1, knownEmails, aggregated, repoCount)
+// mergeRepoStats adds the stats of one repo to the aggregate
+func mergeRepoStats(aggregated, repoStats *stats.RepoStats) {
+	aggregated.TotalCount += repoStats.TotalCount
+	for email, idStats := range repoStats.ByIdentity {
+		if existing, ok := aggregated.ByIdentity[email]; ok {
+			addIdentityStats(existing, idStats)
+			continue
 		}
+		aggregated.ByIdentity[email] = copyIdentityStats(idStats)
 	}
 }
 
-func printRepoStats(repoStats *stats.RepoStats) {
-	fmt.Println(HeaderStyle.Render("Commits by your identities:"))
-	fmt.Println()
-	printIdentityStats(repoStats)
-	printWeekdayChart(repoStats)
+// addIdentityStats adds the commits of idStats to existing
+func addIdentityStats(existing, idStats *stats.IdentityStats) {
+	existing.CommitCount += idStats.CommitCount
+	if idStats.FirstCommit.Before(existing.FirstCommit) {
+		existing.FirstCommit = idStats.FirstCommit
+	}
+	if idStats.LastCommit.After(existing.LastCommit) {
+		existing.LastCommit = idStats.LastCommit
+	}
+	for day, count := range idStats.ByWeekday {
+		existing.ByWeekday[day] += count
+	}
+	for hour, count := range idStats.ByHour {
+		existing.ByHour[hour] += count
+	}
 }
 
-func printIdentityStats(repoStats *stats.RepoStats) {
-	sorted := repoStats.SortedIdentities()
-
-	for _, idStats := range sorted {
-		percentage := float64(idStats.CommitCount) / float64(repoStats.TotalCount) * 100
-		fmt.Printf("  %s <%s>\n", idStats.Name, idStats.Email)
-		fmt.Printf("    %s\n", DimStyle.Render(fmt.Sprintf(
-			"%d commits (%.0f%%) | %s → %s",
-			idStats.CommitCount,
-			percentage,
-			idStats.FirstCommit.Format("2006-01-02"),
-			idStats.LastCommit.Format("2006-01-02"),
-		)))
-		fmt.Println()
+func copyIdentityStats(idStats *stats.IdentityStats) *stats.IdentityStats {
+	copied := &stats.IdentityStats{
+		Name:        idStats.Name,
+		Email:       idStats.Email,
+		CommitCount: idStats.CommitCount,
+		FirstCommit: idStats.FirstCommit,
+		LastCommit:  idStats.LastCommit,
+		ByWeekday:   make(map[time.Weekday]int),
+		ByHour:      make(map[int]int),
+	}
+	for day, count := range idStats.ByWeekday {
+		copied.ByWeekday[day] = count
+	}
+	for hour, count := range idStats.ByHour {
+		copied.ByHour[hour] = count
 	}
+	return copied
 }
 
-func printWeekdayChart(repoStats *stats.RepoStats) {
-	weekdayStats := repoStats.AggregatedWeekdayStats()
-	maxCount := stats.MaxWeekdayCount(weekdayStats)
+// statsReport is the data behind gitme stats
+type statsReport struct {
+	Repos      int                    `json:"repos,omitempty"`
+	Total      int                    `json:"total"`
+	Identities []*stats.IdentityStats `json:"identities"`
+	Weekdays   map[string]int         `json:"weekdays"`
+}
 
-	if maxCount == 0 {
-		return
-	}
+var weekdays = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday,
+	time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
 
-	fmt.Println(HeaderStyle.Render("Activity by weekday:"))
-	fmt.Println()
+// renderStats writes identity totals and the weekday chart, each identity in
+// its color
+func renderStats(w io.Writer, repoStats *stats.RepoStats, colors map[string]string, repoCount int, title, suffix string) error {
+	report := statsReport{
+		Repos:      repoCount,
+		Total:      repoStats.TotalCount,
+		Identities: repoStats.SortedIdentities(),
+		Weekdays:   make(map[string]int),
+	}
+	if report.Identities == nil {
+		report.Identities = []*stats.IdentityStats{}
+	}
 
-	days := []time.Weekday{
-		time.Monday, time.Tuesday, time.Wednesday,
-		time.Thursday, time.Friday, time.Saturday, time.Sunday,
+	out := newRenderer(w)
+	var list render.List
+	for _, idStats := range report.Identities {
+		percentage := float64(idStats.CommitCount) / float64(repoStats.TotalCount) * 100
+		list = append(list, render.Item{
+			Text: colorIdentity(out, colors, idStats.Email, fmt.Sprintf("%s <%s>", idStats.Name, idStats.Email)),
+			Detail: []string{fmt.Sprintf("%d commits (%.0f%%) | %s → %s",
+				idStats.CommitCount,
+				percentage,
+				idStats.FirstCommit.Format("2006-01-02"),
+				idStats.LastCommit.Format("2006-01-02"),
+			)},
+		})
 	}
-	dayNames := []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
 
-	maxBarWidth := 30
-	for i, day := range days {
-		count := weekdayStats[day]
-		barLen := 0
-		if maxCount > 0 {
-			barLen = count * maxBarWidth / maxCount
+	blocks := []render.Block{render.Line(out.Style(HeaderStyle, title) + suffix), render.Line(""), list, render.Line("")}
+
+	weekdayStats := repoStats.AggregatedWeekdayStats()
+	if stats.MaxWeekdayCount(weekdayStats) > 0 {
+		var bars render.Bars
+		for _, day := range weekdays {
+			report.Weekdays[day.String()] = weekdayStats[day]
+			bar := render.Bar{Label: day.String()[:3], Value: weekdayStats[day]}
+			for _, idStats := range report.Identities {
+				bar.Parts = append(bar.Parts, render.BarPart{Value: idStats.ByWeekday[day], Color: colors[strings.ToLower(idStats.Email)]})
+			}
+			bars = append(bars, bar)
 		}
-		bar := strings.Repeat("█", barLen)
-		fmt.Printf("  %s %s %s\n", dayNames[i], DimStyle.Render(bar), DimStyle.Render(fmt.Sprintf("%d", count)))
+		blocks = append(blocks, render.Header("Activity by weekday:"), bars, render.Line(""))
 	}
-	fmt.Println()
+	return out.Render(report, blocks...)
 }
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+	"github.com/vosamoilenko/gitme/internal/stats"
+)
+
+// compareSide is one identity of gitme stats --compare
+type compareSide struct {
+	Email string               `json:"email"`
+	Stats *stats.IdentityStats `json:"stats,omitempty"` // nil without commits
+	Repos []string             `json:"repos"`
+}
+
+// statsComparison is the data behind gitme stats --compare
+type statsComparison struct {
+	Identities [2]compareSide `json:"identities"`
+	Overlap    []string       `json:"overlapping_repos"`
+}
+
+// statsCompare puts the commits of two emails across all known repos side
+// by side
+func statsCompare(w io.Writer, cfg *config.Config, args []string, loc *time.Location) error {
+	if len(args) != 2 {
+		return usageErr("gitme stats --compare <email|alias> <email|alias>")
+	}
+	var cmp statsComparison
+	filter := make(map[string]bool)
+	for i, arg := range args {
+		email := arg
+		if id := resolveIdentity(cfg, arg); id != nil {
+			email = id.Email
+		} else if !strings.Contains(arg, "@") {
+			return fmt.Errorf("identity not found: %s", arg)
+		}
+		cmp.Identities[i].Email = email
+		filter[strings.ToLower(email)] = true
+	}
+
+	for r := range collectStats(cfg, filter, stats.Options{Env: repowalk.GitEnv(), Location: loc}) {
+		if r.err == nil {
+			addComparedRepo(&cmp, r.stats)
+		}
+	}
+	for i := range cmp.Identities {
+		slices.Sort(cmp.Identities[i].Repos) // repos finish in any order
+	}
+	cmp.Overlap = overlappingRepos(cmp.Identities[0].Repos, cmp.Identities[1].Repos)
+	return renderComparison(w, cmp, identityColors(cfg.Identities))
+}
+
+// addComparedRepo adds one repo's commits to both sides of the comparison
+func addComparedRepo(cmp *statsComparison, repoStats *stats.RepoStats) {
+	for i := range cmp.Identities {
+		side := &cmp.Identities[i]
+		idStats, ok := repoStats.ByIdentity[strings.ToLower(side.Email)]
+		if !ok {
+			continue
+		}
+		side.Repos = append(side.Repos, repoStats.RepoPath)
+		if side.Stats == nil {
+			side.Stats = copyIdentityStats(idStats)
+		} else {
+			addIdentityStats(side.Stats, idStats)
+		}
+	}
+}
+
+func overlappingRepos(a, b []string) []string {
+	seen := make(map[string]bool)
+	for _, repo := range a {
+		seen[repo] = true
+	}
+	var overlap []string
+	for _, repo := range b {
+		if seen[repo] {
+			overlap = append(overlap, repo)
+		}
+	}
+	return overlap
+}
+
+// Hour profile buckets of gitme stats --compare
+const compareHourBucket = 3
+
+// renderComparison writes the two identities as columns of one table
+func renderComparison(w io.Writer, cmp statsComparison, colors map[string]string) error {
+	a, b := cmp.Identities[0], cmp.Identities[1]
+	out := newRenderer(w)
+	heading := func(email string) string {
+		if _, ok := colors[strings.ToLower(email)]; ok {
+			return colorIdentity(out, colors, email, email)
+		}
+		return out.Style(HeaderStyle, email)
+	}
+
+	row := func(label string, value func(s *stats.IdentityStats) string) []string {
+		cells := []string{out.Style(DimStyle, label)}
+		for _, side := range cmp.Identities {
+			if side.Stats == nil {
+				cells = append(cells, "—")
+				continue
+			}
+			cells = append(cells, value(side.Stats))
+		}
+		return cells
+	}
+	share := func(n int, s *stats.IdentityStats) string {
+		return fmt.Sprintf("%d (%.0f%%)", n, float64(n)/float64(s.CommitCount)*100)
+	}
+
+	summary := render.Table{Rows: [][]string{
+		{"", heading(a.Email), heading(b.Email)},
+		row("Commits", func(s *stats.IdentityStats) string { return fmt.Sprint(s.CommitCount) }),
+		row("First", func(s *stats.IdentityStats) string { return s.FirstCommit.Format("2006-01-02") }),
+		row("Last", func(s *stats.IdentityStats) string { return s.LastCommit.Format("2006-01-02") }),
+		{out.Style(DimStyle, "Repos"), fmt.Sprint(len(a.Repos)), fmt.Sprint(len(b.Repos))},
+	}}
+
+	var byWeekday render.Table
+	for _, day := range weekdays {
+		byWeekday.Rows = append(byWeekday.Rows, row(day.String()[:3], func(s *stats.IdentityStats) string {
+			return share(s.ByWeekday[day], s)
+		}))
+	}
+	var byHour render.Table
+	for start := 0; start < 24; start += compareHourBucket {
+		label := fmt.Sprintf("%02d–%02d", start, start+compareHourBucket)
+		byHour.Rows = append(byHour.Rows, row(label, func(s *stats.IdentityStats) string {
+			n := 0
+			for h := start; h < start+compareHourBucket; h++ {
+				n += s.ByHour[h]
+			}
+			return share(n, s)
+		}))
+	}
+
+	blocks := []render.Block{
+		render.Header("Identity comparison (across known repositories):"), summary, render.Line(""),
+	}
+	if note := periodNote(a, b); note != "" {
+		blocks = append(blocks, render.Note(note), render.Line(""))
+	}
+	blocks = append(blocks,
+		render.Header("By weekday:"), byWeekday, render.Line(""),
+		render.Header("By hour:"), byHour, render.Line(""),
+	)
+	if len(cmp.Overlap) > 0 {
+		var list render.List
+		for _, repo := range cmp.Overlap {
+			list = append(list, render.Item{Text: repo})
+		}
+		blocks = append(blocks, render.Header("Repos with commits from both:"), list, render.Line(""))
+	}
+	return out.Render(cmp, blocks...)
+}
+
+// periodNote says whether the two identities were ever active at the same
+// time, e.g. whether the old email really stopped after a migration
+func periodNote(a, b compareSide) string {
+	if a.Stats == nil || b.Stats == nil {
+		return ""
+	}
+	start := maxTime(a.Stats.FirstCommit, b.Stats.FirstCommit)
+	end := minTime(a.Stats.LastCommit, b.Stats.LastCommit)
+	if start.After(end) {
+		return "Active periods do not overlap"
+	}
+	return fmt.Sprintf("Active periods overlap from %s to %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
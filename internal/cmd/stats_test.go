@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/stats"
+)
+
+// commitAs makes an empty commit in repo authored by email at date
+func commitAs(t *testing.T, repo, email, date string) {
+	t.Helper()
+	c := exec.Command("git", "-C", repo, "commit", "--allow-empty", "-q", "-m", "change")
+	c.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Me", "GIT_AUTHOR_EMAIL="+email, "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME=Me", "GIT_COMMITTER_EMAIL="+email, "GIT_COMMITTER_DATE="+date)
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %v (%s)", err, out)
+	}
+}
+
+func TestStatsCompareSplitsIdentitiesAndFindsOverlap(t *testing.T) {
+	newSwitchRepo(t)
+	home := os.Getenv("HOME")
+	var repos []string
+	for _, name := range []string{"shared", "new-only"} {
+		repo := filepath.Join(home, "Developer", name)
+		if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+			t.Fatalf("git init failed: %v (%s)", err, out)
+		}
+		repos = append(repos, repo)
+	}
+	commitAs(t, repos[0], "me@old-corp.com", "2023-01-02T09:00:00+00:00")
+	commitAs(t, repos[0], "me@old-corp.com", "2023-06-05T10:00:00+00:00")
+	commitAs(t, repos[0], "me@corp.com", "2023-06-01T22:00:00+00:00")
+	commitAs(t, repos[1], "me@corp.com", "2024-02-03T23:00:00+00:00")
+
+	OutputFormat = render.JSON
+	t.Cleanup(func() { OutputFormat = render.Styled })
+	var buf bytes.Buffer
+	if err := Stats(&buf, []string{"--compare", "me@old-corp.com", "me@corp.com"}); err != nil {
+		t.Fatalf("Stats --compare failed: %v", err)
+	}
+	var cmp statsComparison
+	if err := json.Unmarshal(buf.Bytes(), &cmp); err != nil {
+		t.Fatalf("decoding %s: %v", buf.String(), err)
+	}
+
+	old, current := cmp.Identities[0], cmp.Identities[1]
+	if old.Stats == nil || old.Stats.CommitCount != 2 || len(old.Repos) != 1 {
+		t.Fatalf("old side = %+v, want 2 commits in 1 repo", old)
+	}
+	if current.Stats == nil || current.Stats.CommitCount != 2 || len(current.Repos) != 2 {
+		t.Fatalf("current side = %+v, want 2 commits in 2 repos", current)
+	}
+	if len(cmp.Overlap) != 1 || filepath.Base(cmp.Overlap[0]) != "shared" {
+		t.Fatalf("overlap = %v, want the shared repo", cmp.Overlap)
+	}
+	if note := periodNote(old, current); note != "Active periods overlap from 2023-06-01 to 2023-06-05" {
+		t.Fatalf("periodNote = %q", note)
+	}
+}
+
+func TestStatsTimezoneRebucketsCommits(t *testing.T) {
+	repo := newSwitchRepo(t)
+	// Sunday evening in Los Angeles is Monday morning in UTC
+	commitAs(t, repo, "me@corp.com", "2024-01-07T23:30:00-08:00")
+
+	OutputFormat = render.JSON
+	t.Cleanup(func() { OutputFormat = render.Styled })
+	for zone, want := range map[string]string{"commit": "Sunday", "UTC": "Monday"} {
+		var buf bytes.Buffer
+		if err := Stats(&buf, []string{"--timezone", zone}); err != nil {
+			t.Fatalf("Stats --timezone %s failed: %v", zone, err)
+		}
+		var report statsReport
+		if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+			t.Fatalf("decoding %s: %v", buf.String(), err)
+		}
+		if report.Weekdays[want] != 1 {
+			t.Errorf("--timezone %s: weekdays = %v, want the commit on %s", zone, report.Weekdays, want)
+		}
+	}
+
+	if err := Stats(io.Discard, []string{"--timezone", "Mars/Olympus"}); err == nil {
+		t.Fatalf("expected an unknown timezone to fail")
+	}
+}
+
+func TestStatsRecordsLastCommitsAsUses(t *testing.T) {
+	repo := newSwitchRepo(t)
+	commitAs(t, repo, "me@corp.com", "2024-01-07T23:30:00-08:00")
+
+	if err := Stats(io.Discard, nil); err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	cfg, _ := config.Load()
+	want := time.Date(2024, 1, 8, 7, 30, 0, 0, time.UTC)
+	if got := cfg.IdentityByRef("me@corp.com").LastUsed; !got.Equal(want) {
+		t.Fatalf("LastUsed = %v, want the last commit at %v", got, want)
+	}
+	if got := cfg.IdentityByRef("me@example.com").LastUsed; !got.IsZero() {
+		t.Fatalf("expected an identity without commits to stay unused, got %v", got)
+	}
+}
+
+func TestStatsAndMixedFollowMailmap(t *testing.T) {
+	repo := newSwitchRepo(t)
+	commitAs(t, repo, "me@old-corp.com", "2023-01-02T09:00:00+00:00")
+	commitAs(t, repo, "me@corp.com", "2024-01-02T09:00:00+00:00")
+	if err := os.WriteFile(filepath.Join(repo, ".mailmap"), []byte("Work <me@corp.com> <me@old-corp.com>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	repoStats, err := stats.CollectRepoStats(repo, map[string]bool{"me@corp.com": true}, stats.Options{})
+	if err != nil {
+		t.Fatalf("CollectRepoStats failed: %v", err)
+	}
+	if got := repoStats.ByIdentity["me@corp.com"]; got == nil || got.CommitCount != 2 {
+		t.Fatalf("ByIdentity = %v, want both commits under me@corp.com", repoStats.ByIdentity)
+	}
+
+	known := []identity.Identity{{Name: "Work", Email: "me@corp.com"}, {Name: "Old", Email: "me@old-corp.com"}}
+	if got, _ := mixedAuthors(repo, known, nil, 0); !slices.Equal(got.Identities, []string{"Work <me@corp.com>"}) {
+		t.Fatalf("mixedAuthors = %v, want only Work", got.Identities)
+	}
+}
+
+func TestStatsPathLimitsToCommitsTouchingIt(t *testing.T) {
+	repo := newSwitchRepo(t)
+	for _, c := range []struct{ file, email string }{
+		{"src/api/handler.go", "me@corp.com"},
+		{"src/api/routes.go", "me@corp.com"},
+		{"docs/readme.md", "me@example.com"},
+	} {
+		path := filepath.Join(repo, c.file)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(c.file), 0o644)
+		if out, err := exec.Command("git", "-C", repo, "add", c.file).CombinedOutput(); err != nil {
+			t.Fatalf("git add failed: %v (%s)", err, out)
+		}
+		commitAs(t, repo, c.email, "2024-01-02T09:00:00+00:00")
+	}
+	t.Chdir(filepath.Join(repo, "src"))
+
+	OutputFormat = render.JSON
+	t.Cleanup(func() { OutputFormat = render.Styled })
+	var buf bytes.Buffer
+	if err := Stats(&buf, []string{"--path", "api"}); err != nil {
+		t.Fatalf("Stats --path failed: %v", err)
+	}
+	var report statsReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("decoding %s: %v", buf.String(), err)
+	}
+	if report.Total != 2 || len(report.Identities) != 1 || report.Identities[0].Email != "me@corp.com" {
+		t.Fatalf("report = %+v, want 2 commits by me@corp.com", report)
+	}
+
+	if err := Stats(io.Discard, []string{"--path", "../../elsewhere"}); err == nil {
+		t.Fatalf("expected a path outside the repo to fail")
+	}
+}
@@ -1,10 +1,42 @@
 package cmd
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
 
 var (
-	HeaderStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("170"))
-	DimStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
-	SuccessStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
-	WarnStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("208"))
+	HeaderStyle  = render.HeaderStyle
+	DimStyle     = render.DimStyle
+	SuccessStyle = render.SuccessStyle
+	WarnStyle    = render.WarnStyle
 )
+
+// identityColors maps the lowercased emails of identities, alternate
+// addresses included, to the color each identity is shown in
+func identityColors(identities []identity.Identity) map[string]string {
+	colors := make(map[string]string)
+	for _, id := range identities {
+		for _, email := range append([]string{id.Email}, id.AltEmails...) {
+			colors[strings.ToLower(email)] = id.DisplayColor()
+		}
+	}
+	return colors
+}
+
+// colorIdentity shows text in the color of the identity with email; text for
+// emails of no known identity is left as is
+func colorIdentity(out *render.Renderer, colors map[string]string, email, text string) string {
+	if color, ok := colors[strings.ToLower(email)]; ok {
+		return out.Color(color, text)
+	}
+	return text
+}
+
+// identityEmail returns the email of a "Name <email>" string
+func identityEmail(ident string) string {
+	_, email, _ := strings.Cut(ident, "<")
+	return strings.TrimSuffix(email, ">")
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+// teamClient fetches team directories served over https
+var teamClient = &http.Client{Timeout: 10 * time.Second}
+
+// teamDirectory is the team directory gitme team shows
+type teamDirectory struct {
+	Source  string            `json:"source"`
+	Fetched time.Time         `json:"fetched,omitzero"` // when it was fetched, for URLs
+	Members []config.Coauthor `json:"members"`
+}
+
+// Team lists the collaborators in the team directory set with gitme config
+// team_directory. With --refresh a directory at a URL is fetched again now.
+func Team(w io.Writer, args []string) error {
+	if len(positionalArgs(args)) > 0 {
+		return usageErr("gitme team [--refresh]")
+	}
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	if settings.TeamDirectory == "" {
+		fmt.Fprintln(w, "No team directory set.")
+		fmt.Fprintln(w, DimStyle.Render("Set one with: gitme config team_directory <file|https-url>"))
+		return nil
+	}
+	dir, err := loadTeamDirectory(settings.TeamDirectory, hasFlag(args, "--refresh"))
+	if err != nil {
+		return err
+	}
+
+	var list render.List
+	for _, m := range dir.Members {
+		list = append(list, render.Item{Text: fmt.Sprintf("%s <%s>", m.Name, m.Email)})
+	}
+	header := fmt.Sprintf("Team (%s):", dir.Source)
+	blocks := []render.Block{render.Header(header), list}
+	if !dir.Fetched.IsZero() {
+		blocks = append(blocks, render.Note("Fetched "+dir.Fetched.Local().Format("2006-01-02 15:04")))
+	}
+	return newRenderer(w).Render(dir, blocks...)
+}
+
+// loadTeamDirectory reads the team directory at source, a file or an https
+// URL. A URL is fetched again once the copy gitme keeps is older than
+// config.TeamRefresh, or with refresh; if that fails the copy is used.
+func loadTeamDirectory(source string, refresh bool) (*teamDirectory, error) {
+	if !isTeamURL(source) {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("reading team directory: %w", err)
+		}
+		members, err := config.ParseTeam(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", source, err)
+		}
+		return &teamDirectory{Source: source, Members: members}, nil
+	}
+
+	cache, err := config.LoadTeamCache()
+	if err != nil {
+		return nil, fmt.Errorf("loading team directory: %w", err)
+	}
+	fresh := cache.Source == source && time.Since(cache.Fetched) < config.TeamRefresh
+	if fresh && !refresh {
+		return &teamDirectory{Source: source, Fetched: cache.Fetched, Members: cache.Members}, nil
+	}
+	members, err := fetchTeam(source)
+	if err != nil {
+		if cache.Source == source && !refresh {
+			return &teamDirectory{Source: source, Fetched: cache.Fetched, Members: cache.Members}, nil
+		}
+		return nil, err
+	}
+	cache = &config.TeamCache{Source: source, Fetched: time.Now(), Members: members}
+	if err := cache.Save(); err != nil {
+		return nil, fmt.Errorf("saving team directory: %w", err)
+	}
+	return &teamDirectory{Source: source, Fetched: cache.Fetched, Members: members}, nil
+}
+
+// fetchTeam downloads and parses the team directory at url
+func fetchTeam(url string) ([]config.Coauthor, error) {
+	resp, err := teamClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("fetching team directory: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching team directory: %s returned %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("fetching team directory: %w", err)
+	}
+	members, err := config.ParseTeam(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", url, err)
+	}
+	return members, nil
+}
+
+// teamBook returns the team directory as a book to find collaborators in,
+// empty when none is set or it cannot be read
+func teamBook() *config.CoauthorsConfig {
+	book := &config.CoauthorsConfig{}
+	settings, err := config.LoadSettings()
+	if err != nil || settings.TeamDirectory == "" {
+		return book
+	}
+	if dir, err := loadTeamDirectory(settings.TeamDirectory, false); err == nil {
+		book.Coauthors = dir.Members
+	}
+	return book
+}
+
+// teamEmails maps the lowercased emails in book to Name <email>
+func teamEmails(book *config.CoauthorsConfig) map[string]string {
+	emails := make(map[string]string)
+	for _, m := range book.Coauthors {
+		emails[strings.ToLower(m.Email)] = fmt.Sprintf("%s <%s>", m.Name, m.Email)
+	}
+	return emails
+}
+
+func isTeamURL(source string) bool {
+	return strings.HasPrefix(source, "https://")
+}
+
+// teamSource checks the value of the team_directory setting: an https URL,
+// or a file, which is made absolute with ~ expanded
+func teamSource(value string) (string, error) {
+	if strings.HasPrefix(value, "http://") {
+		return "", fmt.Errorf("team directory must be served over https: %s", value)
+	}
+	if isTeamURL(value) {
+		return value, nil
+	}
+	path := value
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, rest)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("reading team directory: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTeamDirectoryIsFetchedAndKept(t *testing.T) {
+	newSwitchRepo(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Alice Doe <alice@corp.com>")
+	}))
+	client := teamClient
+	teamClient = server.Client()
+	t.Cleanup(func() { teamClient = client })
+	if err := Config(&bytes.Buffer{}, []string{"team_directory", server.URL}); err != nil {
+		t.Fatalf("config team_directory failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Coauthor(&out, []string{"alice"}); err != nil {
+		t.Fatalf("coauthor failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Co-authored-by: Alice Doe <alice@corp.com>") {
+		t.Fatalf("expected a trailer for the team member:\n%s", out.String())
+	}
+
+	// The copy fetched is used while the server cannot be reached
+	server.Close()
+	out.Reset()
+	if err := Coauthor(&out, []string{"alice@corp.com"}); err != nil {
+		t.Fatalf("coauthor with the server down failed: %v", err)
+	}
+	if err := Team(&out, []string{"--refresh"}); err == nil {
+		t.Fatal("expected gitme team --refresh to fail with the server down")
+	}
+}
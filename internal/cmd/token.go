@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/keychain"
+)
+
+// Token manages per-identity platform API tokens stored in the OS keychain
+func Token(w io.Writer, args []string) error {
+	if len(args) < 2 {
+		tokenUsage(os.Stderr)
+		return &ExitError{Code: 1}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	id := resolveIdentity(cfg, args[1])
+	if id == nil {
+		return fmt.Errorf("identity not found: %s", args[1])
+	}
+
+	switch args[0] {
+	case "set":
+		fmt.Fprintf(w, "API token for %s: ", id.Email)
+		token, err := readSecret()
+		fmt.Fprintln(w)
+		if err != nil {
+			return fmt.Errorf("reading token: %w", err)
+		}
+		if token == "" {
+			return fmt.Errorf("empty token, nothing stored")
+		}
+		if readOnlySkip("store the token for %s in the keychain", id.Email) {
+			return nil
+		}
+		if err := keychain.Set(tokenAccount(id.Email), token); err != nil {
+			return fmt.Errorf("storing token: %w", err)
+		}
+		fmt.Fprintln(w, SuccessStyle.Render("Stored token for:"), id.Email)
+
+	case "remove", "rm":
+		if readOnlySkip("remove the token for %s from the keychain", id.Email) {
+			return nil
+		}
+		if err := keychain.Delete(tokenAccount(id.Email)); err != nil {
+			return fmt.Errorf("removing token: %w", err)
+		}
+		fmt.Fprintln(w, SuccessStyle.Render("Removed token for:"), id.Email)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown token command: %s\n", args[0])
+		tokenUsage(os.Stderr)
+		return &ExitError{Code: 1}
+	}
+	return nil
+}
+
+func tokenUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w, "  gitme token set <email|alias>     Store an API token in the OS keychain")
+	fmt.Fprintln(w, "  gitme token remove <email|alias>  Delete the stored token")
+}
+
+// identityToken returns the stored API token for an identity, or "" if none
+func identityToken(email string) string {
+	token, err := keychain.Get(tokenAccount(email))
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// moveToken stores the API token of old, if any, for email instead
+func moveToken(old, email string) error {
+	token := identityToken(old)
+	if token == "" || tokenAccount(old) == tokenAccount(email) {
+		return nil
+	}
+	if readOnlySkip("move the token of %s to %s in the keychain", old, email) {
+		return nil
+	}
+	if err := keychain.Set(tokenAccount(email), token); err != nil {
+		return fmt.Errorf("moving the token of %s: %w", old, err)
+	}
+	return keychain.Delete(tokenAccount(old))
+}
+
+func tokenAccount(email string) string {
+	return strings.ToLower(email)
+}
+
+// readSecret reads a line from stdin without echo when attached to a terminal
+func readSecret() (string, error) {
+	if f, ok := Stdin.(*os.File); ok && term.IsTerminal(f.Fd()) {
+		b, err := term.ReadPassword(f.Fd())
+		return strings.TrimSpace(string(b)), err
+	}
+	return readLine(), nil
+}
@@ -0,0 +1,353 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+	"github.com/vosamoilenko/gitme/internal/stats"
+	"github.com/vosamoilenko/gitme/internal/ui"
+)
+
+// Interactive runs the dashboard: the identity picker for the current folder
+// and screens of the indexed repos, the rules and commit stats
+func Interactive(w io.Writer, args []string) error {
+	cwd, root, err := workingRepo()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	// With nothing stored yet there is nothing to show, so scan up front;
+	// otherwise the scan runs alongside the TUI and fills in new identities
+	scannedUpFront := len(cfg.Identities) == 0
+	if scannedUpFront {
+		result, err := scanIdentities(nil)
+		if err != nil {
+			return fmt.Errorf("scanning identities: %w", err)
+		}
+		if err := settleRenames(w, cfg, cfg.UpdateIdentities(result.Identities), false); err != nil {
+			return err
+		}
+		cfg.Save()
+	}
+
+	if len(cfg.Identities) == 0 {
+		fmt.Fprintln(w, "No identities found.")
+		fmt.Fprintln(w, "Add one with: gitme add \"Your Name\" \"your@email.com\"")
+		return nil
+	}
+
+	// Get current identity for this folder
+	var currentIdentity *identity.Identity
+	if id, ok := mappedIdentity(cfg, root, cwd); ok {
+		currentIdentity = &id
+	}
+
+	rules, err := loadRules(cfg)
+	if err != nil {
+		return fmt.Errorf("loading rules: %w", err)
+	}
+
+	var uiRules []ui.Rule
+	for _, r := range rules.Rules {
+		uiRules = append(uiRules, ui.Rule{Pattern: r.Pattern, Target: ruleTarget(cfg, r), Strict: r.Strict})
+	}
+	model := ui.New(cfg.Identities, currentIdentity, root).WithIcons(iconSet()).WithRules(uiRules)
+	rule := rules.FindRuleForPath(root)
+	var ruleIdentity *identity.Identity
+	if rule != nil {
+		ruleIdentity = resolveIdentity(cfg, rule.Ref())
+	}
+	var override *identity.Identity
+	overrideName, overrideFile := repoOverride(root)
+	if overrideName != "" {
+		override = resolveIdentity(cfg, overrideName)
+	}
+	switch {
+	case override != nil:
+		model = model.WithGovernor("override", overrideFile, override.Email)
+	case ruleIdentity != nil:
+		model = model.WithGovernor("rule", rule.Pattern, ruleIdentity.Email)
+	case currentIdentity != nil:
+		model = model.WithGovernor("mapping", root, currentIdentity.Email)
+	}
+
+	p := tea.NewProgram(model)
+	var scanned []identity.Identity
+	// Quitting closes stop, which ends the scan after its current step;
+	// scanDone is closed once it has, so it is not left running past here
+	stop, scanDone := make(chan struct{}), make(chan struct{})
+	if scannedUpFront {
+		close(scanDone)
+	} else {
+		go func() {
+			defer close(scanDone)
+			_, err := scanIdentitiesUntil(stop, func(cp *identity.Checkpoint) {
+				scanned = cp.Identities
+				select {
+				case <-stop:
+				default:
+					p.Send(ui.ScanMsg{Phase: cp.Phase, Identities: cp.Identities})
+				}
+			})
+			if !errors.Is(err, identity.ErrStopped) {
+				p.Send(ui.ScanMsg{Done: true})
+			}
+		}()
+	}
+
+	go sendDashboard(p)
+
+	finalModel, err := p.Run()
+	close(stop)
+	<-scanDone
+	if err != nil {
+		return fmt.Errorf("running TUI: %w", err)
+	}
+
+	// Keep whatever the scan found so far; an unfinished scan resumes next time
+	renames := cfg.UpdateIdentities(scanned)
+	if err := settleRenames(w, cfg, renames, false); err != nil {
+		return err
+	}
+	cfg.Save()
+
+	m := finalModel.(ui.Model)
+	if err := applyIdentityEdits(w, cfg, m.Edits()); err != nil {
+		return err
+	}
+	if err := applyAssignments(w, cfg, m.Assignments()); err != nil {
+		return err
+	}
+
+	switch m.Action() {
+	case ui.ActionDelete:
+		targets := m.DeleteTargets()
+		if len(targets) == 0 {
+			return nil
+		}
+		remove := make(map[string]bool)
+		for _, target := range targets {
+			remove[target.Email] = true
+		}
+		removeIdentities(cfg, remove)
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		for _, target := range targets {
+			fmt.Fprintln(w, SuccessStyle.Render("Deleted:"), target.Name, "<"+target.Email+">")
+		}
+
+	case ui.ActionRescan:
+		return Scan(w, nil)
+
+	case ui.ActionSelect:
+		selected := m.Choice()
+		if selected == nil {
+			return nil
+		}
+		if err := trustRepo(w, cfg, root, false); err != nil {
+			return err
+		}
+		if err := switchIdentity(cfg, root, *selected); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, SuccessStyle.Render("Switched to:"), selected.Name, "<"+selected.Email+">")
+
+		// Keep the governing rule consistent with the manual choice
+		if rule != nil && (ruleIdentity == nil || !strings.EqualFold(ruleIdentity.Email, selected.Email)) {
+			if confirm(w, fmt.Sprintf("Rule %s points to %s. Update it to %s?", rule.Pattern, ruleTarget(cfg, *rule), selected.Email)) {
+				rules.AddRule(rule.Pattern, selected.ID)
+				if err := rules.Save(); err != nil {
+					return fmt.Errorf("saving rules: %w", err)
+				}
+				fmt.Fprintln(w, SuccessStyle.Render("Updated rule:"), rule.Pattern, "→", selected.Email)
+			}
+		}
+	}
+	return nil
+}
+
+// removeIdentities drops every identity whose email is in emails
+func removeIdentities(cfg *config.Config, emails map[string]bool) {
+	var kept []identity.Identity
+	for _, id := range cfg.Identities {
+		if !emails[id.Email] {
+			kept = append(kept, id)
+		}
+	}
+	cfg.Identities = kept
+}
+
+// sendDashboard reads the indexed repos for the repos screen, then counts
+// their commits for the stats screen. It loads its own config, as the one of
+// Interactive changes once the TUI quits.
+func sendDashboard(p *tea.Program) {
+	cfg, err := config.Load()
+	if err != nil {
+		p.Send(ui.ReposMsg{})
+		return
+	}
+	rules, err := loadRules(cfg)
+	if err != nil {
+		rules = &config.RulesConfig{}
+	}
+	repos, _ := indexedRepos(cfg, nil)
+	p.Send(ui.ReposMsg{Repos: repowalk.Collect(repos, func(repo string) ui.Repo {
+		r := ui.Repo{Path: repo, Email: localConfigValue(repo, "user.email")}
+		if id, _, err := expectedIdentity(cfg, rules, repo); err == nil && id != nil {
+			r.Expected = id.Email
+		}
+		return r
+	})})
+
+	emails := make(map[string]bool)
+	for _, id := range cfg.Identities {
+		emails[strings.ToLower(id.Email)] = true
+	}
+	aggregated := &stats.RepoStats{ByIdentity: make(map[string]*stats.IdentityStats)}
+	counted := 0
+	env := repowalk.GitEnv()
+	for repoStats := range repowalk.Map(repos, func(repo string) *stats.RepoStats {
+		repoStats, _ := stats.CollectRepoStats(repo, emails, stats.Options{Env: env})
+		return repoStats
+	}) {
+		if repoStats != nil && repoStats.TotalCount > 0 {
+			counted++
+			mergeRepoStats(aggregated, repoStats)
+		}
+	}
+	p.Send(ui.StatsMsg{Repos: counted, Total: aggregated.TotalCount, Stats: aggregated.SortedIdentities()})
+}
+
+// applyIdentityEdits stores the identities added and edited in the TUI form.
+// An edited email takes the token, rules and aliases of the old one along.
+func applyIdentityEdits(w io.Writer, cfg *config.Config, edits []ui.IdentityEdit) error {
+	if len(edits) == 0 {
+		return nil
+	}
+	// owner is the index of the identity with email, which the scan running
+	// alongside the form may have added, or -1
+	owner := func(email string) int {
+		return slices.IndexFunc(cfg.Identities, func(id identity.Identity) bool { return strings.EqualFold(id.Email, email) })
+	}
+	var moved [][2]string
+	edited := false
+	for _, e := range edits {
+		if e.Email == "" {
+			if owner(e.Identity.Email) >= 0 {
+				fmt.Fprintln(w, WarnStyle.Render("Skipped:"), e.Identity.Email, "is already an identity")
+				continue
+			}
+			cfg.Identities = append(cfg.Identities, e.Identity)
+			fmt.Fprintln(w, SuccessStyle.Render("Added:"), e.Identity.String())
+			continue
+		}
+		i := owner(e.Email)
+		if i < 0 {
+			fmt.Fprintln(w, WarnStyle.Render("Skipped:"), e.Email, "is no longer an identity")
+			continue
+		}
+		id := &cfg.Identities[i]
+		if !strings.EqualFold(e.Identity.Email, id.Email) {
+			if owner(e.Identity.Email) >= 0 {
+				fmt.Fprintln(w, WarnStyle.Render("Skipped:"), e.Identity.Email, "is already an identity")
+				continue
+			}
+			moved = append(moved, [2]string{id.Email, e.Identity.Email})
+		}
+		id.Name, id.Email, id.Platform = e.Identity.Name, e.Identity.Email, e.Identity.Platform
+		id.SSHKey, id.SigningKey, id.SigningFormat = e.Identity.SSHKey, e.Identity.SigningKey, e.Identity.SigningFormat
+		fmt.Fprintln(w, SuccessStyle.Render("Saved:"), id.String())
+		edited = true
+	}
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	for _, m := range moved {
+		if err := moveEmailReferences(cfg, m[0], m[1]); err != nil {
+			fmt.Fprintln(w, WarnStyle.Render("Warning:"), err)
+		}
+	}
+	if edited {
+		fmt.Fprintln(w, DimStyle.Render("Re-apply an edited identity to a repo with: gitme set <email>"))
+	}
+	return nil
+}
+
+// moveEmailReferences points what refers to an identity by its old email at
+// the new one: its API token, rules written before identity IDs, and aliases
+func moveEmailReferences(cfg *config.Config, old, email string) error {
+	if err := moveToken(old, email); err != nil {
+		return err
+	}
+	rules, err := config.LoadRules()
+	if err != nil {
+		return fmt.Errorf("loading rules: %w", err)
+	}
+	ruleChanged := false
+	for i := range rules.Rules {
+		if rule := &rules.Rules[i]; rule.Identity == "" && strings.EqualFold(rule.Email, old) {
+			rule.Email = email
+			ruleChanged = true
+		}
+	}
+	if migrated := rules.Migrate(cfg.Identities); ruleChanged || migrated {
+		if err := rules.Save(); err != nil {
+			return fmt.Errorf("saving rules: %w", err)
+		}
+	}
+	aliases, err := config.LoadAliases()
+	if err != nil {
+		return fmt.Errorf("loading aliases: %w", err)
+	}
+	aliasChanged := false
+	for name, target := range aliases.Aliases {
+		if strings.EqualFold(target, old) {
+			aliases.Aliases[name] = email
+			aliasChanged = true
+		}
+	}
+	if aliasChanged {
+		if err := aliases.Save(); err != nil {
+			return fmt.Errorf("saving aliases: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyAssignments switches each repo to the identity picked for it on the
+// repos screen, the last pick when there were several
+func applyAssignments(w io.Writer, cfg *config.Config, assignments []ui.Assignment) error {
+	picked := make(map[string]identity.Identity)
+	var order []string
+	for _, a := range assignments {
+		if _, ok := picked[a.Repo]; !ok {
+			order = append(order, a.Repo)
+		}
+		picked[a.Repo] = a.Identity
+	}
+	for _, repo := range order {
+		id := picked[repo]
+		if err := trustRepo(w, cfg, repo, false); err != nil {
+			fmt.Fprintln(w, WarnStyle.Render("Skipped:"), err)
+			continue
+		}
+		if err := switchIdentity(cfg, repo, id); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, SuccessStyle.Render("Switched:"), repo, "→", id.String())
+	}
+	return nil
+}
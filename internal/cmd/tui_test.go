@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/ui"
+)
+
+func TestDashboardAssignmentsApplyTheLastPick(t *testing.T) {
+	repo := newSwitchRepo(t)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	err = applyAssignments(&out, cfg, []ui.Assignment{
+		{Repo: repo, Identity: cfg.Identities[0]},
+		{Repo: repo, Identity: cfg.Identities[1]},
+	})
+	if err != nil {
+		t.Fatalf("applyAssignments failed: %v", err)
+	}
+	if got := mustGit(t, repo, "config", "--local", "user.email"); got != "me@example.com" {
+		t.Errorf("expected the last pick to apply, got %q", got)
+	}
+	if n := strings.Count(out.String(), "Switched:"); n != 1 {
+		t.Errorf("expected the repo switched once, got %d times:\n%s", n, out.String())
+	}
+}
+
+func TestDashboardIdentityEditsAreSaved(t *testing.T) {
+	newSwitchRepo(t)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	(&config.RulesConfig{Rules: []config.Rule{{Pattern: "~/home", Email: "me@example.com"}}}).Save()
+	(&config.AliasConfig{Aliases: map[string]string{"home": "me@example.com"}}).Save()
+
+	edited := cfg.Identities[1]
+	edited.Name, edited.Email = "Personal Renamed", "me@home.com"
+	var out strings.Builder
+	err = applyIdentityEdits(&out, cfg, []ui.IdentityEdit{
+		{Identity: identity.Identity{Name: "New", Email: "new@example.com"}},
+		{Identity: identity.Identity{Name: "Merged meanwhile", Email: "ME@corp.com"}},
+		{Email: "ME@example.com", Identity: edited},
+	})
+	if err != nil {
+		t.Fatalf("applyIdentityEdits failed: %v", err)
+	}
+
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Identities) != 3 || cfg.Identities[2].Email != "new@example.com" {
+		t.Errorf("expected the new identity appended, got %v", cfg.Identities)
+	}
+	if got := cfg.Identities[1]; got.Name != "Personal Renamed" || got.Email != "me@home.com" || got.Username != "me" {
+		t.Errorf("expected the edit saved in place, keeping the username, got %+v", got)
+	}
+	if !strings.Contains(out.String(), "gitme set") {
+		t.Errorf("expected a hint to re-apply the edited identity:\n%s", out.String())
+	}
+	if rules, _ := config.LoadRules(); rules.Rules[0].Ref() != cfg.Identities[1].ID {
+		t.Errorf("expected the rule naming the old email carried over, got %+v", rules.Rules[0])
+	}
+	if aliases, _ := config.LoadAliases(); aliases.Aliases["home"] != "me@home.com" {
+		t.Errorf("expected the alias carried over, got %v", aliases.Aliases)
+	}
+}
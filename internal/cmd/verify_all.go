@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/platform"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+// fetchKeys asks a platform API which public keys an account has
+var fetchKeys = platform.FetchKeys
+
+// What verify --all finds for an email or key of an identity
+const (
+	statusVerified   = "verified"
+	statusUnverified = "unverified"
+	statusUploaded   = "uploaded"
+	statusMissing    = "missing"
+	statusUnknown    = "unknown" // the platform does not tell, or no token
+	statusNone       = "-"       // the identity has no such key
+	statusOffline    = "skipped: offline"
+)
+
+// platformVerification is how an identity checks out against its platform
+// account
+type platformVerification struct {
+	Email      string   `json:"email"`
+	Platform   string   `json:"platform"`
+	Account    string   `json:"account,omitempty"`
+	EmailState string   `json:"email_status"`
+	SSHKey     string   `json:"ssh_key"`
+	SigningKey string   `json:"signing_key"`
+	Gaps       []string `json:"gaps,omitempty"` // what to do to close each gap
+}
+
+// platformSettings are the account pages gaps are fixed on, by platform
+var platformSettings = map[identity.Platform]struct{ emails, ssh, gpg string }{
+	identity.PlatformGitHub: {"https://github.com/settings/emails", "https://github.com/settings/keys", "https://github.com/settings/keys"},
+	identity.PlatformGitLab: {"https://gitlab.com/-/user_settings/emails", "https://gitlab.com/-/user_settings/ssh_keys", "https://gitlab.com/-/user_settings/gpg_keys"},
+}
+
+// verifyAll checks every identity with a platform account, known by its
+// username or stored token: its email is verified on the account, the token
+// is of the account its username names, and its ssh and signing keys are
+// uploaded. It exits 1 when any identity has a gap.
+func verifyAll(w io.Writer) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	results := []platformVerification{}
+	for i := range cfg.Identities {
+		id := &cfg.Identities[i]
+		if _, ok := platformSettings[id.Platform]; !ok {
+			continue
+		}
+		token := identityToken(id.Email)
+		if token == "" && id.Username == "" {
+			continue
+		}
+		results = append(results, verifyOnPlatform(id, token))
+	}
+
+	out := newRenderer(w)
+	if len(results) == 0 && out.Format() != render.JSON {
+		fmt.Fprintln(w, "No identities with a platform account to verify.")
+		fmt.Fprintln(w, DimStyle.Render("Give one a username (gitme username <email> <login>) or a token (gitme token set <email>)"))
+		return nil
+	}
+
+	table := render.Table{Rows: [][]string{{
+		out.Style(HeaderStyle, "Identity"), out.Style(HeaderStyle, "Account"), out.Style(HeaderStyle, "Email"),
+		out.Style(HeaderStyle, "SSH key"), out.Style(HeaderStyle, "Signing key"),
+	}}}
+	var gaps render.List
+	for _, r := range results {
+		table.Rows = append(table.Rows, []string{r.Email, r.Account,
+			verificationCell(out, r.EmailState), verificationCell(out, r.SSHKey), verificationCell(out, r.SigningKey)})
+		for _, gap := range r.Gaps {
+			gaps = append(gaps, render.Item{Marker: out.Style(WarnStyle, "✗"), Text: r.Email + ": " + gap})
+		}
+	}
+	blocks := []render.Block{table}
+	if slices.ContainsFunc(results, func(r platformVerification) bool { return r.EmailState == statusUnknown }) {
+		blocks = append(blocks, render.Note("Emails are checked with a token: gitme token set <email>"))
+	}
+	if len(gaps) > 0 {
+		blocks = append(blocks, render.Line(""), render.Header("Gaps:"), gaps)
+	}
+	if err := out.Render(results, blocks...); err != nil {
+		return err
+	}
+	if len(gaps) > 0 {
+		return &ExitError{Code: 1}
+	}
+	return nil
+}
+
+// verifyOnPlatform checks one identity against the account of its token,
+// else of its username
+func verifyOnPlatform(id *identity.Identity, token string) platformVerification {
+	pages := platformSettings[id.Platform]
+	v := platformVerification{Email: id.Email, Platform: string(id.Platform), Account: id.Username,
+		EmailState: statusUnknown, SSHKey: statusNone, SigningKey: statusNone}
+	offline := false
+
+	if token != "" {
+		acct, err := fetchPlatformAccount(id.Platform, token)
+		switch {
+		case errors.Is(err, platform.ErrOffline):
+			offline = true
+		case err != nil:
+			v.Gaps = append(v.Gaps, "token: "+err.Error())
+		default:
+			if id.Username != "" && !strings.EqualFold(id.Username, acct.Login) {
+				v.Gaps = append(v.Gaps, fmt.Sprintf("the token is of %s, not %s; replace it, or fix the username with: gitme username %s %s",
+					acct.Login, id.Username, id.Email, acct.Login))
+			}
+			v.Account = acct.Login
+			switch found, verified := acct.HasEmail(id.Email); {
+			case found && verified:
+				v.EmailState = statusVerified
+			case found:
+				v.EmailState = statusUnverified
+				v.Gaps = append(v.Gaps, "verify the email at "+pages.emails)
+			default:
+				v.EmailState = statusMissing
+				v.Gaps = append(v.Gaps, fmt.Sprintf("add the email to %s at %s", acct.Login, pages.emails))
+			}
+		}
+	}
+
+	sshKey, signingKey := localSSHKey(id), localSigningKey(id)
+	if sshKey != "" {
+		v.SSHKey = statusUnknown
+	}
+	if signingKey != "" {
+		v.SigningKey = statusUnknown
+	}
+	if v.Account == "" || (sshKey == "" && signingKey == "") {
+		return finishVerification(v, offline)
+	}
+	keys, err := fetchKeys(id.Platform, token, v.Account)
+	switch {
+	case errors.Is(err, platform.ErrOffline):
+		offline = true
+	case err != nil:
+		v.Gaps = append(v.Gaps, "keys: "+err.Error())
+	default:
+		if sshKey != "" {
+			v.SSHKey = keyStatus(slices.Contains(keys.SSH, sshKey))
+			if v.SSHKey == statusMissing {
+				v.Gaps = append(v.Gaps, fmt.Sprintf("upload %s.pub at %s", id.SSHKey, pages.ssh))
+			}
+		}
+		if signingKey != "" {
+			if id.SigningFormat == "ssh" {
+				v.SigningKey = keyStatus(slices.Contains(keys.SSHSigning, signingKey))
+				if v.SigningKey == statusMissing {
+					v.Gaps = append(v.Gaps, fmt.Sprintf("upload %s as a signing key at %s", id.SigningKey, pages.ssh))
+				}
+			} else if keys.GPG != nil {
+				v.SigningKey = keyStatus(slices.ContainsFunc(keys.GPG, func(uploaded string) bool {
+					return strings.HasSuffix(signingKey, uploaded) || strings.HasSuffix(uploaded, signingKey)
+				}))
+				if v.SigningKey == statusMissing {
+					v.Gaps = append(v.Gaps, fmt.Sprintf("upload the output of gpg --armor --export %s at %s", id.SigningKey, pages.gpg))
+				}
+			}
+		}
+	}
+	return finishVerification(v, offline)
+}
+
+// finishVerification marks what could not be checked offline
+func finishVerification(v platformVerification, offline bool) platformVerification {
+	if !offline {
+		return v
+	}
+	for _, state := range []*string{&v.EmailState, &v.SSHKey, &v.SigningKey} {
+		if *state == statusUnknown {
+			*state = statusOffline
+		}
+	}
+	return v
+}
+
+func keyStatus(uploaded bool) string {
+	if uploaded {
+		return statusUploaded
+	}
+	return statusMissing
+}
+
+func verificationCell(out *render.Renderer, status string) string {
+	switch status {
+	case statusVerified, statusUploaded:
+		return out.Style(SuccessStyle, status)
+	case statusUnverified, statusMissing:
+		return out.Style(WarnStyle, status)
+	}
+	return out.Style(DimStyle, status)
+}
+
+// localSSHKey returns the public half of the ssh key of id, normalized, ""
+// when it has none or it cannot be read
+func localSSHKey(id *identity.Identity) string {
+	if id.SSHKey == "" {
+		return ""
+	}
+	data, err := os.ReadFile(id.SSHKey + ".pub")
+	if err != nil {
+		return ""
+	}
+	return platform.NormalizeSSHKey(string(data))
+}
+
+// localSigningKey returns the signing key of id as the platform lists it: a
+// normalized ssh public key, or an uppercase gpg key ID
+func localSigningKey(id *identity.Identity) string {
+	key := strings.TrimSpace(id.SigningKey)
+	if key == "" {
+		return ""
+	}
+	if id.SigningFormat != "ssh" {
+		key = strings.TrimSuffix(strings.TrimPrefix(strings.ToUpper(strings.ReplaceAll(key, " ", "")), "0X"), "!")
+		if strings.Trim(key, "0123456789ABCDEF") != "" {
+			return "" // a user ID, which the platform does not list
+		}
+		return key
+	}
+	if literal, ok := strings.CutPrefix(key, "key::"); ok {
+		return platform.NormalizeSSHKey(literal)
+	}
+	if strings.HasPrefix(key, "ssh-") {
+		return platform.NormalizeSSHKey(key)
+	}
+	if rest, ok := strings.CutPrefix(key, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		key = filepath.Join(home, rest)
+	}
+	if !strings.HasSuffix(key, ".pub") {
+		key += ".pub"
+	}
+	data, err := os.ReadFile(key)
+	if err != nil {
+		return ""
+	}
+	return platform.NormalizeSSHKey(string(data))
+}
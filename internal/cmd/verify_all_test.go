@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/platform"
+)
+
+func TestVerifyAllReportsKeysMissingFromTheAccount(t *testing.T) {
+	repo := newSwitchRepo(t)
+	key := filepath.Join(repo, "id_ed25519")
+	os.WriteFile(key+".pub", []byte("ssh-ed25519 AAAAC3Nza me@example.com\n"), 0644)
+	cfg, _ := config.Load()
+	personal := &cfg.Identities[1]
+	personal.Platform, personal.SSHKey = identity.PlatformGitHub, key
+	personal.SigningFormat, personal.SigningKey = "ssh", key+".pub"
+	cfg.Save()
+
+	var login string
+	fetchKeys = func(_ identity.Platform, _, account string) (*platform.Keys, error) {
+		login = account
+		return &platform.Keys{SSH: []string{"ssh-ed25519 AAAAC3Nza"}}, nil
+	}
+	t.Cleanup(func() { fetchKeys = platform.FetchKeys })
+
+	var out bytes.Buffer
+	var exitErr *ExitError
+	if err := Verify(&out, []string{"--all"}); !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("Verify --all = %v, want exit 1 for the missing signing key: %q", err, out.String())
+	}
+	if login != "me" || !strings.Contains(out.String(), "uploaded") ||
+		!strings.Contains(out.String(), "me@example.com: upload "+key+".pub as a signing key") {
+		t.Errorf("unexpected report for account %q:\n%s", login, out.String())
+	}
+	if strings.Contains(out.String(), "me@corp.com") {
+		t.Errorf("expected the identity without a platform left out:\n%s", out.String())
+	}
+}
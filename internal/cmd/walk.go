@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+)
+
+// maxSkippedShown limits how many skipped paths are listed
+const maxSkippedShown = 10
+
+// reportSkipped lists paths a walk could not read and repos git stalled on.
+// With strict set they fail the command instead of being a warning.
+func reportSkipped(w io.Writer, skipped []repowalk.Skipped, strict bool) error {
+	if len(skipped) == 0 || OutputFormat == render.JSON && !strict {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%s Skipped %d paths:\n", WarnStyle.Render("⚠"), len(skipped))
+	for i, s := range skipped {
+		if i == maxSkippedShown {
+			fmt.Fprintf(os.Stderr, "  %s\n", DimStyle.Render(fmt.Sprintf("... and %d more", len(skipped)-i)))
+			break
+		}
+		fmt.Fprintf(os.Stderr, "  %s %s\n", s.Path, DimStyle.Render("("+s.Reason+")"))
+	}
+	if strict {
+		return fmt.Errorf("%d paths were skipped (--strict)", len(skipped))
+	}
+	return nil
+}
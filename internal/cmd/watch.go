@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/render"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+	"github.com/vosamoilenko/gitme/internal/service"
+)
+
+// defaultWatchInterval is how often the watcher checks every repo
+const defaultWatchInterval = time.Minute
+
+// Watch keeps the identities of all known repos in line with rules and
+// .gitme overrides, or manages the service that runs it
+func Watch(w io.Writer, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "install":
+			return watchInstall(w, args[1:])
+		case "status":
+			return watchStatus(w)
+		case "stop":
+			return watchStop(w)
+		case "uninstall":
+			return watchUninstall(w)
+		}
+	}
+
+	interval, err := watchInterval(args)
+	if err != nil {
+		return err
+	}
+	digest, err := digestMode(args)
+	if err != nil {
+		return err
+	}
+
+	// Watchers started from several shells would all walk every repo
+	release, err := claimWatch()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var state watchState
+	if addr, ok := flagValue(args, "--http"); ok {
+		url, err := serveWatchStatus(ctx, addr, &state)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Serving status at %s\n", url)
+	}
+
+	fmt.Fprintf(w, "Watching repositories every %s\n", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	reported := make(map[string]string)
+	metrics := &config.WatchMetrics{PID: os.Getpid(), Started: time.Now(), Interval: interval.String()}
+	for {
+		report, err := watchOnce(w, reported)
+		recordWatchMetrics(metrics, report, err)
+		if err := metrics.Save(); err != nil {
+			fmt.Fprintf(w, "%s saving metrics: %s\n", WarnStyle.Render("⚠"), err)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "%s %s\n", WarnStyle.Render("⚠"), err)
+		} else {
+			state.set(report)
+			if digest != "" {
+				watchDigest(w, digest, report)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordWatchMetrics adds a pass, or the error that ended it, to metrics
+func recordWatchMetrics(metrics *config.WatchMetrics, report *watchReport, err error) {
+	metrics.Scans++
+	if err != nil {
+		metrics.Errors++
+		metrics.LastError = err.Error()
+		return
+	}
+	metrics.LastScan = report.LastScan
+	metrics.Repos = report.Repos
+	metrics.Mismatches = len(report.Mismatches)
+	metrics.AutoFixed += report.Fixed
+	metrics.Errors += report.Errors
+	if report.lastError != "" {
+		metrics.LastError = report.lastError
+	}
+}
+
+// watchUsage documents the flags of the watcher
+const watchUsage = "gitme watch [--interval <duration>] [--http <host:port>] [--digest terminal|notify]"
+
+// watchDigest records a pass for the weekly digest and delivers the digest
+// of the previous week once a new one begins
+func watchDigest(w io.Writer, mode string, report *watchReport) {
+	d, err := recordDigest(time.Now(), report)
+	if err == nil && d != nil {
+		err = deliverDigest(w, mode, d)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "%s digest: %s\n", WarnStyle.Render("⚠"), err)
+	}
+}
+
+// watchInterval reads --interval, which takes a Go duration like 30s or 5m
+func watchInterval(args []string) (time.Duration, error) {
+	value, ok := flagValue(args, "--interval")
+	if !ok {
+		return defaultWatchInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return 0, usageErr(watchUsage)
+	}
+	return interval, nil
+}
+
+// watchReport is the outcome of one pass over all repos
+type watchReport struct {
+	LastScan   time.Time  `json:"last_scan"`
+	Repos      int        `json:"repos"`
+	Mismatches []Mismatch `json:"mismatches"`
+	Fixed      int        `json:"fixed"`
+	Errors     int        `json:"errors"`
+	lastError  string
+}
+
+// watchOnce runs auto on every repo in the repo index, refreshing its entry,
+// and prints what it did for each repo that needed attention. reported
+// holds the last message per repo so a lasting mismatch is logged only once.
+func watchOnce(w io.Writer, reported map[string]string) (*watchReport, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	rules, err := loadRules(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("loading rules: %w", err)
+	}
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return nil, fmt.Errorf("loading settings: %w", err)
+	}
+
+	report := &watchReport{Mismatches: []Mismatch{}}
+	uses := make(map[string]time.Time)
+	check := func(repo string) {
+		report.Repos++
+		recordHeadCommit(uses, repo)
+		var out bytes.Buffer
+		mismatch, fixed, err := autoRepo(&out, repo, cfg, rules, settings, false)
+		if mismatch != nil {
+			report.Mismatches = append(report.Mismatches, *mismatch)
+		}
+		if fixed {
+			report.Fixed++
+		}
+		if err != nil {
+			report.Errors++
+			report.lastError = repo + ": " + err.Error()
+			fmt.Fprintf(&out, "%s %s\n", WarnStyle.Render("⚠"), err)
+		}
+		if out.String() == reported[repo] {
+			return
+		}
+		reported[repo] = out.String()
+		if out.Len() == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s %s\n", time.Now().Format(time.DateTime), HeaderStyle.Render(repo))
+		for _, line := range strings.Split(strings.TrimRight(out.String(), "\n"), "\n") {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+
+	idx := repoIndex(cfg, false)
+	for _, entry := range idx.Repos {
+		if _, err := os.Stat(filepath.Join(entry.Path, ".git")); err != nil {
+			continue
+		}
+		check(entry.Path)
+		idx.Put(indexRepo(entry.Path))
+	}
+	idx.Save()
+	// Only the use stamps are written back: the config loaded above may
+	// have changed on disk during the pass
+	if err := config.RecordUses(uses); err != nil {
+		return nil, fmt.Errorf("saving config: %w", err)
+	}
+	report.LastScan = time.Now()
+	return report, nil
+}
+
+// recordHeadCommit counts the latest commit of repo as a use of its author,
+// keeping the latest use per lowercased email in uses
+func recordHeadCommit(uses map[string]time.Time, repo string) {
+	out, err := repowalk.Git(repo, repowalk.GitEnv(), "log", "-1", "--format=%ae%x00%aI")
+	if err != nil {
+		return
+	}
+	email, date, _ := strings.Cut(strings.TrimSpace(string(out)), "\x00")
+	email = strings.ToLower(email)
+	if t, err := time.Parse(time.RFC3339, date); err == nil && t.After(uses[email]) {
+		uses[email] = t
+	}
+}
+
+func watchInstall(w io.Writer, args []string) error {
+	if _, err := watchInterval(args); err != nil {
+		return err
+	}
+	if _, err := digestMode(args); err != nil {
+		return err
+	}
+	if addr, ok := flagValue(args, "--http"); ok {
+		if _, err := loopbackAddr(addr); err != nil {
+			return err
+		}
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating gitme: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	command := append([]string{exe, "--config-dir", config.Dir(), "watch"}, args...)
+	if readOnlySkip("install and start the watch service: %s", strings.Join(command, " ")) {
+		return nil
+	}
+	path, err := service.Install(command)
+	if err != nil {
+		return fmt.Errorf("installing watch service: %w", err)
+	}
+	fmt.Fprintln(w, SuccessStyle.Render("Installed:"), path)
+	fmt.Fprintln(w, DimStyle.Render("The watcher starts now and at every login"))
+	return nil
+}
+
+// watchStatusReport is what gitme watch status shows
+type watchStatusReport struct {
+	Running bool                 `json:"running"`
+	PID     int                  `json:"pid,omitempty"`
+	Service string               `json:"service"` // service manager state, "not installed" without one
+	Metrics *config.WatchMetrics `json:"metrics,omitempty"`
+}
+
+// watchStatus shows whether a watcher runs, what the current or last one
+// did, and the state of the watch service
+func watchStatus(w io.Writer) error {
+	report := watchStatusReport{PID: runningWatcher(), Service: "not installed"}
+	report.Running = report.PID != 0
+	metrics, err := config.LoadWatchMetrics()
+	if err != nil {
+		return fmt.Errorf("loading watch metrics: %w", err)
+	}
+	report.Metrics = metrics
+	status, err := service.Query()
+	if err == nil {
+		report.Service = status.Detail
+	} else if !errors.Is(err, service.ErrNotInstalled) {
+		return err
+	}
+
+	out := newRenderer(w)
+	watcher := out.Style(WarnStyle, "not running")
+	if report.Running {
+		watcher = out.Style(SuccessStyle, fmt.Sprintf("running (pid %d)", report.PID))
+	}
+	blocks := []render.Block{render.Line("Watcher: " + watcher)}
+	if metrics != nil {
+		if metrics.PID != report.PID {
+			blocks = append(blocks, render.Note(fmt.Sprintf("Last watcher (pid %d):", metrics.PID)))
+		}
+		lastScan := "none yet"
+		if !metrics.LastScan.IsZero() {
+			lastScan = metrics.LastScan.Format(time.DateTime)
+		}
+		kv := render.KV{
+			{"started", metrics.Started.Format(time.DateTime) + ", every " + metrics.Interval},
+			{"last scan", lastScan},
+			{"repos indexed", strconv.Itoa(metrics.Repos)},
+			{"scans", strconv.Itoa(metrics.Scans)},
+			{"auto-fixed", strconv.Itoa(metrics.AutoFixed)},
+			{"mismatches", strconv.Itoa(metrics.Mismatches)},
+			{"errors", strconv.Itoa(metrics.Errors)},
+		}
+		if metrics.LastError != "" {
+			kv = append(kv, [2]string{"last error", metrics.LastError})
+		}
+		blocks = append(blocks, kv)
+	}
+
+	if status == nil {
+		blocks = append(blocks, render.Line("Watch service is not installed"),
+			render.Note("Install it with: gitme watch install"))
+		return out.Render(report, blocks...)
+	}
+	state := out.Style(WarnStyle, status.Detail)
+	if status.Running {
+		state = out.Style(SuccessStyle, status.Detail)
+	}
+	blocks = append(blocks, render.Line("Watch service: "+state), render.Note(status.Path))
+	return out.Render(report, blocks...)
+}
+
+func watchUninstall(w io.Writer) error {
+	if readOnlySkip("stop and remove the watch service") {
+		return nil
+	}
+	if err := service.Uninstall(); err != nil {
+		return fmt.Errorf("removing watch service: %w", err)
+	}
+	fmt.Fprintln(w, SuccessStyle.Render("Uninstalled watch service"))
+	return nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// watchState holds the latest report for the status endpoint
+type watchState struct {
+	mu     sync.Mutex
+	report *watchReport
+}
+
+func (s *watchState) set(report *watchReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report = report
+}
+
+func (s *watchState) get() watchReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.report == nil {
+		return watchReport{Mismatches: []Mismatch{}}
+	}
+	return *s.report
+}
+
+// ServeHTTP answers GET /status with the latest report as JSON
+func (s *watchState) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/status" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "read-only endpoint", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.get())
+}
+
+// loopbackAddr validates an --http address. Only loopback hosts are
+// accepted; a bare :port means 127.0.0.1.
+func loopbackAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", usageErr(watchUsage)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", fmt.Errorf("--http must listen on localhost, not %s", host)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// serveWatchStatus serves state on addr until ctx is done and returns the
+// status URL
+func serveWatchStatus(ctx context.Context, addr string, state *watchState) (string, error) {
+	addr, err := loopbackAddr(addr)
+	if err != nil {
+		return "", err
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("starting status endpoint: %w", err)
+	}
+	srv := &http.Server{Handler: state, ReadHeaderTimeout: 5 * time.Second}
+	go srv.Serve(ln)
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	return "http://" + ln.Addr().String() + "/status", nil
+}
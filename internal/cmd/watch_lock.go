@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/service"
+)
+
+// errWatchLocked is returned by lockFile when another process holds the lock
+var errWatchLocked = errors.New("locked by another process")
+
+// watchRunningError is returned when another watcher already runs
+type watchRunningError struct {
+	PID int
+}
+
+func (e *watchRunningError) Error() string {
+	running := "gitme watch is already running"
+	if e.PID != 0 {
+		running += fmt.Sprintf(" (pid %d)", e.PID)
+	}
+	return running + "; stop it with: gitme watch stop"
+}
+
+// watchPIDFile is where the running watcher records its pid
+func watchPIDFile() string {
+	return filepath.Join(config.Dir(), "watch.pid")
+}
+
+// claimWatch makes this process the only watcher of the config dir by
+// locking the pidfile and writing its pid there. The lock goes with the
+// process, so a watcher that died leaves nothing stale behind. The file
+// itself is kept, so watchers starting together all lock the same one.
+func claimWatch() (release func(), err error) {
+	if err := os.MkdirAll(config.Dir(), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(watchPIDFile(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening pidfile: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, errWatchLocked) {
+			return nil, &watchRunningError{PID: readPID(watchPIDFile())}
+		}
+		return nil, fmt.Errorf("locking pidfile: %w", err)
+	}
+	f.Truncate(0)
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("writing pidfile: %w", err)
+	}
+	return func() {
+		f.Truncate(0)
+		f.Close()
+	}, nil
+}
+
+// runningWatcher returns the pid of the watcher holding the pidfile, 0 when
+// none runs
+func runningWatcher() int {
+	f, err := os.Open(watchPIDFile())
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	if err := lockFile(f); !errors.Is(err, errWatchLocked) {
+		return 0 // closing the file drops a lock we just took
+	}
+	return readPID(watchPIDFile())
+}
+
+// readPID reads the pid in a pidfile, 0 if there is none
+func readPID(path string) int {
+	data, _ := os.ReadFile(path)
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// watchStop stops the running watcher and waits for it to exit
+func watchStop(w io.Writer) error {
+	pid := runningWatcher()
+	if pid == 0 {
+		fmt.Fprintln(w, "No watcher is running")
+		return nil
+	}
+	proc, err := os.FindProcess(pid)
+	if err == nil {
+		err = proc.Signal(syscall.SIGTERM)
+	}
+	if err != nil {
+		return fmt.Errorf("stopping watcher (pid %d): %w", pid, err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); runningWatcher() == pid; {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("watcher (pid %d) did not stop", pid)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	fmt.Fprintf(w, "%s Stopped watcher (pid %d)\n", SuccessStyle.Render("✓"), pid)
+	if _, err := service.Query(); err == nil {
+		fmt.Fprintln(w, DimStyle.Render("The watch service starts it again; remove it with: gitme watch uninstall"))
+	}
+	return nil
+}
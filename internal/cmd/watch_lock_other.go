@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cmd
+
+import "os"
+
+// lockFile does not lock where flock is missing: any number of watchers may
+// run there
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+	"github.com/vosamoilenko/gitme/internal/render"
+)
+
+func TestWatchOnceAppliesOverrideAndReportsOnce(t *testing.T) {
+	repo := newSwitchRepo(t)
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(repo, ".gitme"), []byte("me@example.com\n"), 0644)
+	settings := &config.Settings{AutoApply: true}
+	if err := settings.Save(); err != nil {
+		t.Fatalf("saving settings: %v", err)
+	}
+
+	reported := make(map[string]string)
+	var out bytes.Buffer
+	if _, err := watchOnce(&out, reported); err != nil {
+		t.Fatalf("watchOnce failed: %v", err)
+	}
+	if !strings.Contains(out.String(), repo) || !strings.Contains(out.String(), "Auto-switched") {
+		t.Fatalf("expected switch to be logged, got %q", out.String())
+	}
+	if got := mustGit(t, repo, "config", "--local", "user.email"); got != "me@example.com" {
+		t.Fatalf("expected override to be applied, got %q", got)
+	}
+
+	out.Reset()
+	if _, err := watchOnce(&out, reported); err != nil {
+		t.Fatalf("watchOnce failed: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected a quiet second pass, got %q", out.String())
+	}
+}
+
+func TestWatchOnceWritesOnlyMovedUseStamps(t *testing.T) {
+	repo := newSwitchRepo(t)
+	commitBy(t, repo, "me@corp.com", "work")
+	if _, err := watchOnce(&bytes.Buffer{}, make(map[string]string)); err != nil {
+		t.Fatalf("watchOnce failed: %v", err)
+	}
+	cfg, _ := config.Load()
+	if cfg.IdentityByRef("me@corp.com").LastUsed.IsZero() {
+		t.Fatal("expected the head commit recorded as a use")
+	}
+
+	// Nothing new: the config is left alone
+	path := filepath.Join(config.Dir(), "identities.json")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(path, old, old)
+	if _, err := watchOnce(&bytes.Buffer{}, make(map[string]string)); err != nil {
+		t.Fatalf("watchOnce failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || !info.ModTime().Equal(old) {
+		t.Fatalf("expected identities.json left alone without new uses: %v", err)
+	}
+}
+
+func TestWatchInterval(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+		ok   bool
+	}{
+		{nil, "1m0s", true},
+		{[]string{"--interval", "30s"}, "30s", true},
+		{[]string{"--interval=5m"}, "5m0s", true},
+		{[]string{"--interval", "soon"}, "", false},
+	} {
+		got, err := watchInterval(tc.args)
+		if (err == nil) != tc.ok || (tc.ok && got.String() != tc.want) {
+			t.Errorf("watchInterval(%v) = %v, %v", tc.args, got, err)
+		}
+	}
+}
+
+func TestWatchStatusEndpointReportsMismatches(t *testing.T) {
+	repo := newSwitchRepo(t)
+	if err := Set(&bytes.Buffer{}, []string{"me@corp.com"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(repo, ".gitme"), []byte("me@example.com\n"), 0644)
+
+	report, err := watchOnce(&bytes.Buffer{}, make(map[string]string))
+	if err != nil {
+		t.Fatalf("watchOnce failed: %v", err)
+	}
+	var state watchState
+	state.set(report)
+
+	rec := httptest.NewRecorder()
+	state.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var got watchReport
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding status: %v", err)
+	}
+	if len(got.Mismatches) != 1 || got.Mismatches[0].Repo != repo || got.Mismatches[0].Expected != "me@example.com" {
+		t.Fatalf("unexpected mismatches: %+v", got.Mismatches)
+	}
+	if got.LastScan.IsZero() {
+		t.Fatalf("expected last scan time")
+	}
+
+	rec = httptest.NewRecorder()
+	state.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/status", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected POST to be refused, got %d", rec.Code)
+	}
+}
+
+func TestLoopbackAddr(t *testing.T) {
+	for addr, want := range map[string]string{
+		":7465":          "127.0.0.1:7465",
+		"localhost:7465": "localhost:7465",
+		"[::1]:7465":     "[::1]:7465",
+		"0.0.0.0:7465":   "",
+		"example.com:80": "",
+	} {
+		got, err := loopbackAddr(addr)
+		if (err == nil) != (want != "") || got != want {
+			t.Errorf("loopbackAddr(%q) = %q, %v", addr, got, err)
+		}
+	}
+}
+
+func TestWatchRunsOnce(t *testing.T) {
+	newSwitchRepo(t)
+	if pid := runningWatcher(); pid != 0 {
+		t.Fatalf("expected no watcher, got pid %d", pid)
+	}
+
+	release, err := claimWatch()
+	if err != nil {
+		t.Fatalf("claiming watch failed: %v", err)
+	}
+	if pid := runningWatcher(); pid != os.Getpid() {
+		t.Fatalf("runningWatcher() = %d, want %d", pid, os.Getpid())
+	}
+	var running *watchRunningError
+	if _, err := claimWatch(); !errors.As(err, &running) || running.PID != os.Getpid() {
+		t.Fatalf("expected a second watcher to be refused, got %v", err)
+	}
+
+	release()
+	if pid := runningWatcher(); pid != 0 {
+		t.Fatalf("expected the watcher gone after release, got pid %d", pid)
+	}
+	release, err = claimWatch()
+	if err != nil {
+		t.Fatalf("claiming watch after release failed: %v", err)
+	}
+	release()
+}
+
+func TestWatchStatusReportsMetrics(t *testing.T) {
+	repo := newSwitchRepo(t)
+	os.WriteFile(filepath.Join(repo, ".gitme"), []byte("me@example.com\n"), 0644)
+	if err := Config(&bytes.Buffer{}, []string{"auto_apply", "on"}); err != nil {
+		t.Fatalf("config failed: %v", err)
+	}
+
+	metrics := &config.WatchMetrics{PID: 1, Interval: "1m0s"}
+	reported := make(map[string]string)
+	for range 2 {
+		report, err := watchOnce(&bytes.Buffer{}, reported)
+		recordWatchMetrics(metrics, report, err)
+	}
+	recordWatchMetrics(metrics, nil, errors.New("loading config: boom"))
+	if err := metrics.Save(); err != nil {
+		t.Fatalf("saving metrics: %v", err)
+	}
+
+	OutputFormat = render.JSON
+	t.Cleanup(func() { OutputFormat = render.Styled })
+	var out bytes.Buffer
+	if err := watchStatus(&out); err != nil {
+		t.Fatalf("watch status failed: %v", err)
+	}
+	var got watchStatusReport
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("decoding status: %v\n%s", err, out.String())
+	}
+	m := got.Metrics
+	if got.Running || m == nil || m.Scans != 3 || m.Repos != 1 || m.AutoFixed != 1 || m.Mismatches != 0 ||
+		m.Errors != 1 || m.LastError != "loading config: boom" || m.LastScan.IsZero() {
+		t.Fatalf("unexpected status: %+v %+v", got, m)
+	}
+}
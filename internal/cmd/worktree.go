@@ -3,12 +3,14 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/atotto/clipboard"
+	"github.com/vosamoilenko/gitme/internal/config"
 )
 
 type worktreeConfig struct {
@@ -16,8 +18,7 @@ type worktreeConfig struct {
 }
 
 func worktreeConfigPath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".config", "gitme", "worktrees.json")
+	return filepath.Join(config.Dir(), "worktrees.json")
 }
 
 func loadWorktreeConfig() *worktreeConfig {
@@ -38,6 +39,10 @@ func (c *worktreeConfig) save() error {
 	if err != nil {
 		return err
 	}
+	if readOnlySkip("write %s", worktreeConfigPath()) {
+		return nil
+	}
+	os.MkdirAll(config.Dir(), 0755)
 	return os.WriteFile(worktreeConfigPath(), data, 0644)
 }
 
@@ -51,20 +56,6 @@ func getWorktreesPath(gitRoot string) string {
 	return filepath.Join(parentDir, dirName+"-worktrees")
 }
 
-func requireGitRoot() string {
-	cwd, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Cannot get working directory")
-		os.Exit(1)
-	}
-	root, err := RepoRoot(cwd)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Not inside a git repository")
-		os.Exit(1)
-	}
-	return root
-}
-
 func branchExists(branch string) bool {
 	cmd := exec.Command("git", "rev-parse", "--verify", branch)
 	cmd.Stdout = nil
@@ -72,42 +63,48 @@ func branchExists(branch string) bool {
 	return cmd.Run() == nil
 }
 
-func treePath(args []string) {
-	gitRoot := requireGitRoot()
+func treePath(w io.Writer, args []string) error {
+	gitRoot, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
 
 	if len(args) < 1 {
 		current := getWorktreesPath(gitRoot)
-		fmt.Println(current)
-		return
+		fmt.Fprintln(w, current)
+		return nil
 	}
 
 	resolved, _ := filepath.Abs(args[0])
 	cfg := loadWorktreeConfig()
 	cfg.Projects[gitRoot] = resolved
 	if err := cfg.save(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("saving config: %w", err)
 	}
-	fmt.Println(SuccessStyle.Render("Worktrees path set to:"), resolved)
+	fmt.Fprintln(w, SuccessStyle.Render("Worktrees path set to:"), resolved)
+	return nil
 }
 
-func wtCb(args []string) {
+func wtCb(w io.Writer, args []string) error {
 	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: gitme tree cb <branch-name>")
-		os.Exit(1)
+		return usageErr("gitme tree cb <branch-name>")
 	}
 	branchName := args[0]
 
-	gitRoot := requireGitRoot()
+	gitRoot, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
 	worktreesDir := getWorktreesPath(gitRoot)
 
-	os.MkdirAll(worktreesDir, 0755)
-
 	wtPath := filepath.Join(worktreesDir, branchName)
 	if _, err := os.Stat(wtPath); err == nil {
-		fmt.Fprintf(os.Stderr, "Path already exists: %s\n", wtPath)
-		os.Exit(1)
+		return fmt.Errorf("path already exists: %s", wtPath)
+	}
+	if readOnlySkip("create a worktree for %s at %s", branchName, wtPath) {
+		return nil
 	}
+	os.MkdirAll(worktreesDir, 0755)
 
 	var cmd *exec.Cmd
 	if branchExists(branchName) {
@@ -115,57 +112,62 @@ func wtCb(args []string) {
 	} else {
 		cmd = exec.Command("git", "worktree", "add", wtPath, "-b", branchName)
 	}
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = w
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		os.Exit(1)
+		return fmt.Errorf("creating worktree: %w", err)
 	}
 
 	clipboard.WriteAll(wtPath)
-	fmt.Println()
-	fmt.Println(SuccessStyle.Render("Worktree created:"), wtPath)
-	fmt.Println(DimStyle.Render("(path copied to clipboard)"))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, SuccessStyle.Render("Worktree created:"), wtPath)
+	fmt.Fprintln(w, DimStyle.Render("(path copied to clipboard)"))
+	return nil
 }
 
-func wtCo(args []string) {
+func wtCo(w io.Writer, args []string) error {
 	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: gitme tree co <branch-name>")
-		os.Exit(1)
+		return usageErr("gitme tree co <branch-name>")
 	}
 	branchName := args[0]
 
-	gitRoot := requireGitRoot()
+	gitRoot, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
 	worktreesDir := getWorktreesPath(gitRoot)
-	os.MkdirAll(worktreesDir, 0755)
 
 	wtPath := filepath.Join(worktreesDir, branchName)
 	if _, err := os.Stat(wtPath); err == nil {
-		fmt.Fprintf(os.Stderr, "Path already exists: %s\n", wtPath)
-		os.Exit(1)
+		return fmt.Errorf("path already exists: %s", wtPath)
 	}
+	if readOnlySkip("fetch origin/%s and create a worktree for it at %s", branchName, wtPath) {
+		return nil
+	}
+	os.MkdirAll(worktreesDir, 0755)
 
 	fetch := exec.Command("git", "fetch", "origin", branchName)
-	fetch.Stdout = os.Stdout
+	fetch.Stdout = w
 	fetch.Stderr = os.Stderr
 	if err := fetch.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to fetch origin/%s\n", branchName)
-		os.Exit(1)
+		return fmt.Errorf("failed to fetch origin/%s", branchName)
 	}
 
 	cmd := exec.Command("git", "worktree", "add", wtPath, "--track", "-b", branchName, "origin/"+branchName)
 	if branchExists(branchName) {
 		cmd = exec.Command("git", "worktree", "add", wtPath, branchName)
 	}
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = w
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		os.Exit(1)
+		return fmt.Errorf("creating worktree: %w", err)
 	}
 
 	clipboard.WriteAll(wtPath)
-	fmt.Println()
-	fmt.Println(SuccessStyle.Render("Worktree created from remote:"), wtPath)
-	fmt.Println(DimStyle.Render("(path copied to clipboard)"))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, SuccessStyle.Render("Worktree created from remote:"), wtPath)
+	fmt.Fprintln(w, DimStyle.Render("(path copied to clipboard)"))
+	return nil
 }
 
 func getMainWorktreePath() string {
@@ -200,12 +202,13 @@ func getNonMainWorktreePaths() []string {
 	return paths
 }
 
-func wtLs() {
-	_ = requireGitRoot()
+func wtLs(w io.Writer) error {
+	if _, err := requireGitRoot(); err != nil {
+		return err
+	}
 	out, err := exec.Command("git", "worktree", "list", "--porcelain").Output()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to list worktrees")
-		os.Exit(1)
+		return fmt.Errorf("listing worktrees: %w", err)
 	}
 
 	first := true
@@ -213,7 +216,7 @@ func wtLs() {
 	for _, line := range strings.Split(string(out), "\n") {
 		if strings.HasPrefix(line, "worktree ") {
 			if len(current) > 0 && !first {
-				fmt.Println(strings.Join(current, " "))
+				fmt.Fprintln(w, strings.Join(current, " "))
 			}
 			if first {
 				first = false
@@ -229,39 +232,44 @@ func wtLs() {
 		}
 	}
 	if len(current) > 0 {
-		fmt.Println(strings.Join(current, " "))
+		fmt.Fprintln(w, strings.Join(current, " "))
 	}
+	return nil
 }
 
-func wtRm(args []string) {
+func wtRm(w io.Writer, args []string) error {
 	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: gitme tree rm <branch-name|path|--all>")
-		os.Exit(1)
+		return usageErr("gitme tree rm <branch-name|path|--all>")
 	}
 
-	_ = requireGitRoot()
+	gitRoot, err := requireGitRoot()
+	if err != nil {
+		return err
+	}
 
 	if args[0] == "--all" {
 		paths := getNonMainWorktreePaths()
 		if len(paths) == 0 {
-			fmt.Println("No worktrees to remove")
-			return
+			fmt.Fprintln(w, "No worktrees to remove")
+			return nil
 		}
 		for _, p := range paths {
+			if readOnlySkip("remove worktree %s", p) {
+				continue
+			}
 			cmd := exec.Command("git", "worktree", "remove", p)
-			cmd.Stdout = os.Stdout
+			cmd.Stdout = w
 			cmd.Stderr = os.Stderr
 			if err := cmd.Run(); err != nil {
 				fmt.Fprintf(os.Stderr, "Failed to remove: %s\n", p)
 				continue
 			}
-			fmt.Println(SuccessStyle.Render("Removed worktree:"), p)
+			fmt.Fprintln(w, SuccessStyle.Render("Removed worktree:"), p)
 		}
-		return
+		return nil
 	}
 
 	target := args[0]
-	gitRoot := requireGitRoot()
 
 	if !filepath.IsAbs(target) {
 		if _, err := os.Stat(target); err != nil {
@@ -275,22 +283,24 @@ func wtRm(args []string) {
 	resolved, _ := filepath.Abs(target)
 
 	if mainWt := getMainWorktreePath(); mainWt != "" && resolved == mainWt {
-		fmt.Fprintln(os.Stderr, "Cannot remove the main working tree")
-		os.Exit(1)
+		return fmt.Errorf("cannot remove the main working tree")
 	}
 
+	if readOnlySkip("remove worktree %s", resolved) {
+		return nil
+	}
 	cmd := exec.Command("git", "worktree", "remove", resolved)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = w
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		os.Exit(1)
+		return fmt.Errorf("removing worktree: %w", err)
 	}
-	fmt.Println(SuccessStyle.Render("Removed worktree:"), resolved)
+	fmt.Fprintln(w, SuccessStyle.Render("Removed worktree:"), resolved)
+	return nil
 }
 
 // Tree dispatches worktree subcommands: gitme tree <subcmd> [args...]
-func Tree() {
-	args := os.Args[2:]
+func Tree(w io.Writer, args []string) error {
 	subcmd := ""
 	if len(args) > 0 {
 		subcmd = args[0]
@@ -299,31 +309,31 @@ func Tree() {
 
 	switch subcmd {
 	case "path":
-		treePath(args)
+		return treePath(w, args)
 	case "cb":
-		wtCb(args)
+		return wtCb(w, args)
 	case "co":
-		wtCo(args)
+		return wtCo(w, args)
 	case "ls":
-		wtLs()
+		return wtLs(w)
 	case "rm":
-		wtRm(args)
+		return wtRm(w, args)
 	case "", "help", "-h", "--help":
-		treeHelp()
+		treeHelp(w)
+		return nil
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown tree command: %s\n", subcmd)
-		treeHelp()
-		os.Exit(1)
+		treeHelp(os.Stderr)
+		return fmt.Errorf("unknown tree command: %s", subcmd)
 	}
 }
 
-func treeHelp() {
-	fmt.Println(HeaderStyle.Render("gitme tree") + " - worktree manager")
-	fmt.Println()
-	fmt.Println("  gitme tree path [<path>]   Show or set worktrees path for this project")
-	fmt.Println("  gitme tree cb <branch>     Create a worktree branch (copies path to clipboard)")
-	fmt.Println("  gitme tree co <branch>     Checkout a remote branch as a worktree")
-	fmt.Println("  gitme tree ls              List all worktrees")
-	fmt.Println("  gitme tree rm <name|path>  Remove a worktree")
-	fmt.Println("  gitme tree rm --all        Remove all worktrees (keeps main repo)")
+func treeHelp(w io.Writer) {
+	fmt.Fprintln(w, HeaderStyle.Render("gitme tree")+" - worktree manager")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  gitme tree path [<path>]   Show or set worktrees path for this project")
+	fmt.Fprintln(w, "  gitme tree cb <branch>     Create a worktree branch (copies path to clipboard)")
+	fmt.Fprintln(w, "  gitme tree co <branch>     Checkout a remote branch as a worktree")
+	fmt.Fprintln(w, "  gitme tree ls              List all worktrees")
+	fmt.Fprintln(w, "  gitme tree rm <name|path>  Remove a worktree")
+	fmt.Fprintln(w, "  gitme tree rm --all        Remove all worktrees (keeps main repo)")
 }
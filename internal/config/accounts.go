@@ -0,0 +1,67 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+// AccountRefresh is how long a platform account fetched with a token is used
+// before gitme asks the API again
+const AccountRefresh = time.Hour
+
+// CachedAccount is a platform account as last fetched with a token
+type CachedAccount struct {
+	Platform identity.Platform `json:"platform"`
+	Login    string            `json:"login"`
+	Emails   []CachedEmail     `json:"emails"`
+	Fetched  time.Time         `json:"fetched"`
+}
+
+// CachedEmail is an address of a cached account
+type CachedEmail struct {
+	Address  string `json:"address"`
+	Verified bool   `json:"verified,omitempty"`
+}
+
+// AccountCache holds the platform accounts fetched with tokens, keyed by a
+// hash of the token, so they are not fetched on every run and are still
+// known offline
+type AccountCache struct {
+	Accounts map[string]CachedAccount `json:"accounts"`
+}
+
+func accountCachePath() string {
+	return filepath.Join(Dir(), "accounts.json")
+}
+
+// LoadAccountCache reads the cached platform accounts from disk
+func LoadAccountCache() (*AccountCache, error) {
+	cache := &AccountCache{Accounts: make(map[string]CachedAccount)}
+	data, err := os.ReadFile(accountCachePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, err
+	}
+	if cache.Accounts == nil {
+		cache.Accounts = make(map[string]CachedAccount)
+	}
+	return cache, nil
+}
+
+// Save writes the cached platform accounts to disk
+func (c *AccountCache) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(accountCachePath(), data)
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// What gitme audit emails --resolve can decide for an unknown email
+const (
+	AuditAdd     = "add"     // added as an identity
+	AuditMap     = "map"     // another address of an identity, in the mailmap
+	AuditIgnore  = "ignore"  // left out of scans and audits
+	AuditRewrite = "rewrite" // to be rewritten to an identity with fix:rewrite
+)
+
+// AuditDecision is what the user decided for an unknown email
+type AuditDecision struct {
+	Email  string    `json:"email"` // lowercased
+	Action string    `json:"action"`
+	Into   string    `json:"into,omitempty"`  // identity email, for map and rewrite
+	Repos  []string  `json:"repos,omitempty"` // where it was found, for rewrite
+	At     time.Time `json:"at"`
+}
+
+// AuditConfig holds the decisions made on unknown emails, so none is asked
+// about twice
+type AuditConfig struct {
+	Decisions []AuditDecision `json:"decisions"`
+}
+
+func auditPath() string {
+	return filepath.Join(Dir(), "audit.json")
+}
+
+// LoadAudit reads the audit decisions from disk
+func LoadAudit() (*AuditConfig, error) {
+	a := &AuditConfig{Decisions: []AuditDecision{}}
+	data, err := os.ReadFile(auditPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return a, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Save writes the audit decisions to disk
+func (a *AuditConfig) Save() error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(auditPath(), data)
+}
+
+// Decide records a decision, replacing the one for the same email
+func (a *AuditConfig) Decide(d AuditDecision) {
+	d.Email = strings.ToLower(d.Email)
+	a.Decisions = slices.DeleteFunc(a.Decisions, func(other AuditDecision) bool { return other.Email == d.Email })
+	a.Decisions = append(a.Decisions, d)
+}
+
+// Decided reports whether there is a decision for email
+func (a *AuditConfig) Decided(email string) bool {
+	email = strings.ToLower(email)
+	return slices.ContainsFunc(a.Decisions, func(d AuditDecision) bool { return d.Email == email })
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+// CandidatesConfig is the queue of proposed identities waiting for review,
+// kept apart from the confirmed ones
+type CandidatesConfig struct {
+	Pending   []identity.Candidate `json:"pending"`
+	Dismissed []string             `json:"dismissed,omitempty"` // lowercased emails never proposed again
+	// Renames are names scans found for stored identities; dismissed ones
+	// are not proposed again
+	Renames          []Rename `json:"renames,omitempty"`
+	DismissedRenames []Rename `json:"dismissed_renames,omitempty"`
+}
+
+func candidatesPath() string {
+	return filepath.Join(Dir(), "candidates.json")
+}
+
+// LoadCandidates reads the review queue from disk
+func LoadCandidates() (*CandidatesConfig, error) {
+	c := &CandidatesConfig{Pending: []identity.Candidate{}}
+
+	data, err := os.ReadFile(candidatesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Save writes the review queue to disk
+func (c *CandidatesConfig) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(candidatesPath(), data)
+}
+
+// Queue adds candidates that are neither pending nor dismissed and refreshes
+// the counts of pending ones; it returns how many were added
+func (c *CandidatesConfig) Queue(candidates []identity.Candidate) int {
+	added := 0
+	for _, cand := range candidates {
+		email := strings.ToLower(cand.Email)
+		if slices.Contains(c.Dismissed, email) {
+			continue
+		}
+		if i := c.index(email); i >= 0 {
+			c.Pending[i].Commits, c.Pending[i].Repos = cand.Commits, cand.Repos
+			continue
+		}
+		c.Pending = append(c.Pending, cand)
+		added++
+	}
+	return added
+}
+
+// Take removes a pending candidate from the queue and returns it
+func (c *CandidatesConfig) Take(email string) (identity.Candidate, bool) {
+	i := c.index(strings.ToLower(email))
+	if i < 0 {
+		return identity.Candidate{}, false
+	}
+	cand := c.Pending[i]
+	c.Pending = append(c.Pending[:i], c.Pending[i+1:]...)
+	return cand, true
+}
+
+// Dismiss drops a pending candidate and keeps it from being proposed again
+func (c *CandidatesConfig) Dismiss(email string) bool {
+	if _, ok := c.Take(email); !ok {
+		return false
+	}
+	c.Dismissed = append(c.Dismissed, strings.ToLower(email))
+	return true
+}
+
+// QueueRenames adds renames that are not dismissed, replacing pending ones
+// for the same email; it returns how many were new
+func (c *CandidatesConfig) QueueRenames(renames []Rename) int {
+	added := 0
+	for _, r := range renames {
+		if slices.ContainsFunc(c.DismissedRenames, func(d Rename) bool { return sameRename(d, r) }) {
+			continue
+		}
+		if i := c.renameIndex(r.Email); i >= 0 {
+			if !sameRename(c.Renames[i], r) {
+				c.Renames[i] = r
+				added++
+			}
+			continue
+		}
+		c.Renames = append(c.Renames, r)
+		added++
+	}
+	return added
+}
+
+// PendingRename returns the pending rename of email
+func (c *CandidatesConfig) PendingRename(email string) (Rename, bool) {
+	if i := c.renameIndex(email); i >= 0 {
+		return c.Renames[i], true
+	}
+	return Rename{}, false
+}
+
+// TakeRename removes the pending rename of email from the queue and returns it
+func (c *CandidatesConfig) TakeRename(email string) (Rename, bool) {
+	i := c.renameIndex(email)
+	if i < 0 {
+		return Rename{}, false
+	}
+	r := c.Renames[i]
+	c.Renames = append(c.Renames[:i], c.Renames[i+1:]...)
+	return r, true
+}
+
+// DismissRename keeps the name of an identity and stops proposing r again
+func (c *CandidatesConfig) DismissRename(r Rename) {
+	c.TakeRename(r.Email)
+	c.DismissedRenames = append(c.DismissedRenames, r)
+}
+
+func (c *CandidatesConfig) renameIndex(email string) int {
+	for i, r := range c.Renames {
+		if strings.EqualFold(r.Email, email) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameRename reports whether a and b propose the same name for an email
+func sameRename(a, b Rename) bool {
+	return strings.EqualFold(a.Email, b.Email) && a.Scanned == b.Scanned
+}
+
+func (c *CandidatesConfig) index(email string) int {
+	for i, cand := range c.Pending {
+		if strings.ToLower(cand.Email) == email {
+			return i
+		}
+	}
+	return -1
+}
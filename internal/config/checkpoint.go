@@ -0,0 +1,43 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+func scanCheckpointPath() string {
+	return filepath.Join(Dir(), "scan.json")
+}
+
+// LoadScanCheckpoint returns the progress of an interrupted scan, or nil
+func LoadScanCheckpoint() (*identity.Checkpoint, error) {
+	data, err := os.ReadFile(scanCheckpointPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	cp := &identity.Checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// SaveScanCheckpoint persists the progress of a running scan
+func SaveScanCheckpoint(cp *identity.Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(scanCheckpointPath(), data)
+}
+
+// ClearScanCheckpoint removes the checkpoint of a finished scan
+func ClearScanCheckpoint() error {
+	return removeFile(scanCheckpointPath())
+}
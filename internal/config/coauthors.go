@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Coauthor is a collaborator gitme coauthor writes trailers for, kept apart
+// from the identities you commit as
+type Coauthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// CoauthorsConfig holds the collaborators
+type CoauthorsConfig struct {
+	Coauthors []Coauthor `json:"coauthors"`
+}
+
+func coauthorsPath() string {
+	return filepath.Join(Dir(), "coauthors.json")
+}
+
+// LoadCoauthors reads the collaborators from disk
+func LoadCoauthors() (*CoauthorsConfig, error) {
+	cfg := &CoauthorsConfig{Coauthors: []Coauthor{}}
+
+	data, err := os.ReadFile(coauthorsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.Coauthors == nil {
+		cfg.Coauthors = []Coauthor{}
+	}
+
+	return cfg, nil
+}
+
+// Save writes the collaborators to disk
+func (c *CoauthorsConfig) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(coauthorsPath(), data)
+}
+
+// Add adds a collaborator, or renames the one with the same email
+func (c *CoauthorsConfig) Add(name, email string) {
+	for i, co := range c.Coauthors {
+		if strings.EqualFold(co.Email, email) {
+			c.Coauthors[i].Name = name
+			return
+		}
+	}
+	c.Coauthors = append(c.Coauthors, Coauthor{Name: name, Email: email})
+}
+
+// Find returns the collaborator whose email, name or email user is ref
+func (c *CoauthorsConfig) Find(ref string) (Coauthor, bool) {
+	for _, co := range c.Coauthors {
+		user, _, _ := strings.Cut(co.Email, "@")
+		if strings.EqualFold(co.Email, ref) || strings.EqualFold(co.Name, ref) || strings.EqualFold(user, ref) {
+			return co, true
+		}
+	}
+	return Coauthor{}, false
+}
+
+// Remove removes the collaborator ref finds, returns false if not found
+func (c *CoauthorsConfig) Remove(ref string) bool {
+	co, ok := c.Find(ref)
+	if !ok {
+		return false
+	}
+	c.Coauthors = slices.DeleteFunc(c.Coauthors, func(other Coauthor) bool { return other == co })
+	return true
+}
@@ -1,46 +1,127 @@
 package config
 
 import (
+	"cmp"
 	"encoding/json"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/vosamoilenko/gitme/internal/identity"
 )
 
 var configDir string
 
-func init() {
+// Dir returns the config directory: the one set with SetDir, else
+// $GITME_CONFIG_DIR, else ~/.config/gitme
+func Dir() string {
+	if configDir == "" {
+		if env := os.Getenv("GITME_CONFIG_DIR"); env != "" {
+			SetDir(env)
+		} else {
+			configDir = defaultDir()
+		}
+	}
+	return configDir
+}
+
+// SetDir overrides the config directory for this process. A relative dir is
+// made absolute now, so later changes of directory do not move it.
+func SetDir(dir string) {
+	if abs, err := filepath.Abs(dir); dir != "" && err == nil {
+		dir = abs
+	}
+	configDir = dir
+}
+
+// DirOverridden reports whether the config directory was chosen with SetDir
+// or $GITME_CONFIG_DIR rather than being the default
+func DirOverridden() bool {
+	return Dir() != defaultDir()
+}
+
+func defaultDir() string {
 	home, _ := os.UserHomeDir()
-	configDir = filepath.Join(home, ".config", "gitme")
-	os.MkdirAll(configDir, 0755)
+	return filepath.Join(home, ".config", "gitme")
 }
 
-// ============ Identities Config ============
+// readOnly, when set, is told about config writes instead of them happening
+var readOnly func(action string)
 
-// Config holds identities and folder mappings
+// SetReadOnly makes config writes and removals call report with what they
+// would do instead; nil makes them happen again
+func SetReadOnly(report func(action string)) {
+	readOnly = report
+}
+
+// writeFile writes a config file, creating the config directory if needed
+func writeFile(path string, data []byte) error {
+	if readOnly != nil {
+		readOnly("write " + path)
+		return nil
+	}
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return err
+	}
+	// Write to a temp file and rename so an interrupted write never leaves a
+	// truncated file behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// removeFile removes a config file; a missing one is not an error
+func removeFile(path string) error {
+	if readOnly != nil {
+		if _, err := os.Stat(path); err == nil {
+			readOnly("remove " + path)
+		}
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Config holds identities and folder mappings. Mappings point at identities
+// by ID, so editing an identity's name or email keeps them.
 type Config struct {
-	FolderIdentities map[string]identity.Identity `json:"folder_identities"`
-	Identities       []identity.Identity          `json:"identities"`
+	// Folders maps folders to the ID of the identity they use
+	Folders    map[string]string   `json:"folders"`
+	Identities []identity.Identity `json:"identities"`
+	// BranchIdentities maps repo roots to branch patterns (e.g. release/*)
+	// and the ID of the identity committed with on matching branches
+	BranchIdentities map[string]map[string]string `json:"branch_identities,omitempty"`
+	// HostDefaults maps remote hosts (e.g. github.com) to the ID of the
+	// identity repos hosted there use when nothing more specific applies
+	HostDefaults map[string]string `json:"host_defaults,omitempty"`
+	// LegacyFolders are mappings from before IDs, holding a copy of the
+	// identity; Load moves them to Folders
+	LegacyFolders map[string]identity.Identity `json:"folder_identities,omitempty"`
 }
 
 func identitiesPath() string {
-	return filepath.Join(configDir, "identities.json")
+	return filepath.Join(Dir(), "identities.json")
 }
 
 // Load reads the identities config from disk
 func Load() (*Config, error) {
 	cfg := &Config{
-		FolderIdentities: make(map[string]identity.Identity),
-		Identities:       []identity.Identity{},
+		Folders:    make(map[string]string),
+		Identities: []identity.Identity{},
 	}
 
 	data, err := os.ReadFile(identitiesPath())
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Try legacy config.json
-			legacyPath := filepath.Join(configDir, "config.json")
+			legacyPath := filepath.Join(Dir(), "config.json")
 			data, err = os.ReadFile(legacyPath)
 			if err != nil {
 				return cfg, nil
@@ -49,9 +130,10 @@ func Load() (*Config, error) {
 			if err := json.Unmarshal(data, cfg); err != nil {
 				return nil, err
 			}
+			cfg.migrateRefs()
 			// Save to new location and delete legacy
 			cfg.Save()
-			os.Remove(legacyPath)
+			removeFile(legacyPath)
 			return cfg, nil
 		}
 		return nil, err
@@ -61,246 +143,300 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
-	if cfg.FolderIdentities == nil {
-		cfg.FolderIdentities = make(map[string]identity.Identity)
+	if cfg.Folders == nil {
+		cfg.Folders = make(map[string]string)
+	}
+	// Identities from before IDs get theirs now and mappings move over to
+	// them, saved so rules can use the IDs too
+	if cfg.migrateRefs() {
+		if err := cfg.Save(); err != nil {
+			return nil, err
+		}
 	}
 
 	return cfg, nil
 }
 
+// migrateRefs gives identities without an ID one and points folder and
+// branch mappings naming an email at the identity's ID. Mappings to unknown
+// identities keep the email. It reports whether anything changed.
+func (c *Config) migrateRefs() bool {
+	changed := c.assignIDs()
+	if c.Folders == nil {
+		c.Folders = make(map[string]string)
+	}
+	for folder, id := range c.LegacyFolders {
+		c.Folders[folder] = cmp.Or(id.ID, id.Email)
+		changed = true
+	}
+	c.LegacyFolders = nil
+	toID := func(ref string) string {
+		if id := c.IdentityByRef(ref); id != nil && id.ID != ref {
+			changed = true
+			return id.ID
+		}
+		return ref
+	}
+	for folder, ref := range c.Folders {
+		c.Folders[folder] = toID(ref)
+	}
+	for _, patterns := range c.BranchIdentities {
+		for pattern, ref := range patterns {
+			patterns[pattern] = toID(ref)
+		}
+	}
+	for host, ref := range c.HostDefaults {
+		c.HostDefaults[host] = toID(ref)
+	}
+	return changed
+}
+
+// IdentityByRef returns the identity with ID ref, else the one with email
+// ref, or nil
+func (c *Config) IdentityByRef(ref string) *identity.Identity {
+	for i := range c.Identities {
+		if c.Identities[i].ID == ref {
+			return &c.Identities[i]
+		}
+	}
+	for i := range c.Identities {
+		if strings.EqualFold(c.Identities[i].Email, ref) {
+			return &c.Identities[i]
+		}
+	}
+	return nil
+}
+
+// assignIDs gives every identity without an ID a new one and reports whether
+// there were any
+func (c *Config) assignIDs() bool {
+	assigned := false
+	for i := range c.Identities {
+		if c.Identities[i].ID == "" {
+			c.Identities[i].ID = identity.NewID()
+			assigned = true
+		}
+	}
+	return assigned
+}
+
 // Save writes the identities config to disk
 func (c *Config) Save() error {
+	c.assignIDs()
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(identitiesPath(), data, 0644)
+	return writeFile(identitiesPath(), data)
 }
 
 // Delete removes the identities config file
 func Delete() error {
-	if err := os.Remove(identitiesPath()); err != nil && !os.IsNotExist(err) {
-		return err
-	}
-	return nil
+	return removeFile(identitiesPath())
 }
 
 // SetIdentityForFolder associates an identity with a folder
 func (c *Config) SetIdentityForFolder(folder string, id identity.Identity) {
-	c.FolderIdentities[folder] = id
+	if c.Folders == nil {
+		c.Folders = make(map[string]string)
+	}
+	c.Folders[folder] = cmp.Or(id.ID, id.Email)
 }
 
-// GetIdentityForFolder returns the identity for a folder, if set
+// GetIdentityForFolder returns the identity for a folder, if set and still
+// known
 func (c *Config) GetIdentityForFolder(folder string) (identity.Identity, bool) {
-	id, ok := c.FolderIdentities[folder]
-	return id, ok
-}
-
-// UpdateIdentities merges newly discovered identities with stored ones
-func (c *Config) UpdateIdentities(ids []identity.Identity) {
-	seen := make(map[string]bool)
-	for _, id := range c.Identities {
-		seen[id.Email] = true
+	ref, ok := c.Folders[folder]
+	if !ok {
+		return identity.Identity{}, false
 	}
-	for _, id := range ids {
-		if !seen[id.Email] {
-			c.Identities = append(c.Identities, id)
-			seen[id.Email] = true
-		}
+	if id := c.IdentityByRef(ref); id != nil {
+		return *id, true
 	}
+	return identity.Identity{}, false
 }
 
-// ============ Rules Config ============
-
-// Rule maps a path pattern to an identity email
-type Rule struct {
-	Pattern string `json:"pattern"` // e.g., "github.com/vosamoilenko" or "~/work"
-	Email   string `json:"email"`
-}
-
-// RulesConfig holds auto-switch rules
-type RulesConfig struct {
-	Rules []Rule `json:"rules"`
-}
-
-func rulesPath() string {
-	return filepath.Join(configDir, "rules.json")
-}
-
-// LoadRules reads the rules config from disk
-func LoadRules() (*RulesConfig, error) {
-	cfg := &RulesConfig{Rules: []Rule{}}
-
-	data, err := os.ReadFile(rulesPath())
-	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil
-		}
-		return nil, err
+// SetBranchIdentity commits as id on branches of repo matching pattern
+func (c *Config) SetBranchIdentity(repo, pattern string, id identity.Identity) {
+	if c.BranchIdentities == nil {
+		c.BranchIdentities = make(map[string]map[string]string)
 	}
-
-	if err := json.Unmarshal(data, cfg); err != nil {
-		return nil, err
+	if c.BranchIdentities[repo] == nil {
+		c.BranchIdentities[repo] = make(map[string]string)
 	}
-
-	return cfg, nil
+	c.BranchIdentities[repo][pattern] = cmp.Or(id.ID, id.Email)
 }
 
-// Save writes the rules config to disk
-func (r *RulesConfig) Save() error {
-	data, err := json.MarshalIndent(r, "", "  ")
-	if err != nil {
-		return err
+// RemoveBranchIdentity drops a branch pattern of repo; it reports false if
+// there was none
+func (c *Config) RemoveBranchIdentity(repo, pattern string) bool {
+	if _, ok := c.BranchIdentities[repo][pattern]; !ok {
+		return false
 	}
-	return os.WriteFile(rulesPath(), data, 0644)
-}
-
-// AddRule adds a new rule or updates existing one
-func (r *RulesConfig) AddRule(pattern, email string) {
-	for i, rule := range r.Rules {
-		if rule.Pattern == pattern {
-			r.Rules[i].Email = email
-			return
-		}
+	delete(c.BranchIdentities[repo], pattern)
+	if len(c.BranchIdentities[repo]) == 0 {
+		delete(c.BranchIdentities, repo)
 	}
-	r.Rules = append(r.Rules, Rule{Pattern: pattern, Email: email})
+	return true
 }
 
-// RemoveRule removes a rule by pattern
-func (r *RulesConfig) RemoveRule(pattern string) bool {
-	for i, rule := range r.Rules {
-		if rule.Pattern == pattern {
-			r.Rules = append(r.Rules[:i], r.Rules[i+1:]...)
-			return true
+// BranchIdentity returns the identity ID for branch of repo and the pattern
+// that chose it; the longest matching pattern wins
+func (c *Config) BranchIdentity(repo, branch string) (ref, pattern string, ok bool) {
+	for p, r := range c.BranchIdentities[repo] {
+		if matched, _ := path.Match(p, branch); matched && len(p) > len(pattern) {
+			ref, pattern, ok = r, p, true
 		}
 	}
-	return false
+	return ref, pattern, ok
 }
 
-// FindRuleForPath finds the best matching rule for a path
-func (r *RulesConfig) FindRuleForPath(path string) *Rule {
-	var bestMatch *Rule
-	bestLen := 0
-	for i, rule := range r.Rules {
-		if matchesPattern(path, rule.Pattern) && len(rule.Pattern) > bestLen {
-			bestMatch = &r.Rules[i]
-			bestLen = len(rule.Pattern)
-		}
+// SetHostDefault makes id the identity of repos whose remote is on host
+func (c *Config) SetHostDefault(host string, id identity.Identity) {
+	if c.HostDefaults == nil {
+		c.HostDefaults = make(map[string]string)
 	}
-	return bestMatch
+	c.HostDefaults[strings.ToLower(host)] = cmp.Or(id.ID, id.Email)
 }
 
-// matchesPattern checks if path contains the pattern
-func matchesPattern(path, pattern string) bool {
-	// Expand ~ in pattern
-	if len(pattern) > 0 && pattern[0] == '~' {
-		home, _ := os.UserHomeDir()
-		pattern = home + pattern[1:]
+// RemoveHostDefault drops the default identity of host; it reports false if
+// there was none
+func (c *Config) RemoveHostDefault(host string) bool {
+	host = strings.ToLower(host)
+	if _, ok := c.HostDefaults[host]; !ok {
+		return false
 	}
-	// Simple contains match - patterns like "github.com/user" or "/full/path"
-	return len(pattern) > 0 && strings.Contains(path, pattern)
+	delete(c.HostDefaults, host)
+	return true
 }
 
-// ============ Settings Config ============
-
-// Settings holds user preferences
-type Settings struct {
-	AutoApply bool `json:"auto_apply"` // false = warn, true = auto-set identity
+// HostDefault returns the default identity of host, if set and still known
+func (c *Config) HostDefault(host string) (identity.Identity, bool) {
+	ref, ok := c.HostDefaults[strings.ToLower(host)]
+	if !ok {
+		return identity.Identity{}, false
+	}
+	if id := c.IdentityByRef(ref); id != nil {
+		return *id, true
+	}
+	return identity.Identity{}, false
 }
 
-func settingsPath() string {
-	return filepath.Join(configDir, "settings.json")
+// MarkUsed records that the identity with this email was just applied
+func (c *Config) MarkUsed(email string) {
+	c.MarkUsedAt(email, time.Now())
 }
 
-// LoadSettings reads the settings from disk
-func LoadSettings() (*Settings, error) {
-	s := &Settings{AutoApply: false}
-
-	data, err := os.ReadFile(settingsPath())
-	if err != nil {
-		if os.IsNotExist(err) {
-			return s, nil
+// MarkUsedAt records a use of the identity at t, e.g. a commit it authored;
+// earlier uses than the recorded one are ignored. It reports whether the
+// recorded use moved.
+func (c *Config) MarkUsedAt(email string, t time.Time) bool {
+	moved := false
+	for i := range c.Identities {
+		if strings.EqualFold(c.Identities[i].Email, email) && t.After(c.Identities[i].LastUsed) {
+			c.Identities[i].LastUsed = t
+			moved = true
 		}
-		return nil, err
-	}
-
-	if err := json.Unmarshal(data, s); err != nil {
-		return nil, err
 	}
-
-	return s, nil
+	return moved
 }
 
-// Save writes the settings to disk
-func (s *Settings) Save() error {
-	data, err := json.MarshalIndent(s, "", "  ")
+// RecordUses marks the uses in uses, email to time, in the identities config
+// on disk. It loads the config right before writing it, so long-running
+// callers such as the watcher keep what other commands changed meanwhile,
+// and writes only when a recorded use moved.
+func RecordUses(uses map[string]time.Time) error {
+	cfg, err := Load()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(settingsPath(), data, 0644)
-}
-
-// ============ Aliases Config ============
-
-// AliasConfig holds name-to-email aliases
-type AliasConfig struct {
-	Aliases map[string]string `json:"aliases"`
-}
-
-func aliasesPath() string {
-	return filepath.Join(configDir, "aliases.json")
-}
-
-// LoadAliases reads the aliases config from disk
-func LoadAliases() (*AliasConfig, error) {
-	cfg := &AliasConfig{Aliases: make(map[string]string)}
-
-	data, err := os.ReadFile(aliasesPath())
-	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil
+	moved := false
+	for email, t := range uses {
+		if cfg.MarkUsedAt(email, t) {
+			moved = true
 		}
-		return nil, err
 	}
-
-	if err := json.Unmarshal(data, cfg); err != nil {
-		return nil, err
+	if !moved {
+		return nil
 	}
+	return cfg.Save()
+}
 
-	if cfg.Aliases == nil {
-		cfg.Aliases = make(map[string]string)
+// AcceptCandidate adds a reviewed candidate as a confirmed identity
+func (c *Config) AcceptCandidate(cand identity.Candidate) {
+	for _, id := range c.Identities {
+		if strings.EqualFold(id.Email, cand.Email) {
+			return
+		}
 	}
+	c.Identities = append(c.Identities, cand.Identity)
+}
 
-	return cfg, nil
+// MergeCandidate records the candidate's email as another address of the
+// identity with email into; it reports false if there is no such identity
+func (c *Config) MergeCandidate(cand identity.Candidate, into string) bool {
+	for i := range c.Identities {
+		id := &c.Identities[i]
+		if !strings.EqualFold(id.Email, into) {
+			continue
+		}
+		if !slices.ContainsFunc(id.AltEmails, func(e string) bool { return strings.EqualFold(e, cand.Email) }) {
+			id.AltEmails = append(id.AltEmails, cand.Email)
+		}
+		return true
+	}
+	return false
 }
 
-// Save writes the aliases config to disk
-func (a *AliasConfig) Save() error {
-	data, err := json.MarshalIndent(a, "", "  ")
-	if err != nil {
-		return err
+// UpdateIdentities merges newly discovered identities with stored ones. A
+// stored identity keeps its name; the names found for it under another one
+// are returned for review.
+func (c *Config) UpdateIdentities(ids []identity.Identity) []Rename {
+	seen := make(map[string]bool)
+	for _, id := range c.Identities {
+		seen[id.Email] = true
+	}
+	var renames []Rename
+	for _, id := range ids {
+		if !seen[id.Email] {
+			c.Identities = append(c.Identities, id)
+			seen[id.Email] = true
+		} else if stored := c.IdentityByRef(id.Email); stored != nil {
+			if r, ok := NameDrift(*stored, id); ok {
+				renames = append(renames, r)
+			}
+		}
 	}
-	return os.WriteFile(aliasesPath(), data, 0644)
+	return renames
 }
 
-// SetAlias adds or updates an alias
-func (a *AliasConfig) SetAlias(name, email string) {
-	a.Aliases[name] = email
+// Rename is another name a scan found for a stored identity, waiting for
+// review before it replaces the stored one
+type Rename struct {
+	Email   string `json:"email"`
+	Name    string `json:"name"`    // stored
+	Scanned string `json:"scanned"` // found by the scan
+	Source  string `json:"source,omitempty"`
 }
 
-// RemoveAlias removes an alias, returns false if not found
-func (a *AliasConfig) RemoveAlias(name string) bool {
-	if _, ok := a.Aliases[name]; !ok {
-		return false
+// NameDrift reports the rename of stored to the name scanned found for it,
+// if the names differ
+func NameDrift(stored, scanned identity.Identity) (Rename, bool) {
+	name := strings.TrimSpace(scanned.Name)
+	if name == "" || name == stored.Name {
+		return Rename{}, false
 	}
-	delete(a.Aliases, name)
-	return true
+	return Rename{Email: stored.Email, Name: stored.Name, Scanned: name, Source: scanned.Source}, true
 }
 
-// ResolveAlias returns the email for an alias, or the input if not found
-func (a *AliasConfig) ResolveAlias(nameOrEmail string) string {
-	if email, ok := a.Aliases[nameOrEmail]; ok {
-		return email
+// RenameIdentity gives the identity with email a new name; it reports false
+// if there is no such identity
+func (c *Config) RenameIdentity(email, name string) bool {
+	for i := range c.Identities {
+		if strings.EqualFold(c.Identities[i].Email, email) {
+			c.Identities[i].Name = name
+			return true
+		}
 	}
-	return nameOrEmail
+	return false
 }
@@ -3,7 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/identity"
 )
 
 func TestMatchesPattern(t *testing.T) {
@@ -31,3 +35,215 @@ func TestMatchesPatternTilde(t *testing.T) {
 		t.Fatalf("expected ~ expansion pattern to match")
 	}
 }
+
+func TestMatchesPatternOnSegmentBoundaries(t *testing.T) {
+	for _, tc := range []struct {
+		path, pattern string
+		want          bool
+	}{
+		{"/src/github.com/acme/repo", "github.com/acme/", true},
+		{"/src/github.com/acme/repo", "acme/repo", true},
+		{"/src/github.com/acme-corp/repo", "github.com/acme", false},
+		{"/src/github.com/acme/repo", "/", false},
+		{"/src/github.com/acme/repo", "", false},
+	} {
+		if got := matchesPattern(tc.path, tc.pattern); got != tc.want {
+			t.Errorf("matchesPattern(%q, %q) = %v, want %v", tc.path, tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestFindRuleForPathIgnoresSiblingPrefixes(t *testing.T) {
+	rules := &RulesConfig{}
+	rules.AddRule("github.com/acme", "a@example.com")
+	if rule := rules.FindRuleForPath("/src/github.com/acme-corp/repo"); rule != nil {
+		t.Errorf("expected no rule for a sibling org, got %+v", rule)
+	}
+	if rule := rules.FindRuleForPath("/src/github.com/acme/repo"); rule == nil {
+		t.Error("expected the rule to match its own org")
+	}
+}
+
+func TestSetDirIsolatesConfig(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "gitme")
+	SetDir(dir)
+	defer SetDir("")
+
+	rules := &RulesConfig{}
+	rules.AddRule("github.com/acme", "a@example.com")
+	if err := rules.Save(); err != nil {
+		t.Fatalf("failed to save rules: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "rules.json")); err != nil {
+		t.Fatalf("expected rules.json in overridden dir: %v", err)
+	}
+
+	loaded, err := LoadRules()
+	if err != nil {
+		t.Fatalf("failed to load rules: %v", err)
+	}
+	if len(loaded.Rules) != 1 || loaded.Rules[0].Ref() != "a@example.com" {
+		t.Fatalf("unexpected rules: %+v", loaded.Rules)
+	}
+}
+
+func TestSetDirMakesRelativeDirsAbsolute(t *testing.T) {
+	t.Chdir(t.TempDir())
+	SetDir("isolated")
+	defer SetDir("")
+	want, _ := filepath.Abs("isolated")
+
+	t.Chdir(t.TempDir())
+	if got := Dir(); got != want {
+		t.Errorf("Dir() = %q, want %q", got, want)
+	}
+}
+
+func TestDirOverridden(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GITME_CONFIG_DIR", "")
+	SetDir("")
+	defer SetDir("")
+	if DirOverridden() {
+		t.Fatalf("default dir %q reported as overridden", Dir())
+	}
+	SetDir(t.TempDir())
+	if !DirOverridden() {
+		t.Fatalf("dir %q set with SetDir not reported as overridden", Dir())
+	}
+}
+
+func TestRecordUsesKeepsChangesMadeMeanwhile(t *testing.T) {
+	SetDir(t.TempDir())
+	defer SetDir("")
+	if err := (&Config{Identities: []identity.Identity{{Email: "me@example.com"}}}).Save(); err != nil {
+		t.Fatal(err)
+	}
+	// Another command adds an identity while a caller holds an older config
+	cfg, _ := Load()
+	cfg.Identities = append(cfg.Identities, identity.Identity{Email: "new@example.com"})
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	used := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := RecordUses(map[string]time.Time{"Me@Example.com": used}); err != nil {
+		t.Fatalf("RecordUses failed: %v", err)
+	}
+	cfg, _ = Load()
+	if cfg.IdentityByRef("new@example.com") == nil {
+		t.Fatalf("identities = %+v, want the added one kept", cfg.Identities)
+	}
+	if got := cfg.IdentityByRef("me@example.com").LastUsed; !got.Equal(used) {
+		t.Fatalf("LastUsed = %v, want %v", got, used)
+	}
+}
+
+func TestMarkUsedAtOnlyMovesForward(t *testing.T) {
+	cfg := &Config{Identities: []identity.Identity{{Email: "me@example.com"}}}
+	recent := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	cfg.MarkUsedAt("Me@Example.com", recent)
+	cfg.MarkUsedAt("me@example.com", recent.AddDate(-1, 0, 0))
+
+	if got := cfg.Identities[0].LastUsed; !got.Equal(recent) {
+		t.Fatalf("expected last use %v, got %v", recent, got)
+	}
+}
+
+func TestDismissedCandidatesAreNotRequeued(t *testing.T) {
+	queue := &CandidatesConfig{}
+	cands := []identity.Candidate{
+		{Identity: identity.Identity{Name: "Me", Email: "Me@old.com"}, Commits: 4},
+		{Identity: identity.Identity{Name: "Me", Email: "me@laptop.local"}, Commits: 3},
+	}
+	if added := queue.Queue(cands); added != 2 {
+		t.Fatalf("Queue added %d, want 2", added)
+	}
+	if !queue.Dismiss("me@old.com") {
+		t.Fatalf("Dismiss did not find the pending candidate")
+	}
+
+	cands[1].Commits = 9
+	if added := queue.Queue(cands); added != 0 {
+		t.Fatalf("requeue added %d, want 0", added)
+	}
+	if len(queue.Pending) != 1 || queue.Pending[0].Commits != 9 {
+		t.Fatalf("pending = %+v, want only the refreshed laptop candidate", queue.Pending)
+	}
+}
+
+func TestMigrateRulesPointsEmailsAtIdentityIDs(t *testing.T) {
+	rules := &RulesConfig{Rules: []Rule{
+		{Pattern: "~/work", Email: "Me@Corp.com"},
+		{Pattern: "~/old", Email: "old@corp.com"},
+		{Pattern: "~/gone", Email: "gone@example.com"},
+		{Pattern: "~/oss", Identity: "home"},
+	}}
+	ids := []identity.Identity{{ID: "a1", Email: "me@corp.com", AltEmails: []string{"old@corp.com"}}}
+
+	if !rules.Migrate(ids) {
+		t.Fatalf("expected rules to be migrated")
+	}
+	var got []string
+	for _, rule := range rules.Rules {
+		got = append(got, rule.Ref())
+	}
+	if want := []string{"a1", "a1", "gone@example.com", "home"}; !slices.Equal(got, want) {
+		t.Fatalf("rule refs = %v, want %v", got, want)
+	}
+	if rules.Migrate(ids) {
+		t.Fatalf("expected nothing left to migrate")
+	}
+}
+
+func TestLoadMovesMappingsToIdentityIDs(t *testing.T) {
+	dir := t.TempDir()
+	SetDir(dir)
+	defer SetDir("")
+
+	legacy := `{
+  "folder_identities": {"/src/api": {"name": "Work", "email": "me@corp.com"}},
+  "identities": [{"name": "Work", "email": "me@corp.com"}],
+  "branch_identities": {"/src/api": {"release/*": "Me@Corp.com"}}
+}`
+	if err := os.WriteFile(filepath.Join(dir, "identities.json"), []byte(legacy), 0644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	id := cfg.Identities[0].ID
+	if id == "" || cfg.Folders["/src/api"] != id || cfg.BranchIdentities["/src/api"]["release/*"] != id || cfg.LegacyFolders != nil {
+		t.Fatalf("mappings not moved to ID %q: %+v", id, cfg)
+	}
+
+	cfg.Identities[0].Email = "me@newcorp.com"
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("saving config: %v", err)
+	}
+	cfg, _ = Load()
+	if got, ok := cfg.GetIdentityForFolder("/src/api"); !ok || got.Email != "me@newcorp.com" || got.ID != id {
+		t.Fatalf("mapping after email change = %+v, %v", got, ok)
+	}
+}
+
+func TestParseTeamReadsJSONAndMailmapLines(t *testing.T) {
+	want := []Coauthor{{Name: "Alice Doe", Email: "alice@corp.com"}, {Name: "Bob", Email: "bob@corp.com"}}
+	for _, data := range []string{
+		`[{"name": "Alice Doe", "email": "alice@corp.com"}, {"name": "Bob", "email": "bob@corp.com"}]`,
+		`{"members": [{"name": "Alice Doe", "email": "alice@corp.com"}, {"name": "Bob", "email": "bob@corp.com"}, {"name": "No email"}]}`,
+		"# the team\nAlice Doe <alice@corp.com>\n\nBob <bob@corp.com> # on leave\nnot a member\n",
+	} {
+		got, err := ParseTeam([]byte(data))
+		if err != nil {
+			t.Fatalf("ParseTeam(%q) failed: %v", data, err)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("ParseTeam(%q) = %v, want %v", data, got, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DigestState is what the watcher has collected for the weekly digest
+type DigestState struct {
+	Start      time.Time         `json:"start"`                // Monday the collected week began
+	Mismatches map[string]string `json:"mismatches,omitempty"` // repo → "current → expected" seen that week
+}
+
+func digestPath() string {
+	return filepath.Join(Dir(), "digest.json")
+}
+
+// LoadDigest reads the weekly digest state from disk
+func LoadDigest() (*DigestState, error) {
+	d := &DigestState{Mismatches: make(map[string]string)}
+
+	data, err := os.ReadFile(digestPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return d, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, err
+	}
+	if d.Mismatches == nil {
+		d.Mismatches = make(map[string]string)
+	}
+
+	return d, nil
+}
+
+// Save writes the weekly digest state to disk
+func (d *DigestState) Save() error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(digestPath(), data)
+}
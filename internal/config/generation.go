@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func generationPath() string {
+	return filepath.Join(Dir(), "generation")
+}
+
+// Generation returns the switch generation, a counter bumped whenever gitme
+// changes the identity of a repo. Shell prompts caching the identity in
+// effect compare it with the one they cached to know when to look again.
+// It is 0 until the first switch.
+func Generation() int {
+	data, _ := os.ReadFile(generationPath())
+	n, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return n
+}
+
+// BumpGeneration increments the switch generation. The file is replaced
+// atomically, so readers never see it half written, and bumps hold a lock on
+// a file beside it, so processes bumping at once do not lose any.
+func BumpGeneration() error {
+	if readOnly != nil {
+		readOnly("write " + generationPath())
+		return nil
+	}
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return err
+	}
+	// Not the counter itself: replacing it would leave the lock behind
+	lock, err := os.OpenFile(generationPath()+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return err
+	}
+	return writeFile(generationPath(), []byte(strconv.Itoa(Generation()+1)+"\n"))
+}
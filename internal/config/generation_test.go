@@ -0,0 +1,53 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestGenerationCountsBumps(t *testing.T) {
+	SetDir(t.TempDir())
+	defer SetDir("")
+
+	if got := Generation(); got != 0 {
+		t.Fatalf("Generation() = %d before any bump, want 0", got)
+	}
+	for want := 1; want <= 2; want++ {
+		if err := BumpGeneration(); err != nil {
+			t.Fatalf("BumpGeneration failed: %v", err)
+		}
+		if got := Generation(); got != want {
+			t.Fatalf("Generation() = %d, want %d", got, want)
+		}
+	}
+
+	if err := os.Remove(generationPath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(generationPath(), "blocked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := BumpGeneration(); err == nil {
+		t.Fatal("expected a bump over a directory to fail")
+	}
+}
+
+func TestBumpGenerationLosesNoConcurrentBumps(t *testing.T) {
+	SetDir(t.TempDir())
+	defer SetDir("")
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			if err := BumpGeneration(); err != nil {
+				t.Errorf("BumpGeneration failed: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if got := Generation(); got != 20 {
+		t.Fatalf("Generation() = %d, want 20", got)
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+)
+
+// IndexedRepo is what the repo index knows about one repository
+type IndexedRepo struct {
+	Path     string            `json:"path"`
+	Remotes  map[string]string `json:"remotes,omitempty"` // name -> url
+	Platform identity.Platform `json:"platform,omitempty"`
+	Name     string            `json:"name,omitempty"`  // user.name in effect
+	Email    string            `json:"email,omitempty"` // user.email in effect
+	Scanned  time.Time         `json:"scanned"`
+	// Modified is the modification time of the repo's git config when it was
+	// read; the entry is read again once that changes
+	Modified time.Time `json:"modified,omitzero"`
+}
+
+// IndexedDir is a directory the repo index walked, as it was then
+type IndexedDir struct {
+	Modified time.Time `json:"modified"`
+	Depth    int       `json:"depth"` // levels below it that were walked
+}
+
+// RepoIndex lists the known repositories so commands need not each walk the
+// workspace directories
+type RepoIndex struct {
+	Updated time.Time          `json:"updated"` // last full walk
+	Repos   []IndexedRepo      `json:"repos"`
+	Skipped []repowalk.Skipped `json:"skipped,omitempty"` // paths unreadable in that walk
+	// ThirdParty are repos left out as clearly not the user's
+	ThirdParty []string `json:"third_party,omitempty"`
+	// Dirs are the directories walked, so a refresh lists only those whose
+	// contents changed since
+	Dirs map[string]IndexedDir `json:"dirs,omitempty"`
+}
+
+func repoIndexPath() string {
+	return filepath.Join(Dir(), "repos.json")
+}
+
+// LoadRepoIndex reads the repo index from disk
+func LoadRepoIndex() (*RepoIndex, error) {
+	idx := &RepoIndex{Repos: []IndexedRepo{}}
+
+	data, err := os.ReadFile(repoIndexPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return idx, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// Save writes the repo index to disk
+func (idx *RepoIndex) Save() error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(repoIndexPath(), data)
+}
+
+// Put adds repo to the index or replaces the entry with the same path
+func (idx *RepoIndex) Put(repo IndexedRepo) {
+	for i := range idx.Repos {
+		if idx.Repos[i].Path == repo.Path {
+			idx.Repos[i] = repo
+			return
+		}
+	}
+	idx.Repos = append(idx.Repos, repo)
+}
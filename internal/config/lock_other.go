@@ -0,0 +1,11 @@
+//go:build !unix
+
+package config
+
+import "os"
+
+// lockFile does not lock where flock is missing: concurrent generation
+// bumps may be lost there
+func lockFile(f *os.File) error {
+	return nil
+}
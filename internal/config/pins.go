@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// PinsConfig holds pinned repository paths
+type PinsConfig struct {
+	Pins []string `json:"pins"`
+}
+
+func pinsPath() string {
+	return filepath.Join(Dir(), "pins.json")
+}
+
+// LoadPins reads the pinned repos from disk
+func LoadPins() (*PinsConfig, error) {
+	cfg := &PinsConfig{Pins: []string{}}
+
+	data, err := os.ReadFile(pinsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Save writes the pinned repos to disk
+func (p *PinsConfig) Save() error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(pinsPath(), data)
+}
+
+// Pin adds a repo path, returns false if already pinned
+func (p *PinsConfig) Pin(path string) bool {
+	if p.IsPinned(path) {
+		return false
+	}
+	p.Pins = append(p.Pins, path)
+	return true
+}
+
+// Unpin removes a repo path, returns false if not pinned
+func (p *PinsConfig) Unpin(path string) bool {
+	for i, pin := range p.Pins {
+		if pin == path {
+			p.Pins = append(p.Pins[:i], p.Pins[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IsPinned reports whether a repo path is pinned
+func (p *PinsConfig) IsPinned(path string) bool {
+	for _, pin := range p.Pins {
+		if pin == path {
+			return true
+		}
+	}
+	return false
+}
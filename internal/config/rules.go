@@ -0,0 +1,165 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+// Rule maps a path pattern to an identity
+type Rule struct {
+	Pattern  string `json:"pattern"`            // e.g., "github.com/vosamoilenko" or "~/work"
+	Identity string `json:"identity,omitempty"` // alias or identity ID
+	Email    string `json:"email,omitempty"`    // rules written before Identity
+	// Strict repos under the pattern must not fall back to the global
+	// identity when nothing says which one they use; a strict rule may name
+	// no identity and only flag the tree
+	Strict bool `json:"strict,omitempty"`
+}
+
+// Ref returns the alias, ID or, for old rules, the email the rule points at
+func (r Rule) Ref() string {
+	if r.Identity != "" {
+		return r.Identity
+	}
+	return r.Email
+}
+
+// RulesConfig holds auto-switch rules
+type RulesConfig struct {
+	Rules []Rule `json:"rules"`
+}
+
+func rulesPath() string {
+	return filepath.Join(Dir(), "rules.json")
+}
+
+// LoadRules reads the rules config from disk
+func LoadRules() (*RulesConfig, error) {
+	cfg := &RulesConfig{Rules: []Rule{}}
+
+	data, err := os.ReadFile(rulesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Save writes the rules config to disk
+func (r *RulesConfig) Save() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(rulesPath(), data)
+}
+
+// AddRule adds a new rule or updates existing one; ref is an alias or
+// identity ID
+func (r *RulesConfig) AddRule(pattern, ref string) {
+	for i, rule := range r.Rules {
+		if rule.Pattern == pattern {
+			r.Rules[i].Identity, r.Rules[i].Email = ref, ""
+			return
+		}
+	}
+	r.Rules = append(r.Rules, Rule{Pattern: pattern, Identity: ref})
+}
+
+// Migrate points rules that name an email at the ID of the identity with
+// that address, its own or one merged into it. It reports whether any rule
+// changed; rules naming unknown emails are left as they are.
+func (r *RulesConfig) Migrate(identities []identity.Identity) bool {
+	changed := false
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.Identity != "" || rule.Email == "" {
+			continue
+		}
+		for _, id := range identities {
+			owns := func(e string) bool { return strings.EqualFold(e, rule.Email) }
+			if id.ID != "" && (owns(id.Email) || slices.ContainsFunc(id.AltEmails, owns)) {
+				rule.Identity, rule.Email = id.ID, ""
+				changed = true
+				break
+			}
+		}
+	}
+	return changed
+}
+
+// SetStrict flags the tree of pattern as strict or not, adding a rule
+// without an identity when there is none for it
+func (r *RulesConfig) SetStrict(pattern string, strict bool) {
+	for i, rule := range r.Rules {
+		if rule.Pattern == pattern {
+			r.Rules[i].Strict = strict
+			return
+		}
+	}
+	if strict {
+		r.Rules = append(r.Rules, Rule{Pattern: pattern, Strict: true})
+	}
+}
+
+// RemoveRule removes a rule by pattern
+func (r *RulesConfig) RemoveRule(pattern string) bool {
+	for i, rule := range r.Rules {
+		if rule.Pattern == pattern {
+			r.Rules = append(r.Rules[:i], r.Rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// FindRuleForPath finds the best matching rule naming an identity for a path
+func (r *RulesConfig) FindRuleForPath(path string) *Rule {
+	return r.findRule(path, func(rule Rule) bool { return rule.Ref() != "" })
+}
+
+// StrictRuleForPath finds the best matching strict rule for a path
+func (r *RulesConfig) StrictRuleForPath(path string) *Rule {
+	return r.findRule(path, func(rule Rule) bool { return rule.Strict })
+}
+
+// findRule returns the rule with the longest pattern matching path among
+// those keep accepts
+func (r *RulesConfig) findRule(path string, keep func(Rule) bool) *Rule {
+	var bestMatch *Rule
+	bestLen := 0
+	for i, rule := range r.Rules {
+		if keep(rule) && matchesPattern(path, rule.Pattern) && len(rule.Pattern) > bestLen {
+			bestMatch = &r.Rules[i]
+			bestLen = len(rule.Pattern)
+		}
+	}
+	return bestMatch
+}
+
+// matchesPattern checks if path contains the pattern on path-segment boundaries
+func matchesPattern(path, pattern string) bool {
+	// Expand ~ in pattern
+	if len(pattern) > 0 && pattern[0] == '~' {
+		home, _ := os.UserHomeDir()
+		pattern = home + pattern[1:]
+	}
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+	// Patterns like "github.com/user" or "/full/path" must match whole segments
+	return strings.Contains("/"+strings.Trim(path, "/")+"/", "/"+pattern+"/")
+}
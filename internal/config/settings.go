@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// DefaultProtectedBranches are refused by fix:rewrite unless overridden
+var DefaultProtectedBranches = []string{"main", "master", "release/*"}
+
+// DefaultBackupLimitMB caps the backups fix:rewrite keeps unless overridden
+const DefaultBackupLimitMB = 1024
+
+// Settings holds user preferences
+type Settings struct {
+	AutoApply         bool     `json:"auto_apply"`                  // false = warn, true = auto-set identity
+	ProtectedBranches []string `json:"protected_branches"`          // glob patterns, nil = defaults, empty = none
+	Timezone          string   `json:"timezone,omitempty"`          // zone stats bucket commits in, "" = each commit's own
+	DisabledScanners  []string `json:"disabled_scanners,omitempty"` // identity sources scan skips
+	Icons             string   `json:"icons,omitempty"`             // platform icon set, "" = text
+	Forgotten         []string `json:"forgotten,omitempty"`         // paths and globs scan and the repo index ignore
+	ReferenceDirs     []string `json:"reference_dirs,omitempty"`    // trees of third-party clones
+	Ignored           []string `json:"ignored,omitempty"`           // lowercased emails scans leave out
+	ReadOnly          bool     `json:"read_only,omitempty"`         // describe changes instead of making them
+	BackupLimitMB     int      `json:"backup_limit_mb,omitempty"`   // MB of backups kept, 0 = default, -1 = no backups
+	TeamDirectory     string   `json:"team_directory,omitempty"`    // file or https URL listing collaborators
+	Strict            bool     `json:"strict,omitempty"`            // repos in the workspace dirs need a matching identity
+	ScanExclude       []string `json:"scan_exclude,omitempty"`      // globs of directories repo walks never enter
+}
+
+// BackupsDir is where fix:rewrite keeps bundles of repos it rewrites
+func BackupsDir() string {
+	return filepath.Join(Dir(), "backups")
+}
+
+func settingsPath() string {
+	return filepath.Join(Dir(), "settings.json")
+}
+
+// LoadSettings reads the settings from disk
+func LoadSettings() (*Settings, error) {
+	s := &Settings{AutoApply: false}
+
+	data, err := os.ReadFile(settingsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ProtectedBranchPatterns returns the configured protected branch patterns
+func (s *Settings) ProtectedBranchPatterns() []string {
+	if s.ProtectedBranches == nil {
+		return DefaultProtectedBranches
+	}
+	return s.ProtectedBranches
+}
+
+// BackupLimit returns how many bytes of backups to keep, 0 when backups
+// are off
+func (s *Settings) BackupLimit() int64 {
+	switch {
+	case s.BackupLimitMB < 0:
+		return 0
+	case s.BackupLimitMB == 0:
+		return DefaultBackupLimitMB << 20
+	}
+	return int64(s.BackupLimitMB) << 20
+}
+
+// Save writes the settings to disk
+func (s *Settings) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(settingsPath(), data)
+}
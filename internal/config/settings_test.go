@@ -0,0 +1,31 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestProtectedBranchesSurviveSaveAndLoad(t *testing.T) {
+	SetDir(t.TempDir())
+	defer SetDir("")
+
+	for _, tc := range []struct {
+		saved []string
+		want  []string
+	}{
+		{nil, DefaultProtectedBranches},
+		{[]string{}, []string{}},
+		{[]string{"trunk"}, []string{"trunk"}},
+	} {
+		if err := (&Settings{ProtectedBranches: tc.saved}).Save(); err != nil {
+			t.Fatalf("saving settings: %v", err)
+		}
+		s, err := LoadSettings()
+		if err != nil {
+			t.Fatalf("loading settings: %v", err)
+		}
+		if got := s.ProtectedBranchPatterns(); !slices.Equal(got, tc.want) {
+			t.Errorf("saved %#v, loaded patterns %#v, want %#v", tc.saved, got, tc.want)
+		}
+	}
+}
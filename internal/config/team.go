@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TeamRefresh is how long a team directory fetched from a URL is used
+// before gitme fetches it again
+const TeamRefresh = 24 * time.Hour
+
+// TeamCache is the team directory last fetched from a URL
+type TeamCache struct {
+	Source  string     `json:"source"`
+	Fetched time.Time  `json:"fetched"`
+	Members []Coauthor `json:"members"`
+}
+
+func teamCachePath() string {
+	return filepath.Join(Dir(), "team.json")
+}
+
+// LoadTeamCache reads the fetched team directory from disk; it is empty when
+// none was fetched yet
+func LoadTeamCache() (*TeamCache, error) {
+	cache := &TeamCache{}
+	data, err := os.ReadFile(teamCachePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Save writes the fetched team directory to disk
+func (c *TeamCache) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(teamCachePath(), data)
+}
+
+// ParseTeam reads a team directory: a JSON list of {"name", "email"}
+// objects, the same under "members", or lines of Name <email> as in a
+// .mailmap, where # starts a comment
+func ParseTeam(data []byte) ([]Coauthor, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		var members []Coauthor
+		if strings.HasPrefix(trimmed, "{") {
+			var doc struct {
+				Members []Coauthor `json:"members"`
+			}
+			if err := json.Unmarshal(data, &doc); err != nil {
+				return nil, err
+			}
+			members = doc.Members
+		} else if err := json.Unmarshal(data, &members); err != nil {
+			return nil, err
+		}
+		return slices.DeleteFunc(members, func(m Coauthor) bool { return !strings.Contains(m.Email, "@") }), nil
+	}
+
+	var members []Coauthor
+	for _, line := range strings.Split(trimmed, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		name, rest, ok := strings.Cut(line, "<")
+		email, _, closed := strings.Cut(rest, ">")
+		if !ok || !closed || !strings.Contains(email, "@") {
+			continue
+		}
+		members = append(members, Coauthor{Name: strings.TrimSpace(name), Email: strings.TrimSpace(email)})
+	}
+	return members, nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WatchMetrics is what the running watcher has done since it started
+type WatchMetrics struct {
+	PID        int       `json:"pid"`
+	Started    time.Time `json:"started"`
+	Interval   string    `json:"interval"`
+	LastScan   time.Time `json:"last_scan,omitzero"`
+	Repos      int       `json:"repos_indexed"` // repos checked in the last pass
+	Scans      int       `json:"scans"`         // passes over all repos
+	AutoFixed  int       `json:"auto_fixed"`    // mismatches switched to the expected identity
+	Mismatches int       `json:"mismatches"`    // repos left on the wrong identity in the last pass
+	Errors     int       `json:"errors"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+func watchMetricsPath() string {
+	return filepath.Join(Dir(), "watch-metrics.json")
+}
+
+// LoadWatchMetrics reads the metrics of the last watcher, nil if none ran
+func LoadWatchMetrics() (*WatchMetrics, error) {
+	data, err := os.ReadFile(watchMetricsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	m := &WatchMetrics{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Save writes the watcher's metrics to disk
+func (m *WatchMetrics) Save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(watchMetricsPath(), data)
+}
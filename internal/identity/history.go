@@ -0,0 +1,97 @@
+package identity
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+)
+
+// SourceHistory marks identities accepted from commit history
+const SourceHistory = "history"
+
+// Candidate is an author seen in commit history but not configured anywhere
+type Candidate struct {
+	Identity
+	Commits int      `json:"commits"`
+	Repos   []string `json:"repos"`
+	Like    string   `json:"like,omitempty"`   // email of the known identity it resembles
+	Reason  string   `json:"reason,omitempty"` // why it resembles it, see Resemble
+}
+
+// HistoryCandidates samples the last perRepo commits of each repo and returns
+// authors that look like the user, as Resemble tells, but are not among
+// known. Bots and platform web-flow
+// addresses are ignored, and authors are read through each repo's .mailmap.
+// env is the environment git runs with. Repos git stalled on are returned as
+// skipped.
+func HistoryCandidates(repos []string, known []Identity, perRepo, minCommits int, env []string) ([]Candidate, []repowalk.Skipped) {
+	byEmail := make(map[string]*Candidate)
+	strangers := make(map[string]bool) // authors that resemble no one
+	var skipped []repowalk.Skipped
+	type result struct {
+		out []byte
+		err error
+	}
+	logs := repowalk.Collect(repos, func(repo string) result {
+		out, err := repowalk.Git(repo, env, "log", "-n", strconv.Itoa(perRepo), "--format=%aN%x00%aE")
+		return result{out, err}
+	})
+	for i, repo := range repos {
+		out, err := logs[i].out, logs[i].err
+		if s, ok := repowalk.Failed(repo, err); ok {
+			skipped = append(skipped, s)
+		}
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			name, email, ok := strings.Cut(line, "\x00")
+			lower := strings.ToLower(strings.TrimSpace(email))
+			if !ok || lower == "" || IsAutomated(name, lower) {
+				continue
+			}
+			c, ok := byEmail[lower]
+			if !ok {
+				if strangers[lower] {
+					continue
+				}
+				like, ok := Resemble(name, email, known)
+				if !ok {
+					strangers[lower] = true
+					continue
+				}
+				c = &Candidate{Identity: Identity{Name: name, Email: email, Source: SourceHistory}, Like: like.Identity.Email, Reason: like.Reason}
+				c.Platform = DetectPlatform(email)
+				byEmail[lower] = c
+			}
+			c.Commits++
+			if len(c.Repos) == 0 || c.Repos[len(c.Repos)-1] != repo {
+				c.Repos = append(c.Repos, repo)
+			}
+		}
+	}
+
+	var candidates []Candidate
+	for _, c := range byEmail {
+		if c.Commits >= minCommits {
+			c.Sources = append([]string(nil), c.Repos...)
+			candidates = append(candidates, *c)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Commits != candidates[j].Commits {
+			return candidates[i].Commits > candidates[j].Commits
+		}
+		return candidates[i].Email < candidates[j].Email
+	})
+	return candidates, skipped
+}
+
+// IsAutomated reports whether an author is a bot or a platform address
+// rather than a person
+func IsAutomated(name, email string) bool {
+	return strings.Contains(name, "[bot]") || strings.Contains(email, "[bot]") ||
+		email == "noreply@github.com" || strings.HasPrefix(email, "noreply@")
+}
@@ -0,0 +1,66 @@
+package identity
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func commitAs(t *testing.T, repo, name, email string) {
+	t.Helper()
+	cmd := exec.Command("git", "-C", repo, "commit", "--allow-empty", "-q", "-m", "c")
+	cmd.Env = append(cmd.Environ(),
+		"GIT_AUTHOR_NAME="+name, "GIT_AUTHOR_EMAIL="+email,
+		"GIT_COMMITTER_NAME="+name, "GIT_COMMITTER_EMAIL="+email)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("commit failed: %v (%s)", err, out)
+	}
+}
+
+func TestHistoryCandidatesProposeOnlyTheUser(t *testing.T) {
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v (%s)", err, out)
+	}
+	for i := 0; i < 3; i++ {
+		commitAs(t, repo, "Jane Doe", "jane@oldcorp.com")
+		commitAs(t, repo, "Coworker", "coworker@corp.com")
+		commitAs(t, repo, "dependabot[bot]", "49699333+dependabot[bot]@users.noreply.github.com")
+		commitAs(t, repo, "Jane Doe", "jane@example.com")
+	}
+	commitAs(t, repo, "J. Doe", "jdoe@corp.com") // same user part, too rare
+
+	known := []Identity{{Name: "Jane Doe", Email: "jane@example.com", Username: "jdoe"}}
+	candidates, _ := HistoryCandidates([]string{repo}, known, 100, 2, nil)
+
+	if len(candidates) != 1 {
+		t.Fatalf("expected one candidate, got %+v", candidates)
+	}
+	c := candidates[0]
+	if c.Email != "jane@oldcorp.com" || c.Commits != 3 || c.Source != SourceHistory || len(c.Repos) != 1 {
+		t.Fatalf("unexpected candidate %+v", c)
+	}
+}
+
+func TestResembleRanksNamesOverEmailUsers(t *testing.T) {
+	known := []Identity{
+		{Name: "Jane Doe", Email: "jane@example.com", Username: "jdoe"},
+		{Name: "Work", Email: "doe@corp.com"},
+	}
+	for _, tc := range []struct{ name, email, like, reason string }{
+		{"Doe, Jane", "doe@old.com", "jane@example.com", ReasonSameName},
+		{"J", "jane@old.com", "jane@example.com", ReasonSameUser},
+		{"J", "1234+jdoe@users.noreply.github.com", "jane@example.com", ReasonSameUsername},
+		{"JD", "jane.doe@old.com", "jane@example.com", ReasonNameInEmail},
+		{"X", "doe@other.com", "doe@corp.com", ReasonSameUser},
+	} {
+		r, ok := Resemble(tc.name, tc.email, known)
+		if !ok || r.Identity.Email != tc.like || r.Reason != tc.reason {
+			t.Fatalf("Resemble(%q, %q) = %+v, %v; want %s (%s)", tc.name, tc.email, r, ok, tc.like, tc.reason)
+		}
+	}
+	for _, email := range []string{"jane@example.com", "bob@corp.com"} {
+		if r, ok := Resemble("Bob", email, known); ok {
+			t.Fatalf("expected %s to resemble no one, got %+v", email, r)
+		}
+	}
+}
@@ -2,10 +2,17 @@ package identity
 
 import (
 	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"hash/fnv"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/repowalk"
 )
 
 // Platform represents the git hosting platform
@@ -18,13 +25,70 @@ const (
 	PlatformBitbucket Platform = "bitbucket"
 )
 
+// Icon sets platforms are marked with, chosen with the icons setting
+const (
+	IconsText  = "text"  // [GitHub]
+	IconsEmoji = "emoji" // 🐙
+	IconsNerd  = "nerd"  // Nerd Font glyphs
+	IconsNone  = "none"  // no marker
+)
+
+// IconSets lists the icon set names in the order they are documented
+var IconSets = []string{IconsText, IconsEmoji, IconsNerd, IconsNone}
+
+var platformIcons = map[string]map[Platform]string{
+	IconsText:  {PlatformGitHub: "[GitHub]", PlatformGitLab: "[GitLab]", PlatformBitbucket: "[Bitbucket]"},
+	IconsEmoji: {PlatformGitHub: "🐙", PlatformGitLab: "🦊", PlatformBitbucket: "🪣"},
+	IconsNerd:  {PlatformGitHub: "\uf09b", PlatformGitLab: "\uf296", PlatformBitbucket: "\uf171"},
+}
+
+// Icon returns the marker of the platform in an icon set, "" for unknown
+// platforms; an unknown or empty set means text
+func (p Platform) Icon(set string) string {
+	if set == IconsNone {
+		return ""
+	}
+	icons, ok := platformIcons[set]
+	if !ok {
+		icons = platformIcons[IconsText]
+	}
+	return icons[p]
+}
+
 // Identity represents a git identity
 type Identity struct {
-	Name     string   `json:"name"`
-	Email    string   `json:"email"`
-	Source   string   `json:"source"`   // primary source (for backward compat)
-	Sources  []string `json:"sources"`  // ALL places where this identity was found
-	Platform Platform `json:"platform"` // github, gitlab, etc.
+	// ID is a stable reference to the identity, kept when its email changes;
+	// rules point at identities by ID
+	ID       string    `json:"id,omitempty"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Source   string    `json:"source"`             // primary source (for backward compat)
+	Sources  []string  `json:"sources"`            // ALL places where this identity was found
+	Platform Platform  `json:"platform"`           // github, gitlab, etc.
+	Username string    `json:"username,omitempty"` // platform handle, e.g. GitHub login
+	LastUsed time.Time `json:"last_used,omitzero"` // when gitme last applied this identity
+	Protocol string    `json:"protocol,omitempty"` // preferred remote protocol: ssh or https
+	SSHHost  string    `json:"ssh_host,omitempty"` // ~/.ssh/config alias for ssh remotes, e.g. github-work
+	// SSHKey is the private key git uses for ssh remotes of repos switched to
+	// this identity, set as core.sshCommand
+	SSHKey string `json:"ssh_key,omitempty"`
+	// SigningKey is the key commits in repos switched to this identity are
+	// signed with: a gpg key ID, or the path of an ssh public key
+	SigningKey    string `json:"signing_key,omitempty"`
+	SigningFormat string `json:"signing_format,omitempty"` // gpg or ssh
+	// AltEmails are other addresses of this identity merged in on review,
+	// e.g. an old work email found in commit history
+	AltEmails []string `json:"alt_emails,omitempty"`
+	// Color is the color the user chose for this identity, an ANSI 256 code
+	// or #rrggbb; empty picks one from Palette
+	Color string `json:"color,omitempty"`
+	// Config is extra git config switching a repo to this identity sets, by
+	// key, e.g. credential.helper or url.<base>.insteadOf
+	Config map[string]string `json:"config,omitempty"`
+	// InsteadOf maps URL prefixes to what switching a repo to this identity
+	// rewrites them to with url.<base>.insteadOf, e.g. https://gitlab.company.com/
+	// to git@work-gitlab:
+	InsteadOf map[string]string `json:"instead_of,omitempty"`
 }
 
 // sshHostPlatforms maps SSH host aliases to their platform
@@ -36,6 +100,94 @@ func (i Identity) String() string {
 	return i.Name + " <" + i.Email + ">"
 }
 
+// Palette is the ANSI 256 colors identities without a chosen color are
+// shown in; all of them read on dark and light terminals
+var Palette = []string{"39", "170", "42", "214", "99", "203", "37", "178"}
+
+// DisplayColor returns the color the user chose for the identity, or one
+// picked from Palette by its email so it stays the same across runs
+func (i Identity) DisplayColor() string {
+	if i.Color != "" {
+		return i.Color
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(i.Email)))
+	return Palette[h.Sum32()%uint32(len(Palette))]
+}
+
+var hexColorRe = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ValidColor reports whether color is an ANSI 256 code or a #rgb/#rrggbb hex
+func ValidColor(color string) bool {
+	if hexColorRe.MatchString(color) {
+		return true
+	}
+	n, err := strconv.Atoi(color)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// NewID returns a random identity ID
+func NewID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// MergeUserFields copies fields the user manages (as opposed to ones
+// discovered by scanning) from a previously stored copy of this identity
+func (i *Identity) MergeUserFields(prev Identity) {
+	if i.ID == "" {
+		i.ID = prev.ID
+	}
+	if i.Username == "" {
+		i.Username = prev.Username
+	}
+	if i.Protocol == "" {
+		i.Protocol, i.SSHHost = prev.Protocol, prev.SSHHost
+	}
+	if i.SSHKey == "" {
+		i.SSHKey = prev.SSHKey
+	}
+	if i.SigningKey == "" {
+		i.SigningKey, i.SigningFormat = prev.SigningKey, prev.SigningFormat
+	}
+	if len(i.AltEmails) == 0 {
+		i.AltEmails = prev.AltEmails
+	}
+	if i.Color == "" {
+		i.Color = prev.Color
+	}
+	if prev.LastUsed.After(i.LastUsed) {
+		i.LastUsed = prev.LastUsed
+	}
+}
+
+// NoreplyEmail returns the platform's private commit email for this identity,
+// or "" if the platform or username is unknown
+func (i Identity) NoreplyEmail() string {
+	if i.Username == "" {
+		return ""
+	}
+	switch i.Platform {
+	case PlatformGitHub:
+		return i.Username + "@users.noreply.github.com"
+	case PlatformGitLab:
+		return i.Username + "@users.noreply.gitlab.com"
+	}
+	return ""
+}
+
+// emailPattern is what ValidEmail accepts: a local part and a domain
+// without spaces or angle brackets, the domain not starting or ending with a
+// dot
+var emailPattern = regexp.MustCompile(`^[^@\s<>]+@[^@\s<>.]([^@\s<>]*[^@\s<>.])?$`)
+
+// ValidEmail reports whether email looks like an address git can commit
+// with, e.g. me@example.com or root@localhost
+func ValidEmail(email string) bool {
+	return emailPattern.MatchString(email)
+}
+
 // DetectPlatform detects the platform from email
 func DetectPlatform(email string) Platform {
 	email = strings.ToLower(email)
@@ -126,6 +278,14 @@ func parseSSHConfig() map[string]Platform {
 	return hosts
 }
 
+// HostPlatform returns the platform of a remote host or ~/.ssh/config alias
+func HostPlatform(host string) Platform {
+	if p, ok := sshHostPlatforms[host]; ok {
+		return p
+	}
+	return detectPlatformFromHostInfo(host, host)
+}
+
 // detectPlatformFromHostInfo detects platform from host alias or hostname
 func detectPlatformFromHostInfo(host, hostName string) Platform {
 	combined := strings.ToLower(host + " " + hostName)
@@ -147,191 +307,36 @@ func detectPlatformFromHostInfo(host, hostName string) Platform {
 	return PlatformUnknown
 }
 
-// Scan finds all git identities on the machine
-func Scan() ([]Identity, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return nil, err
-	}
-
-	// Parse SSH config to detect platform hosts
-	sshHostPlatforms = parseSSHConfig()
-
-	// Map to collect all sources for each email
-	identityMap := make(map[string]*Identity)
-
-	// Helper to add or update identity
-	addIdentity := func(id *Identity) {
-		if id == nil || id.Email == "" {
-			return
-		}
-		if existing, ok := identityMap[id.Email]; ok {
-			// Add this source to existing identity
-			existing.Sources = append(existing.Sources, id.Source)
-			// Update platform if we found a better match
-			if existing.Platform == PlatformUnknown && id.Platform != PlatformUnknown {
-				existing.Platform = id.Platform
-			}
-		} else {
-			// New identity
-			id.Sources = []string{id.Source}
-			identityMap[id.Email] = id
-		}
-	}
-
-	workspaceDirs := []string{
-		filepath.Join(home, "Developer"),
-		filepath.Join(home, "Projects"),
-		filepath.Join(home, "Code"),
-		filepath.Join(home, "workspace"),
-		filepath.Join(home, "src"),
-		filepath.Join(home, "work"),
-	}
-
-	// First pass: scan all repos to detect platforms
-	emailPlatforms := make(map[string]Platform)
-	globalEmail := ""
-	globalConfig := filepath.Join(home, ".gitconfig")
-	if id, _ := parseGitConfig(globalConfig, globalConfig, ""); id != nil {
-		globalEmail = id.Email
-	}
-	for _, dir := range workspaceDirs {
-		if _, err := os.Stat(dir); err == nil {
-			scanRepoPlatforms(dir, 3, emailPlatforms, globalEmail)
-		}
-	}
-
-	// Parse ~/.gitconfig
-	if id, _ := parseGitConfig(globalConfig, globalConfig, ""); id != nil {
-		if id.Platform == PlatformUnknown {
-			if p, ok := emailPlatforms[id.Email]; ok {
-				id.Platform = p
-			}
-		}
-		addIdentity(id)
-	}
-
-	// Parse ~/.config/git/config
-	xdgConfig := filepath.Join(home, ".config", "git", "config")
-	if id, _ := parseGitConfig(xdgConfig, xdgConfig, ""); id != nil {
-		if id.Platform == PlatformUnknown {
-			if p, ok := emailPlatforms[id.Email]; ok {
-				id.Platform = p
-			}
-		}
-		addIdentity(id)
-	}
-
-	// Scan ALL repos for local identities (increased depth to 4)
-	for _, dir := range workspaceDirs {
-		if _, err := os.Stat(dir); err == nil {
-			scanAllRepos(dir, 4, identityMap, emailPlatforms)
-		}
-	}
-
-	// Convert map to slice
-	var identities []Identity
-	for _, id := range identityMap {
-		identities = append(identities, *id)
-	}
-
-	return identities, nil
-}
-
-// scanAllRepos scans all repos and collects identities with all their sources
-func scanAllRepos(dir string, maxDepth int, identityMap map[string]*Identity, emailPlatforms map[string]Platform) {
-	if maxDepth <= 0 {
-		return
-	}
-
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return
-	}
-
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-
-		subdir := filepath.Join(dir, entry.Name())
-		gitDir := filepath.Join(subdir, ".git")
-		gitConfig := filepath.Join(gitDir, "config")
-
-		if id, _ := parseGitConfig(gitConfig, gitConfig, gitDir); id != nil {
-			if id.Platform == PlatformUnknown {
-				if p, ok := emailPlatforms[id.Email]; ok {
-					id.Platform = p
-				}
-			}
-			// Add to map (will merge sources if email already exists)
-			if existing, ok := identityMap[id.Email]; ok {
-				existing.Sources = append(existing.Sources, id.Source)
-			} else {
-				id.Sources = []string{id.Source}
-				identityMap[id.Email] = id
-			}
-		}
-
-		// Recurse deeper
-		if maxDepth > 1 {
-			scanAllRepos(subdir, maxDepth-1, identityMap, emailPlatforms)
-		}
-	}
-}
-
-// scanRepoPlatforms scans repos to build email -> platform mapping
+// recordRepoPlatform adds the platform of the repo's remotes to emailPlatforms
 // globalEmail is used when a repo has no local email configured (inherits global)
-func scanRepoPlatforms(dir string, maxDepth int, emailPlatforms map[string]Platform, globalEmail string) {
-	if maxDepth <= 0 {
+func recordRepoPlatform(repo string, emailPlatforms map[string]Platform, globalEmail string) {
+	gitDir := repowalk.GitDir(repo)
+	platform, remoteHost := detectPlatformFromRemotesWithHost(gitDir)
+	if platform == PlatformUnknown {
 		return
 	}
-
-	entries, err := os.ReadDir(dir)
-	if err != nil {
+	// Get the email configured for this repo (local or inherited)
+	email := getRepoEmail(gitDir)
+	if email == "" {
+		// No local email - repo uses global email
+		email = globalEmail
+	}
+	if email == "" {
 		return
 	}
-
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-
-		subdir := filepath.Join(dir, entry.Name())
-		gitDir := filepath.Join(subdir, ".git")
-
-		if _, err := os.Stat(gitDir); err == nil {
-			// Found a git repo - detect its platform and remote host
-			platform, remoteHost := detectPlatformFromRemotesWithHost(gitDir)
-			if platform != PlatformUnknown {
-				// Get the email configured for this repo (local or inherited)
-				email := getRepoEmail(gitDir)
-				if email == "" {
-					// No local email - repo uses global email
-					email = globalEmail
-				}
-				if email != "" {
-					existingPlatform, exists := emailPlatforms[email]
-					// Prefer platform that matches email domain
-					// e.g., sclable.com email + git.sclable.com remote = strong match
-					emailDomain := getEmailDomain(email)
-					if !exists {
-						emailPlatforms[email] = platform
-					} else if remoteHost != "" && strings.Contains(remoteHost, emailDomain) {
-						// This remote matches the email domain - prefer it
-						emailPlatforms[email] = platform
-					} else if existingPlatform == PlatformGitHub && platform == PlatformGitLab {
-						// Prefer GitLab for non-gmail/non-github emails (likely corporate)
-						if !strings.Contains(email, "gmail") && !strings.Contains(email, "github") {
-							emailPlatforms[email] = platform
-						}
-					}
-				}
-			}
-		}
-
-		if maxDepth > 1 {
-			scanRepoPlatforms(subdir, maxDepth-1, emailPlatforms, globalEmail)
+	existingPlatform, exists := emailPlatforms[email]
+	// Prefer platform that matches email domain
+	// e.g., sclable.com email + git.sclable.com remote = strong match
+	emailDomain := getEmailDomain(email)
+	if !exists {
+		emailPlatforms[email] = platform
+	} else if remoteHost != "" && strings.Contains(remoteHost, emailDomain) {
+		// This remote matches the email domain - prefer it
+		emailPlatforms[email] = platform
+	} else if existingPlatform == PlatformGitHub && platform == PlatformGitLab {
+		// Prefer GitLab for non-gmail/non-github emails (likely corporate)
+		if !strings.Contains(email, "gmail") && !strings.Contains(email, "github") {
+			emailPlatforms[email] = platform
 		}
 	}
 }
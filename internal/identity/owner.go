@@ -0,0 +1,46 @@
+package identity
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+)
+
+// scanUser returns the home directory and uid of the user a scan collects
+// identities for: the one who ran sudo when gitme runs as root through it,
+// else the current user. The uid is -1 where files have no owner.
+var scanUser = func() (home string, uid int, err error) {
+	if os.Geteuid() == 0 {
+		if sudoUID := os.Getenv("SUDO_UID"); sudoUID != "" {
+			u, err := user.LookupId(sudoUID)
+			if err != nil {
+				return "", 0, fmt.Errorf("looking up the sudo user: %w", err)
+			}
+			uid, _ := strconv.Atoi(u.Uid)
+			return u.HomeDir, uid, nil
+		}
+	}
+	home, err = os.UserHomeDir()
+	return home, os.Geteuid(), err
+}
+
+// owned reports whether path belongs to the user the scan is for. Files of
+// other users, e.g. a coworker's config reached through an include or a
+// symlink on a shared host, are recorded as skipped instead of read.
+func (s *scanState) owned(path string) bool {
+	uid, ok := fileOwner(path)
+	if !ok || s.uid < 0 || uid == s.uid {
+		return true
+	}
+	if !slices.ContainsFunc(s.walker.Skipped, func(sk repowalk.Skipped) bool { return sk.Path == path }) {
+		s.walker.Skipped = append(s.walker.Skipped, repowalk.Skipped{
+			Path:   path,
+			Reason: fmt.Sprintf("owned by another user (uid %d)", uid),
+		})
+	}
+	return false
+}
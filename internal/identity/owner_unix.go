@@ -0,0 +1,21 @@
+//go:build unix
+
+package identity
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the uid owning path; ok is false when it cannot be read
+func fileOwner(path string) (uid int, ok bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(stat.Uid), true
+}
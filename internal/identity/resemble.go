@@ -0,0 +1,76 @@
+package identity
+
+import (
+	"slices"
+	"strings"
+)
+
+// Reasons an author resembles a known identity, strongest first
+const (
+	ReasonSameName     = "same name"
+	ReasonSameUser     = "same email user"
+	ReasonSameUsername = "same username"
+	ReasonNameInEmail  = "name spelled in the email"
+)
+
+// Resemblance is a known identity an author seen under another address
+// probably is, and why
+type Resemblance struct {
+	Identity Identity
+	Reason   string
+}
+
+// Resemble returns the known identity the author name <email> most likely is
+// with another address: one with the same name, the same email user part or
+// platform username, or whose name the email user spells, e.g.
+// jane.doe@old-corp.com for Jane Doe. Names match ignoring case, punctuation
+// and word order. It returns false for known addresses and when nothing is
+// alike.
+func Resemble(name, email string, known []Identity) (Resemblance, bool) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	user, _, _ := strings.Cut(email, "@")
+	if _, login, ok := strings.Cut(user, "+"); ok {
+		user = login // GitHub's 123+login noreply form
+	}
+	words := nameWords(name)
+	userWords := nameWords(user)
+
+	var best Resemblance
+	rank := len(reasonOrder)
+	for _, id := range known {
+		if strings.EqualFold(id.Email, email) || slices.ContainsFunc(id.AltEmails, func(alt string) bool { return strings.EqualFold(alt, email) }) {
+			return Resemblance{}, false
+		}
+		idUser, _, _ := strings.Cut(strings.ToLower(id.Email), "@")
+		idWords := nameWords(id.Name)
+		var reason string
+		switch {
+		case words != "" && words == idWords:
+			reason = ReasonSameName
+		case user != "" && user == idUser:
+			reason = ReasonSameUser
+		case user != "" && strings.EqualFold(user, id.Username):
+			reason = ReasonSameUsername
+		case strings.Contains(idWords, " ") && userWords == idWords:
+			reason = ReasonNameInEmail
+		default:
+			continue
+		}
+		if r := slices.Index(reasonOrder, reason); r < rank {
+			best, rank = Resemblance{Identity: id, Reason: reason}, r
+		}
+	}
+	return best, rank < len(reasonOrder)
+}
+
+var reasonOrder = []string{ReasonSameName, ReasonSameUser, ReasonSameUsername, ReasonNameInEmail}
+
+// nameWords lowercases a name and splits it into words on spaces and
+// punctuation, sorted, so "Doe, Jane" and jane.doe both give "doe jane"
+func nameWords(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return r == ' ' || r == '.' || r == '_' || r == '-' || r == ',' || r == '\t'
+	})
+	slices.Sort(words)
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,245 @@
+package identity
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+)
+
+// Phase names a scanner; a checkpoint records the ones that finished
+type Phase string
+
+// Checkpoint is the progress of a scan. Persisting it after every step lets
+// an interrupted scan resume instead of starting over.
+type Checkpoint struct {
+	Phase      Phase               `json:"phase"`       // scanner in progress
+	Done       []Phase             `json:"done"`        // completed scanners
+	Dirs       []string            `json:"dirs"`        // repo trees completed by the repos scanner
+	Identities []Identity          `json:"identities"`  // found so far, in discovery order
+	Platforms  map[string]Platform `json:"platforms"`   // email -> platform from repo remotes
+	Skipped    []repowalk.Skipped  `json:"skipped"`     // paths that could not be read
+	Timings    []Timing            `json:"timings"`     // one per completed scanner
+	ThirdParty []string            `json:"third_party"` // repos the repos scanner left out as not the user's
+}
+
+// known reports whether all phases recorded in cp are scanners that exist;
+// a nil checkpoint is known
+func (cp *Checkpoint) known() bool {
+	if cp == nil {
+		return true
+	}
+	if cp.Phase != "" && !slices.Contains(Phases, cp.Phase) {
+		return false
+	}
+	for _, phase := range cp.Done {
+		if !slices.Contains(Phases, phase) {
+			return false
+		}
+	}
+	return true
+}
+
+// Timing is how long a scanner took and how many new identities it found
+type Timing struct {
+	Phase    Phase         `json:"phase"`
+	Duration time.Duration `json:"duration"`
+	Found    int           `json:"found"`
+}
+
+// Options adjusts a scan
+type Options struct {
+	Disabled      []Phase           // scanners to skip
+	Progress      func(*Checkpoint) // called after every scanner and repo tree
+	ReferenceDirs []string          // trees of clones that are never the user's, see ThirdParty
+	Usernames     []string          // platform logins known to be the user's
+	Exclude       []string          // directories never walked, see repowalk.Excluded
+	Stop          <-chan struct{}   // closing it ends the scan after the step in progress
+}
+
+// ErrStopped is returned by Resume when Options.Stop ended the scan early; the
+// checkpoint it returns resumes the scan
+var ErrStopped = errors.New("scan stopped")
+
+// Scan finds all git identities on the machine
+func Scan() ([]Identity, error) {
+	cp, err := Resume(nil, Options{})
+	if err != nil {
+		return nil, err
+	}
+	return cp.Identities, nil
+}
+
+// Resume runs the enabled scanners, skipping the work already recorded in cp
+// (which may be nil). The final checkpoint holds the result.
+func Resume(cp *Checkpoint, opts Options) (*Checkpoint, error) {
+	home, uid, err := scanUser()
+	if err != nil {
+		return nil, err
+	}
+
+	if !cp.known() {
+		cp = nil // left by an older gitme with other scanners, start over
+	}
+	s := newScanState(cp)
+	s.home, s.uid = home, uid
+	s.opts = opts
+	s.walker.Exclude = opts.Exclude
+	s.report = func() {
+		if opts.Progress != nil {
+			opts.Progress(s.checkpoint())
+		}
+	}
+
+	sshHostPlatforms = nil
+	for _, sc := range scanners {
+		if slices.Contains(opts.Disabled, sc.phase) || slices.Contains(s.done, sc.phase) && !sc.rerun {
+			continue
+		}
+		if s.stopped() {
+			return s.checkpoint(), ErrStopped
+		}
+		s.phase = sc.phase
+		start, before := time.Now(), len(s.order)
+		sc.run(s)
+		if s.stopped() { // the scanner may not have finished
+			return s.checkpoint(), ErrStopped
+		}
+		if !slices.Contains(s.done, sc.phase) {
+			s.done = append(s.done, sc.phase)
+			s.timings = append(s.timings, Timing{Phase: sc.phase, Duration: time.Since(start), Found: len(s.order) - before})
+		}
+		s.report()
+	}
+
+	return s.checkpoint(), nil
+}
+
+// WorkspaceDirs returns the directories searched for repositories
+func WorkspaceDirs(home string) []string {
+	return []string{
+		filepath.Join(home, "Developer"),
+		filepath.Join(home, "Projects"),
+		filepath.Join(home, "Code"),
+		filepath.Join(home, "workspace"),
+		filepath.Join(home, "src"),
+		filepath.Join(home, "work"),
+	}
+}
+
+// scanState is the mutable form of a Checkpoint
+type scanState struct {
+	home       string
+	uid        int    // owner of the files the scan reads
+	report     func() // persists progress
+	phase      Phase
+	done       []Phase
+	timings    []Timing
+	dirs       []string
+	order      []string // emails in discovery order
+	identities map[string]*Identity
+	platforms  map[string]Platform
+	walker     repowalk.Walker
+	opts       Options
+	thirdParty map[string]bool
+}
+
+// stopped reports whether Options.Stop has been closed
+func (s *scanState) stopped() bool {
+	select {
+	case <-s.opts.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func newScanState(cp *Checkpoint) *scanState {
+	s := &scanState{
+		identities: make(map[string]*Identity),
+		platforms:  make(map[string]Platform),
+		thirdParty: make(map[string]bool),
+	}
+	if cp == nil {
+		return s
+	}
+	s.done = slices.Clone(cp.Done)
+	s.timings = slices.Clone(cp.Timings)
+	s.dirs = slices.Clone(cp.Dirs)
+	s.walker.Skipped = slices.Clone(cp.Skipped)
+	for email, p := range cp.Platforms {
+		s.platforms[email] = p
+	}
+	for _, repo := range cp.ThirdParty {
+		s.thirdParty[repo] = true
+	}
+	for i := range cp.Identities {
+		id := cp.Identities[i]
+		s.order = append(s.order, id.Email)
+		s.identities[id.Email] = &id
+	}
+	return s
+}
+
+// add records an identity or merges its source into the known one. Config
+// identities may also upgrade an unknown platform.
+func (s *scanState) add(id *Identity, upgradePlatform bool) {
+	if id == nil || id.Email == "" {
+		return
+	}
+	if id.Platform == PlatformUnknown {
+		if p, ok := s.platforms[id.Email]; ok {
+			id.Platform = p
+		}
+	}
+	if existing, ok := s.identities[id.Email]; ok {
+		existing.Sources = append(existing.Sources, id.Source)
+		if upgradePlatform && existing.Platform == PlatformUnknown && id.Platform != PlatformUnknown {
+			existing.Platform = id.Platform
+		}
+		return
+	}
+	id.Sources = []string{id.Source}
+	s.identities[id.Email] = id
+	s.order = append(s.order, id.Email)
+}
+
+// scanRepoTree collects local identities of dir and the repos up to three
+// levels below it; trees already reached through a symlink are skipped
+func (s *scanState) scanRepoTree(dir string) {
+	addRepo := func(repo string) {
+		gitDir := repowalk.GitDir(repo)
+		gitConfig := filepath.Join(gitDir, "config")
+		if s.thirdParty[repo] || !s.owned(gitConfig) {
+			return
+		}
+		if id, _ := parseGitConfig(gitConfig, gitConfig, gitDir); id != nil {
+			s.add(id, false)
+		}
+	}
+	s.walker.Walk(dir, 3, addRepo)
+}
+
+func (s *scanState) checkpoint() *Checkpoint {
+	cp := &Checkpoint{
+		Phase:     s.phase,
+		Done:      slices.Clone(s.done),
+		Dirs:      slices.Clone(s.dirs),
+		Platforms: make(map[string]Platform, len(s.platforms)),
+		Skipped:   slices.Clone(s.walker.Skipped),
+		Timings:   slices.Clone(s.timings),
+	}
+	for email, p := range s.platforms {
+		cp.Platforms[email] = p
+	}
+	for repo := range s.thirdParty {
+		cp.ThirdParty = append(cp.ThirdParty, repo)
+	}
+	slices.Sort(cp.ThirdParty)
+	for _, email := range s.order {
+		cp.Identities = append(cp.Identities, *s.identities[email])
+	}
+	return cp
+}
@@ -0,0 +1,232 @@
+package identity
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeGitConfig(t *testing.T, path, name, email string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := "[user]\n\tname = " + name + "\n\temail = " + email + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResumeContinuesInterruptedScan(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeGitConfig(t, filepath.Join(home, ".gitconfig"), "Me", "me@example.com")
+	writeGitConfig(t, filepath.Join(home, "Developer", "a", ".git", "config"), "Work", "me@corp.com")
+	writeGitConfig(t, filepath.Join(home, "Developer", "b", ".git", "config"), "Me", "me@example.com")
+
+	full, err := Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	// Interrupt after the first repo tree
+	var saved *Checkpoint
+	func() {
+		defer func() { recover() }()
+		Resume(nil, Options{Progress: func(cp *Checkpoint) {
+			saved = cp
+			if len(cp.Dirs) == 1 {
+				panic("interrupted")
+			}
+		}})
+	}()
+	if saved == nil || len(saved.Dirs) != 1 || !slices.Contains(saved.Done, PhaseGitConfig) {
+		t.Fatalf("unexpected checkpoint: %+v", saved)
+	}
+
+	result, err := Resume(saved, Options{})
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	resumed := result.Identities
+	if len(resumed) != len(full) {
+		t.Fatalf("expected %d identities, got %d", len(full), len(resumed))
+	}
+	for i := range full {
+		if full[i].Email != resumed[i].Email || len(full[i].Sources) != len(resumed[i].Sources) {
+			t.Errorf("identity %d differs: %+v vs %+v", i, full[i], resumed[i])
+		}
+	}
+}
+
+func TestResumeStopsWhenAsked(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeGitConfig(t, filepath.Join(home, ".gitconfig"), "Me", "me@example.com")
+	writeGitConfig(t, filepath.Join(home, "Developer", "a", ".git", "config"), "Work", "me@corp.com")
+	writeGitConfig(t, filepath.Join(home, "Developer", "b", ".git", "config"), "Other", "other@corp.com")
+
+	// Stop after the first repo tree
+	stop := make(chan struct{})
+	cp, err := Resume(nil, Options{Stop: stop, Progress: func(cp *Checkpoint) {
+		if len(cp.Dirs) == 1 {
+			close(stop)
+		}
+	}})
+	if err != ErrStopped {
+		t.Fatalf("Resume = %v, want ErrStopped", err)
+	}
+	if len(cp.Dirs) != 1 || slices.Contains(cp.Done, PhaseRepos) {
+		t.Fatalf("checkpoint = %+v, want the repos scanner stopped after one tree", cp)
+	}
+
+	result, err := Resume(cp, Options{})
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if len(result.Identities) != 3 {
+		t.Fatalf("identities = %+v, want the stopped scan finished", result.Identities)
+	}
+}
+
+func TestResumeDiscardsCheckpointsOfOldScanners(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeGitConfig(t, filepath.Join(home, ".gitconfig"), "Me", "me@example.com")
+
+	old := &Checkpoint{Phase: "platforms", Done: []Phase{"configs"}, Identities: []Identity{{Email: "stale@example.com"}}}
+	cp, err := Resume(old, Options{})
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if len(cp.Identities) != 1 || cp.Identities[0].Email != "me@example.com" {
+		t.Fatalf("identities = %+v, want the scan to start over", cp.Identities)
+	}
+	if slices.Contains(cp.Done, "configs") || !slices.Contains(cp.Done, PhaseGitConfig) {
+		t.Fatalf("done = %v, want only current scanners", cp.Done)
+	}
+}
+
+func TestScannersCanBeDisabledAndAreTimed(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GIT_AUTHOR_NAME", "Env")
+	t.Setenv("GIT_AUTHOR_EMAIL", "env@example.com")
+	writeGitConfig(t, filepath.Join(home, ".gitconfig"), "Me", "me@example.com")
+	orig := gpgSecretKeys
+	t.Cleanup(func() { gpgSecretKeys = orig })
+	gpgSecretKeys = func() ([]byte, error) {
+		return []byte("sec:u:255:22:AAAA:1700000000:::u:::scESC:::+:::ed25519:::0:\n" +
+			"uid:u::::1700000000::HASH::Me (laptop) <me@corp.com>::::::::::0:\n" +
+			"uid:r::::1700000000::HASH::Old <old@corp.com>::::::::::0:\n"), nil
+	}
+
+	cp, err := Resume(nil, Options{Disabled: []Phase{PhaseEnv}})
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	var emails []string
+	for _, id := range cp.Identities {
+		emails = append(emails, id.Email+"="+id.Source)
+	}
+	if want := []string{"me@example.com=" + filepath.Join(home, ".gitconfig"), "me@corp.com=gpg"}; !slices.Equal(emails, want) {
+		t.Fatalf("identities = %v, want %v", emails, want)
+	}
+	for _, timing := range cp.Timings {
+		if timing.Phase == PhaseEnv {
+			t.Fatalf("disabled scanner ran: %+v", cp.Timings)
+		}
+		if timing.Phase == PhaseGPG && timing.Found != 1 {
+			t.Fatalf("gpg timing = %+v, want 1 new identity", timing)
+		}
+	}
+	if len(cp.Timings) != len(Phases)-1 {
+		t.Fatalf("got %d timings, want one per enabled scanner", len(cp.Timings))
+	}
+}
+
+func TestScanLeavesOutThirdPartyRepos(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(home, ".gitconfig"))
+	writeGitConfig(t, filepath.Join(home, ".gitconfig"), "Me", "me@example.com")
+	dev := filepath.Join(home, "Developer")
+	repo := func(name, remote string) string {
+		path := filepath.Join(dev, name)
+		if out, err := exec.Command("git", "init", "-q", path).CombinedOutput(); err != nil {
+			t.Fatalf("git init failed: %v (%s)", err, out)
+		}
+		if remote != "" {
+			exec.Command("git", "-C", path, "remote", "add", "origin", remote).Run()
+		}
+		return path
+	}
+	commit := func(path, email string) {
+		cmd := exec.Command("git", "-C", path, "commit", "-q", "--allow-empty", "-m", "x")
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=A", "GIT_AUTHOR_EMAIL="+email,
+			"GIT_COMMITTER_NAME=A", "GIT_COMMITTER_EMAIL="+email)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("commit failed: %v (%s)", err, out)
+		}
+	}
+
+	work := repo("work", "git@github.com:acme/api.git")
+	exec.Command("git", "-C", work, "config", "user.email", "me@example.com").Run()
+	sibling := repo("sibling", "https://github.com/acme/web.git")
+	contrib := repo("contrib", "https://github.com/rust-lang/rust.git")
+	commit(contrib, "me@example.com")
+	named := repo("dotfiles", "git@github.com:me/dotfiles.git")
+	local := repo("scratch", "")
+	oss := repo("linux", "https://github.com/torvalds/linux.git")
+	commit(oss, "torvalds@example.org")
+	exec.Command("git", "-C", oss, "config", "user.email", "torvalds@example.org").Run()
+	reference := repo("refs/go", "https://github.com/me/go.git")
+
+	cp, err := Resume(nil, Options{ReferenceDirs: []string{"~/Developer/refs"}})
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if want := []string{oss, reference}; !slices.Equal(cp.ThirdParty, want) {
+		t.Fatalf("third-party repos = %v, want %v (kept %v)", cp.ThirdParty, want, []string{work, sibling, contrib, named, local})
+	}
+	for _, id := range cp.Identities {
+		if id.Email == "torvalds@example.org" {
+			t.Fatalf("identity of a third-party repo was collected: %+v", id)
+		}
+	}
+}
+
+func TestScanSkipsFilesOfOtherUsers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeGitConfig(t, filepath.Join(home, ".gitconfig"), "Me", "me@example.com")
+	writeGitConfig(t, filepath.Join(home, "Developer", "a", ".git", "config"), "Work", "me@corp.com")
+	if _, ok := fileOwner(filepath.Join(home, ".gitconfig")); !ok {
+		t.Skip("files have no owner here")
+	}
+
+	// Scan as someone else, as root through sudo would for the invoking user
+	orig := scanUser
+	scanUser = func() (string, int, error) { return home, os.Geteuid() + 1, nil }
+	t.Cleanup(func() { scanUser = orig })
+
+	cp, err := Resume(nil, Options{Disabled: []Phase{PhaseGPG, PhaseEnv}})
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if len(cp.Identities) != 0 {
+		t.Fatalf("expected no identities from files of another user, got %+v", cp.Identities)
+	}
+	skipped := make(map[string]string)
+	for _, s := range cp.Skipped {
+		skipped[s.Path] = s.Reason
+	}
+	for _, path := range []string{filepath.Join(home, ".gitconfig"), filepath.Join(home, "Developer", "a", ".git", "config")} {
+		if !strings.HasPrefix(skipped[path], "owned by another user") {
+			t.Fatalf("expected %s to be skipped as another user's, got %v", path, cp.Skipped)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package identity
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+)
+
+// Scanners, in the order they run
+const (
+	PhaseSSH       Phase = "ssh"       // ~/.ssh/config host aliases, for platform detection
+	PhaseGitConfig Phase = "gitconfig" // ~/.gitconfig and ~/.config/git/config
+	PhaseIncludes  Phase = "includes"  // files pulled in with [include] path = ...
+	PhaseRepos     Phase = "repos"     // remotes and local identities of workspace repos
+	PhaseGPG       Phase = "gpg"       // user IDs of gpg secret keys
+	PhaseEnv       Phase = "env"       // GIT_AUTHOR_EMAIL and friends
+	PhaseGH        Phase = "gh"        // GitHub CLI logins, as usernames of known identities
+)
+
+// scanner is one source of identities. Identities it adds carry where they
+// were found in Source.
+type scanner struct {
+	phase Phase
+	run   func(s *scanState)
+	rerun bool // only loads lookup state, so it runs again on resume
+}
+
+// scanners is the registry of sources a scan runs
+var scanners = []scanner{
+	{phase: PhaseSSH, run: scanSSH, rerun: true},
+	{phase: PhaseGitConfig, run: scanGitConfigs},
+	{phase: PhaseIncludes, run: scanIncludeFiles},
+	{phase: PhaseRepos, run: scanRepos},
+	{phase: PhaseGPG, run: scanGPG},
+	{phase: PhaseEnv, run: scanEnv},
+	{phase: PhaseGH, run: scanGH},
+}
+
+// Phases lists the scanners in the order they run
+var Phases = func() []Phase {
+	var phases []Phase
+	for _, sc := range scanners {
+		phases = append(phases, sc.phase)
+	}
+	return phases
+}()
+
+func scanSSH(s *scanState) {
+	sshHostPlatforms = parseSSHConfig()
+}
+
+func (s *scanState) globalConfig() string {
+	return filepath.Join(s.home, ".gitconfig")
+}
+
+func scanGitConfigs(s *scanState) {
+	xdgConfig := filepath.Join(s.home, ".config", "git", "config")
+	for _, path := range []string{s.globalConfig(), xdgConfig} {
+		if !s.owned(path) {
+			continue
+		}
+		if id, _ := parseGitConfig(path, path, ""); id != nil {
+			s.add(id, true)
+		}
+	}
+}
+
+func scanIncludeFiles(s *scanState) {
+	if !s.owned(s.globalConfig()) {
+		return
+	}
+	includes, _ := scanIncludes(s.globalConfig())
+	for i := range includes {
+		if s.owned(includes[i].Source) {
+			s.add(&includes[i], true)
+		}
+	}
+}
+
+// scanRepos sets aside third-party repos and learns platforms from the
+// remotes of the rest, then collects their local identities one tree at a time
+func scanRepos(s *scanState) {
+	if len(s.dirs) == 0 {
+		globalEmail := ""
+		if s.owned(s.globalConfig()) {
+			if id, _ := parseGitConfig(s.globalConfig(), s.globalConfig(), ""); id != nil {
+				globalEmail = id.Email
+			}
+		}
+		var repos []string
+		for _, dir := range WorkspaceDirs(s.home) {
+			s.walker.Walk(dir, 3, func(repo string) {
+				if s.owned(filepath.Join(repowalk.GitDir(repo), "config")) {
+					repos = append(repos, repo)
+				}
+			})
+		}
+		s.walker.Rewind()
+		s.thirdParty = ThirdParty(repos, s.order, s.opts.Usernames, s.opts.ReferenceDirs)
+		for _, repo := range repos {
+			if !s.thirdParty[repo] {
+				recordRepoPlatform(repo, s.platforms, globalEmail)
+			}
+		}
+		// Identities from configs were found before any remote was seen
+		for _, id := range s.identities {
+			if p, ok := s.platforms[id.Email]; ok && id.Platform == PlatformUnknown {
+				id.Platform = p
+			}
+		}
+	}
+
+	for _, dir := range WorkspaceDirs(s.home) {
+		for _, subdir := range s.walker.Dirs(dir) {
+			if slices.Contains(s.dirs, subdir) {
+				continue
+			}
+			if s.stopped() {
+				return
+			}
+			s.scanRepoTree(subdir)
+			s.dirs = append(s.dirs, subdir)
+			s.report()
+		}
+	}
+}
+
+// gpgSecretKeys lists the secret keys in gpg's --with-colons format
+var gpgSecretKeys = func() ([]byte, error) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		return nil, err
+	}
+	return exec.Command("gpg", "--list-secret-keys", "--with-colons").Output()
+}
+
+func scanGPG(s *scanState) {
+	out, err := gpgSecretKeys()
+	if err != nil {
+		return
+	}
+	for _, id := range parseGPGUserIDs(string(out)) {
+		s.add(&id, false)
+	}
+}
+
+var userIDRe = regexp.MustCompile(`^(.*?)\s*(?:\(.*\))?\s*<([^<>\s]+@[^<>\s]+)>$`)
+
+// parseGPGUserIDs reads "Name (comment) <email>" user IDs from the uid
+// records of gpg --with-colons output, skipping revoked and expired ones
+func parseGPGUserIDs(colons string) []Identity {
+	var ids []Identity
+	for _, line := range strings.Split(colons, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 10 || fields[0] != "uid" || fields[1] == "r" || fields[1] == "e" {
+			continue
+		}
+		m := userIDRe.FindStringSubmatch(unescapeColons(fields[9]))
+		if m == nil {
+			continue
+		}
+		ids = append(ids, Identity{Name: m[1], Email: m[2], Source: "gpg", Platform: DetectPlatform(m[2])})
+	}
+	return ids
+}
+
+// unescapeColons undoes gpg's \xNN escaping of colon-format fields
+func unescapeColons(field string) string {
+	return strings.NewReplacer(`\x3a`, ":", `\x5c`, `\`).Replace(field)
+}
+
+// scanEnv picks up identities set for git in the environment
+func scanEnv(s *scanState) {
+	for _, pair := range [][2]string{
+		{"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL"},
+		{"GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL"},
+		{"", "EMAIL"},
+	} {
+		email := os.Getenv(pair[1])
+		if !strings.Contains(email, "@") {
+			continue
+		}
+		name := ""
+		if pair[0] != "" {
+			name = os.Getenv(pair[0])
+		}
+		s.add(&Identity{Name: name, Email: email, Source: "env:" + pair[1], Platform: DetectPlatform(email)}, false)
+	}
+}
+
+// ghHosts is the part of the GitHub CLI's hosts.yml gitme reads
+type ghHosts map[string]struct {
+	User  string         `yaml:"user"`
+	Users map[string]any `yaml:"users"`
+}
+
+// scanGH matches the logins of the GitHub CLI to known identities of the same
+// platform whose email names that login, recording it as their username
+func scanGH(s *scanState) {
+	dir := os.Getenv("GH_CONFIG_DIR")
+	if dir == "" {
+		dir = filepath.Join(s.home, ".config", "gh")
+	}
+	hostsFile := filepath.Join(dir, "hosts.yml")
+	if !s.owned(hostsFile) {
+		return
+	}
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		return
+	}
+	var hosts ghHosts
+	if yaml.Unmarshal(data, &hosts) != nil {
+		return
+	}
+
+	for host, entry := range hosts {
+		platform := detectPlatformFromHostInfo(host, host)
+		logins := []string{entry.User}
+		for login := range entry.Users {
+			logins = append(logins, login)
+		}
+		for _, login := range logins {
+			if login == "" {
+				continue
+			}
+			for _, email := range s.order {
+				id := s.identities[email]
+				if id.Platform == platform && id.Username == "" && emailNamesLogin(id.Email, login) {
+					id.Username = login
+					id.Sources = append(id.Sources, "gh:"+host)
+				}
+			}
+		}
+	}
+}
+
+// emailNamesLogin reports whether email is login@… or a 123+login@ noreply
+func emailNamesLogin(email, login string) bool {
+	user, _, _ := strings.Cut(strings.ToLower(email), "@")
+	if _, rest, ok := strings.Cut(user, "+"); ok {
+		user = rest
+	}
+	return user == strings.ToLower(login)
+}
@@ -0,0 +1,144 @@
+package identity
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/remoteurl"
+	"github.com/vosamoilenko/gitme/internal/repowalk"
+)
+
+// ownerHistory is how many recent commits of a repo are read when looking
+// for the user's emails
+const ownerHistory = 500
+
+// ThirdParty returns the repos that are clearly not the user's: those under
+// one of referenceDirs, and those whose remotes all belong to owners (orgs or
+// users) never seen with the user's emails. An owner counts as seen when it is
+// one of usernames or the user part of an email, when one of its repos sets
+// an email locally, or when one has recent commits by one. Repos without
+// remotes are the user's; with no emails only referenceDirs apply.
+func ThirdParty(repos []string, emails, usernames, referenceDirs []string) map[string]bool {
+	known := make(map[string]bool)
+	mine := make(map[string]bool)
+	for _, email := range emails {
+		email = strings.ToLower(email)
+		known[email] = true
+		user, _, _ := strings.Cut(email, "@")
+		mine[user] = true
+	}
+	for _, username := range usernames {
+		mine[strings.ToLower(username)] = true
+	}
+
+	thirdParty := make(map[string]bool)
+	owners := make(map[string][]string)
+	byOwner := make(map[string][]string)
+	for _, repo := range repos {
+		if underAny(repo, referenceDirs) {
+			thirdParty[repo] = true
+			continue
+		}
+		owners[repo] = remoteOwners(repo)
+		for _, owner := range owners[repo] {
+			byOwner[owner] = append(byOwner[owner], repo)
+		}
+		if known[strings.ToLower(getRepoEmail(repowalk.GitDir(repo)))] {
+			for _, owner := range owners[repo] {
+				mine[owner] = true
+			}
+		}
+	}
+	if len(known) == 0 {
+		return thirdParty
+	}
+
+	env := repowalk.GitEnv()
+	var unsure []string // owners only the history of their repos can tell
+	for owner := range byOwner {
+		if !mine[owner] {
+			unsure = append(unsure, owner)
+		}
+	}
+	ours := repowalk.Collect(unsure, func(owner string) bool {
+		return slices.ContainsFunc(byOwner[owner], func(repo string) bool { return hasCommitsBy(repo, known, env) })
+	})
+	for i, owner := range unsure {
+		mine[owner] = ours[i]
+	}
+
+	for repo, repoOwners := range owners {
+		if len(repoOwners) == 0 {
+			continue
+		}
+		ours := false
+		for _, owner := range repoOwners {
+			ours = ours || mine[owner]
+		}
+		if !ours {
+			thirdParty[repo] = true
+		}
+	}
+	return thirdParty
+}
+
+// remoteOwners returns the lowercased owners of the remotes of repo, the
+// first path segment of each remote URL
+func remoteOwners(repo string) []string {
+	file, err := os.Open(filepath.Join(repowalk.GitDir(repo), "config"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var owners []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || strings.TrimSpace(key) != "url" {
+			continue
+		}
+		u, err := remoteurl.Parse(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		owner, _, _ := strings.Cut(u.Path, "/")
+		owners = append(owners, strings.ToLower(owner))
+	}
+	return owners
+}
+
+// hasCommitsBy reports whether one of the recent commits of repo was
+// authored with one of emails
+func hasCommitsBy(repo string, emails map[string]bool, env []string) bool {
+	out, err := repowalk.Git(repo, env, "log", "-n", strconv.Itoa(ownerHistory), "--format=%aE")
+	if err != nil {
+		return false
+	}
+	for _, email := range strings.Split(string(out), "\n") {
+		if emails[strings.ToLower(email)] {
+			return true
+		}
+	}
+	return false
+}
+
+// underAny reports whether path is one of dirs or below one; dirs may start
+// with ~/
+func underAny(path string, dirs []string) bool {
+	home, _ := os.UserHomeDir()
+	for _, dir := range dirs {
+		if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+			dir = filepath.Join(home, rest)
+		}
+		dir = filepath.Clean(dir)
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
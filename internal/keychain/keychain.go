@@ -0,0 +1,104 @@
+package keychain
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// service is the keychain service name all gitme secrets are stored under
+const service = "gitme"
+
+// ErrNotFound is returned when no secret is stored for an account
+var ErrNotFound = errors.New("no token stored")
+
+// ErrUnsupported is returned when no keychain backend is available
+var ErrUnsupported = errors.New("no supported keychain (need macOS security or secret-tool)")
+
+// Set stores secret for account, replacing any existing value
+func Set(account, secret string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		// Given to security -i on stdin, so the secret never shows in ps
+		if strings.ContainsAny(secret, "\r\n") {
+			return fmt.Errorf("secret spans lines")
+		}
+		line := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", quote(service), quote(account), quote(secret))
+		cmd := exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(line)
+		// security -i may exit 0 after a failed command, so its output decides
+		out, err := cmd.CombinedOutput()
+		if msg := strings.TrimSpace(strings.TrimPrefix(string(out), "security>")); err != nil || msg != "" {
+			return fmt.Errorf("security: %s", cmp.Or(msg, fmt.Sprint(err)))
+		}
+		return nil
+	case "linux":
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return ErrUnsupported
+		}
+		return run(strings.NewReader(secret), "secret-tool", "store",
+			"--label=gitme token for "+account, "service", service, "account", account)
+	}
+	return ErrUnsupported
+}
+
+// Get returns the secret stored for account
+func Get(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "linux":
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return "", ErrUnsupported
+		}
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	default:
+		return "", ErrUnsupported
+	}
+	out, err := cmd.Output()
+	secret := strings.TrimSpace(string(out))
+	if err != nil || secret == "" {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+// Delete removes the secret stored for account
+func Delete(account string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		if err := run(nil, "security", "delete-generic-password", "-s", service, "-a", account); err != nil {
+			return ErrNotFound
+		}
+		return nil
+	case "linux":
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return ErrUnsupported
+		}
+		return run(nil, "secret-tool", "clear", "service", service, "account", account)
+	}
+	return ErrUnsupported
+}
+
+func run(stdin *strings.Reader, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// quote makes s one argument of a security -i command line
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,210 @@
+package platform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrOffline is returned, wrapped, when a platform cannot be reached at all.
+// Features that need the network report it as skipped instead of failing.
+var ErrOffline = errors.New("offline")
+
+// RateLimitError is returned when a platform's API rate limit is used up for
+// longer than gitme waits
+type RateLimitError struct {
+	Host  string
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s API rate limit exceeded until %s", e.Host, e.Reset.Local().Format("15:04"))
+}
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+var (
+	// maxBackoff is the longest a request waits for a rate limit to reset
+	maxBackoff = 30 * time.Second
+	sleep      = time.Sleep
+	// offline holds the hosts a request could not reach, so the requests
+	// after it to the same host fail right away instead of each waiting for
+	// a timeout
+	offline sync.Map
+	// fetched are the responses this process already got, by auth and URL
+	fetched sync.Map
+)
+
+// Offline reports whether host is known to be unreachable: a request to it
+// failed to connect, or GITME_OFFLINE is set
+func Offline(host string) bool {
+	if os.Getenv("GITME_OFFLINE") != "" {
+		return true
+	}
+	_, ok := offline.Load(host)
+	return ok
+}
+
+// getJSON decodes the response to a GET of url into v. Responses are reused
+// for the rest of the process; rate limits and server errors are retried
+// after a backoff.
+func getJSON(rawURL, auth string, v any) error {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Host
+	}
+	key := auth + " " + rawURL
+	if data, ok := fetched.Load(key); ok {
+		return json.Unmarshal(data.([]byte), v)
+	}
+	if Offline(host) {
+		return fmt.Errorf("%s: %w", host, ErrOffline)
+	}
+
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req)
+		if err != nil {
+			if unreachable(err) {
+				offline.Store(host, true)
+				return fmt.Errorf("%s: %w", host, ErrOffline)
+			}
+			return err
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", rawURL, err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			if err := json.Unmarshal(data, v); err != nil {
+				return fmt.Errorf("%s: %w", rawURL, err)
+			}
+			fetched.Store(key, data)
+			return nil
+		case rateLimited(resp):
+			reset := rateLimitReset(resp)
+			if wait := time.Until(reset); wait <= maxBackoff && attempt < 3 {
+				sleep(max(wait, time.Second))
+				continue
+			}
+			return &RateLimitError{Host: host, Reset: reset}
+		case resp.StatusCode >= 500 && attempt < 2:
+			sleep(time.Second << attempt)
+			continue
+		}
+		return fmt.Errorf("%s: %s", rawURL, resp.Status)
+	}
+}
+
+// StatusError is a platform's refusal of a request, with the body it sent
+type StatusError struct {
+	URL  string
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.URL, e.Code, http.StatusText(e.Code))
+}
+
+// postJSON POSTs body as JSON to url and decodes the response into v, unless
+// v is nil. It is sent once; the responses getJSON remembered are dropped,
+// as they may no longer hold.
+func postJSON(rawURL, auth string, body, v any) error {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Host
+	}
+	if Offline(host) {
+		return fmt.Errorf("%s: %w", host, ErrOffline)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", rawURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if unreachable(err) {
+			offline.Store(host, true)
+			return fmt.Errorf("%s: %w", host, ErrOffline)
+		}
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", rawURL, err)
+	}
+	fetched.Clear()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		if v == nil {
+			return nil
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("%s: %w", rawURL, err)
+		}
+		return nil
+	case rateLimited(resp):
+		return &RateLimitError{Host: host, Reset: rateLimitReset(resp)}
+	}
+	return &StatusError{URL: rawURL, Code: resp.StatusCode, Body: string(data)}
+}
+
+// unreachable reports whether err means the host could not be connected to.
+// A response that is slow to come is not: the host was reached.
+func unreachable(err error) bool {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	return errors.As(err, &dnsErr) || errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// rateLimited reports whether resp refuses a request for exceeding a rate
+// limit: 429, or GitHub's 403 with no requests remaining
+func rateLimited(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode == http.StatusForbidden && (resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != "")
+}
+
+// rateLimitReset returns when a rate limit lifts, from Retry-After or the
+// reset time GitHub and GitLab send, else a minute from now
+func rateLimitReset(resp *http.Response) time.Time {
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	for _, header := range []string{"X-RateLimit-Reset", "RateLimit-Reset"} {
+		if unix, err := strconv.ParseInt(resp.Header.Get(header), 10, 64); err == nil {
+			return time.Unix(unix, 0)
+		}
+	}
+	return time.Now().Add(time.Minute)
+}
@@ -0,0 +1,105 @@
+package platform
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetJSONBacksOffAndDegradesOffline(t *testing.T) {
+	defer func() { sleep = time.Sleep }()
+	var slept time.Duration
+	sleep = func(d time.Duration) { slept += d }
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"login":"octocat"}`))
+	}))
+
+	var user struct{ Login string }
+	for range 2 {
+		if err := getJSON(srv.URL+"/user", "Bearer t", &user); err != nil || user.Login != "octocat" {
+			t.Fatalf("getJSON = %v, %+v", err, user)
+		}
+	}
+	if requests != 2 || slept < time.Second || slept > 2*time.Second {
+		t.Errorf("expected one retry after about 2s and the second call answered from memory, got %d requests after %v", requests, slept)
+	}
+
+	srv.Close()
+	t.Cleanup(offline.Clear)
+	host := strings.TrimPrefix(srv.URL, "http://")
+	if err := getJSON(srv.URL+"/user/emails", "Bearer t", &user); !errors.Is(err, ErrOffline) || !Offline(host) {
+		t.Fatalf("expected an unreachable host to be offline, got %v", err)
+	}
+	if Offline("api.github.com") {
+		t.Error("expected other hosts to stay online")
+	}
+}
+
+func TestSlowResponsesAreNotOffline(t *testing.T) {
+	defer func(c *http.Client) { client = c }(client)
+	client = &http.Client{Timeout: 50 * time.Millisecond}
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-release }))
+	defer srv.Close()
+	defer close(release)
+	t.Cleanup(offline.Clear)
+
+	var user struct{ Login string }
+	if err := getJSON(srv.URL+"/user", "Bearer t", &user); err == nil || errors.Is(err, ErrOffline) {
+		t.Fatalf("expected a timeout that is not offline, got %v", err)
+	}
+	if Offline(strings.TrimPrefix(srv.URL, "http://")) {
+		t.Error("expected the host that answered slowly to stay online")
+	}
+}
+
+func TestUploadKeyTellsDuplicatesApart(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		switch len(bodies) {
+		case 1:
+			w.WriteHeader(http.StatusCreated)
+		case 2:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"message":"Validation Failed","errors":[{"message":"key is already in use"}]}`))
+		default:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"message":"Validation Failed","errors":[{"message":"key is invalid"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	fetched.Store("Bearer t "+srv.URL+"/keys", []byte(`[]`))
+	body := map[string]string{"title": "me", "key": "ssh-ed25519 AAAA"}
+	if err := uploadKey(srv.URL+"/keys", "Bearer t", body); err != nil {
+		t.Fatalf("first upload failed: %v", err)
+	}
+	if _, ok := fetched.Load("Bearer t " + srv.URL + "/keys"); ok {
+		t.Error("expected the upload to drop the remembered key list")
+	}
+	if err := uploadKey(srv.URL+"/keys", "Bearer t", body); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("expected a duplicate to be ErrKeyExists, got %v", err)
+	}
+	var status *StatusError
+	if err := uploadKey(srv.URL+"/keys", "Bearer t", body); !errors.As(err, &status) || errors.Is(err, ErrKeyExists) {
+		t.Errorf("expected another refusal to be a StatusError, got %v", err)
+	}
+	if bodies[0] != `{"key":"ssh-ed25519 AAAA","title":"me"}` {
+		t.Errorf("unexpected request body %s", bodies[0])
+	}
+}
@@ -0,0 +1,244 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+// Email is an address attached to a platform account
+type Email struct {
+	Address  string
+	Verified bool
+}
+
+// Account is the platform account a token authenticates as
+type Account struct {
+	Platform identity.Platform
+	Login    string
+	Emails   []Email
+}
+
+// FetchAccount returns the account and its emails for a token. When the
+// platform cannot be reached the error wraps ErrOffline; a used up rate limit
+// is a *RateLimitError.
+func FetchAccount(p identity.Platform, token string) (*Account, error) {
+	switch p {
+	case identity.PlatformGitHub:
+		return fetchGitHub(token)
+	case identity.PlatformGitLab:
+		return fetchGitLab(token)
+	}
+	return nil, fmt.Errorf("platform %q has no API support", p)
+}
+
+// HasEmail reports whether the account owns email and whether it is verified
+func (a *Account) HasEmail(email string) (found, verified bool) {
+	for _, e := range a.Emails {
+		if strings.EqualFold(e.Address, email) {
+			return true, e.Verified
+		}
+	}
+	return false, false
+}
+
+func fetchGitHub(token string) (*Account, error) {
+	var user struct {
+		Login string `json:"login"`
+		ID    int64  `json:"id"`
+	}
+	if err := getJSON("https://api.github.com/user", "Bearer "+token, &user); err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON("https://api.github.com/user/emails", "Bearer "+token, &emails); err != nil {
+		return nil, err
+	}
+
+	acct := &Account{Platform: identity.PlatformGitHub, Login: user.Login}
+	for _, e := range emails {
+		acct.Emails = append(acct.Emails, Email{Address: e.Email, Verified: e.Verified})
+	}
+	// GitHub always attributes both noreply forms to the account
+	acct.Emails = append(acct.Emails,
+		Email{Address: user.Login + "@users.noreply.github.com", Verified: true},
+		Email{Address: fmt.Sprintf("%d+%s@users.noreply.github.com", user.ID, user.Login), Verified: true},
+	)
+	return acct, nil
+}
+
+func fetchGitLab(token string) (*Account, error) {
+	var user struct {
+		Username    string `json:"username"`
+		Email       string `json:"email"`
+		CommitEmail string `json:"commit_email"`
+	}
+	if err := getJSON("https://gitlab.com/api/v4/user", "Bearer "+token, &user); err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email       string  `json:"email"`
+		ConfirmedAt *string `json:"confirmed_at"`
+	}
+	if err := getJSON("https://gitlab.com/api/v4/user/emails", "Bearer "+token, &emails); err != nil {
+		return nil, err
+	}
+
+	acct := &Account{Platform: identity.PlatformGitLab, Login: user.Username}
+	// The primary email can only be set once confirmed
+	acct.Emails = append(acct.Emails, Email{Address: user.Email, Verified: true})
+	for _, e := range emails {
+		acct.Emails = append(acct.Emails, Email{Address: e.Email, Verified: e.ConfirmedAt != nil})
+	}
+	if strings.HasSuffix(user.CommitEmail, "@users.noreply.gitlab.com") {
+		acct.Emails = append(acct.Emails, Email{Address: user.CommitEmail, Verified: true})
+	}
+	return acct, nil
+}
+
+// Keys are the public keys uploaded to a platform account
+type Keys struct {
+	SSH        []string // authentication keys, as "<type> <base64>"
+	SSHSigning []string // ssh keys signed commits are verified with
+	GPG        []string // uppercase gpg key IDs and their subkeys' IDs; nil when the platform does not tell
+}
+
+// FetchKeys returns the public keys of the account login on p. GitHub lists
+// them for anyone, with token if given; GitLab only for the token's account.
+func FetchKeys(p identity.Platform, token, login string) (*Keys, error) {
+	auth := ""
+	if token != "" {
+		auth = "Bearer " + token
+	}
+	switch p {
+	case identity.PlatformGitHub:
+		return fetchGitHubKeys(auth, login)
+	case identity.PlatformGitLab:
+		if token == "" {
+			return nil, fmt.Errorf("GitLab lists keys only with a token")
+		}
+		return fetchGitLabKeys(auth)
+	}
+	return nil, fmt.Errorf("platform %q has no API support", p)
+}
+
+func fetchGitHubKeys(auth, login string) (*Keys, error) {
+	base := "https://api.github.com/users/" + url.PathEscape(login)
+	var auths, signing []struct {
+		Key string `json:"key"`
+	}
+	if err := getJSON(base+"/keys", auth, &auths); err != nil {
+		return nil, err
+	}
+	if err := getJSON(base+"/ssh_signing_keys", auth, &signing); err != nil {
+		return nil, err
+	}
+	type gpgKey struct {
+		KeyID   string   `json:"key_id"`
+		Subkeys []gpgKey `json:"subkeys"`
+	}
+	var gpg []gpgKey
+	if err := getJSON(base+"/gpg_keys", auth, &gpg); err != nil {
+		return nil, err
+	}
+
+	keys := &Keys{GPG: []string{}}
+	for _, k := range auths {
+		keys.SSH = append(keys.SSH, NormalizeSSHKey(k.Key))
+	}
+	for _, k := range signing {
+		keys.SSHSigning = append(keys.SSHSigning, NormalizeSSHKey(k.Key))
+	}
+	for _, k := range gpg {
+		keys.GPG = append(keys.GPG, strings.ToUpper(k.KeyID))
+		for _, sub := range k.Subkeys {
+			keys.GPG = append(keys.GPG, strings.ToUpper(sub.KeyID))
+		}
+	}
+	return keys, nil
+}
+
+func fetchGitLabKeys(auth string) (*Keys, error) {
+	var ssh []struct {
+		Key       string `json:"key"`
+		UsageType string `json:"usage_type"`
+	}
+	if err := getJSON("https://gitlab.com/api/v4/user/keys", auth, &ssh); err != nil {
+		return nil, err
+	}
+	keys := &Keys{}
+	for _, k := range ssh {
+		key := NormalizeSSHKey(k.Key)
+		if k.UsageType != "signing" {
+			keys.SSH = append(keys.SSH, key)
+		}
+		if k.UsageType != "auth" {
+			keys.SSHSigning = append(keys.SSHSigning, key)
+		}
+	}
+	// GitLab returns gpg keys armored only, so their IDs stay unknown
+	return keys, nil
+}
+
+// NormalizeSSHKey reduces a public key line to its type and base64 blob,
+// dropping options and the comment
+func NormalizeSSHKey(line string) string {
+	fields := strings.Fields(line)
+	for i := 0; i+1 < len(fields); i++ {
+		if strings.HasPrefix(fields[i], "ssh-") || strings.HasPrefix(fields[i], "ecdsa-") || strings.HasPrefix(fields[i], "sk-") {
+			return fields[i] + " " + fields[i+1]
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+// ErrKeyExists is returned when a key uploaded is already on the account
+var ErrKeyExists = errors.New("key already uploaded")
+
+// SigningKey is a public key commits are signed with, to upload to an account
+type SigningKey struct {
+	Format string // "ssh" or "gpg"
+	Key    string // "<type> <base64>" for ssh, the armored public key for gpg
+	Title  string // what the platform lists an ssh key as
+}
+
+// UploadSigningKey adds key to the account of token on p, so the commits it
+// signs show as verified. A key the account has already is ErrKeyExists.
+func UploadSigningKey(p identity.Platform, token string, key SigningKey) error {
+	var rawURL string
+	var body map[string]string
+	switch {
+	case p == identity.PlatformGitHub && key.Format == "ssh":
+		rawURL, body = "https://api.github.com/user/ssh_signing_keys", map[string]string{"title": key.Title, "key": key.Key}
+	case p == identity.PlatformGitHub:
+		rawURL, body = "https://api.github.com/user/gpg_keys", map[string]string{"name": key.Title, "armored_public_key": key.Key}
+	case p == identity.PlatformGitLab && key.Format == "ssh":
+		rawURL, body = "https://gitlab.com/api/v4/user/keys", map[string]string{"title": key.Title, "key": key.Key, "usage_type": "signing"}
+	case p == identity.PlatformGitLab:
+		rawURL, body = "https://gitlab.com/api/v4/user/gpg_keys", map[string]string{"key": key.Key}
+	default:
+		return fmt.Errorf("platform %q has no API support", p)
+	}
+	return uploadKey(rawURL, "Bearer "+token, body)
+}
+
+// uploadKey POSTs a key, telling a duplicate, which GitHub refuses with 422
+// and GitLab with 400, from other refusals
+func uploadKey(rawURL, auth string, body any) error {
+	err := postJSON(rawURL, auth, body, nil)
+	var status *StatusError
+	if errors.As(err, &status) && (status.Code == http.StatusUnprocessableEntity || status.Code == http.StatusBadRequest) &&
+		strings.Contains(status.Body, "already") {
+		return ErrKeyExists
+	}
+	return err
+}
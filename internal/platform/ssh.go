@@ -0,0 +1,60 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// sshGreetings match the account name hosting platforms greet an ssh key with
+var sshGreetings = []*regexp.Regexp{
+	regexp.MustCompile(`Hi ([^!\s]+)! You've successfully authenticated`), // GitHub
+	regexp.MustCompile(`Welcome to GitLab, @([^!\s]+)!`),                  // GitLab
+	regexp.MustCompile(`logged in as ([^\s.]+)`),                          // Bitbucket
+}
+
+// sshUnreachable are what ssh says when the network is down
+var sshUnreachable = []string{"Could not resolve hostname", "Network is unreachable", "Connection timed out", "Operation timed out"}
+
+// SSHAccount returns the account the ssh key used for host authenticates as.
+// host may be an ~/.ssh/config alias such as github-work, or a destination
+// with a user and port such as ssh://git@host:2222. When host cannot be
+// reached the error wraps ErrOffline.
+func SSHAccount(host string) (string, error) {
+	dest := host
+	if !strings.Contains(dest, "@") {
+		dest = "git@" + dest
+	}
+	if Offline(host) {
+		return "", fmt.Errorf("%s: %w", host, ErrOffline)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	// The platforms refuse a shell, so ssh exits non-zero even on success
+	out, _ := exec.CommandContext(ctx, "ssh", "-T",
+		"-o", "BatchMode=yes", "-o", "ConnectTimeout=10",
+		dest).CombinedOutput()
+	if account := ParseSSHGreeting(string(out)); account != "" {
+		return account, nil
+	}
+	for _, msg := range sshUnreachable {
+		if strings.Contains(string(out), msg) {
+			offline.Store(host, true)
+			return "", fmt.Errorf("%s: %w", host, ErrOffline)
+		}
+	}
+	return "", fmt.Errorf("ssh to %s did not report an account: %s", host, strings.TrimSpace(string(out)))
+}
+
+// ParseSSHGreeting extracts the account name from an ssh -T greeting
+func ParseSSHGreeting(out string) string {
+	for _, re := range sshGreetings {
+		if m := re.FindStringSubmatch(out); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,16 @@
+package platform
+
+import "testing"
+
+func TestParseSSHGreeting(t *testing.T) {
+	for out, want := range map[string]string{
+		"Hi octocat! You've successfully authenticated, but GitHub does not provide shell access.": "octocat",
+		"Welcome to GitLab, @jdoe!\n": "jdoe",
+		"authenticated via ssh key.\n\nYou can use git to connect to Bitbucket. Shell access is disabled\nlogged in as jdoe.\n": "jdoe",
+		"git@github.com: Permission denied (publickey).":                                                                        "",
+	} {
+		if got := ParseSSHGreeting(out); got != want {
+			t.Errorf("ParseSSHGreeting(%q) = %q, want %q", out, got, want)
+		}
+	}
+}
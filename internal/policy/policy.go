@@ -0,0 +1,86 @@
+// Package policy reads the identity rules a repository carries in .gitme.yml
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the policy file looked up at the repository root
+const FileName = ".gitme.yml"
+
+// Policy restricts which identities may commit to a repository
+type Policy struct {
+	AllowedDomains    []string `yaml:"allowed_domains"`    // e.g. corp.com
+	AllowedIdentities []string `yaml:"allowed_identities"` // exact emails
+	RequireSigning    bool     `yaml:"require_signing"`
+	RequireNoreply    bool     `yaml:"require_noreply"` // GitHub/GitLab noreply addresses only
+}
+
+// Setup is the identity configuration a policy is checked against
+type Setup struct {
+	Email   string
+	Signing bool // commit.gpgsign
+}
+
+// Load reads the policy of the repo at root; a missing file yields nil
+func Load(root string) (*Policy, error) {
+	data, err := os.ReadFile(filepath.Join(root, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", FileName, err)
+	}
+	return p, nil
+}
+
+// Violations lists every rule the setup breaks
+func (p *Policy) Violations(s Setup) []string {
+	var violations []string
+	email := strings.ToLower(strings.TrimSpace(s.Email))
+
+	if !p.allowsEmail(email) {
+		violations = append(violations, fmt.Sprintf("%s is not an allowed identity", s.Email))
+	}
+	if p.RequireNoreply && !isNoreply(email) {
+		violations = append(violations, fmt.Sprintf("%s is not a noreply address", s.Email))
+	}
+	if p.RequireSigning && !s.Signing {
+		violations = append(violations, "commit signing is required (commit.gpgsign)")
+	}
+	return violations
+}
+
+func (p *Policy) allowsEmail(email string) bool {
+	if len(p.AllowedDomains) == 0 && len(p.AllowedIdentities) == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedIdentities {
+		if strings.EqualFold(allowed, email) {
+			return true
+		}
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	for _, allowed := range p.AllowedDomains {
+		allowed = strings.ToLower(strings.TrimPrefix(allowed, "@"))
+		if domain == allowed || strings.HasSuffix(domain, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNoreply(email string) bool {
+	return strings.HasSuffix(email, "@users.noreply.github.com") ||
+		strings.HasSuffix(email, "@users.noreply.gitlab.com")
+}
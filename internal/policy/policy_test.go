@@ -0,0 +1,53 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMissingPolicy(t *testing.T) {
+	p, err := Load(t.TempDir())
+	if err != nil || p != nil {
+		t.Fatalf("expected no policy, got %+v (%v)", p, err)
+	}
+}
+
+func TestViolations(t *testing.T) {
+	dir := t.TempDir()
+	data := "allowed_domains: [corp.com]\nallowed_identities: [me@example.com]\nrequire_signing: true\n"
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	tests := []struct {
+		setup Setup
+		want  int
+	}{
+		{Setup{Email: "a@corp.com", Signing: true}, 0},
+		{Setup{Email: "a@eu.corp.com", Signing: true}, 0},
+		{Setup{Email: "ME@example.com", Signing: true}, 0},
+		{Setup{Email: "a@notcorp.com", Signing: true}, 1},
+		{Setup{Email: "a@corp.com", Signing: false}, 1},
+		{Setup{Email: "a@gmail.com", Signing: false}, 2},
+	}
+	for _, tt := range tests {
+		if got := p.Violations(tt.setup); len(got) != tt.want {
+			t.Errorf("Violations(%+v) = %v, want %d", tt.setup, got, tt.want)
+		}
+	}
+}
+
+func TestRequireNoreply(t *testing.T) {
+	p := &Policy{RequireNoreply: true}
+	if v := p.Violations(Setup{Email: "me@users.noreply.github.com"}); len(v) != 0 {
+		t.Errorf("unexpected violations: %v", v)
+	}
+	if v := p.Violations(Setup{Email: "me@example.com"}); len(v) != 1 {
+		t.Errorf("expected one violation, got %v", v)
+	}
+}
@@ -0,0 +1,146 @@
+// Package remoteurl parses git remote URLs and rewrites them between ssh and https
+package remoteurl
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Protocols a remote can be rewritten to
+const (
+	SSH   = "ssh"
+	HTTPS = "https"
+)
+
+// URL is a remote on a hosting platform
+type URL struct {
+	Protocol string // SSH or HTTPS
+	Scheme   string // as written, e.g. "http" or "ssh"; "" for git@host:org/repo
+	User     string // as written, possibly ""
+	Host     string // as written, possibly an ~/.ssh/config alias; without the port
+	Port     string // as written, possibly ""
+	Path     string // org/repo, without .git
+	Suffix   string // ".git" when the original had it
+}
+
+var scpLikeRe = regexp.MustCompile(`^(?:([^@/]+)@)?([^:/]+):(.+)$`)
+
+// Parse reads https://host/org/repo, ssh://git@host/org/repo and
+// git@host:org/repo remotes
+func Parse(raw string) (*URL, error) {
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		protocol := HTTPS
+		switch u.Scheme {
+		case "https", "http":
+		case "ssh", "git+ssh":
+			protocol = SSH
+		default:
+			return nil, fmt.Errorf("unsupported remote scheme: %s", u.Scheme)
+		}
+		parsed, err := newURL(protocol, u.Hostname(), u.Path)
+		if err != nil {
+			return nil, err
+		}
+		parsed.Scheme, parsed.User, parsed.Port = u.Scheme, u.User.String(), u.Port()
+		return parsed, nil
+	}
+	if m := scpLikeRe.FindStringSubmatch(raw); m != nil {
+		parsed, err := newURL(SSH, m[2], m[3])
+		if err != nil {
+			return nil, err
+		}
+		parsed.User = m[1]
+		return parsed, nil
+	}
+	return nil, fmt.Errorf("not a remote URL: %s", raw)
+}
+
+func newURL(protocol, host, path string) (*URL, error) {
+	path = strings.Trim(path, "/")
+	u := &URL{Protocol: protocol, Host: host, Path: strings.TrimSuffix(path, ".git")}
+	if u.Path != path {
+		u.Suffix = ".git"
+	}
+	if host == "" || u.Path == "" {
+		return nil, fmt.Errorf("incomplete remote URL")
+	}
+	return u, nil
+}
+
+// Rewrite returns u in the given protocol. sshHost, an ~/.ssh/config alias
+// such as github-work, replaces the host of ssh remotes when it stands for
+// the same host; https remotes always use the real host behind an alias. A
+// remote already in protocol keeps its scheme, user and port.
+func (u *URL) Rewrite(protocol, sshHost string) string {
+	switch protocol {
+	case SSH:
+		host := u.Host
+		if sshHost != "" && strings.EqualFold(ResolveHost(sshHost), ResolveHost(u.Host)) {
+			host = sshHost
+		}
+		if u.Protocol == SSH {
+			rewritten := *u
+			rewritten.Host = host
+			return rewritten.String()
+		}
+		return "git@" + host + ":" + u.Path + u.Suffix
+	case HTTPS:
+		if u.Protocol == HTTPS {
+			return u.String()
+		}
+		return "https://" + ResolveHost(u.Host) + "/" + u.Path + u.Suffix
+	}
+	return u.String()
+}
+
+// String formats u as it was written
+func (u *URL) String() string {
+	host := u.Host
+	if u.User != "" {
+		host = u.User + "@" + host
+	}
+	if u.Scheme == "" {
+		if u.Protocol == SSH {
+			return host + ":" + u.Path + u.Suffix
+		}
+		return "https://" + host + "/" + u.Path + u.Suffix
+	}
+	if u.Port != "" {
+		host += ":" + u.Port
+	}
+	return u.Scheme + "://" + host + "/" + u.Path + u.Suffix
+}
+
+// SSHDestination is what to give ssh to reach the host of u as its user,
+// git unless the remote names another, on its port
+func (u *URL) SSHDestination() string {
+	user := u.User
+	if user == "" {
+		user = "git"
+	}
+	if u.Port != "" {
+		return "ssh://" + user + "@" + u.Host + ":" + u.Port
+	}
+	return user + "@" + u.Host
+}
+
+// ResolveHost returns the real hostname behind an ssh host alias
+var ResolveHost = func(alias string) string {
+	out, err := exec.Command("ssh", "-G", alias).Output()
+	if err != nil {
+		return alias
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if host, ok := strings.CutPrefix(line, "hostname "); ok {
+			return strings.TrimSpace(host)
+		}
+	}
+	return alias
+}
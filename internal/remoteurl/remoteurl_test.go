@@ -0,0 +1,60 @@
+package remoteurl
+
+import "testing"
+
+func TestRewrite(t *testing.T) {
+	ResolveHost = func(alias string) string {
+		switch alias {
+		case "github-work":
+			return "github.com"
+		case "gitlab-work":
+			return "gitlab.com"
+		}
+		return alias
+	}
+
+	for _, tc := range []struct {
+		remote, protocol, sshHost, want string
+	}{
+		{"https://github.com/org/x", SSH, "github-work", "git@github-work:org/x"},
+		{"https://github.com/org/x.git", SSH, "", "git@github.com:org/x.git"},
+		{"git@github-work:org/x.git", HTTPS, "", "https://github.com/org/x.git"},
+		{"ssh://git@gitlab.com/group/sub/x.git", SSH, "gitlab-work", "ssh://git@gitlab-work/group/sub/x.git"},
+		{"git@github.com:org/x.git", SSH, "", "git@github.com:org/x.git"},
+		{"https://gitlab.com/org/x", SSH, "github-work", "git@gitlab.com:org/x"},
+		{"ssh://git@github.com:2222/org/x.git", SSH, "github-work", "ssh://git@github-work:2222/org/x.git"},
+		{"ssh://deploy@gitlab.com:2222/org/x.git", SSH, "github-work", "ssh://deploy@gitlab.com:2222/org/x.git"},
+		{"http://ci@git.example.com:8080/org/x", HTTPS, "", "http://ci@git.example.com:8080/org/x"},
+	} {
+		u, err := Parse(tc.remote)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tc.remote, err)
+		}
+		if got := u.Rewrite(tc.protocol, tc.sshHost); got != tc.want {
+			t.Errorf("Rewrite(%q, %s, %q) = %q, want %q", tc.remote, tc.protocol, tc.sshHost, got, tc.want)
+		}
+	}
+}
+
+func TestParseRejectsLocalPaths(t *testing.T) {
+	for _, remote := range []string{"/srv/git/x.git", "../x", "file:///srv/git/x.git"} {
+		if _, err := Parse(remote); err == nil {
+			t.Errorf("expected %q to be rejected", remote)
+		}
+	}
+}
+
+func TestStringReproducesTheRemote(t *testing.T) {
+	for _, remote := range []string{
+		"git@github.com:org/x.git", "github-work:org/x", "ssh://git@host:2222/org/x.git",
+		"https://user@host/org/x", "http://host:8080/org/x.git", "git+ssh://git@host/org/x",
+	} {
+		u, err := Parse(remote)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", remote, err)
+		}
+		if got := u.String(); got != remote {
+			t.Errorf("String() = %q, want %q", got, remote)
+		}
+	}
+}
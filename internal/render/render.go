@@ -0,0 +1,226 @@
+// Package render writes command output as styled text, plain text or JSON
+// from the same data
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Format selects how output is written
+type Format int
+
+const (
+	Styled Format = iota // text with terminal colors
+	Plain                // text without escape codes
+	JSON                 // the data value, encoded as JSON
+)
+
+var (
+	HeaderStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("170"))
+	DimStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	SuccessStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	WarnStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("208"))
+)
+
+// Renderer writes blocks of output in one format
+type Renderer struct {
+	w      io.Writer
+	format Format
+}
+
+// New returns a renderer writing to w
+func New(w io.Writer, format Format) *Renderer {
+	return &Renderer{w: w, format: format}
+}
+
+// Format returns the output format of the renderer
+func (r *Renderer) Format() Format {
+	return r.format
+}
+
+// Render writes data as JSON, or the blocks as text
+func (r *Renderer) Render(data any, blocks ...Block) error {
+	if r.format == JSON {
+		enc := json.NewEncoder(r.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	}
+	for _, b := range blocks {
+		b.write(r)
+	}
+	return nil
+}
+
+// Style applies s to text unless the output is plain
+func (r *Renderer) Style(s lipgloss.Style, text string) string {
+	if r.format == Plain || text == "" {
+		return text
+	}
+	return s.Render(text)
+}
+
+// Color applies the foreground color (an ANSI code or #rrggbb) to text unless
+// the output is plain
+func (r *Renderer) Color(color, text string) string {
+	return r.Style(lipgloss.NewStyle().Foreground(lipgloss.Color(color)), text)
+}
+
+// Block is one piece of text output
+type Block interface {
+	write(r *Renderer)
+}
+
+// Header is a section title followed by a blank line
+type Header string
+
+func (h Header) write(r *Renderer) {
+	fmt.Fprintln(r.w, r.Style(HeaderStyle, string(h)))
+	fmt.Fprintln(r.w)
+}
+
+// Line is a single line of text; an empty Line is a blank line
+type Line string
+
+func (l Line) write(r *Renderer) {
+	fmt.Fprintln(r.w, string(l))
+}
+
+// Note is a dimmed hint line
+type Note string
+
+func (n Note) write(r *Renderer) {
+	fmt.Fprintln(r.w, r.Style(DimStyle, string(n)))
+}
+
+// Item is a list entry with dimmed detail lines beneath it
+type Item struct {
+	Marker string // e.g. "1." or "★", details align after it
+	Text   string
+	Detail []string
+}
+
+// List is an indented sequence of items
+type List []Item
+
+func (l List) write(r *Renderer) {
+	for _, item := range l {
+		indent := "  "
+		if item.Marker != "" {
+			fmt.Fprintf(r.w, "  %s %s\n", item.Marker, item.Text)
+			indent += strings.Repeat(" ", utf8.RuneCountInString(item.Marker)+1)
+		} else {
+			fmt.Fprintf(r.w, "  %s\n", item.Text)
+			indent += "  "
+		}
+		for _, d := range item.Detail {
+			fmt.Fprintf(r.w, "%s%s\n", indent, r.Style(DimStyle, d))
+		}
+	}
+}
+
+// Table is rows of left-aligned columns
+type Table struct {
+	Rows [][]string
+	Sep  string // column separator, two spaces when empty
+}
+
+func (t Table) write(r *Renderer) {
+	sep := t.Sep
+	if sep == "" {
+		sep = "  "
+	}
+	var widths []int
+	for _, row := range t.Rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], lipgloss.Width(cell))
+		}
+	}
+	for _, row := range t.Rows {
+		var b strings.Builder
+		b.WriteString("  ")
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteString(sep)
+			}
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-lipgloss.Width(cell)))
+			}
+		}
+		fmt.Fprintln(r.w, b.String())
+	}
+}
+
+// KV is a list of key/value pairs
+type KV [][2]string
+
+func (kv KV) write(r *Renderer) {
+	for _, pair := range kv {
+		fmt.Fprintf(r.w, "  %s: %s\n", pair[0], pair[1])
+	}
+}
+
+// Bar is one labelled value of a bar chart. A bar with Parts is drawn as
+// colored segments that add up to Value.
+type Bar struct {
+	Label string
+	Value int
+	Parts []BarPart
+}
+
+// BarPart is one colored segment of a bar; without a Color it is dimmed
+type BarPart struct {
+	Value int
+	Color string
+}
+
+// Bars is a horizontal bar chart scaled to its largest value
+type Bars []Bar
+
+const maxBarWidth = 30
+
+func (bars Bars) write(r *Renderer) {
+	maxValue := 0
+	for _, b := range bars {
+		maxValue = max(maxValue, b.Value)
+	}
+	for _, b := range bars {
+		barLen := 0
+		if maxValue > 0 {
+			barLen = b.Value * maxBarWidth / maxValue
+		}
+		bar := r.Style(DimStyle, strings.Repeat("█", barLen))
+		if len(b.Parts) > 0 && maxValue > 0 {
+			bar = b.segments(r, maxValue)
+		}
+		fmt.Fprintf(r.w, "  %s %s %s\n", b.Label, bar, r.Style(DimStyle, fmt.Sprintf("%d", b.Value)))
+	}
+}
+
+// segments draws the parts of b, sizing each from the running total so the
+// rounded segments add up to the length of the whole bar
+func (b Bar) segments(r *Renderer, maxValue int) string {
+	var sb strings.Builder
+	total, drawn := 0, 0
+	for _, part := range b.Parts {
+		total += part.Value
+		end := total * maxBarWidth / maxValue
+		segment := strings.Repeat("█", end-drawn)
+		if part.Color == "" {
+			sb.WriteString(r.Style(DimStyle, segment))
+		} else {
+			sb.WriteString(r.Color(part.Color, segment))
+		}
+		drawn = end
+	}
+	return sb.String()
+}
@@ -0,0 +1,55 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPlainBlocks(t *testing.T) {
+	var out bytes.Buffer
+	r := New(&out, Plain)
+	err := r.Render(nil,
+		Header("Identities:"),
+		List{{Marker: "1.", Text: "A <a@example.com>", Detail: []string{"~/.gitconfig"}}},
+		Table{Rows: [][]string{{"github.com/acme", "a@example.com"}, {"~/work", "b@example.com"}}, Sep: " → "},
+		KV{{"auto_apply", "off"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Identities:\n\n" +
+		"  1. A <a@example.com>\n" +
+		"     ~/.gitconfig\n" +
+		"  github.com/acme → a@example.com\n" +
+		"  ~/work          → b@example.com\n" +
+		"  auto_apply: off\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
+
+func TestJSONIgnoresBlocks(t *testing.T) {
+	var out bytes.Buffer
+	r := New(&out, JSON)
+	if err := r.Render(map[string]int{"total": 3}, Header("ignored")); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "{\n  \"total\": 3\n}\n" {
+		t.Fatalf("unexpected JSON: %q", out.String())
+	}
+}
+
+func TestBarPartsAddUpToTheBar(t *testing.T) {
+	var out bytes.Buffer
+	r := New(&out, Plain)
+	r.Render(nil, Bars{
+		{Label: "Mon", Value: 3, Parts: []BarPart{{Value: 1, Color: "39"}, {Value: 1}, {Value: 1, Color: "42"}}},
+		{Label: "Tue", Value: 1, Parts: []BarPart{{Value: 1, Color: "39"}}},
+	})
+	want := "  Mon " + strings.Repeat("█", 30) + " 3\n" +
+		"  Tue " + strings.Repeat("█", 10) + " 1\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
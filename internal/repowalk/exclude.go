@@ -0,0 +1,62 @@
+package repowalk
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Excluded reports whether path or one of its parents matches one of
+// patterns, which are written like .gitignore lines: a pattern without a
+// slash matches a directory name anywhere (node_modules), ** stands for any
+// number of directories (**/vendor/**), and patterns that start with / or ~/
+// are anchored there (~/work/legacy/**). Other patterns match anywhere.
+func Excluded(dir string, patterns []string) bool {
+	if len(patterns) == 0 || !filepath.IsAbs(dir) {
+		return false
+	}
+	segments := strings.Split(strings.Trim(filepath.ToSlash(dir), "/"), "/")
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+		switch {
+		case pattern == "":
+			continue
+		case strings.HasPrefix(pattern, "~/"):
+			home, _ := os.UserHomeDir()
+			pattern = filepath.ToSlash(home) + pattern[1:]
+		case !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "**/"):
+			pattern = "**/" + pattern
+		}
+		want := strings.Split(strings.Trim(pattern, "/"), "/")
+		for n := len(segments); n > 0; n-- {
+			if matchSegments(want, segments[:n]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, where **
+// matches any number of segments and the others are globs
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,101 @@
+package repowalk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Limits on one git invocation in a repo, so a single pathological repo (huge
+// history, hung filesystem) cannot stall a command that visits many
+var (
+	GitTimeout   = 30 * time.Second
+	GitMaxOutput = 256 << 20
+)
+
+// Errors returned by Git when a limit is hit
+var (
+	ErrTimeout     = errors.New("git timed out")
+	ErrOutputLimit = errors.New("git output too large")
+)
+
+// Git runs git -C repo args with env (the process environment when nil) and
+// returns its output. It is killed after GitTimeout or once it has printed
+// more than GitMaxOutput bytes.
+func Git(repo string, env []string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), GitTimeout)
+	defer cancel()
+
+	out := &limitedBuffer{max: GitMaxOutput, cancel: cancel}
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", repo}, args...)...)
+	cmd.Env = env
+	cmd.Stdout = out
+	cmd.WaitDelay = time.Second // don't wait on children holding the pipe open
+	err := cmd.Run()
+	switch {
+	case out.overflow:
+		return nil, fmt.Errorf("%w (over %d MiB)", ErrOutputLimit, GitMaxOutput>>20)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, fmt.Errorf("%w after %s", ErrTimeout, GitTimeout)
+	case err != nil:
+		return nil, err
+	}
+	return out.buf.Bytes(), nil
+}
+
+// limitedBuffer collects output up to max bytes and cancels the command
+// writing to it when there is more. The buffer is not embedded so io.Copy
+// cannot bypass Write through its ReadFrom.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	overflow bool
+	cancel   func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.max {
+		b.overflow = true
+		b.cancel()
+		return 0, ErrOutputLimit
+	}
+	return b.buf.Write(p)
+}
+
+// Failed records a repo whose git invocation failed, for listing next to the
+// paths a walk skipped; ordinary git errors are not worth listing
+func Failed(repo string, err error) (Skipped, bool) {
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrOutputLimit) {
+		return Skipped{Path: repo, Reason: err.Error()}, true
+	}
+	return Skipped{}, false
+}
+
+// GitDir returns the git directory holding the local config of repo: its
+// .git directory, or where the .git file of a worktree or submodule leads.
+// It is "" when repo is no repository.
+func GitDir(repo string) string {
+	git := filepath.Join(repo, ".git")
+	info, err := os.Stat(git)
+	switch {
+	case err != nil:
+		return ""
+	case info.IsDir():
+		return git
+	}
+	out, err := Git(repo, GitEnv(), "rev-parse", "--git-common-dir")
+	if err != nil {
+		return ""
+	}
+	dir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repo, dir)
+	}
+	return dir
+}
@@ -0,0 +1,59 @@
+package repowalk
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGitEnforcesLimits(t *testing.T) {
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v (%s)", err, out)
+	}
+
+	out, err := Git(repo, nil, "rev-parse", "--git-dir")
+	if err != nil || len(out) == 0 {
+		t.Fatalf("Git = %q, %v; want output", out, err)
+	}
+
+	defer func(max int) { GitMaxOutput = max }(GitMaxOutput)
+	GitMaxOutput = 3
+	if _, err := Git(repo, nil, "rev-parse", "--git-dir"); !errors.Is(err, ErrOutputLimit) {
+		t.Fatalf("err = %v, want ErrOutputLimit", err)
+	}
+
+	defer func(timeout time.Duration) { GitTimeout = timeout }(GitTimeout)
+	GitTimeout = time.Nanosecond
+	_, err = Git(repo, nil, "rev-parse", "--git-dir")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	if s, ok := Failed(repo, err); !ok || s.Path != repo {
+		t.Fatalf("Failed = %+v, %v; want the repo listed", s, ok)
+	}
+}
+
+func TestGitDirFollowsWorktrees(t *testing.T) {
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", repo},
+		{"-C", repo, "-c", "user.email=me@example.com", "-c", "user.name=Me", "commit", "-q", "--allow-empty", "-m", "c"},
+		{"-C", repo, "worktree", "add", "-q", filepath.Join(repo, "wt")},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v (%s)", args, err, out)
+		}
+	}
+
+	want, _ := filepath.EvalSymlinks(filepath.Join(repo, ".git"))
+	got, _ := filepath.EvalSymlinks(GitDir(filepath.Join(repo, "wt")))
+	if got != want {
+		t.Errorf("GitDir(worktree) = %q, want %q", got, want)
+	}
+	if GitDir(filepath.Join(repo, "missing")) != "" {
+		t.Error("expected no git dir for a path that is no repo")
+	}
+}
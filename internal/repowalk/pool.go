@@ -0,0 +1,49 @@
+package repowalk
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Workers bounds how many repos Map and Collect work on at once
+var Workers = runtime.NumCPU()
+
+// Map runs fn on each of repos, Workers at a time, and sends the results in
+// the order they finish; the channel closes after the last one
+func Map[T any](repos []string, fn func(repo string) T) <-chan T {
+	results := make(chan T)
+	go func() {
+		each(len(repos), func(i int) { results <- fn(repos[i]) })
+		close(results)
+	}()
+	return results
+}
+
+// Collect runs fn on each of repos like Map and returns the results in the
+// order of repos, for callers whose output must not depend on timing
+func Collect[T any](repos []string, fn func(repo string) T) []T {
+	values := make([]T, len(repos))
+	each(len(repos), func(i int) { values[i] = fn(repos[i]) })
+	return values
+}
+
+// each calls fn with 0 to n-1 on Workers goroutines and returns when all
+// calls have
+func each(n int, fn func(i int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range max(1, min(Workers, n)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
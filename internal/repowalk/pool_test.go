@@ -0,0 +1,43 @@
+package repowalk
+
+import (
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolIsBoundedAndKeepsOrder(t *testing.T) {
+	defer func(n int) { Workers = n }(Workers)
+	Workers = 2
+
+	repos := []string{"a", "b", "c", "d", "e", "f"}
+	var running, most atomic.Int32
+	upper := func(repo string) string {
+		n := running.Add(1)
+		for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		return strings.ToUpper(repo)
+	}
+
+	if got := Collect(repos, upper); !slices.Equal(got, []string{"A", "B", "C", "D", "E", "F"}) {
+		t.Errorf("Collect = %v, want the results in the order of the repos", got)
+	}
+	var mapped []string
+	for s := range Map(repos, upper) {
+		mapped = append(mapped, s)
+	}
+	slices.Sort(mapped)
+	if !slices.Equal(mapped, []string{"A", "B", "C", "D", "E", "F"}) {
+		t.Errorf("Map sent %v, want every result once", mapped)
+	}
+	if most.Load() > 2 {
+		t.Errorf("%d repos worked on at once, want at most 2", most.Load())
+	}
+	if got := Collect(nil, upper); len(got) != 0 {
+		t.Errorf("Collect(nil) = %v, want nothing", got)
+	}
+}
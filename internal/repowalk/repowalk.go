@@ -0,0 +1,171 @@
+// Package repowalk finds git repositories below workspace directories
+package repowalk
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// repoEnv lists the variables that point git at one particular repository
+var repoEnv = []string{
+	"GIT_DIR",
+	"GIT_WORK_TREE",
+	"GIT_COMMON_DIR",
+	"GIT_INDEX_FILE",
+	"GIT_OBJECT_DIRECTORY",
+	"GIT_ALTERNATE_OBJECT_DIRECTORIES",
+}
+
+// GitEnv returns the environment for running git in a walked repository.
+// GIT_DIR and friends are dropped: they name the caller's repository and
+// would otherwise redirect every command to it.
+func GitEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if !slices.Contains(repoEnv, key) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// Skipped is a path the walk could not read
+type Skipped struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Walker walks directory trees looking for repositories. It records every
+// path it could not read instead of silently ignoring it, and follows
+// symlinks while visiting each real directory only once, so linked
+// workspaces are neither missed nor counted twice and link cycles end.
+type Walker struct {
+	Skipped []Skipped
+	// Visit, when set, is called with every directory the walk enters and
+	// how many levels below it are walked
+	Visit func(dir string, depth int)
+	// Exclude are patterns of directories the walk never enters, see
+	// Excluded
+	Exclude []string
+	seen    map[string]bool
+	visited map[string]bool // resolved paths
+}
+
+// Walk calls fn for root and every repository below it, up to maxDepth
+// levels deep. Repositories are descended into as well, to find nested ones.
+func (w *Walker) Walk(root string, maxDepth int, fn func(repo string)) {
+	if Excluded(root, w.Exclude) || !w.visit(root) {
+		return
+	}
+	if w.Visit != nil {
+		w.Visit(root, maxDepth)
+	}
+	if w.IsRepo(root) {
+		fn(root)
+	}
+	w.walk(root, maxDepth, fn)
+}
+
+func (w *Walker) walk(dir string, maxDepth int, fn func(repo string)) {
+	if maxDepth <= 0 {
+		return
+	}
+	for _, sub := range w.Dirs(dir) {
+		if !w.visit(sub) {
+			continue
+		}
+		if w.Visit != nil {
+			w.Visit(sub, maxDepth-1)
+		}
+		if w.IsRepo(sub) {
+			fn(sub)
+		}
+		w.walk(sub, maxDepth-1, fn)
+	}
+}
+
+// Rewind forgets the visited directories so the same trees can be walked
+// again; skipped paths are kept
+func (w *Walker) Rewind() {
+	w.visited = nil
+}
+
+// Dirs returns the subdirectories of dir, including symlinks to directories,
+// that are not excluded
+func (w *Walker) Dirs(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.skip(dir, err)
+		return nil
+	}
+	var dirs []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if Excluded(path, w.Exclude) {
+			continue
+		}
+		if entry.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(path)
+			if err != nil {
+				w.skip(path, err)
+				continue
+			}
+			if !info.IsDir() {
+				continue
+			}
+		} else if !entry.IsDir() {
+			continue
+		}
+		dirs = append(dirs, path)
+	}
+	return dirs
+}
+
+// IsRepo reports whether dir contains a .git entry
+func (w *Walker) IsRepo(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	if err != nil {
+		w.skip(dir, err)
+		return false
+	}
+	return true
+}
+
+// visit marks the real directory behind dir as visited, reporting false if
+// it already was
+func (w *Walker) visit(dir string) bool {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		w.skip(dir, err)
+		return false
+	}
+	if w.visited[resolved] {
+		return false
+	}
+	if w.visited == nil {
+		w.visited = make(map[string]bool)
+	}
+	w.visited[resolved] = true
+	return true
+}
+
+func (w *Walker) skip(path string, err error) {
+	if errors.Is(err, fs.ErrNotExist) || w.seen[path] {
+		return
+	}
+	if w.seen == nil {
+		w.seen = make(map[string]bool)
+	}
+	w.seen[path] = true
+	reason := err.Error()
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		reason = pathErr.Err.Error()
+	}
+	w.Skipped = append(w.Skipped, Skipped{Path: path, Reason: reason})
+}
@@ -0,0 +1,106 @@
+package repowalk
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWalkFindsNestedRepos(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a/.git", "a/sub/.git", "group/b/.git", "plain"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var w Walker
+	var repos []string
+	w.Walk(root, 3, func(repo string) {
+		rel, _ := filepath.Rel(root, repo)
+		repos = append(repos, rel)
+	})
+	slices.Sort(repos)
+
+	want := []string{"a", filepath.Join("a", "sub"), filepath.Join("group", "b")}
+	if !slices.Equal(repos, want) {
+		t.Errorf("expected %v, got %v", want, repos)
+	}
+	if len(w.Skipped) != 0 {
+		t.Errorf("expected nothing skipped, got %v", w.Skipped)
+	}
+}
+
+func TestWalkRecordsUnreadableDirs(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	root := t.TempDir()
+	locked := filepath.Join(root, "locked")
+	if err := os.Mkdir(locked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+
+	var w Walker
+	w.Walk(root, 3, func(string) {})
+
+	if len(w.Skipped) != 1 || w.Skipped[0].Path != locked {
+		t.Fatalf("expected %s to be skipped, got %v", locked, w.Skipped)
+	}
+}
+
+func TestWalkFollowsSymlinksOnce(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "volume", "Projects")
+	if err := os.MkdirAll(filepath.Join(target, "app", ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	workspace := filepath.Join(root, "home", "Projects")
+	os.MkdirAll(filepath.Dir(workspace), 0755)
+	links := map[string]string{
+		workspace:                         target,                       // symlinked workspace
+		filepath.Join(target, "app-link"): filepath.Join(target, "app"), // same repo twice
+		filepath.Join(target, "loop"):     target,                       // cycle
+	}
+	for link, dest := range links {
+		if err := os.Symlink(dest, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var w Walker
+	var repos []string
+	for _, dir := range []string{workspace, target} {
+		w.Walk(dir, 4, func(repo string) { repos = append(repos, repo) })
+	}
+
+	if len(repos) != 1 {
+		t.Fatalf("expected the repo once, got %v", repos)
+	}
+}
+
+func TestWalkSkipsExcludedDirs(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", root)
+	for _, dir := range []string{"app/.git", "app/node_modules/dep/.git", "legacy/old/.git", "tools/vendor/lib/.git"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := Walker{Exclude: []string{"node_modules", "~/legacy/**", "**/vendor/**"}}
+	var repos []string
+	w.Walk(root, 4, func(repo string) { repos = append(repos, repo) })
+
+	if want := []string{filepath.Join(root, "app")}; !slices.Equal(repos, want) {
+		t.Errorf("repos = %v, want %v", repos, want)
+	}
+	if !Excluded(filepath.Join(root, "legacy"), w.Exclude) || Excluded(filepath.Join(root, "app"), w.Exclude) {
+		t.Error("expected only ~/legacy and below to be excluded")
+	}
+}
@@ -0,0 +1,232 @@
+// Package rewrite rewrites who git history names as author and committer by
+// piping git fast-export through a filter into git fast-import, which is far
+// faster than git filter-branch and leaves no refs/original backups
+package rewrite
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Person is a name and email as commits record them
+type Person struct {
+	Name  string
+	Email string
+}
+
+// AllRefs are what a rewrite without Options.Refs covers: branches and tags.
+// Remote-tracking refs are left alone; they must keep naming the commits the
+// remote has, or a later force push could not tell what it replaces.
+var AllRefs = []string{"--branches", "--tags"}
+
+// Options limits which history a rewrite touches
+type Options struct {
+	Refs []string // branches to rewrite; empty means AllRefs
+	// Revs are further rev-list arguments limiting the commits rewritten,
+	// e.g. ^origin/main or --since=<date>; commits they leave out keep
+	// their hashes and stay parents of the rewritten ones
+	Revs []string
+	// Paths are pathspecs; when set, only commits touching them are
+	// rewritten and the others keep their people
+	Paths []string
+	Env   []string // environment git runs in, the process environment when nil
+}
+
+// Email makes every commit of repo whose author or committer email is old
+// (case-insensitively) name to instead, and returns how many commits changed.
+// Taggers of annotated tags are rewritten the same way.
+func Email(repo, old string, to Person, opts Options) (int, error) {
+	if strings.ContainsAny(to.Name, "<>\n") || strings.ContainsAny(to.Email, "<>\n") {
+		return 0, fmt.Errorf("invalid identity %q <%s>", to.Name, to.Email)
+	}
+	return Run(repo, opts, func(p Person) (Person, bool) {
+		if strings.EqualFold(p.Email, old) {
+			return to, true
+		}
+		return p, false
+	})
+}
+
+// Run rewrites the people of the history opts selects with fn, which reports
+// whether it changed a person, and returns how many commits changed
+func Run(repo string, opts Options, fn func(Person) (Person, bool)) (int, error) {
+	refs := opts.Refs
+	if len(refs) == 0 {
+		refs = AllRefs
+	}
+	args := []string{"-C", repo, "fast-export", "--signed-tags=strip", "--tag-of-filtered-object=rewrite",
+		"--reencode=no", "--reference-excluded-parents"}
+	var only func(oid string) bool
+	if len(opts.Paths) > 0 {
+		commits, err := touching(repo, slices.Concat(refs, opts.Revs), opts)
+		if err != nil {
+			return 0, err
+		}
+		only = func(oid string) bool { return commits[oid] }
+		args = append(args, "--show-original-ids")
+	}
+	export := exec.Command("git", slices.Concat(args, refs, opts.Revs)...)
+	export.Env = opts.Env
+	var exportErr bytes.Buffer
+	export.Stderr = &exportErr
+	stream, err := export.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+
+	imp := exec.Command("git", "-C", repo, "fast-import", "--force", "--quiet")
+	imp.Env = opts.Env
+	var importErr bytes.Buffer
+	imp.Stdout, imp.Stderr = &importErr, &importErr
+	sink, err := imp.StdinPipe()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := export.Start(); err != nil {
+		return 0, fmt.Errorf("starting git fast-export: %w", err)
+	}
+	if err := imp.Start(); err != nil {
+		export.Process.Kill()
+		export.Wait()
+		return 0, fmt.Errorf("starting git fast-import: %w", err)
+	}
+
+	// fast-import updates refs when its input ends, so a stream cut short
+	// must kill it rather than end its input, or branches would lose commits
+	changed, filterErr := filter(stream, sink, fn, only)
+	if filterErr != nil {
+		export.Process.Kill()
+	}
+	exportWait := export.Wait()
+	if filterErr != nil || exportWait != nil {
+		imp.Process.Kill()
+	}
+	sink.Close()
+	importWait := imp.Wait()
+	switch {
+	case exportWait != nil && filterErr == nil:
+		return 0, fmt.Errorf("git fast-export: %w: %s", exportWait, strings.TrimSpace(exportErr.String()))
+	case importWait != nil && importErr.Len() > 0:
+		return 0, fmt.Errorf("git fast-import: %w: %s", importWait, strings.TrimSpace(importErr.String()))
+	case filterErr != nil:
+		return 0, filterErr
+	case importWait != nil:
+		return 0, fmt.Errorf("git fast-import: %w", importWait)
+	}
+	return changed, nil
+}
+
+// touching returns the commits revs selects that touch the paths of opts
+func touching(repo string, revs []string, opts Options) (map[string]bool, error) {
+	cmd := exec.Command("git", slices.Concat([]string{"-C", repo, "rev-list"}, revs, []string{"--"}, opts.Paths)...)
+	cmd.Env = opts.Env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git rev-list: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	commits := make(map[string]bool)
+	for _, oid := range strings.Fields(string(out)) {
+		commits[oid] = true
+	}
+	return commits, nil
+}
+
+// Filter copies a fast-export stream from r to w with the author, committer
+// and tagger lines passed through fn, and returns how many commits it changed
+func Filter(r io.Reader, w io.Writer, fn func(Person) (Person, bool)) (int, error) {
+	return filter(r, w, fn, nil)
+}
+
+// filter is Filter rewriting only the objects whose original-oid line only
+// accepts, or every object when only is nil
+func filter(r io.Reader, w io.Writer, fn func(Person) (Person, bool), only func(oid string) bool) (int, error) {
+	in := bufio.NewReader(r)
+	out := bufio.NewWriter(w)
+	changed := 0
+	inCommit, commitChanged := false, false
+	skip := only != nil
+	endCommit := func() {
+		if inCommit && commitChanged {
+			changed++
+		}
+		inCommit, commitChanged = false, false
+		skip = only != nil
+	}
+
+	for {
+		line, err := in.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("reading git fast-export: %w", err)
+		}
+
+		switch {
+		case strings.HasPrefix(line, "commit "):
+			endCommit()
+			inCommit = true
+		case strings.HasPrefix(line, "original-oid "):
+			skip = only != nil && !only(strings.TrimSpace(strings.TrimPrefix(line, "original-oid ")))
+		case !skip && (strings.HasPrefix(line, "author ") || strings.HasPrefix(line, "committer ") || strings.HasPrefix(line, "tagger ")):
+			var ok bool
+			line, ok, err = rewriteLine(line, fn)
+			if err != nil {
+				return 0, err
+			}
+			commitChanged = commitChanged || ok && inCommit
+		case strings.HasPrefix(line, "data "):
+			// Messages and blobs are copied as they are, whatever they hold
+			if _, err := out.WriteString(line); err != nil {
+				return 0, err
+			}
+			n, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "data ")), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("unexpected fast-export data line %q", strings.TrimSpace(line))
+			}
+			if _, err := io.CopyN(out, in, n); err != nil {
+				return 0, fmt.Errorf("reading git fast-export: %w", err)
+			}
+			continue
+		case strings.HasPrefix(line, "blob"), strings.HasPrefix(line, "tag "), strings.HasPrefix(line, "reset "):
+			endCommit()
+		}
+		if _, err := out.WriteString(line); err != nil {
+			return 0, err
+		}
+	}
+	endCommit()
+	if err := out.Flush(); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+// rewriteLine passes the person of an author, committer or tagger line
+// ("author Name <email> 1700000000 +0100", the name may be missing) through fn
+func rewriteLine(line string, fn func(Person) (Person, bool)) (string, bool, error) {
+	kind, rest, _ := strings.Cut(line, " ")
+	lt, gt := strings.LastIndex(rest, "<"), strings.LastIndex(rest, ">")
+	if lt < 0 || gt < lt {
+		return "", false, errors.New("unexpected fast-export line " + strconv.Quote(strings.TrimSpace(line)))
+	}
+	p, ok := fn(Person{Name: strings.TrimSuffix(rest[:lt], " "), Email: rest[lt+1 : gt]})
+	if !ok {
+		return line, false, nil
+	}
+	person := "<" + p.Email + ">"
+	if p.Name != "" {
+		person = p.Name + " " + person
+	}
+	return kind + " " + person + rest[gt+1:], true, nil
+}
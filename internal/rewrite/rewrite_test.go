@@ -0,0 +1,145 @@
+package rewrite
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFilterRewritesPeopleAndKeepsData(t *testing.T) {
+	msg := "author Old <old@example.com> 1 +0000\n"
+	stream := "blob\nmark :1\ndata 5\nhello\n" +
+		"commit refs/heads/main\nmark :2\n" +
+		"author Old <OLD@example.com> 1700000000 +0100\n" +
+		"committer Other <other@example.com> 1700000000 +0100\n" +
+		"data " + strconv.Itoa(len(msg)) + "\n" + msg + "M 100644 :1 a.txt\n\n" +
+		"commit refs/heads/main\nmark :3\n" +
+		"author <other@example.com> 1700000001 +0100\n" +
+		"committer <other@example.com> 1700000001 +0100\n" +
+		"data 2\nx\nfrom :2\n\n"
+
+	var out bytes.Buffer
+	changed, err := Filter(strings.NewReader(stream), &out, func(p Person) (Person, bool) {
+		if strings.EqualFold(p.Email, "old@example.com") {
+			return Person{Name: "New", Email: "new@example.com"}, true
+		}
+		return p, false
+	})
+	if err != nil {
+		t.Fatalf("Filter failed: %v", err)
+	}
+	if changed != 1 {
+		t.Fatalf("expected 1 changed commit, got %d", changed)
+	}
+	want := strings.Replace(stream, "author Old <OLD@example.com>", "author New <new@example.com>", 1)
+	if out.String() != want {
+		t.Fatalf("unexpected stream:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestEmailRewritesBranchesAndTags(t *testing.T) {
+	repo := t.TempDir()
+	git := func(env []string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(os.Environ(), env...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v (%s)", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	as := func(email string) []string {
+		return []string{"GIT_AUTHOR_NAME=A", "GIT_AUTHOR_EMAIL=" + email, "GIT_COMMITTER_NAME=A", "GIT_COMMITTER_EMAIL=" + email}
+	}
+	git(nil, "init", "-q", "-b", "main")
+	git(as("keep@example.com"), "commit", "-q", "--allow-empty", "-m", "first")
+	first := git(nil, "rev-parse", "HEAD")
+	git(as("old@example.com"), "commit", "-q", "--allow-empty", "-m", "second")
+	git(as("old@example.com"), "tag", "-a", "v1", "-m", "release")
+	git(as("keep@example.com"), "commit", "-q", "--allow-empty", "-m", "third")
+	git(nil, "update-ref", "refs/remotes/origin/main", "HEAD")
+	fetched := git(nil, "rev-parse", "HEAD")
+
+	changed, err := Email(repo, "old@example.com", Person{Name: "New", Email: "new@example.com"}, Options{})
+	if err != nil {
+		t.Fatalf("Email failed: %v", err)
+	}
+	if changed != 1 {
+		t.Fatalf("expected 1 changed commit, got %d", changed)
+	}
+	if got := git(nil, "log", "--format=%an <%ae>|%cn <%ce>", "main"); strings.Contains(got, "old@example.com") || !strings.Contains(got, "New <new@example.com>|New <new@example.com>") {
+		t.Fatalf("unexpected history:\n%s", got)
+	}
+	if got := git(nil, "rev-parse", "main~2"); got != first {
+		t.Fatalf("expected the untouched first commit to keep its hash %s, got %s", first, got)
+	}
+	if got := git(nil, "log", "-1", "--format=%ae", "v1^{commit}"); got != "new@example.com" {
+		t.Fatalf("expected the tag to follow the rewritten commit, got %s", got)
+	}
+	if got := git(nil, "rev-parse", "origin/main"); got != fetched {
+		t.Fatalf("expected the remote-tracking ref left at %s, got %s", fetched, got)
+	}
+	if got := git(nil, "for-each-ref", "refs/original"); got != "" {
+		t.Fatalf("expected no backup refs, got %s", got)
+	}
+	if got := git(nil, "status", "--porcelain"); got != "" {
+		t.Fatalf("expected a clean worktree, got %s", got)
+	}
+
+	if _, err := Email(repo, "new@example.com", Person{Name: "Bad <x>", Email: "x@example.com"}, Options{}); err == nil {
+		t.Fatal("expected a name with <> to be refused")
+	}
+}
+
+func TestEmailLimitedToPaths(t *testing.T) {
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=A", "GIT_AUTHOR_EMAIL=old@example.com", "GIT_COMMITTER_NAME=A", "GIT_COMMITTER_EMAIL=old@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v (%s)", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(path string) {
+		t.Helper()
+		file := filepath.Join(repo, path)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(path+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", path)
+		git("commit", "-q", "-m", path)
+	}
+	git("init", "-q", "-b", "main")
+	commit("other/a.txt")
+	commit("team/b.txt")
+	commit("other/c.txt")
+	first := git("rev-parse", "HEAD~2")
+
+	changed, err := Email(repo, "old@example.com", Person{Name: "New", Email: "new@example.com"}, Options{Paths: []string{"team"}})
+	if err != nil {
+		t.Fatalf("Email failed: %v", err)
+	}
+	if changed != 1 {
+		t.Fatalf("expected 1 changed commit, got %d", changed)
+	}
+	if got := git("log", "--format=%ae %s"); got != "old@example.com other/c.txt\nnew@example.com team/b.txt\nold@example.com other/a.txt" {
+		t.Fatalf("unexpected history:\n%s", got)
+	}
+	if got := git("rev-parse", "HEAD~2"); got != first {
+		t.Fatalf("expected the commit before the touched one to keep its hash %s, got %s", first, got)
+	}
+	if got := git("status", "--porcelain"); got != "" {
+		t.Fatalf("expected a clean worktree, got %s", got)
+	}
+}
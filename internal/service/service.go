@@ -0,0 +1,225 @@
+// Package service installs gitme watch as a launchd agent or systemd user unit
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// Label is the launchd label of the watch agent
+	Label = "com.vosamoilenko.gitme.watch"
+	// Unit is the systemd user unit running the watcher
+	Unit = "gitme-watch.service"
+)
+
+// ErrUnsupported is returned when no service manager is available
+var ErrUnsupported = errors.New("no supported service manager (need launchd or systemd)")
+
+// ErrNotInstalled is returned when the service has not been installed
+var ErrNotInstalled = errors.New("watch service is not installed")
+
+// Status describes the installed service
+type Status struct {
+	Path    string // plist or unit file
+	Running bool
+	Detail  string // state reported by the service manager
+}
+
+// Install writes the service definition running command and starts it
+func Install(command []string) (string, error) {
+	path, err := Path()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		home, _ := os.UserHomeDir()
+		logFile := filepath.Join(home, "Library", "Logs", "gitme-watch.log")
+		if err := os.WriteFile(path, []byte(LaunchdPlist(command, os.Getenv("PATH"), logFile)), 0644); err != nil {
+			return "", err
+		}
+		// Replace a previously loaded agent so the new definition takes effect
+		run("launchctl", "bootout", launchdTarget())
+		return path, run("launchctl", "bootstrap", launchdDomain(), path)
+	case "linux":
+		if err := os.WriteFile(path, []byte(SystemdUnit(command, os.Getenv("PATH"))), 0644); err != nil {
+			return "", err
+		}
+		if err := run("systemctl", "--user", "daemon-reload"); err != nil {
+			return "", err
+		}
+		return path, run("systemctl", "--user", "enable", "--now", Unit)
+	}
+	return "", ErrUnsupported
+}
+
+// Uninstall stops the service and removes its definition
+func Uninstall() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return ErrNotInstalled
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		run("launchctl", "bootout", launchdTarget()) // fails harmlessly when not loaded
+		return os.Remove(path)
+	case "linux":
+		run("systemctl", "--user", "disable", "--now", Unit)
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		return run("systemctl", "--user", "daemon-reload")
+	}
+	return ErrUnsupported
+}
+
+// Query reports whether the service is installed and running
+func Query() (*Status, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotInstalled
+	}
+
+	status := &Status{Path: path}
+	switch runtime.GOOS {
+	case "darwin":
+		out, _ := exec.Command("launchctl", "print", launchdTarget()).Output()
+		for _, line := range strings.Split(string(out), "\n") {
+			if state, ok := strings.CutPrefix(strings.TrimSpace(line), "state = "); ok {
+				status.Detail = state
+				status.Running = state == "running"
+				break
+			}
+		}
+		if status.Detail == "" {
+			status.Detail = "not loaded"
+		}
+	case "linux":
+		out, _ := exec.Command("systemctl", "--user", "is-active", Unit).Output()
+		status.Detail = strings.TrimSpace(string(out))
+		status.Running = status.Detail == "active"
+	default:
+		return nil, ErrUnsupported
+	}
+	return status, nil
+}
+
+// Path returns where the service definition lives on this platform
+func Path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(home, "Library", "LaunchAgents", Label+".plist"), nil
+	case "linux":
+		configHome := os.Getenv("XDG_CONFIG_HOME")
+		if configHome == "" {
+			configHome = filepath.Join(home, ".config")
+		}
+		return filepath.Join(configHome, "systemd", "user", Unit), nil
+	}
+	return "", ErrUnsupported
+}
+
+// LaunchdPlist returns a launch agent that keeps command running
+func LaunchdPlist(command []string, path, logFile string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + Label + `</string>
+	<key>ProgramArguments</key>
+	<array>
+`)
+	for _, arg := range command {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", html.EscapeString(arg))
+	}
+	fmt.Fprintf(&b, `	</array>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>PATH</key>
+		<string>%s</string>
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>%s</string>
+	<key>StandardErrorPath</key>
+	<string>%s</string>
+</dict>
+</plist>
+`, html.EscapeString(path), html.EscapeString(logFile), html.EscapeString(logFile))
+	return b.String()
+}
+
+// SystemdUnit returns a user unit that keeps command running
+func SystemdUnit(command []string, path string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = systemdQuote(arg)
+	}
+	return fmt.Sprintf(`[Unit]
+Description=gitme identity watcher
+
+[Service]
+ExecStart=%s
+Environment=%s
+Restart=on-failure
+RestartSec=10
+
+[Install]
+WantedBy=default.target
+`, strings.Join(quoted, " "), systemdQuote("PATH="+path))
+}
+
+// systemdQuote quotes arg for ExecStart and Environment lines
+func systemdQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\$%") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`)
+	return `"` + r.Replace(arg) + `"`
+}
+
+func launchdDomain() string {
+	return fmt.Sprintf("gui/%d", os.Getuid())
+}
+
+func launchdTarget() string {
+	return launchdDomain() + "/" + Label
+}
+
+func run(name string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemdUnitQuotesArguments(t *testing.T) {
+	unit := SystemdUnit([]string{"/opt/my tools/gitme", "--config-dir", "/home/me/.config/gitme", "watch"}, "/usr/bin:/bin")
+
+	for _, want := range []string{
+		`ExecStart="/opt/my tools/gitme" --config-dir /home/me/.config/gitme watch`,
+		`Environment=PATH=/usr/bin:/bin`,
+		`WantedBy=default.target`,
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestLaunchdPlistEscapesArguments(t *testing.T) {
+	plist := LaunchdPlist([]string{"/usr/local/bin/gitme", "--config-dir", "/Users/me/R&D", "watch"}, "/usr/bin", "/tmp/watch.log")
+
+	for _, want := range []string{
+		"<string>" + Label + "</string>",
+		"<string>/Users/me/R&amp;D</string>",
+		"<key>KeepAlive</key>",
+		"<string>/tmp/watch.log</string>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("plist missing %q:\n%s", want, plist)
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package stats
 
 import (
-	"os/exec"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/vosamoilenko/gitme/internal/repowalk"
 )
 
 // CommitInfo holds info about a single commit
@@ -17,13 +18,13 @@ type CommitInfo struct {
 
 // IdentityStats holds statistics for one identity
 type IdentityStats struct {
-	Name        string
-	Email       string
-	CommitCount int
-	FirstCommit time.Time
-	LastCommit  time.Time
-	ByWeekday   map[time.Weekday]int
-	ByHour      map[int]int
+	Name        string               `json:"name"`
+	Email       string               `json:"email"`
+	CommitCount int                  `json:"commits"`
+	FirstCommit time.Time            `json:"first_commit"`
+	LastCommit  time.Time            `json:"last_commit"`
+	ByWeekday   map[time.Weekday]int `json:"by_weekday"`
+	ByHour      map[int]int          `json:"by_hour"`
 }
 
 // RepoStats holds all statistics for a repository
@@ -33,11 +34,29 @@ type RepoStats struct {
 	ByIdentity map[string]*IdentityStats // keyed by email
 }
 
+// Options narrows and adjusts what CollectRepoStats counts
+type Options struct {
+	Env      []string       // environment git runs with, nil = the process environment
+	Location *time.Location // zone commits are bucketed in, nil = each commit's own offset
+	Paths    []string       // only commits touching these pathspecs (relative to the repo root)
+	Since    time.Time      // only commits at or after Since, unless zero
+	Until    time.Time      // only commits before Until, unless zero
+}
+
 // CollectRepoStats gathers commit statistics for a repository
-func CollectRepoStats(repoPath string, knownEmails map[string]bool) (*RepoStats, error) {
-	// Get all commits with author info and date
-	cmd := exec.Command("git", "-C", repoPath, "log", "--format=%H|%an|%ae|%aI")
-	output, err := cmd.Output()
+func CollectRepoStats(repoPath string, knownEmails map[string]bool, opts Options) (*RepoStats, error) {
+	// Get all commits with author info and date, mapped through .mailmap
+	args := []string{"log", "--format=%H|%aN|%aE|%aI"}
+	if !opts.Since.IsZero() {
+		args = append(args, "--since="+opts.Since.Format(time.RFC3339))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, "--until="+opts.Until.Format(time.RFC3339))
+	}
+	if len(opts.Paths) > 0 {
+		args = append(append(args, "--"), opts.Paths...)
+	}
+	output, err := repowalk.Git(repoPath, opts.Env, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +88,9 @@ func CollectRepoStats(repoPath string, knownEmails map[string]bool) (*RepoStats,
 		}
 
 		date, _ := time.Parse(time.RFC3339, dateStr)
+		if opts.Location != nil {
+			date = date.In(opts.Location)
+		}
 
 		// Get or create identity stats
 		idStats, ok := stats.ByIdentity[email]
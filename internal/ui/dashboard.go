@@ -0,0 +1,287 @@
+package ui
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/vosamoilenko/gitme/internal/identity"
+	"github.com/vosamoilenko/gitme/internal/stats"
+)
+
+var (
+	tabStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	activeTabStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("170"))
+	warnStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+)
+
+// Screen is one tab of the dashboard
+type Screen int
+
+const (
+	ScreenIdentities Screen = iota
+	ScreenRepos
+	ScreenRules
+	ScreenStats
+)
+
+var screenNames = []string{"Identities", "Repos", "Rules", "Stats"}
+
+// Repo is an indexed repo as the repos screen shows it
+type Repo struct {
+	Path     string
+	Email    string // local user.email, "" when the global one applies
+	Expected string // email rules, defaults or .gitme files expect, if any
+}
+
+// Rule is a path rule as the rules screen shows it
+type Rule struct {
+	Pattern string
+	Target  string // identity the rule points to, "" for a strict-only rule
+	Strict  bool
+}
+
+// Assignment is an identity picked for a repo on the repos screen
+type Assignment struct {
+	Repo     string
+	Identity identity.Identity
+}
+
+// ReposMsg delivers the indexed repos, which are read alongside the TUI
+type ReposMsg struct {
+	Repos []Repo
+}
+
+// StatsMsg delivers the commit counts of the identities across the indexed
+// repos, which are counted alongside the TUI
+type StatsMsg struct {
+	Repos int
+	Total int
+	Stats []*stats.IdentityStats
+}
+
+// WithRules sets what the rules screen lists
+func (m Model) WithRules(rules []Rule) Model {
+	m.rules = rules
+	return m
+}
+
+// Assignments returns the identities picked for repos, latest last; one
+// repo may appear more than once
+func (m Model) Assignments() []Assignment {
+	return m.assignments
+}
+
+// switchScreen handles the keys that move between screens
+func (m Model) switchScreen(key string) (Model, bool) {
+	switch key {
+	case "tab":
+		m.screen = (m.screen + 1) % Screen(len(screenNames))
+	case "shift+tab":
+		m.screen = (m.screen + Screen(len(screenNames)) - 1) % Screen(len(screenNames))
+	case "1", "2", "3", "4":
+		m.screen = Screen(key[0] - '1')
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// updateRepos handles keys on the repos screen and in its identity picker
+func (m Model) updateRepos(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	identities := m.identities()
+	if m.assigning {
+		switch msg.String() {
+		case "up", "k":
+			m.target = max(0, m.target-1)
+		case "down", "j":
+			m.target = min(len(identities)-1, m.target+1)
+		case "enter":
+			m.assigning = false
+			repo := &m.repos[m.repoCursor]
+			id := identities[m.target]
+			m.assignments = append(m.assignments, Assignment{Repo: repo.Path, Identity: id})
+			repo.Email = id.Email
+			m.status = "assigned " + id.Email + " to " + displayPath(repo.Path)
+		case "esc":
+			m.assigning = false
+		case "ctrl+c":
+			m.quitting = true
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "q", "ctrl+c", "esc":
+		m.quitting = true
+		return m, tea.Quit
+	case "up", "k":
+		m.repoCursor = max(0, m.repoCursor-1)
+	case "down", "j":
+		m.repoCursor = max(0, min(len(m.repos)-1, m.repoCursor+1))
+	case "enter", "a":
+		if len(m.repos) > 0 && len(identities) > 0 {
+			m.assigning = true
+			// Start at the identity the repo should have, else the one it has
+			repo := m.repos[m.repoCursor]
+			want := repo.Expected
+			if want == "" {
+				want = repo.Email
+			}
+			m.target = max(0, slices.IndexFunc(identities, func(id identity.Identity) bool { return strings.EqualFold(id.Email, want) }))
+		}
+	}
+	return m, nil
+}
+
+// updateScreen handles keys on the screens other than the identities list
+func (m Model) updateScreen(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.screen == ScreenRepos {
+		return m.updateRepos(msg)
+	}
+	switch msg.String() {
+	case "q", "ctrl+c", "esc":
+		m.quitting = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// identities returns the identities of the list, in list order
+func (m Model) identities() []identity.Identity {
+	var identities []identity.Identity
+	for _, li := range m.list.Items() {
+		if i, ok := li.(item); ok {
+			identities = append(identities, i.identity)
+		}
+	}
+	return identities
+}
+
+func (m Model) tabsView() string {
+	tabs := make([]string, len(screenNames))
+	for i, name := range screenNames {
+		label := fmt.Sprintf("%d %s", i+1, name)
+		if Screen(i) == m.screen {
+			tabs[i] = activeTabStyle.Render(label)
+		} else {
+			tabs[i] = tabStyle.Render(label)
+		}
+	}
+	return titleStyle.Render("gitme") + "  " + strings.Join(tabs, tabStyle.Render(" │ "))
+}
+
+func (m Model) reposView() string {
+	var b strings.Builder
+	if m.assigning {
+		b.WriteString(titleStyle.Render("Assign to "+displayPath(m.repos[m.repoCursor].Path)+":") + "\n")
+		for i, id := range m.identities() {
+			if i == m.target {
+				b.WriteString(selectedItemStyle.Render("> "+id.String()) + "\n")
+			} else {
+				b.WriteString(itemStyle.Render(id.String()) + "\n")
+			}
+		}
+		b.WriteString("\n" + helpStyle.Render("  ↑/↓: navigate • enter: assign • esc: back") + "\n")
+		return b.String()
+	}
+
+	switch {
+	case m.repos == nil:
+		b.WriteString(currentStyle.Render("reading the repo index…") + "\n")
+	case len(m.repos) == 0:
+		b.WriteString(currentStyle.Render("No repos indexed. Run 'gitme scan' to find them.") + "\n")
+	}
+	first, last := window(m.repoCursor, len(m.repos), m.rows())
+	for i := first; i < last; i++ {
+		repo := m.repos[i]
+		str := displayPath(repo.Path) + "  " + cmp.Or(repo.Email, "(global)")
+		if repo.Expected != "" && !strings.EqualFold(repo.Email, repo.Expected) {
+			str += " " + warnStyle.Render("expects "+repo.Expected)
+		}
+		if i == m.repoCursor {
+			b.WriteString(selectedItemStyle.Render("> "+str) + "\n")
+		} else {
+			b.WriteString(itemStyle.Render(str) + "\n")
+		}
+	}
+	if len(m.repos) > last-first {
+		b.WriteString(currentStyle.Render(fmt.Sprintf("%d of %d", m.repoCursor+1, len(m.repos))) + "\n")
+	}
+	b.WriteString(m.statusView())
+	return b.String() + "\n" + helpStyle.Render("  ↑/↓: navigate • enter: assign identity • tab/1-4: screens • q: quit") + "\n"
+}
+
+func (m Model) rulesView() string {
+	var b strings.Builder
+	if len(m.rules) == 0 {
+		b.WriteString(currentStyle.Render("No rules. Add one with: gitme rule add <pattern> <email|alias>") + "\n")
+	}
+	for _, rule := range m.rules {
+		str := rule.Pattern + " → " + cmp.Or(rule.Target, "(no identity)")
+		if rule.Strict {
+			str += " " + warnStyle.Render("[strict]")
+		}
+		b.WriteString(itemStyle.Render(str) + "\n")
+	}
+	return b.String() + "\n" + helpStyle.Render("  tab/1-4: screens • q: quit") + "\n"
+}
+
+func (m Model) statsView() string {
+	var b strings.Builder
+	switch {
+	case m.stats == nil:
+		b.WriteString(currentStyle.Render("counting commits…") + "\n")
+	case m.stats.Total == 0:
+		b.WriteString(currentStyle.Render("No commits found from your known identities.") + "\n")
+	default:
+		b.WriteString(itemStyle.Render(fmt.Sprintf("%d commits across %d repos", m.stats.Total, m.stats.Repos)) + "\n\n")
+		colors := make(map[string]string)
+		for _, id := range m.identities() {
+			colors[strings.ToLower(id.Email)] = id.DisplayColor()
+		}
+		for _, s := range m.stats.Stats {
+			share := float64(s.CommitCount) / float64(m.stats.Total)
+			bar := lipgloss.NewStyle().Foreground(lipgloss.Color(colors[strings.ToLower(s.Email)])).
+				Render(strings.Repeat("█", max(1, int(share*20))))
+			b.WriteString(itemStyle.Render(fmt.Sprintf("%s %5d (%3.0f%%) %s <%s>", bar, s.CommitCount, share*100, s.Name, s.Email)) + "\n")
+		}
+	}
+	return b.String() + "\n" + helpStyle.Render("  tab/1-4: screens • q: quit") + "\n"
+}
+
+func (m Model) statusView() string {
+	if m.status == "" {
+		return ""
+	}
+	return helpStyle.Render("  "+m.status) + "\n"
+}
+
+// rows is how many repos fit on the screen
+func (m Model) rows() int {
+	if m.height == 0 {
+		return 12
+	}
+	return max(3, m.height-8)
+}
+
+// window returns the range of n rows, at most size long, that shows cursor
+func window(cursor, n, size int) (first, last int) {
+	first = max(0, min(cursor-size/2, n-size))
+	return first, min(n, first+size)
+}
+
+// displayPath shortens paths in the home directory to ~
+func displayPath(path string) string {
+	home, _ := os.UserHomeDir()
+	if rel, err := filepath.Rel(home, path); home != "" && err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join("~", rel)
+	}
+	return path
+}
@@ -0,0 +1,183 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+// IdentityEdit is an identity added or edited in the TUI form; Email is the
+// address of the identity edited, "" for one added
+type IdentityEdit struct {
+	Email    string
+	Identity identity.Identity
+}
+
+// Fields of the identity form, in order
+const (
+	fieldName = iota
+	fieldEmail
+	fieldSSHKey
+	fieldSigningKey
+	fieldCount
+)
+
+var fieldLabels = []string{"Name", "Email", "SSH key", "Signing key"}
+
+// identityForm adds an identity, or edits the one it was opened on
+type identityForm struct {
+	editing  *identity.Identity // nil when adding
+	inputs   []textinput.Model
+	focus    int
+	err      string
+	taken    map[string]bool // lowercased emails of the other identities
+	listedAt int             // list index of the identity edited
+}
+
+func newIdentityForm(editing *identity.Identity, listedAt int, identities []identity.Identity) *identityForm {
+	f := &identityForm{editing: editing, listedAt: listedAt, taken: make(map[string]bool)}
+	for _, id := range identities {
+		if editing == nil || !strings.EqualFold(id.Email, editing.Email) {
+			f.taken[strings.ToLower(id.Email)] = true
+		}
+	}
+	placeholders := []string{"Jane Doe", "jane@example.com", "~/.ssh/id_ed25519 (optional)", "gpg key ID or ~/.ssh/key.pub (optional)"}
+	for i := range fieldCount {
+		in := textinput.New()
+		in.Prompt = ""
+		in.Placeholder = placeholders[i]
+		in.Cursor.SetMode(cursor.CursorStatic)
+		f.inputs = append(f.inputs, in)
+	}
+	if editing != nil {
+		f.inputs[fieldName].SetValue(editing.Name)
+		f.inputs[fieldEmail].SetValue(editing.Email)
+		f.inputs[fieldSSHKey].SetValue(editing.SSHKey)
+		f.inputs[fieldSigningKey].SetValue(editing.SigningKey)
+	}
+	f.inputs[fieldName].Focus()
+	return f
+}
+
+// update handles a key; done is set when the form was submitted or
+// cancelled, with the identity it produced in the first case
+func (f *identityForm) update(msg tea.KeyMsg) (id *identity.Identity, done bool) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		return nil, true
+	case "tab", "down":
+		f.move(1)
+		return nil, false
+	case "shift+tab", "up":
+		f.move(-1)
+		return nil, false
+	case "enter":
+		if f.focus < fieldCount-1 {
+			f.move(1)
+			return nil, false
+		}
+		id, err := f.identity()
+		if err != nil {
+			f.err = err.Error()
+			return nil, false
+		}
+		return id, true
+	}
+	f.inputs[f.focus], _ = f.inputs[f.focus].Update(msg)
+	f.err = ""
+	return nil, false
+}
+
+func (f *identityForm) move(by int) {
+	f.inputs[f.focus].Blur()
+	f.focus = (f.focus + by + fieldCount) % fieldCount
+	f.inputs[f.focus].Focus()
+}
+
+// identity validates the fields and returns the identity they describe; an
+// edited identity keeps what the form does not show
+func (f *identityForm) identity() (*identity.Identity, error) {
+	id := identity.Identity{Source: "manual"}
+	if f.editing != nil {
+		id = *f.editing
+	}
+	id.Name = strings.TrimSpace(f.inputs[fieldName].Value())
+	email := strings.TrimSpace(f.inputs[fieldEmail].Value())
+	switch {
+	case id.Name == "":
+		return nil, fmt.Errorf("a name is required")
+	case !identity.ValidEmail(email):
+		return nil, fmt.Errorf("not a valid email: %q", email)
+	case f.taken[strings.ToLower(email)]:
+		return nil, fmt.Errorf("another identity has %s", email)
+	}
+	if f.editing == nil || !strings.EqualFold(email, f.editing.Email) {
+		id.Platform = identity.DetectPlatform(email)
+	}
+	id.Email = email
+
+	sshKey := absPath(strings.TrimSpace(f.inputs[fieldSSHKey].Value()))
+	if sshKey != "" {
+		if strings.HasSuffix(sshKey, ".pub") {
+			return nil, fmt.Errorf("%s is a public key; give the private key next to it", sshKey)
+		}
+		if _, err := os.Stat(sshKey); err != nil {
+			return nil, fmt.Errorf("no ssh key at %s", sshKey)
+		}
+	}
+	id.SSHKey = sshKey
+
+	id.SigningKey, id.SigningFormat = strings.TrimSpace(f.inputs[fieldSigningKey].Value()), ""
+	if key := absPath(id.SigningKey); strings.ContainsAny(id.SigningKey, `/\`) || strings.HasSuffix(key, ".pub") {
+		if _, err := os.Stat(key); err != nil {
+			return nil, fmt.Errorf("no signing key at %s", key)
+		}
+		id.SigningKey, id.SigningFormat = key, "ssh"
+	} else if key != "" {
+		id.SigningFormat = "gpg"
+	}
+	return &id, nil
+}
+
+func (f *identityForm) view() string {
+	var b strings.Builder
+	title := "Add identity"
+	if f.editing != nil {
+		title = "Edit " + f.editing.Email
+	}
+	b.WriteString(titleStyle.Render(title) + "\n\n")
+	for i, in := range f.inputs {
+		label := fmt.Sprintf("%-13s", fieldLabels[i]+":")
+		if i == f.focus {
+			b.WriteString(selectedItemStyle.Render("> "+label) + in.View() + "\n")
+		} else {
+			b.WriteString(itemStyle.Render(label) + in.View() + "\n")
+		}
+	}
+	b.WriteString("\n")
+	if f.err != "" {
+		b.WriteString(deleteStyle.Render("  "+f.err) + "\n")
+	}
+	return b.String() + helpStyle.Render("  tab/↑/↓: field • enter: next, save on the last • esc: cancel") + "\n"
+}
+
+// absPath makes a path typed in the form absolute, expanding a leading ~/
+func absPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, rest)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
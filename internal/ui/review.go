@@ -0,0 +1,146 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/vosamoilenko/gitme/internal/identity"
+)
+
+// ReviewAction is what the user decided for a candidate identity
+type ReviewAction int
+
+const (
+	ReviewSkip ReviewAction = iota
+	ReviewAccept
+	ReviewMerge
+	ReviewDismiss
+)
+
+// ReviewDecision is the outcome for one candidate; Into is the email of the
+// identity a merged candidate joins
+type ReviewDecision struct {
+	Candidate identity.Candidate
+	Action    ReviewAction
+	Into      string
+}
+
+// ReviewModel walks through pending candidates one at a time
+type ReviewModel struct {
+	candidates []identity.Candidate
+	identities []identity.Identity
+	index      int  // candidate under review
+	merging    bool // picking the identity to merge into
+	target     int
+	decisions  []ReviewDecision
+}
+
+// NewReview creates a review of candidates against the confirmed identities
+func NewReview(candidates []identity.Candidate, identities []identity.Identity) ReviewModel {
+	return ReviewModel{candidates: candidates, identities: identities}
+}
+
+func (m ReviewModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m ReviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok || m.index >= len(m.candidates) {
+		return m, nil
+	}
+
+	if m.merging {
+		switch key.String() {
+		case "up", "k":
+			if m.target > 0 {
+				m.target--
+			}
+		case "down", "j":
+			if m.target < len(m.identities)-1 {
+				m.target++
+			}
+		case "enter":
+			m.merging = false
+			return m.decide(ReviewMerge, m.identities[m.target].Email)
+		case "esc":
+			m.merging = false
+		case "ctrl+c":
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	switch key.String() {
+	case "a":
+		return m.decide(ReviewAccept, "")
+	case "d", "x":
+		return m.decide(ReviewDismiss, "")
+	case "s", "n", "right":
+		return m.decide(ReviewSkip, "")
+	case "m":
+		if len(m.identities) > 0 {
+			m.merging = true
+			// Start at the identity the candidate resembles
+			like := m.candidates[m.index].Like
+			m.target = max(0, slices.IndexFunc(m.identities, func(id identity.Identity) bool { return strings.EqualFold(id.Email, like) }))
+		}
+	case "q", "esc", "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m ReviewModel) decide(action ReviewAction, into string) (tea.Model, tea.Cmd) {
+	m.decisions = append(m.decisions, ReviewDecision{Candidate: m.candidates[m.index], Action: action, Into: into})
+	m.index++
+	if m.index >= len(m.candidates) {
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m ReviewModel) View() string {
+	if m.index >= len(m.candidates) {
+		return ""
+	}
+	c := m.candidates[m.index]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n%s\n\n", titleStyle.Render(fmt.Sprintf("Candidate %d of %d", m.index+1, len(m.candidates))))
+	fmt.Fprintf(&b, "%s\n", itemStyle.Render(c.Identity.String()))
+	fmt.Fprintf(&b, "%s\n", currentStyle.Render(fmt.Sprintf("%d commits in %d repos (%s)", c.Commits, len(c.Repos), c.Source)))
+	if c.Like != "" {
+		fmt.Fprintf(&b, "%s\n", currentStyle.Render(fmt.Sprintf("Probably %s with another address (%s)", c.Like, c.Reason)))
+	}
+	for i, repo := range c.Repos {
+		if i == 3 {
+			fmt.Fprintf(&b, "%s\n", currentStyle.Render(fmt.Sprintf("… and %d more", len(c.Repos)-i)))
+			break
+		}
+		fmt.Fprintf(&b, "%s\n", currentStyle.Render("  "+repo))
+	}
+	b.WriteString("\n")
+
+	if m.merging {
+		b.WriteString(titleStyle.Render("Merge into:") + "\n")
+		for i, id := range m.identities {
+			if i == m.target {
+				b.WriteString(selectedItemStyle.Render("> "+id.String()) + "\n")
+			} else {
+				b.WriteString(itemStyle.Render(id.String()) + "\n")
+			}
+		}
+		b.WriteString("\n" + helpStyle.Render("  ↑/↓: navigate • enter: merge • esc: back") + "\n")
+		return b.String()
+	}
+	b.WriteString(helpStyle.Render("  a: accept • m: merge into existing • d: dismiss • s: skip • q: quit") + "\n")
+	return b.String()
+}
+
+// Decisions returns what was decided, in review order
+func (m ReviewModel) Decisions() []ReviewDecision {
+	return m.decisions
+}
@@ -3,7 +3,10 @@ package ui
 import (
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 
+	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -29,15 +32,26 @@ const (
 	ActionRescan
 )
 
+// ScanMsg reports identities found by a scan running alongside the TUI
+type ScanMsg struct {
+	Phase      identity.Phase
+	Identities []identity.Identity
+	Done       bool
+}
+
 // item wraps an identity for the list
 type item struct {
 	identity  identity.Identity
 	isCurrent bool
+	governor  string // "rule", "mapping" or "override" if that points at this identity
 }
 
 func (i item) FilterValue() string { return i.identity.Email }
 
-type itemDelegate struct{}
+type itemDelegate struct {
+	marked map[string]bool // emails marked for deletion, shared with Model
+	icons  string          // platform icon set
+}
 
 func (d itemDelegate) Height() int                             { return 1 }
 func (d itemDelegate) Spacing() int                            { return 0 }
@@ -48,10 +62,23 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 		return
 	}
 
-	str := fmt.Sprintf("%s <%s>", i.identity.Name, i.identity.Email)
+	swatch := lipgloss.NewStyle().Foreground(lipgloss.Color(i.identity.DisplayColor())).Render("■")
+	str := fmt.Sprintf("%s %s <%s>", swatch, i.identity.Name, i.identity.Email)
+	if icon := i.identity.Platform.Icon(d.icons); icon != "" {
+		str = fmt.Sprintf("%s %s %s <%s>", swatch, icon, i.identity.Name, i.identity.Email)
+	}
+	if d.marked[i.identity.Email] {
+		str = "● " + str
+	}
+	if i.identity.Username != "" {
+		str += " @" + i.identity.Username
+	}
 	if i.isCurrent {
 		str += " (current)"
 	}
+	if i.governor != "" {
+		str += " (" + i.governor + ")"
+	}
 
 	fn := itemStyle.Render
 	if index == m.Index() {
@@ -67,25 +94,48 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 
 // Model is the main UI model
 type Model struct {
-	list           list.Model
-	choice         *identity.Identity
-	action         Action
-	quitting       bool
-	folder         string
-	confirmDelete  bool
-	deleteTarget   *identity.Identity
+	list          list.Model
+	choice        *identity.Identity
+	action        Action
+	quitting      bool
+	folder        string
+	confirmDelete bool
+	deleteTargets []identity.Identity
+	marked        map[string]bool
+	governedBy    string
+	scanPhase     identity.Phase // phase of the background scan, "" when idle
+	status        string         // outcome of the last copy, shown until the next key
+	screen        Screen
+	height        int
+	repos         []Repo // nil until ReposMsg arrives
+	repoCursor    int
+	assigning     bool // picking the identity for the repo under the cursor
+	target        int
+	assignments   []Assignment
+	rules         []Rule
+	stats         *StatsMsg // nil until the commits are counted
+	form          *identityForm
+	edits         []IdentityEdit
 }
 
-// New creates a new UI model
+// New creates the dashboard, opening on the identities screen. Identities
+// are listed most recently used first.
 func New(identities []identity.Identity, currentIdentity *identity.Identity, folder string) Model {
-	items := make([]list.Item, len(identities))
-	for i, id := range identities {
+	sorted := make([]identity.Identity, len(identities))
+	copy(sorted, identities)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].LastUsed.After(sorted[j].LastUsed)
+	})
+
+	items := make([]list.Item, len(sorted))
+	for i, id := range sorted {
 		isCurrent := currentIdentity != nil && id.Email == currentIdentity.Email
 		items[i] = item{identity: id, isCurrent: isCurrent}
 	}
 
-	l := list.New(items, itemDelegate{}, 50, 14)
-	l.Title = "gitme"
+	marked := make(map[string]bool)
+	l := list.New(items, itemDelegate{marked: marked}, 50, 14)
+	l.SetShowTitle(false) // the tabs of the dashboard stand in for it
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(true)
 	l.Styles.Title = titleStyle
@@ -95,9 +145,31 @@ func New(identities []identity.Identity, currentIdentity *identity.Identity, fol
 		list:   l,
 		folder: folder,
 		action: ActionNone,
+		marked: marked,
 	}
 }
 
+// WithIcons marks identities with the icons of their platform from set
+func (m Model) WithIcons(set string) Model {
+	m.list.SetDelegate(itemDelegate{marked: m.marked, icons: set})
+	return m
+}
+
+// WithGovernor annotates the identity a rule, folder mapping or repo .gitme
+// file points to. kind is "rule", "mapping" or "override"; source describes
+// it (e.g. the rule pattern).
+func (m Model) WithGovernor(kind, source, email string) Model {
+	m.governedBy = fmt.Sprintf("%s: %s → %s", kind, source, email)
+	items := m.list.Items()
+	for idx, li := range items {
+		if i, ok := li.(item); ok && strings.EqualFold(i.identity.Email, email) {
+			i.governor = kind
+			m.list.SetItem(idx, i)
+		}
+	}
+	return m
+}
+
 func (m Model) Init() tea.Cmd {
 	return nil
 }
@@ -106,9 +178,44 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.list.SetWidth(msg.Width)
+		m.height = msg.Height
+		return m, nil
+
+	case ReposMsg:
+		m.repos = msg.Repos
+		if m.repos == nil {
+			m.repos = []Repo{}
+		}
+		return m, nil
+
+	case StatsMsg:
+		m.stats = &msg
+		return m, nil
+
+	case ScanMsg:
+		m.scanPhase = msg.Phase
+		if msg.Done {
+			m.scanPhase = ""
+		}
+		known := make(map[string]bool)
+		for _, li := range m.list.Items() {
+			if i, ok := li.(item); ok {
+				known[strings.ToLower(i.identity.Email)] = true
+			}
+		}
+		for _, id := range msg.Identities {
+			if !known[strings.ToLower(id.Email)] {
+				known[strings.ToLower(id.Email)] = true
+				m.list.InsertItem(len(m.list.Items()), item{identity: id})
+			}
+		}
 		return m, nil
 
 	case tea.KeyMsg:
+		if m.form != nil {
+			return m.updateForm(msg), nil
+		}
+
 		// Handle delete confirmation
 		if m.confirmDelete {
 			switch msg.String() {
@@ -117,16 +224,25 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, tea.Quit
 			case "n", "N", "esc":
 				m.confirmDelete = false
-				m.deleteTarget = nil
+				m.deleteTargets = nil
 				return m, nil
 			}
 			return m, nil
 		}
 
 		// Don't capture keys when filtering
-		if m.list.FilterState() == list.Filtering {
+		if m.screen == ScreenIdentities && m.list.FilterState() == list.Filtering {
 			break
 		}
+		m.status = ""
+		if !m.assigning {
+			if switched, ok := m.switchScreen(msg.String()); ok {
+				return switched, nil
+			}
+		}
+		if m.screen != ScreenIdentities {
+			return m.updateScreen(msg)
+		}
 
 		switch msg.String() {
 		case "q", "ctrl+c", "esc":
@@ -140,16 +256,54 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, tea.Quit
 
+		case " ":
+			if i, ok := m.list.SelectedItem().(item); ok {
+				if m.marked[i.identity.Email] {
+					delete(m.marked, i.identity.Email)
+				} else {
+					m.marked[i.identity.Email] = true
+				}
+			}
+			return m, nil
+
 		case "d", "x":
+			m.deleteTargets = nil
+			for _, li := range m.list.Items() {
+				if i, ok := li.(item); ok && m.marked[i.identity.Email] {
+					m.deleteTargets = append(m.deleteTargets, i.identity)
+				}
+			}
+			if len(m.deleteTargets) == 0 {
+				if i, ok := m.list.SelectedItem().(item); ok {
+					m.deleteTargets = []identity.Identity{i.identity}
+				}
+			}
+			m.confirmDelete = len(m.deleteTargets) > 0
+			return m, nil
+
+		case "c":
 			if i, ok := m.list.SelectedItem().(item); ok {
-				m.deleteTarget = &i.identity
-				m.confirmDelete = true
+				if err := clipboard.WriteAll(i.identity.String()); err != nil {
+					m.status = "could not copy: " + err.Error()
+				} else {
+					m.status = "copied " + i.identity.String()
+				}
 			}
 			return m, nil
 
 		case "r":
 			m.action = ActionRescan
 			return m, tea.Quit
+
+		case "a":
+			m.form = newIdentityForm(nil, -1, m.identities())
+			return m, nil
+
+		case "e":
+			if i, ok := m.list.SelectedItem().(item); ok {
+				m.form = newIdentityForm(&i.identity, m.list.GlobalIndex(), m.identities())
+			}
+			return m, nil
 		}
 	}
 
@@ -163,15 +317,76 @@ func (m Model) View() string {
 		return ""
 	}
 
-	if m.confirmDelete && m.deleteTarget != nil {
-		return fmt.Sprintf("\n  %s\n\n  %s\n\n  %s\n",
-			deleteStyle.Render("Delete identity?"),
-			fmt.Sprintf("  %s <%s>", m.deleteTarget.Name, m.deleteTarget.Email),
+	if m.confirmDelete && len(m.deleteTargets) > 0 {
+		title := "Delete identity?"
+		if len(m.deleteTargets) > 1 {
+			title = fmt.Sprintf("Delete %d identities?", len(m.deleteTargets))
+		}
+		var targets string
+		for _, id := range m.deleteTargets {
+			targets += fmt.Sprintf("    %s <%s>\n", id.Name, id.Email)
+		}
+		return fmt.Sprintf("\n  %s\n\n%s\n  %s\n",
+			deleteStyle.Render(title),
+			targets,
 			helpStyle.Render("y: yes • n: no"),
 		)
 	}
 
-	return "\n" + m.list.View() + "\n" + helpStyle.Render("  ↑/↓: navigate • enter: select • d: delete • r: rescan • /: filter • q: quit") + "\n"
+	view := "\n" + m.tabsView() + "\n"
+	if m.form != nil {
+		return view + "\n" + m.form.view()
+	}
+	switch m.screen {
+	case ScreenRepos:
+		return view + "\n" + m.reposView()
+	case ScreenRules:
+		return view + "\n" + m.rulesView()
+	case ScreenStats:
+		return view + "\n" + m.statsView()
+	}
+	view += m.list.View() + "\n"
+	if m.governedBy != "" {
+		view += helpStyle.Render("  governed by "+m.governedBy) + "\n"
+	}
+	view += m.statusView()
+	if m.scanPhase != "" {
+		view += helpStyle.Render("  scanning "+string(m.scanPhase)+"…") + "\n"
+	}
+	return view + helpStyle.Render("  ↑/↓: navigate • enter: select • a: add • e: edit • space: mark • c: copy • d: delete • r: rescan • /: filter • tab/1-4: screens • q: quit") + "\n"
+}
+
+// updateForm passes a key to the identity form, and on save shows the
+// identity in the list and records it for Edits
+func (m Model) updateForm(msg tea.KeyMsg) Model {
+	id, done := m.form.update(msg)
+	if !done {
+		return m
+	}
+	form := m.form
+	m.form = nil
+	if id == nil {
+		return m
+	}
+	if form.editing == nil {
+		m.list.InsertItem(len(m.list.Items()), item{identity: *id})
+		m.edits = append(m.edits, IdentityEdit{Identity: *id})
+		m.status = "added " + id.String()
+		return m
+	}
+	if i, ok := m.list.Items()[form.listedAt].(item); ok {
+		i.identity = *id
+		m.list.SetItem(form.listedAt, i)
+	}
+	m.edits = append(m.edits, IdentityEdit{Email: form.editing.Email, Identity: *id})
+	m.status = "saved " + id.String()
+	return m
+}
+
+// Edits returns the identities added and edited in the form, in the order
+// they were saved
+func (m Model) Edits() []IdentityEdit {
+	return m.edits
 }
 
 // Choice returns the selected identity
@@ -184,7 +399,7 @@ func (m Model) Action() Action {
 	return m.action
 }
 
-// DeleteTarget returns the identity to delete
-func (m Model) DeleteTarget() *identity.Identity {
-	return m.deleteTarget
+// DeleteTargets returns the identities to delete
+func (m Model) DeleteTargets() []identity.Identity {
+	return m.deleteTargets
 }
@@ -1,123 +1,266 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
-	tea "github.com/charmbracelet/bubbletea"
 	"github.com/vosamoilenko/gitme/internal/cmd"
 	"github.com/vosamoilenko/gitme/internal/config"
-	"github.com/vosamoilenko/gitme/internal/identity"
-	"github.com/vosamoilenko/gitme/internal/ui"
+	"github.com/vosamoilenko/gitme/internal/render"
 )
 
 var version = "dev"
 
 func main() {
+	os.Args = extractConfigDir(os.Args)
+	var readOnly, jsonOutput bool
+	os.Args, readOnly = extractFlag(os.Args, "--read-only")
+	os.Args, jsonOutput = extractFlag(os.Args, "--json")
+	if jsonOutput {
+		cmd.OutputFormat = render.JSON
+	}
+	args, err := chdirArgs(os.Args)
+	if err != nil {
+		exit(err)
+	}
+	os.Args = args
+	if readOnly || readOnlySetting(os.Args) {
+		cmd.SetReadOnly()
+	}
+	if err := cmd.ResolveGitEnv(); err != nil {
+		exit(err)
+	}
+
 	if len(os.Args) < 2 {
-		runTUI()
-		return
+		exit(cmd.Interactive(os.Stdout, nil))
 	}
 
+	args = os.Args[2:]
 	switch os.Args[1] {
 	case "version", "--version", "-v":
 		fmt.Println("gitme " + version)
 		return
-	// Identity management
-	case "list", "ls":
-		cmd.List()
-	case "add":
-		cmd.Add()
-	case "remove", "rm":
-		cmd.Remove()
-	case "scan", "refresh":
-		cmd.Scan()
-	case "reset":
-		cmd.Reset()
-
-	// Repository commands
-	case "repos":
-		cmd.Repos()
-	case "mixed":
-		cmd.Mixed()
-	case "current", "whoami":
-		cmd.Current()
-	case "set":
-		cmd.Set()
-
-	// Fix commands
-	case "fix:scan":
-		cmd.FixScan()
-	case "fix:rewrite":
-		cmd.FixRewrite()
-
-	// Auto-switch commands
-	case "auto":
-		cmd.Auto()
-	case "rule":
-		cmd.Rule()
-	case "config":
-		cmd.Config()
-
-	// Worktree management
-	case "tree":
-		cmd.Tree()
-
-	// Aliases
-	case "alias":
-		cmd.Alias()
-	case "use":
-		cmd.Use()
-
-	// Statistics
-	case "stats":
-		cmd.Stats()
-
-	// Help
 	case "help", "-h", "--help":
+		if len(args) == 1 {
+			if c := cmd.Lookup(args[0]); c != nil {
+				c.PrintHelp(os.Stdout)
+				return
+			}
+		}
 		printHelp()
+		return
+	case "__complete":
+		for _, word := range cmd.Complete(args) {
+			fmt.Println(word)
+		}
+		return
+	}
 
-	default:
+	c := cmd.Lookup(os.Args[1])
+	if c == nil {
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", os.Args[1])
 		printHelp()
 		os.Exit(1)
 	}
+	err = c.Execute(os.Stdout, args)
+	exit(err)
+}
+
+// exit reports a command error and terminates with the matching status
+func exit(err error) {
+	if err == nil {
+		os.Exit(0)
+	}
+	var usage *cmd.UsageError
+	var exitErr *cmd.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		os.Exit(exitErr.Code)
+	case errors.As(err, &usage):
+		fmt.Fprintln(os.Stderr, "Usage: "+usage.Usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	os.Exit(1)
+}
+
+// extractConfigDir applies and strips a global --config-dir flag given
+// before any "--"
+func extractConfigDir(args []string) []string {
+	result := []string{args[0]}
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return append(result, args[i:]...)
+		case arg == "--config-dir" && i+1 < len(args):
+			config.SetDir(args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--config-dir="):
+			config.SetDir(strings.TrimPrefix(arg, "--config-dir="))
+		default:
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+// extractFlag strips a global boolean flag and reports whether it was given
+func extractFlag(args []string, flag string) ([]string, bool) {
+	result := []string{args[0]}
+	found := false
+	for _, arg := range args[1:] {
+		if arg == flag {
+			found = true
+			continue
+		}
+		result = append(result, arg)
+	}
+	return result, found
+}
+
+// readOnlySetting reports whether the read_only setting is on. It is ignored
+// by gitme config read_only, so the setting can be turned off again.
+func readOnlySetting(args []string) bool {
+	if len(args) > 2 && args[1] == "config" && args[2] == "read_only" {
+		return false
+	}
+	settings, err := config.LoadSettings()
+	return err == nil && settings.ReadOnly
+}
+
+// chdirArgs handles leading -C <path> options like git does, changing into
+// each path in turn, and returns the remaining arguments
+func chdirArgs(args []string) ([]string, error) {
+	for len(args) > 2 && args[1] == "-C" {
+		if err := os.Chdir(args[2]); err != nil {
+			return nil, fmt.Errorf("cannot change to %s: %w", args[2], err)
+		}
+		args = append(args[:1:1], args[3:]...)
+	}
+	return args, nil
 }
 
 func printHelp() {
 	fmt.Println(cmd.HeaderStyle.Render("gitme") + " - Git identity switcher")
 	fmt.Println()
 	fmt.Println("Usage:")
-	fmt.Println("  gitme              Interactive TUI (enter=select, d=delete, r=rescan)")
+	fmt.Println("  gitme              Dashboard of identities, repos, rules and stats (tab/1-4=screens, enter=select, a=add, e=edit, space=mark, c=copy, d=delete, r=rescan)")
 	fmt.Println("  gitme list         List all known identities")
+	fmt.Println("  gitme list --remote  Mark identities verified, unverified or not on account on GitHub/GitLab (needs tokens)")
 	fmt.Println("  gitme repos        Show all repos and which identity they use")
+	fmt.Println("  gitme repos --pinned  Show only pinned repos")
+	fmt.Println("  gitme repos --group platform|org  Group repos by platform or remote org")
+	fmt.Println("  gitme pin [path]   Pin a repo so it is listed first (unpin to remove)")
 	fmt.Println("  gitme mixed        Show repos with multiple identities in history")
-	fmt.Println("  gitme fix:scan     Show commits by your identities in current repo")
-	fmt.Println("  gitme fix:rewrite <old> <new>  Rewrite commits from old to new email")
+	fmt.Println("                     --max-commits <n>  Only read the last n commits of each repo, for speed on giant ones")
+	fmt.Println("                     --fix  Rewrite each one to the identity it should use, via fix:rewrite (--include-protected too)")
+	fmt.Println("                     --reindex  Walk the workspace again instead of using the repo index (also repos, stats)")
+	fmt.Println("  gitme fix:scan     Show commits by your identities in current repo and their platforms")
+	fmt.Println("                     --list [e]  List the commits themselves, of every identity or of e")
+	fmt.Println("  gitme fix:rewrite <old> <new>  Rewrite commits from old to new email and verify the result")
+	fmt.Println("                     --include-protected  Also rewrite protected branches (main, master, release/*) and tags")
+	fmt.Println("                     --dry-run  List the commits it would rewrite, by branch")
+	fmt.Println("                     --branch <name>  Only that branch; --range <base>..<branch>  only commits after base")
+	fmt.Println("                     --since <date>  Only commits since date, e.g. to fix unpushed work")
+	fmt.Println("  gitme check        Check this repo's identity against its .gitme.yml policy")
+	fmt.Println("  gitme verify [path]  Exit 0 quietly if the repo commits as the identity gitme expects, else")
+	fmt.Println("                     print <reason>: <message> and exit 1 mismatch, 2 unset, 3 unclear, 4 not-a-repo, 5 unmatched, 6 policy")
+	fmt.Println("  gitme verify --all  Check every identity's email and keys against its GitHub/GitLab account")
+	fmt.Println("  gitme guard install|uninstall  Block commits with the wrong identity (pre-commit hook, husky too)")
+	fmt.Println("  gitme check-remote [remote]  Check the remote pushes as the same account you commit as")
+	fmt.Println("  gitme diff-config [--exit-code]  Diff the identity config gitme would write against .git/config")
+	fmt.Println("  gitme sync-gitconfig [--dry-run]  Write folder mappings and rules as includeIf sections of ~/.gitconfig")
+	fmt.Println("                     --remove  Take them out again")
 	fmt.Println("  gitme add          Add a new identity interactively")
 	fmt.Println("  gitme add <n> <e>  Add identity with name and email")
+	fmt.Println("  gitme import identities <file.csv|json>  Add identities in bulk")
+	fmt.Println("  gitme import from <git-profile|git-user-switch|gitconfig-profiles> [file]")
+	fmt.Println("                     Convert another tool's profiles into identities and rules")
 	fmt.Println("  gitme remove <#|e> Remove identity by number or email")
-	fmt.Println("  gitme scan         Rescan machine for git identities")
+	fmt.Println("  gitme scan         Rescan machine for git identities and show what changed")
+	fmt.Println("                     --strict  Fail if any path could not be read (also repos, mixed)")
+	fmt.Println("                     --history  Also queue candidate identities from recent commits")
+	fmt.Println("                     --verbose  Show what each scanner found and how long it took")
+	fmt.Println("                     --full  Rebuild the repo index from a walk of every directory")
+	fmt.Println("  gitme review       Accept, merge or dismiss candidate identities and new names (TUI)")
+	fmt.Println("  gitme review list|accept <e>|merge <e> <into>|dismiss <e>  The same without the TUI")
+	fmt.Println("  gitme forget <path|glob>  Drop identities and repos found only there; later scans skip it")
+	fmt.Println("  gitme ignore <email>|list|rm <email>  Remove an identity and keep scans from re-adding it")
+	fmt.Println("                     --undo  Let scans include the path again")
 	fmt.Println("  gitme reset        Delete config and rescan from scratch")
+	fmt.Println("  gitme username <e> [name]  Show or set platform username (used for noreply email)")
+	fmt.Println("  gitme color <e> [c|auto]  Show or set the color an identity is shown in (0-255 or #rrggbb)")
 	fmt.Println("  gitme current      Show current identity for this folder")
+	fmt.Println("                     --copy  Also copy it as Name <email>, e.g. for Co-authored-by trailers")
 	fmt.Println("  gitme set <email>  Set identity by email (no TUI)")
+	fmt.Println("  gitme set <email> --author-only  Author commits as email, committer unchanged")
+	fmt.Println("  gitme set <email> --allow-outside  Change a repo outside the workspace dirs without asking")
+	fmt.Println("  gitme branch add <pattern> <e>  Commit as another identity on matching branches (post-checkout hook)")
+	fmt.Println("  gitme branch list|rm <pattern>|apply  List, remove or re-apply branch identities")
+	fmt.Println()
+	fmt.Println(cmd.HeaderStyle.Render("Remotes:"))
+	fmt.Println("  gitme remote prefer <e> [ssh [host-alias]|https|none]  Show or set an identity's remote protocol")
+	fmt.Println("  gitme remote fix [--dry-run]  Rewrite this repo's remotes to its identity's preference")
+	fmt.Println("  gitme key <e> [path|none]  Show or set the ssh key switching to it sets as core.sshCommand")
+	fmt.Println("  gitme signing <e> [gpg <key-id>|ssh <public-key>|none]  Show or set the key its commits are signed with")
+	fmt.Println("  gitme keys push <e>  Upload its signing key to its GitHub/GitLab account (needs a token)")
+	fmt.Println("  gitme profile <e> [set <key> <value>|unset <key>]  Show or change extra git config it sets, e.g. credential.helper")
+	fmt.Println("  gitme insteadof <e> [add <url-prefix> [<rewrite>]|rm <url-prefix>]  URL rewrites it sets, e.g. to its ssh host alias")
+	fmt.Println("  gitme clone <url> [dir] [--as <e>]  Clone using the preference of the identity that applies")
 	fmt.Println()
 	fmt.Println(cmd.HeaderStyle.Render("Auto-switch:"))
 	fmt.Println("  gitme auto                  Auto-detect and apply identity for current dir")
-	fmt.Println("  gitme rule add <pat> <email> Add auto-switch rule")
+	fmt.Println("                --allow-outside  Also outside the workspace dirs (also for set, use)")
+	fmt.Println("                --quiet     One line on a switch or mismatch, else nothing (for shell hooks)")
+	fmt.Println("  gitme hook install [shell]  Run gitme auto on every cd (bash, zsh, fish, powershell)")
+	fmt.Println("                --print     Print the hook instead of adding it to the shell's rc file")
+	fmt.Println("  gitme hook uninstall [shell]  Remove the hook from the rc file")
+	fmt.Println("  gitme hook git-install      Run gitme auto in every repo cloned from now on (git template hook)")
+	fmt.Println("  gitme hook git-uninstall    Remove the template hook")
+	fmt.Println("  gitme rule add <pat> <email|alias> Add auto-switch rule")
+	fmt.Println("                --strict    Repos there must not fall back to the global identity (identity optional)")
 	fmt.Println("  gitme rule list             List all rules")
 	fmt.Println("  gitme rule rm <pattern>     Remove a rule")
+	fmt.Println("  gitme default [<host> <email|alias> | rm <host>]  Identity repos on a host use when no rule applies")
 	fmt.Println("  gitme config auto_apply <on|off>  Set auto-apply behavior")
+	fmt.Println("  gitme config protected_branches <a,b/*|none|default>  Branches fix:rewrite refuses to touch")
+	fmt.Println("  gitme config timezone <zone|local|commit>  Zone stats bucket commits in")
+	fmt.Println("  gitme config disabled_scanners <gh,gpg|none>  Identity sources scan skips")
+	fmt.Println("  gitme config icons <text|emoji|nerd|none>  How platforms are marked in lists and the TUI")
+	fmt.Println("  gitme config scan_exclude <glob,...|none>  Dirs repo walks never enter, e.g. **/node_modules/**")
+	fmt.Println("  gitme config reference_dirs <~/ref,...|none>  Dirs of third-party clones scan and repos leave out")
+	fmt.Println("  gitme config read_only <on|off>  Describe changes instead of making them")
+	fmt.Println("  gitme config backup_limit <MB|off>  Space for the bundles fix:rewrite backs repos up to (default 1024)")
+	fmt.Println("  gitme config strict <on|off>  Workspace repos no identity matches warn, and verify fails")
+	fmt.Println("  gitme config team_directory <file|https-url|none>  Shared list of collaborators for coauthor, mixed and review")
+	fmt.Println("  gitme watch [--interval 1m] Keep every repo on its expected identity (runs until stopped)")
+	fmt.Println("                --http :7465  Serve mismatches as JSON at http://127.0.0.1:7465/status")
+	fmt.Println("                --digest notify  Summarize each week's commits and mismatches (or: terminal)")
+	fmt.Println("  gitme watch install         Run the watcher as a launchd/systemd user service")
+	fmt.Println("  gitme watch status [--json] Show the watcher's metrics and whether it and the service run")
+	fmt.Println("  gitme watch stop            Stop the running watcher (only one runs at a time)")
+	fmt.Println("  gitme watch uninstall       Stop and remove the watch service")
+	fmt.Println("  gitme menubar               Print an xbar/SwiftBar plugin menu with quick-switch actions")
 	fmt.Println()
 	fmt.Println(cmd.HeaderStyle.Render("Aliases:"))
 	fmt.Println("  gitme alias add <name> <email>  Add an alias for quick switching")
 	fmt.Println("  gitme alias list                List all aliases")
 	fmt.Println("  gitme alias rm <name>           Remove an alias")
 	fmt.Println("  gitme use <alias>               Switch identity by alias name")
+	fmt.Println("  gitme coauthor <e|alias|name>... [--copy]  Print (or copy) Co-authored-by trailers")
+	fmt.Println("  gitme coauthor add <name> <email>|list|rm <e>  Keep collaborators apart from your identities")
+	fmt.Println("  gitme team [--refresh]     List the collaborators in the team directory")
+	fmt.Println("  gitme audit emails [--resolve]  List unknown author emails; resolve each once: add, map, ignore or rewrite")
 	fmt.Println()
 	fmt.Println(cmd.HeaderStyle.Render("Statistics:"))
 	fmt.Println("  gitme stats                 Show commit stats by identity in current repo")
 	fmt.Println("  gitme stats --all           Show commit stats across all repos")
+	fmt.Println("  gitme stats --compare <a> <b>  Compare two identities side by side across all repos")
+	fmt.Println("                     --timezone <zone>  Bucket commits in zone (e.g. Europe/Berlin, local)")
+	fmt.Println("                     --path <path>  Only commits touching path in the current repo")
 	fmt.Println()
 	fmt.Println(cmd.HeaderStyle.Render("Worktrees:"))
 	fmt.Println("  gitme tree path [<path>]    Show or set worktrees path for this project")
@@ -125,92 +268,25 @@ func printHelp() {
 	fmt.Println("  gitme tree ls               List all worktrees")
 	fmt.Println("  gitme tree rm <name|path>   Remove a worktree")
 	fmt.Println()
+	fmt.Println(cmd.HeaderStyle.Render("Platform tokens:"))
+	fmt.Println("  gitme token set <email|alias>     Store a GitHub/GitLab API token in the OS keychain")
+	fmt.Println("  gitme token remove <email|alias>  Delete the stored token")
+	fmt.Println()
+	fmt.Println(cmd.HeaderStyle.Render("Diagnostics:"))
+	fmt.Println("  gitme doctor [--fix]        Check signing keys, authentication, mapped folders and identities")
+	fmt.Println("                              (exit 2 on errors, 1 on warnings; --fix re-applies mapped identities)")
+	fmt.Println("  gitme test <email|alias>    Check its ssh key and token authenticate as its account")
+	fmt.Println()
 	fmt.Println("  gitme help         Show this help")
+	fmt.Println("  gitme <command> -h Show the usage and flags of a command (or: gitme help <command>)")
+	fmt.Println("  gitme completion <bash|zsh|fish|powershell>  Print a shell completion script")
+	fmt.Println("                     e.g. eval \"$(gitme completion bash)\" in ~/.bashrc")
 	fmt.Println()
 	fmt.Println("Aliases: ls=list, rm=remove, whoami=current, refresh=scan")
 	fmt.Println()
-	fmt.Println("Config stored in: ~/.config/gitme/")
-}
-
-func runTUI() {
-	cwd, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
-		os.Exit(1)
-	}
-
-	cfg, err := config.Load()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
-	}
-
-	identities, err := identity.Scan()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error scanning identities: %v\n", err)
-		os.Exit(1)
-	}
-	cfg.UpdateIdentities(identities)
-	cfg.Save()
-
-	if len(cfg.Identities) == 0 {
-		fmt.Println("No identities found.")
-		fmt.Println("Add one with: gitme add \"Your Name\" \"your@email.com\"")
-		return
-	}
-
-	// Get current identity for this folder
-	var currentIdentity *identity.Identity
-	if id, ok := cfg.GetIdentityForFolder(cwd); ok {
-		currentIdentity = &id
-	}
-
-	model := ui.New(cfg.Identities, currentIdentity, cwd)
-	p := tea.NewProgram(model)
-
-	finalModel, err := p.Run()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error running TUI: %v\n", err)
-		os.Exit(1)
-	}
-
-	m := finalModel.(ui.Model)
-
-	switch m.Action() {
-	case ui.ActionDelete:
-		if target := m.DeleteTarget(); target != nil {
-			// Remove the identity from the list
-			var newIdentities []identity.Identity
-			for _, id := range cfg.Identities {
-				if id.Email != target.Email {
-					newIdentities = append(newIdentities, id)
-				}
-			}
-			cfg.Identities = newIdentities
-			if err := cfg.Save(); err != nil {
-				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Println(cmd.SuccessStyle.Render("Deleted:"), target.Name, "<"+target.Email+">")
-		}
-
-	case ui.ActionRescan:
-		cmd.Scan()
-
-	case ui.ActionSelect:
-		if selected := m.Choice(); selected != nil {
-			if err := cmd.ApplyIdentity(cwd, *selected); err != nil {
-				fmt.Fprintf(os.Stderr, "Error applying identity: %v\n", err)
-				os.Exit(1)
-			}
-
-			cfg.SetIdentityForFolder(cwd, *selected)
-			if err := cfg.Save(); err != nil {
-				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-				os.Exit(1)
-			}
-
-			fmt.Println(cmd.SuccessStyle.Render("Switched to:"), selected.Name, "<"+selected.Email+">")
-		}
-	}
+	fmt.Println("Config stored in: ~/.config/gitme/ (override with --config-dir <dir> or GITME_CONFIG_DIR)")
+	fmt.Println("Print results as JSON with: gitme --json <command> (list, current, repos, mixed, stats, watch status, ...)")
+	fmt.Println("Print what would change instead of changing it with: gitme --read-only <command>")
+	fmt.Println("                                            (or by default: gitme config read_only on)")
+	fmt.Println("Run as if started in <path> with: gitme -C <path> <command>")
 }
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vosamoilenko/gitme/internal/config"
+)
+
+func TestExtractConfigDirStopsAtDoubleDash(t *testing.T) {
+	defer config.SetDir("")
+	args := extractConfigDir([]string{"gitme", "--config-dir", "a", "tree", "--", "--config-dir=b"})
+	if strings.Join(args, " ") != "gitme tree -- --config-dir=b" {
+		t.Errorf("unexpected arguments %q", args)
+	}
+	if want, _ := filepath.Abs("a"); config.Dir() != want {
+		t.Errorf("config dir = %q, want %q", config.Dir(), want)
+	}
+}